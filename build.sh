@@ -1,2 +1,9 @@
-go build -o repo-server ./cmd/server/
-go build -o repo-cli ./cmd/cli/
\ No newline at end of file
+# 版本信息注入 internal/buildinfo，可通过 -version 或 /api/capabilities 查看
+VERSION=$(git describe --tags --always --dirty 2>/dev/null || echo dev)
+COMMIT=$(git rev-parse HEAD 2>/dev/null)
+BUILD_TIME=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+PKG=code-browser/internal/buildinfo
+LDFLAGS="-X $PKG.Version=$VERSION -X $PKG.Commit=$COMMIT -X $PKG.BuildTime=$BUILD_TIME"
+
+go build -ldflags "$LDFLAGS" -o repo-server ./cmd/server/
+go build -ldflags "$LDFLAGS" -o repo-cli ./cmd/cli/
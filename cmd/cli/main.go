@@ -1,20 +1,24 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
 	"log"
 	"os"
-	"path/filepath"
+	"strconv"
+	"strings"
 
+	"code-browser/internal/buildinfo"
+	"code-browser/internal/config"
+	"code-browser/internal/fileperm"
 	"code-browser/internal/repo"
 )
 
 func main() {
 	// --- Define Flags ---
 	// Command flag determines the action
-	command := flag.String("command", "", "操作命令: 'add', 'delete' 或 'index' (必填)")
+	command := flag.String("command", "", "操作命令: 'add', 'clone', 'delete', 'archive', 'unarchive', 'enable', 'disable', 'relocate', 'index', 'reindex-all', 'reconcile' 或 'import-config' (必填)")
 	// Common flags
 	dataDir := flag.String("data-dir", "./.data", "应用程序的全局数据目录")
 	// A single ID flag used by both 'add' and 'delete' commands
@@ -22,16 +26,39 @@ func main() {
 	// Flags for 'add' command
 	repoName := flag.String("name", "", "'add' 命令: 仓库的显示名称 (必填)")
 	repoPath := flag.String("path", "", "'add' 命令: 仓库源代码的绝对路径 (必填)")
+	cloneURL := flag.String("url", "", "'clone' 命令: 远程仓库地址 (必填)")
+	newID := flag.Uint("new-id", 0, "'relocate' 命令: 仓库的新 ID (必填)")
+	autoIndex := flag.Bool("auto-index", false, "'add' 命令: 添加成功后立即为 Git 仓库生成 Zoekt 索引")
+	indexerPath := flag.String("indexer-path", "", "zoekt-git-index 的路径 (为空时从 PATH 查找)")
+	indexerArgs := flag.String("indexer-args", "", "追加给 zoekt-git-index 的额外参数，按空白分隔 (如 \"-parallelism 4 -file_limit 4194304\")")
+	noGitConfig := flag.Bool("no-git-config", false, "'index'/'relocate' 命令: 不修改源仓库的 .git/config，改为通过 -name/-repoid 参数传给 zoekt-git-index")
+	concurrency := flag.Int("concurrency", repo.DefaultReindexConcurrency, "'reindex-all' 命令: 同时运行的索引数")
+	dryRun := flag.Bool("dry-run", false, "'index'/'reconcile' 命令: 只校验并打印执行计划/差异报告，不做任何修改")
+	jsonOutput := flag.Bool("json", false, "'index -dry-run' 和 'reconcile': 以 JSON 格式输出")
 	scipPath := flag.String("scip-path", "", "SCIP 索引文件路径 (register-scip 必填)")
+	configPath := flag.String("config", "", "'import-config' 命令: 旧版 JSON 仓库配置文件路径 (必填)")
+	strictConfig := flag.Bool("strict", false, "'import-config' 命令: 导入前校验整个配置文件 (必填字段、ID 唯一、路径存在)，有任何问题时列出所有问题并退出，不导入")
 	// Flags for 'delete' command
+	showVersion := flag.Bool("version", false, "打印版本与构建信息后退出 (不需要 -command)")
+	modes := fileperm.Modes{Dir: fileperm.DefaultDirMode, File: fileperm.DefaultFileMode}
+	flag.Var(fileperm.Value{Mode: &modes.Dir}, "dir-mode", "在数据目录中新建目录使用的权限 (八进制，再经过进程 umask)")
+	flag.Var(fileperm.Value{Mode: &modes.File}, "file-mode", "在数据目录中新建文件使用的权限 (八进制，再经过进程 umask)")
+	flag.BoolVar(&modes.IgnoreUmask, "ignore-umask", false, "新建目录和文件的权限严格等于 -dir-mode/-file-mode，不受进程 umask 影响")
 	// --- Parse Flags ---
 	flag.Parse()
+	if *showVersion {
+		fmt.Println("repo-cli", buildinfo.Get())
+		return
+	}
 	// --- Initialize Repository Provider ---
 	log.Printf("使用数据目录: %s", *dataDir)
-	repoProvider, err := repo.NewProvider(*dataDir)
+	repoProvider, err := repo.NewProviderWithOptions(*dataDir, repo.ProviderOptions{Modes: modes})
 	if err != nil {
 		log.Fatalf("错误: 无法初始化仓库服务: %v", err)
 	}
+	repoProvider.NoGitConfig = *noGitConfig
+	repoProvider.IndexerPath = *indexerPath
+	repoProvider.IndexerArgs = strings.Fields(*indexerArgs)
 	defer func() {
 		if err := repoProvider.Close(); err != nil {
 			log.Printf("关闭数据库连接时出错: %v", err)
@@ -45,11 +72,45 @@ func main() {
 			fmt.Fprintln(os.Stderr, "错误: 'add' 命令需要 -id, -name, 和 -path 参数。")
 			os.Exit(1)
 		}
-		err = repoProvider.AddRepository(uint32(*repoID), *repoName, *repoPath)
+		job, err := repoProvider.AddRepository(uint32(*repoID), *repoName, *repoPath, *autoIndex)
 		if err != nil {
 			log.Fatalf("错误: 添加仓库失败: %v", err)
 		}
 		fmt.Printf("成功添加仓库: ID=%d, Name=%s\n", *repoID, *repoName)
+		if job != nil {
+			// CLI 进程结束会中断后台任务，因此在这里等待索引完成
+			result, _ := repoProvider.WaitJob(job.ID)
+			if result.Status != repo.JobSucceeded {
+				log.Fatalf("错误: 自动索引失败: %s", result.Error)
+			}
+			fmt.Printf("成功生成仓库 %d 的 Zoekt 索引。\n", *repoID)
+		}
+
+	case "clone":
+		if *repoID == 0 || *repoName == "" || *cloneURL == "" {
+			fmt.Fprintln(os.Stderr, "错误: 'clone' 命令需要 -id, -name, 和 -url 参数。")
+			os.Exit(1)
+		}
+		job, err := repoProvider.CloneRepository(uint32(*repoID), *repoName, *cloneURL, *autoIndex, os.Stderr)
+		if err != nil {
+			log.Fatalf("错误: 克隆仓库失败: %v", err)
+		}
+		result, _ := repoProvider.WaitJob(job.ID)
+		if result.Status != repo.JobSucceeded {
+			log.Fatalf("错误: 克隆仓库失败: %s", result.Error)
+		}
+		fmt.Printf("成功克隆并添加仓库: ID=%d, Name=%s\n", *repoID, *repoName)
+		if *autoIndex {
+			// 索引任务在克隆任务中启动，CLI 进程结束会中断它，因此在这里等待
+			for _, j := range repoProvider.ListJobs(uint32(*repoID)) {
+				if j.Kind == repo.JobKindIndex && j.Status == repo.JobRunning {
+					if res, _ := repoProvider.WaitJob(j.ID); res.Status != repo.JobSucceeded {
+						log.Fatalf("错误: 自动索引失败: %s", res.Error)
+					}
+					fmt.Printf("成功生成仓库 %d 的 Zoekt 索引。\n", *repoID)
+				}
+			}
+		}
 
 	case "delete":
 		// Validate required flags for delete
@@ -67,53 +128,202 @@ func main() {
 		}
 		fmt.Printf("成功删除仓库: ID=%d\n", *repoID)
 
+	case "archive", "unarchive":
+		if *repoID == 0 {
+			fmt.Fprintf(os.Stderr, "错误: '%s' 命令需要 -id 参数。\n", *command)
+			os.Exit(1)
+		}
+		if *command == "archive" {
+			err = repoProvider.ArchiveRepository(uint32(*repoID))
+		} else {
+			err = repoProvider.UnarchiveRepository(uint32(*repoID))
+		}
+		if err != nil {
+			log.Fatalf("错误: 更新仓库归档状态失败: %v", err)
+		}
+		fmt.Printf("成功更新仓库归档状态: ID=%d, 命令=%s\n", *repoID, *command)
+
+	case "enable", "disable":
+		if *repoID == 0 {
+			fmt.Fprintf(os.Stderr, "错误: '%s' 命令需要 -id 参数。\n", *command)
+			os.Exit(1)
+		}
+		if err := repoProvider.SetEnabled(uint32(*repoID), *command == "enable"); err != nil {
+			log.Fatalf("错误: 更新仓库启用状态失败: %v", err)
+		}
+		fmt.Printf("成功更新仓库启用状态: ID=%d, 命令=%s\n", *repoID, *command)
+
+	case "relocate":
+		if *repoID == 0 || *newID == 0 {
+			fmt.Fprintln(os.Stderr, "错误: 'relocate' 命令需要 -id 和 -new-id 参数。")
+			os.Exit(1)
+		}
+		err = repoProvider.RelocateRepository(uint32(*repoID), uint32(*newID))
+		if err != nil {
+			log.Fatalf("错误: 重新映射仓库失败: %v", err)
+		}
+		fmt.Printf("成功将仓库 %d 重新映射为 %d (需要重新执行 index 以恢复 Zoekt 搜索)\n", *repoID, *newID)
+
+	case "reconcile":
+		report, err := repoProvider.Reconcile(*dryRun)
+		if err != nil {
+			log.Fatalf("错误: 对账失败: %v", err)
+		}
+		printReconcileReport(report, *jsonOutput)
+		if len(report.Errors) > 0 {
+			os.Exit(1)
+		}
+
+	case "import-config":
+		if *configPath == "" {
+			fmt.Fprintln(os.Stderr, "错误: 'import-config' 命令需要 -config 参数。")
+			os.Exit(1)
+		}
+		if *strictConfig {
+			if _, err := config.LoadStrict(*configPath); err != nil {
+				log.Fatalf("错误: 配置文件校验失败:\n%v", err)
+			}
+		} else if err := config.Load(*configPath); err != nil {
+			log.Fatalf("错误: 读取配置文件失败: %v", err)
+		}
+		importLegacyRepos(repoProvider, config.GetRepos())
+
+	case "reindex-all":
+		results := repoProvider.ReindexAll(*concurrency)
+		failed := 0
+		for _, r := range results {
+			fmt.Printf("%d\t%s\t%s\t%s\n", r.RepoID, r.Name, r.Status, r.Error)
+			if r.Status == repo.JobFailed {
+				failed++
+			}
+		}
+		if failed > 0 {
+			log.Fatalf("错误: %d 个仓库索引失败", failed)
+		}
+
 	case "index":
 		if *repoID == 0 {
 			fmt.Fprintln(os.Stderr, "错误: 'index' 命令需要 -id 参数。")
 			os.Exit(1)
 		}
 
-		err = repoProvider.IndexRepositoryZoekt(uint32(*repoID))
+		plan, err := repoProvider.IndexRepositoryZoekt(uint32(*repoID), *dryRun)
 		if err != nil {
 			log.Fatalf("错误: 索引仓库失败: %v", err)
 		}
+		if *dryRun {
+			printIndexPlan(plan, *jsonOutput)
+			break
+		}
 		fmt.Printf("成功触发仓库 %d 的 Zoekt 索引生成。\n", *repoID)
+		if plan.Stats != nil {
+			fmt.Printf("%s\n", plan.Stats)
+		}
 	case "register-scip":
 		if *repoID == 0 || *scipPath == "" {
 			log.Fatal("错误: register-scip 需要 --id 和 --scip-path")
 		}
-		
+
 		repoInfo, ok := repoProvider.GetRepo(uint32(*repoID))
 		if !ok {
 			log.Fatalf("仓库 %d 未找到", *repoID)
 		}
 
 		// 目标路径: <DataDir>/repos/<ID>/scip/index.scip
-		targetDir := filepath.Join(repoInfo.DataPath, "scip")
-		if err := os.MkdirAll(targetDir, 0755); err != nil {
-			log.Fatalf("创建 SCIP 目录失败: %v", err)
+		if err := repoProvider.RegisterScipIndex(repoInfo.RepoID, *scipPath); err != nil {
+			log.Fatalf("注册 SCIP 索引失败: %v", err)
 		}
-		targetFile := filepath.Join(targetDir, "index.scip")
+		fmt.Printf("成功注册 SCIP 索引到: %s\n", repoInfo.ScipIndexPath())
 
-		src, err := os.Open(*scipPath)
-		if err != nil {
-			log.Fatalf("打开源文件失败: %v", err)
-		}
-		defer src.Close()
+	default:
+		fmt.Println("未知命令。可用: add, clone, delete, archive, unarchive, enable, disable, relocate, index, reindex-all, reconcile, import-config, register-scip")
+		os.Exit(1)
+	}
+}
 
-		dst, err := os.Create(targetFile)
-		if err != nil {
-			log.Fatalf("创建目标文件失败: %v", err)
+// importLegacyRepos 将旧版 JSON 配置中的仓库逐个添加到数据库，并逐行输出结果
+// ID 无法解析或源路径已不存在的条目给出警告并跳过；ID 已存在等冲突只报告，不中断导入
+func importLegacyRepos(p *repo.Provider, repos []config.Repo) {
+	added, conflicts, skipped := 0, 0, 0
+	for _, r := range repos {
+		id, err := strconv.ParseUint(r.ID, 10, 32)
+		if err != nil || id == 0 {
+			log.Printf("警告: 跳过仓库 '%s': 无效的 ID '%s'", r.Name, r.ID)
+			fmt.Printf("%s\t%s\tskipped\t无效的 ID\n", r.ID, r.Name)
+			skipped++
+			continue
+		}
+		if _, err := os.Stat(r.Path); err != nil {
+			log.Printf("警告: 跳过仓库 '%s' (%d): 源路径 '%s' 不可用: %v", r.Name, id, r.Path, err)
+			fmt.Printf("%d\t%s\tskipped\t源路径不存在\n", id, r.Name)
+			skipped++
+			continue
 		}
-		defer dst.Close()
+		if _, err := p.AddRepository(uint32(id), r.Name, r.Path, false); err != nil {
+			fmt.Printf("%d\t%s\terror\t%v\n", id, r.Name, err)
+			conflicts++
+			continue
+		}
+		fmt.Printf("%d\t%s\tok\t\n", id, r.Name)
+		added++
+	}
+	fmt.Printf("导入完成: 成功 %d 个, 冲突/失败 %d 个, 跳过 %d 个 (共 %d 个)\n", added, conflicts, skipped, len(repos))
+}
 
-		if _, err := io.Copy(dst, src); err != nil {
-			log.Fatalf("复制文件失败: %v", err)
+// printIndexPlan 输出 index -dry-run 的执行计划，asJSON 时输出到 stdout 的是单个 JSON 对象
+func printIndexPlan(plan *repo.IndexPlan, asJSON bool) {
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(plan); err != nil {
+			log.Fatalf("错误: 输出 JSON 失败: %v", err)
+		}
+		return
+	}
+	fmt.Printf("仓库:          %d (%s)\n", plan.RepoID, plan.Name)
+	fmt.Printf("源路径:        %s\n", plan.SourcePath)
+	fmt.Printf("索引目录:      %s\n", plan.IndexDir)
+	fmt.Printf("索引名称:      %s\n", plan.ZoektName)
+	if len(plan.GitConfig) == 0 {
+		fmt.Printf(".git/config:   不修改\n")
+	}
+	for _, key := range []string{"zoekt.name", "zoekt.repoid"} {
+		if v, ok := plan.GitConfig[key]; ok {
+			fmt.Printf(".git/config:   %s = %s\n", key, v)
 		}
-		fmt.Printf("成功注册 SCIP 索引到: %s\n", targetFile)
+	}
+	fmt.Printf("命令:          %s %s\n", plan.Command, strings.Join(plan.Args, " "))
+	fmt.Printf("HEAD:          %s\n", plan.HeadCommit)
+	fmt.Printf("已有分片:      %d\n", len(plan.ExistingShards))
+	fmt.Printf("可能已是最新:  %t\n", plan.UpToDate)
+}
 
-	default:
-		fmt.Println("未知命令。可用: add, delete, index, register-scip")
-		os.Exit(1)
+// printReconcileReport 输出 reconcile 的差异报告
+func printReconcileReport(report *repo.ReconcileReport, asJSON bool) {
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			log.Fatalf("错误: 输出 JSON 失败: %v", err)
+		}
+		return
+	}
+	for _, dir := range report.OrphanedDirs {
+		fmt.Printf("孤立目录:     %s\n", dir)
+	}
+	for _, m := range report.MissingDirs {
+		fmt.Printf("缺失目录:     仓库 %d (%s): %s\n", m.RepoID, m.Name, m.Path)
+	}
+	for _, shard := range report.OrphanedShards {
+		fmt.Printf("孤立分片:     %s\n", shard)
+	}
+	for _, e := range report.Errors {
+		fmt.Printf("错误:         %s\n", e)
+	}
+	total := len(report.OrphanedDirs) + len(report.MissingDirs) + len(report.OrphanedShards)
+	if report.DryRun {
+		fmt.Printf("共发现 %d 处不一致 (dry-run，未修改)\n", total)
+	} else {
+		fmt.Printf("共发现 %d 处不一致，已修复 %d 处\n", total, report.Fixed)
 	}
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os/exec"
+	"sort"
+
+	"code-browser/internal/buildinfo"
+	"code-browser/internal/search"
+)
+
+// capabilities 描述服务端启用的功能与限制，前端据此调整界面 (隐藏不可用的引擎等)
+type capabilities struct {
+	Version           buildinfo.Info    `json:"version"`
+	Engines           []string          `json:"engines"`           // 已注册的搜索引擎
+	DefaultEngine     string            `json:"defaultEngine"`     // 请求未指定 engine 时使用的引擎
+	RipgrepInstalled  bool              `json:"ripgrepInstalled"`  // PATH 中是否存在 rg
+	ZoektIndexer      bool              `json:"zoektIndexer"`      // 配置的 zoekt-git-index 是否可用
+	AdminAuthRequired bool              `json:"adminAuthRequired"` // 管理 API 是否需要 Token
+	ReadOnly          bool              `json:"readOnly"`          // 只读实例: 仓库管理写操作返回 403，反馈 API 不可用
+	Features          capabilityFeature `json:"features"`
+	Limits            capabilityLimits  `json:"limits"`
+}
+
+type capabilityFeature struct {
+	Scip            bool `json:"scip"`            // 已注册 SCIP 引擎 (各仓库是否有索引见 hasScip)
+	FuzzyFileSearch bool `json:"fuzzyFileSearch"` // search-files?fuzzy=true
+	MultilineSearch bool `json:"multilineSearch"` // search?multiline=true (需要 ripgrep)
+	CountOnlySearch bool `json:"countOnlySearch"` // search?countOnly=true
+}
+
+type capabilityLimits struct {
+	MaxQueryLength           int   `json:"maxQueryLength"`
+	MaxFileResults           int   `json:"maxFileResults"`
+	MaxFileSize              int64 `json:"maxFileSize"` // 0 表示不限制
+	MaxListFiles             int   `json:"maxListFiles"`
+	ZoektMaxMatches          int   `json:"zoektMaxMatches"`
+	RipgrepMaxMatchesPerFile int   `json:"ripgrepMaxMatchesPerFile"`
+	MaxSearchResponseBytes   int   `json:"maxSearchResponseBytes"` // 0 表示不限制
+	MaxScipUploadBytes       int64 `json:"maxScipUploadBytes"`
+}
+
+// newCapabilities 根据已注册的引擎和启动配置组装能力描述 (启动时计算一次)
+func newCapabilities(engines map[string]search.Engine, defaultEngine, adminToken string, indexerAvailable bool, limits capabilityLimits) capabilities {
+	names := make([]string, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	_, rgErr := exec.LookPath("rg")
+	_, hasRipgrep := engines[search.EngineRipgrep]
+	_, hasScip := engines[search.EngineScip]
+
+	return capabilities{
+		Version:           buildinfo.Get(),
+		Engines:           names,
+		DefaultEngine:     defaultEngine,
+		RipgrepInstalled:  rgErr == nil,
+		ZoektIndexer:      indexerAvailable,
+		AdminAuthRequired: adminToken != "",
+		Features: capabilityFeature{
+			Scip:            hasScip,
+			FuzzyFileSearch: true,
+			MultilineSearch: hasRipgrep && rgErr == nil,
+			CountOnlySearch: true,
+		},
+		Limits: limits,
+	}
+}
+
+// capabilitiesHandler 处理 GET /api/capabilities
+func capabilitiesHandler(c capabilities) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(c)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"code-browser/internal/search"
+)
+
+func TestCapabilitiesHandler(t *testing.T) {
+	get := func(engines map[string]search.Engine) map[string]any {
+		t.Helper()
+		caps := newCapabilities(engines, search.EngineZoekt, "secret", false, capabilityLimits{MaxFileSize: 0, MaxQueryLength: 100})
+		rec := httptest.NewRecorder()
+		capabilitiesHandler(caps)(rec, httptest.NewRequest("GET", "/api/capabilities", nil))
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Fatalf("Content-Type = %q", ct)
+		}
+		var body map[string]any
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return body
+	}
+
+	body := get(map[string]search.Engine{search.EngineZoekt: nil, search.EngineScip: nil})
+	features := body["features"].(map[string]any)
+	limits := body["limits"].(map[string]any)
+	if features["scip"] != true || body["adminAuthRequired"] != true || body["defaultEngine"] != search.EngineZoekt {
+		t.Fatalf("unexpected capabilities: %v", body)
+	}
+	if limits["maxFileSize"] != float64(0) || limits["maxQueryLength"] != float64(100) {
+		t.Fatalf("unexpected limits: %v", limits)
+	}
+	if engines := body["engines"].([]any); len(engines) != 2 || engines[0] != search.EngineScip {
+		t.Fatalf("engines = %v", engines)
+	}
+
+	// 未注册 SCIP 引擎时不声明 SCIP 能力
+	body = get(map[string]search.Engine{search.EngineZoekt: nil})
+	if body["features"].(map[string]any)["scip"] != false {
+		t.Fatalf("scip feature should follow the registered engines: %v", body["features"])
+	}
+}
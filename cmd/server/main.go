@@ -1,14 +1,27 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
 	"code-browser/internal/analysis"
+	"code-browser/internal/buildinfo"
 	"code-browser/internal/core"
 	"code-browser/internal/feedback"
+	"code-browser/internal/fileperm"
+	"code-browser/internal/logging"
+	"code-browser/internal/lru"
 	"code-browser/internal/repo"
 	"code-browser/internal/search"
 
@@ -20,7 +33,8 @@ func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-Request-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, X-Total-Count, X-Search-Truncated, X-Next-Cursor")
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
 			return
@@ -29,42 +43,175 @@ func corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// newCache 按配置创建缓存实例，ttl 为 0 时使用 fallback
+func newCache(ttl, fallback, cleanup time.Duration) *cache.Cache {
+	if ttl == 0 {
+		ttl = fallback
+	}
+	return cache.New(ttl, cleanup)
+}
+
+// splitPatterns 把逗号分隔的标志值拆分为模式列表，忽略空白项
+func splitPatterns(s string) []string {
+	var patterns []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
 func main() {
 	// 1. 定义命令行参数
 	dataDir := flag.String("data-dir", "./.data", "应用程序的全局数据目录 (包含数据库和仓库数据)")
 	adminToken := flag.String("admin-token", "", "管理 API 的鉴权 Token (如果为空则不开启鉴权)")
+	cacheTTL := flag.Duration("cache-ttl", 5*time.Minute, "缓存条目的默认过期时间")
+	cacheCleanup := flag.Duration("cache-cleanup-interval", 10*time.Minute, "过期缓存条目的清理间隔")
+	treeCacheTTL := flag.Duration("tree-cache-ttl", 0, "目录树/文件列表缓存的过期时间 (0 表示使用 -cache-ttl)")
+	blobCacheTTL := flag.Duration("blob-cache-ttl", 0, "文件内容缓存的过期时间 (0 表示使用 -cache-ttl)")
+	searchCacheTTL := flag.Duration("search-cache-ttl", 0, "搜索结果缓存的过期时间 (0 表示使用 -cache-ttl)")
+	scipCacheTTL := flag.Duration("scip-cache-ttl", 0, "SCIP 索引缓存的过期时间 (0 表示永不过期)")
+	blobCacheMaxItems := flag.Int("blob-cache-max-items", 0, "文件内容缓存的最大条目数 (0 表示不限制)")
+	blobCacheMaxBytes := flag.Int64("blob-cache-max-bytes", 256<<20, "文件内容缓存的最大字节数 (0 表示不限制)")
+	blobCacheByContent := flag.Bool("blob-cache-by-content", false, "文件内容缓存按内容哈希去重，相同内容的文件只缓存一份")
+	scipCacheMaxItems := flag.Int("scip-cache-max-items", 8, "SCIP 索引缓存的最大索引数 (0 表示不限制)")
+	scipCacheMaxBytes := flag.Int64("scip-cache-max-bytes", 0, "SCIP 索引缓存的最大字节数 (按索引文件大小估算, 0 表示不限制)")
+	plainSymlinks := flag.Bool("plain-symlinks", false, "符号链接按普通文件显示 (内容为链接目标)，不跟随也不标记")
+	maxFileSize := flag.Int64("max-file-size", 0, "文件内容接口允许返回的最大文件字节数 (0 表示不限制)")
+	maxQueryLength := flag.Int("max-query-length", search.DefaultMaxQueryLength, "搜索查询允许的最大字符数")
+	maxFileResults := flag.Int("max-file-results", search.DefaultMaxFileResults, "文件名搜索最多返回的路径数")
+	maxRipgrepProcs := flag.Int("max-ripgrep-processes", search.DefaultMaxRipgrepProcesses, "同时运行的 rg 搜索进程数上限，已满时请求最多等待 5 秒后返回 503 (0 表示不限制)")
+	defaultEngine := flag.String("default-search-engine", search.EngineZoekt, "搜索请求未指定 engine 时使用的引擎: zoekt, ripgrep, scip")
+	snippetContext := flag.Int("search-snippet-context", search.DefaultSnippetContext, "搜索结果中超长行只保留首个匹配两侧各多少字节 (0 表示不截断)")
+	searchIgnore := flag.String("search-ignore", strings.Join(search.DefaultSearchIgnore, ","), "内容和文件名搜索默认排除的路径模式，逗号分隔 (规则同 .code-browser.json 的 hiddenPaths；为空表示不排除)")
+	maxSearchResponse := flag.Int("max-search-response-bytes", search.DefaultMaxResponseBytes, "内容搜索结果的估算大小上限，超出时截断并设置 X-Search-Truncated 响应头 (0 表示不限制)")
+	maxScipUpload := flag.Int64("max-scip-upload-bytes", repo.DefaultMaxScipUploadBytes, "SCIP 索引上传的最大字节数")
+	reindexConcurrency := flag.Int("reindex-concurrency", repo.DefaultReindexConcurrency, "重建全部索引时同时运行的索引数")
+	prewarmCaches := flag.Bool("prewarm", false, "启动后在后台预先计算每个仓库的文件列表并加载 SCIP 索引 (受 SCIP 缓存上限约束)")
+	prewarmConcurrency := flag.Int("prewarm-concurrency", 2, "预热时同时处理的仓库数")
+	readOnly := flag.Bool("read-only", false, "以只读模式打开数据库 (不迁移、不修改，用于水平扩展的只读实例；数据由另一个管理实例维护)")
+	readOnlyRefresh := flag.Duration("read-only-refresh", repo.DefaultRefreshInterval, "只读模式下重新加载仓库列表的间隔")
+	reindexInterval := flag.Duration("reindex-interval", 0, "定时为 HEAD 有新提交的仓库重建索引的间隔 (0 表示不启用)")
+	indexerPath := flag.String("indexer-path", "", "zoekt-git-index 的路径 (为空时从 PATH 查找)")
+	indexerArgs := flag.String("indexer-args", "", "追加给 zoekt-git-index 的额外参数，按空白分隔 (如 \"-parallelism 4 -file_limit 4194304\")")
+	noGitConfig := flag.Bool("no-git-config", false, "索引时不修改源仓库的 .git/config，改为通过 -name/-repoid 参数传给 zoekt-git-index")
+	webDir := flag.String("web-dir", "", "前端静态文件目录 (为空时使用编译进二进制的文件，开发时可指定 ./web)")
+	tlsCert := flag.String("tls-cert", "", "TLS 证书文件路径 (与 -tls-key 同时指定时启用 HTTPS)")
+	tlsKey := flag.String("tls-key", "", "TLS 私钥文件路径")
+	httpRedirect := flag.String("http-redirect", "", "启用 TLS 时额外监听的 HTTP 地址 (如 :80)，所有请求重定向到 HTTPS (为空则不启用)")
+	logLevel := flag.String("log-level", "info", "日志级别: debug, info, warn, error")
+	publicURL := flag.String("public-url", "", "服务对外访问的地址 (如 https://code.example.com)，用于通知中的链接")
+	feedbackWebhook := flag.String("feedback-webhook-url", "", "收到新反馈时 POST JSON 通知的地址 (为空则不启用)")
+	feedbackSMTPAddr := flag.String("feedback-smtp-addr", "", "收到新反馈时发送邮件的 SMTP 服务器 host:port (为空则不启用)")
+	feedbackSMTPUser := flag.String("feedback-smtp-user", "", "SMTP 用户名 (为空则不鉴权)")
+	feedbackSMTPPassword := flag.String("feedback-smtp-password", os.Getenv("FEEDBACK_SMTP_PASSWORD"), "SMTP 密码 (默认读取环境变量 FEEDBACK_SMTP_PASSWORD)")
+	feedbackSMTPFrom := flag.String("feedback-smtp-from", "", "通知邮件的发件人")
+	feedbackSMTPTo := flag.String("feedback-smtp-to", "", "通知邮件的收件人，逗号分隔")
+	enablePprof := flag.Bool("pprof", false, "在 /debug/pprof/ 下开启性能分析接口 (设置了 -admin-token 时需要鉴权)")
+	feedbackTransitions := flag.String("feedback-transitions", "", "允许的反馈状态变更，如 \"open=in_progress|closed;in_progress=open|closed;closed=open\" (为空则不限制)")
+	showVersion := flag.Bool("version", false, "打印版本与构建信息后退出")
+	modes := fileperm.Modes{Dir: fileperm.DefaultDirMode, File: fileperm.DefaultFileMode}
+	flag.Var(fileperm.Value{Mode: &modes.Dir}, "dir-mode", "在数据目录中新建目录使用的权限 (八进制，再经过进程 umask)")
+	flag.Var(fileperm.Value{Mode: &modes.File}, "file-mode", "在数据目录中新建文件使用的权限 (八进制，再经过进程 umask)")
+	flag.BoolVar(&modes.IgnoreUmask, "ignore-umask", false, "新建目录和文件的权限严格等于 -dir-mode/-file-mode，不受进程 umask 影响")
 	flag.Parse()
 
-	log.Printf("使用数据目录: %s", *dataDir)
+	if *showVersion {
+		fmt.Println("repo-server", buildinfo.Get())
+		return
+	}
+
+	level, err := logging.ParseLevel(*logLevel)
+	if err != nil {
+		log.Fatalf("错误: %v", err)
+	}
+	logging.Setup(level)
+	if err := validateTLSFlags(*tlsCert, *tlsKey, *httpRedirect); err != nil {
+		log.Fatalf("错误: %v", err)
+	}
+
+	slog.Info("使用数据目录", "dir", *dataDir)
 
 	// 2. 创建仓库管理服务实例
-	repoProvider, err := repo.NewProvider(*dataDir)
+	repoProvider, err := repo.NewProviderWithOptions(*dataDir, repo.ProviderOptions{ReadOnly: *readOnly, RefreshInterval: *readOnlyRefresh, Modes: modes})
 	if err != nil {
 		log.Fatalf("错误: 无法初始化仓库服务: %v", err)
 	}
+	repoProvider.NoGitConfig = *noGitConfig
+	repoProvider.IndexerPath = *indexerPath
+	repoProvider.IndexerArgs = strings.Fields(*indexerArgs)
+	repoProvider.IgnoredPaths = splitPatterns(*searchIgnore)
+	indexerAvailable := true
+	if _, err := repoProvider.ResolveIndexer(); err != nil {
+		indexerAvailable = false
+		slog.Warn("索引程序不可用，索引请求将失败", "err", err)
+	}
 	defer func() {
 		if err := repoProvider.Close(); err != nil {
-			log.Printf("关闭数据库连接时出错: %v", err)
+			slog.Error("关闭数据库连接时出错", "err", err)
 		}
 	}()
 
-	log.Printf("成功加载并初始化 %d 个仓库", repoProvider.Count())
+	slog.Info("成功加载并初始化仓库", "count", repoProvider.Count())
+
+	if *reindexInterval > 0 {
+		scheduler, err := repoProvider.StartReindexScheduler(*reindexInterval, *reindexConcurrency)
+		if err != nil {
+			log.Fatalf("错误: 无法启动定时重建索引: %v", err)
+		}
+		// 先于数据库关闭执行 (defer 按后进先出)
+		defer scheduler.Stop()
+	}
+
+	// 各类缓存使用独立实例，避免大文件内容挤占搜索结果等缓存
+	// 文件内容和 SCIP 索引体积大，使用有界 LRU 防止内存无限增长
+	treeCache := newCache(*treeCacheTTL, *cacheTTL, *cacheCleanup)
+	searchCache := newCache(*searchCacheTTL, *cacheTTL, *cacheCleanup)
+	if *blobCacheTTL == 0 {
+		*blobCacheTTL = *cacheTTL
+	}
+	blobCache := lru.New(*blobCacheMaxItems, *blobCacheMaxBytes, *blobCacheTTL)
+	scipCache := lru.New(*scipCacheMaxItems, *scipCacheMaxBytes, *scipCacheTTL)
 
-	appCache := cache.New(5*time.Minute, 10*time.Minute)
+	coreService := core.NewService(repoProvider, treeCache, blobCache)
+	coreService.MaxFileSize = *maxFileSize
+	coreService.ContentAddressedBlobs = *blobCacheByContent
+	coreService.PlainSymlinks = *plainSymlinks
+	coreService.IgnoredPaths = splitPatterns(*searchIgnore)
 
-	coreService := core.NewService(repoProvider, appCache)
+	// 收到 SIGHUP 时重新加载仓库列表 (数据库在进程外被修改后无需重启)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	go reloadOnSignal(hup, repoProvider, coreService)
 
 	zoektEngine := &search.ZoektEngine{ApiUrl: "http://localhost:6070"}
 	ripgrepEngine := &search.RipgrepEngine{}
+	search.SetMaxRipgrepProcesses(*maxRipgrepProcs)
 
 	// 3. 创建并配置搜索服务
 	searchHandlers := &search.Handlers{
 		RepoProvider: repoProvider,
 		Engines: map[string]search.Engine{
-			"zoekt":   zoektEngine,
-			"ripgrep": ripgrepEngine,
+			zoektEngine.Name():   zoektEngine,
+			ripgrepEngine.Name(): ripgrepEngine,
 		},
-		Cache: appCache,
+		CoreService:      coreService,
+		Cache:            searchCache,
+		MaxQueryLength:   *maxQueryLength,
+		MaxFileResults:   *maxFileResults,
+		SnippetContext:   *snippetContext,
+		DefaultEngine:    *defaultEngine,
+		MaxResponseBytes: *maxSearchResponse,
+		SearchIgnore:     splitPatterns(*searchIgnore),
+		AdminToken:       *adminToken,
+	}
+	if *snippetContext <= 0 {
+		searchHandlers.SnippetContext = -1 // Handlers 中 0 表示默认值
+	}
+	if *maxSearchResponse <= 0 {
+		searchHandlers.MaxResponseBytes = -1
 	}
 
 	// 4. 创建核心服务
@@ -73,66 +220,228 @@ func main() {
 		Service:      coreService,
 	}
 
-	analysisService := analysis.NewService(repoProvider, zoektEngine, coreService)
+	analysisService := analysis.NewService(repoProvider, zoektEngine, coreService, scipCache)
 	analysisHandlers := &analysis.Handlers{Service: analysisService}
+	// SCIP 语义搜索依赖分析服务的索引加载，因此在分析服务创建后注册
+	scipEngine := analysis.NewScipEngine(analysisService)
+	if *prewarmCaches {
+		// 后台执行，不阻塞启动；请求可以在预热完成前正常处理
+		go prewarm(repoProvider.GetAll(), coreService, analysisService, *prewarmConcurrency)
+	}
+	searchHandlers.Engines[scipEngine.Name()] = scipEngine
+	if _, ok := searchHandlers.Engines[*defaultEngine]; !ok {
+		log.Fatalf("错误: 无效的 -default-search-engine: '%s'", *defaultEngine)
+	}
 
 	// 5.1 创建仓库管理 Handler
 	repoHandlers := &repo.Handlers{
-		Provider:   repoProvider,
-		AdminToken: *adminToken,
+		Provider:           repoProvider,
+		AdminToken:         *adminToken,
+		MaxScipUploadBytes: *maxScipUpload,
+		ReindexConcurrency: *reindexConcurrency,
+		Reload:             func() error { return reloadRepos(repoProvider, coreService) },
 	}
 
 	// 5. 创建路由器并集中注册所有服务的路由 (恢复简洁方式)
 	mux := http.NewServeMux()
 
 	// 静态文件服务
-	mux.Handle("GET /", http.FileServer(http.Dir("web"))) // Serve static files from web directory
+	mux.Handle("GET /", staticHandler(staticFileSystem(*webDir)))
 
-	// 仓库管理 API (受 AuthMiddleware 保护)
+	// 仓库管理 API (受 AuthMiddleware 保护；修改类接口在只读模式下返回 403)
+	adminWrite := func(next http.HandlerFunc) http.HandlerFunc {
+		return repoHandlers.AuthMiddleware(repoHandlers.RequireWritable(next))
+	}
 	mux.HandleFunc("GET /api/admin/repositories", repoHandlers.AuthMiddleware(repoHandlers.HandleListAdmin))
-	mux.HandleFunc("POST /api/repositories", repoHandlers.AuthMiddleware(repoHandlers.HandleAdd))
-	mux.HandleFunc("DELETE /api/repositories/{id}", repoHandlers.AuthMiddleware(repoHandlers.HandleDelete))
-	mux.HandleFunc("POST /api/repositories/{id}/index", repoHandlers.AuthMiddleware(repoHandlers.HandleIndex))
-	mux.HandleFunc("POST /api/repositories/{id}/scip", repoHandlers.AuthMiddleware(repoHandlers.HandleRegisterScip))
-	mux.HandleFunc("POST /api/repositories/{id}/zoekt-file", repoHandlers.AuthMiddleware(repoHandlers.HandleRegisterZoekt))
+	mux.HandleFunc("POST /api/admin/reconcile", repoHandlers.AuthMiddleware(repoHandlers.HandleReconcile))
+	mux.HandleFunc("GET /api/admin/reload", repoHandlers.AuthMiddleware(repoHandlers.HandleReload))
+	mux.HandleFunc("POST /api/admin/reload", repoHandlers.AuthMiddleware(repoHandlers.HandleReload))
+	mux.HandleFunc("POST /api/repositories", adminWrite(repoHandlers.HandleAdd))
+	mux.HandleFunc("POST /api/repositories/bulk", adminWrite(repoHandlers.HandleBulkAdd))
+	mux.HandleFunc("POST /api/repositories/clone", adminWrite(repoHandlers.HandleClone))
+	mux.HandleFunc("DELETE /api/repositories/{id}", adminWrite(repoHandlers.HandleDelete))
+	mux.HandleFunc("POST /api/repositories/reindex-all", adminWrite(repoHandlers.HandleReindexAll))
+	mux.HandleFunc("POST /api/repositories/{id}/index", adminWrite(repoHandlers.HandleIndex))
+	mux.HandleFunc("POST /api/repositories/{id}/archive", adminWrite(repoHandlers.HandleArchive))
+	mux.HandleFunc("POST /api/repositories/{id}/unarchive", adminWrite(repoHandlers.HandleUnarchive))
+	mux.HandleFunc("POST /api/repositories/{id}/tags", adminWrite(repoHandlers.HandleAddTag))
+	mux.HandleFunc("DELETE /api/repositories/{id}/tags/{tag}", adminWrite(repoHandlers.HandleRemoveTag))
+	mux.HandleFunc("PUT /api/repositories/{id}/default-branch", adminWrite(repoHandlers.HandleSetDefaultBranch))
+	mux.HandleFunc("PUT /api/repositories/{id}/enabled", adminWrite(repoHandlers.HandleSetEnabled))
+	mux.HandleFunc("POST /api/repositories/{id}/scip", adminWrite(repoHandlers.HandleRegisterScip))
+	mux.HandleFunc("POST /api/repositories/{id}/scip/upload", adminWrite(repoHandlers.HandleUploadScip))
+	mux.HandleFunc("GET /api/repositories/{id}/scip", repoHandlers.AuthMiddleware(repoHandlers.HandleDownloadScip))
+	mux.HandleFunc("POST /api/repositories/{id}/zoekt-file", adminWrite(repoHandlers.HandleRegisterZoekt))
+	mux.HandleFunc("GET /api/repositories/{id}/index-log", repoHandlers.AuthMiddleware(repoHandlers.HandleIndexLog))
+	mux.HandleFunc("GET /api/repositories/{id}/git-info", repoHandlers.AuthMiddleware(repoHandlers.HandleGitInfo))
+	mux.HandleFunc("GET /api/jobs", repoHandlers.AuthMiddleware(repoHandlers.HandleListJobs))
+	mux.HandleFunc("GET /api/jobs/{jobId}", repoHandlers.AuthMiddleware(repoHandlers.HandleGetJob))
+	mux.HandleFunc("POST /api/jobs/{jobId}/cancel", repoHandlers.AuthMiddleware(repoHandlers.HandleCancelJob))
+	// 进度流供浏览器 EventSource 使用，Token 也可以通过 ?access_token= 传递；
+	// GET /api/repositories/clone-progress/{jobId} 见下方 withCloneProgress
+	jobProgress := repoHandlers.StreamAuthMiddleware(repoHandlers.HandleJobProgress)
+	mux.HandleFunc("GET /api/jobs/{jobId}/progress", jobProgress)
+
+	// 性能分析 (默认关闭)
+	if *enablePprof {
+		registerPprof(mux, repoHandlers.AuthMiddleware)
+		if *adminToken == "" {
+			slog.Warn("已开启 -pprof 但未设置 -admin-token，/debug/pprof/ 无需鉴权即可访问")
+		} else {
+			slog.Info("性能分析接口已开启", "path", "/debug/pprof/")
+		}
+	}
+
+	// 服务能力描述 (启用的引擎、限制、版本)
+	caps := newCapabilities(searchHandlers.Engines, *defaultEngine, *adminToken, indexerAvailable, capabilityLimits{
+		MaxQueryLength:           *maxQueryLength,
+		MaxFileResults:           *maxFileResults,
+		MaxFileSize:              *maxFileSize,
+		MaxListFiles:             core.MaxListFiles,
+		ZoektMaxMatches:          search.ZoektMaxMatchCount,
+		RipgrepMaxMatchesPerFile: search.RipgrepMaxMatchesPerFile,
+		MaxSearchResponseBytes:   max(*maxSearchResponse, 0),
+		MaxScipUploadBytes:       *maxScipUpload,
+	})
+	caps.ReadOnly = repoProvider.ReadOnly()
+	mux.HandleFunc("GET /api/capabilities", capabilitiesHandler(caps))
 
 	// 核心文件浏览服务 (处理器内部解析 {id})
 	mux.HandleFunc("GET /api/repositories", coreHandlers.ListRepositories)
 	mux.HandleFunc("GET /api/repositories/{id}/tree", coreHandlers.GetTree)
 	mux.HandleFunc("GET /api/repositories/{id}/blob", coreHandlers.GetBlob)
+	mux.HandleFunc("GET /api/repositories/{id}/render", coreHandlers.GetRender)
+	mux.HandleFunc("GET /api/repositories/{id}/resolve-path", coreHandlers.ResolvePath)
+	mux.HandleFunc("GET /api/repositories/{id}/blob-url", coreHandlers.GetBlobURL)
+	mux.HandleFunc("GET /api/repositories/{id}/languages", coreHandlers.GetLanguages)
+	mux.HandleFunc("GET /api/repositories/{id}/archive-tree", coreHandlers.GetArchiveTree)
+	mux.HandleFunc("GET /api/repositories/{id}/archive-blob", coreHandlers.GetArchiveBlob)
+	mux.HandleFunc("GET /api/repositories/{id}/files", coreHandlers.ListFiles)
+	mux.HandleFunc("GET /api/repositories/{id}/settings", coreHandlers.GetSettings)
+	// 首次计算需要回溯大量提交，与 git-info 一样只对管理员开放
+	mux.HandleFunc("GET /api/repositories/{id}/file-ages", repoHandlers.AuthMiddleware(repoHandlers.HandleFileAges))
+	mux.HandleFunc("GET /api/repositories/{id}/tree-diff", repoHandlers.HandleTreeDiff)
 
 	// 搜索服务 (处理器内部解析 {id})
 	mux.HandleFunc("GET /api/repositories/{id}/search", searchHandlers.SearchContent)
 	mux.HandleFunc("GET /api/repositories/{id}/search-files", searchHandlers.SearchFiles)
+	mux.HandleFunc("GET /api/repositories/{id}/search-all", searchHandlers.SearchAll)
+	mux.HandleFunc("GET /api/engines/{name}/syntax", searchHandlers.EngineSyntax)
 
 	mux.HandleFunc("POST /api/intelligence/definitions", analysisHandlers.GetDefinitionHandler)
 	mux.HandleFunc("POST /api/intelligence/references", analysisHandlers.GetReferencesHandler)
+	mux.HandleFunc("POST /api/repositories/{id}/symbol-actions", analysisHandlers.GetSymbolActionsHandler)
+	mux.HandleFunc("POST /api/repositories/{id}/definitions-batch", analysisHandlers.GetDefinitionsBatchHandler)
+	mux.HandleFunc("GET /api/repositories/{id}/related", analysisHandlers.GetRelatedFilesHandler)
 
-	// Feedback API
-	feedbackService, err := feedback.NewService(repoProvider.GetDB())
+	// Feedback API (与仓库服务共用同一个数据库连接池，由 repoProvider.Close 关闭)
+	var feedbackService *feedback.Service
+	if repoProvider.ReadOnly() {
+		err = errors.New("read-only mode")
+	} else {
+		feedbackService, err = feedback.NewService(repoProvider.GetDB())
+	}
 	if err != nil {
-		log.Printf("Warning: Failed to initialize feedback service: %v", err)
+		slog.Warn("Failed to initialize feedback service", "err", err)
 	} else {
+		feedbackService.AttachmentDir = filepath.Join(repoProvider.DataDir, feedback.AttachmentsSubDir)
+		feedbackService.AttachmentModes = modes
+		feedbackService.Repos = repoProvider
+		if feedbackService.Transitions, err = feedback.ParseTransitions(*feedbackTransitions); err != nil {
+			log.Fatalf("错误: 无效的 -feedback-transitions: %v", err)
+		}
+		adminLink := ""
+		if *publicURL != "" {
+			adminLink = strings.TrimSuffix(*publicURL, "/") + "/admin.html"
+		}
+		var notifiers feedback.Notifiers
+		if *feedbackWebhook != "" {
+			notifiers = append(notifiers, &feedback.WebhookNotifier{URL: *feedbackWebhook, Link: adminLink})
+		}
+		if *feedbackSMTPAddr != "" {
+			to := strings.FieldsFunc(*feedbackSMTPTo, func(r rune) bool { return r == ',' || r == ' ' })
+			if *feedbackSMTPFrom == "" || len(to) == 0 {
+				log.Fatalf("错误: -feedback-smtp-addr 需要同时指定 -feedback-smtp-from 和 -feedback-smtp-to")
+			}
+			notifiers = append(notifiers, &feedback.SMTPNotifier{
+				Addr:     *feedbackSMTPAddr,
+				Username: *feedbackSMTPUser,
+				Password: *feedbackSMTPPassword,
+				From:     *feedbackSMTPFrom,
+				To:       to,
+				Link:     adminLink,
+			})
+		}
+		if len(notifiers) > 0 {
+			feedbackService.Notifier = notifiers
+			slog.Info("新反馈通知已启用", "notifiers", len(notifiers))
+		}
 		feedbackHandler := feedback.NewHandler(feedbackService, *adminToken)
 		mux.HandleFunc("POST /api/feedback", feedbackHandler.HandleSubmit)
 		mux.HandleFunc("GET /api/admin/feedbacks", feedbackHandler.AuthMiddleware(feedbackHandler.HandleList))
+		mux.HandleFunc("GET /api/admin/feedbacks/by-context", feedbackHandler.AuthMiddleware(feedbackHandler.HandleListByContext))
+		mux.HandleFunc("GET /api/admin/feedbacks/{id}", feedbackHandler.AuthMiddleware(feedbackHandler.HandleGet))
 		mux.HandleFunc("PATCH /api/admin/feedbacks/{id}", feedbackHandler.AuthMiddleware(feedbackHandler.HandleUpdateStatus))
 		mux.HandleFunc("DELETE /api/admin/feedbacks/{id}", feedbackHandler.AuthMiddleware(feedbackHandler.HandleDelete))
+		mux.HandleFunc("GET /api/admin/feedbacks/{id}/attachments/{name}", feedbackHandler.AuthMiddleware(feedbackHandler.HandleGetAttachment))
 	}
 
 	// 6. 配置并启动服务器
 	server := &http.Server{
 		Addr:         ":8088",
-		Handler:      corsMiddleware(mux),
+		Handler:      logging.Middleware(corsMiddleware(withCloneProgress(mux, jobProgress))),
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  120 * time.Second,
 	}
 
-	log.Println("服务器启动，监听端口 :8088")
-	log.Println("请在浏览器中打开 http://localhost:8088/")
+	useTLS := *tlsCert != ""
+	servers := []*http.Server{server}
+	if *httpRedirect != "" {
+		servers = append(servers, newRedirectServer(*httpRedirect, server.Addr))
+	}
+
+	serveErr := make(chan error, len(servers))
+	go func() {
+		if useTLS {
+			slog.Info("服务器启动 (HTTPS)，监听端口 :8088")
+			slog.Info("请在浏览器中打开 https://localhost:8088/")
+			serveErr <- server.ListenAndServeTLS(*tlsCert, *tlsKey)
+		} else {
+			slog.Info("服务器启动，监听端口 :8088")
+			slog.Info("请在浏览器中打开 http://localhost:8088/")
+			serveErr <- server.ListenAndServe()
+		}
+	}()
+	for _, s := range servers[1:] {
+		go func(s *http.Server) {
+			slog.Info("HTTP 重定向服务启动", "addr", s.Addr)
+			serveErr <- s.ListenAndServe()
+		}(s)
+	}
+
+	// 7. 收到 SIGINT/SIGTERM 时优雅关闭所有监听
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("启动服务器失败: %v", err)
+	var serveFailure error
+	select {
+	case err := <-serveErr:
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveFailure = err
+		}
+	case <-ctx.Done():
+		slog.Info("收到退出信号，正在关闭服务器")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, s := range servers {
+		if err := s.Shutdown(shutdownCtx); err != nil {
+			slog.Error("关闭服务器时出错", "addr", s.Addr, "err", err)
+		}
+	}
+	if serveFailure != nil {
+		log.Fatalf("启动服务器失败: %v", serveFailure)
 	}
 }
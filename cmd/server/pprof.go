@@ -0,0 +1,28 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"time"
+)
+
+// registerPprof 在 mux 上注册 /debug/pprof/ 下的性能分析接口，auth 为管理鉴权中间件
+// (未设置 admin token 时不鉴权，因此只应在可信网络中开启 -pprof)
+// 注意 net/http/pprof 的 init 会注册到 http.DefaultServeMux，但服务器使用自己的 mux，不会因此暴露
+func registerPprof(mux *http.ServeMux, auth func(http.HandlerFunc) http.HandlerFunc) {
+	mux.HandleFunc("GET /debug/pprof/", auth(pprof.Index))
+	mux.HandleFunc("GET /debug/pprof/cmdline", auth(pprof.Cmdline))
+	mux.HandleFunc("GET /debug/pprof/profile", auth(noWriteDeadline(pprof.Profile)))
+	mux.HandleFunc("GET /debug/pprof/symbol", auth(pprof.Symbol))
+	mux.HandleFunc("POST /debug/pprof/symbol", auth(pprof.Symbol))
+	mux.HandleFunc("GET /debug/pprof/trace", auth(noWriteDeadline(pprof.Trace)))
+}
+
+// noWriteDeadline 取消服务器的 WriteTimeout: CPU profile 和 trace 按 ?seconds= 采样 (默认 30 秒)，
+// 会超过全局的写超时
+func noWriteDeadline(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.NewResponseController(w).SetWriteDeadline(time.Time{})
+		next(w, r)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"code-browser/internal/repo"
+)
+
+func TestRegisterPprof_RequiresAdminToken(t *testing.T) {
+	mux := http.NewServeMux()
+	registerPprof(mux, (&repo.Handlers{AdminToken: "secret"}).AuthMiddleware)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/pprof/cmdline", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/debug/pprof/heap?debug=1", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 with token, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code-browser/internal/analysis"
+	"code-browser/internal/core"
+	"code-browser/internal/repo"
+)
+
+// prewarm 为每个仓库预先计算文件列表并加载 SCIP 索引，最多 concurrency 个仓库并行，全部完成后返回。
+// 文件列表进入 core 的目录树缓存 (按 -tree-cache-ttl 过期)；SCIP 缓存放不下时跳过剩余的索引而不是淘汰已加载的，
+// 因此预热不会超出 -scip-cache-max-items / -scip-cache-max-bytes 的限制。已停用的仓库跳过。
+func prewarm(repos []repo.Repository, coreService *core.Service, analysisService *analysis.Service, concurrency int) {
+	start := time.Now()
+	var targets []repo.Repository
+	for _, r := range repos {
+		if !r.Disabled {
+			targets = append(targets, r)
+		}
+	}
+	slog.Info("开始预热缓存", "repos", len(targets), "concurrency", concurrency)
+
+	sem := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+	var done, scipLoaded atomic.Int32
+	for _, r := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(r repo.Repository) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if _, err := coreService.ListAllFiles(context.Background(), r.RepoID); err != nil {
+				slog.Warn("预热文件列表失败", "repo", r.RepoID, "err", err)
+			}
+			loaded, err := analysisService.PrewarmSCIP(r)
+			switch {
+			case errors.Is(err, analysis.ErrCacheFull):
+				slog.Info("SCIP 缓存已满，跳过预热", "repo", r.RepoID)
+			case err != nil:
+				slog.Warn("预热 SCIP 索引失败", "repo", r.RepoID, "err", err)
+			case loaded:
+				scipLoaded.Add(1)
+			}
+			slog.Info("预热进度", "repo", r.RepoID, "done", done.Add(1), "total", len(targets))
+		}(r)
+	}
+	wg.Wait()
+	slog.Info("缓存预热完成", "repos", done.Load(), "scipIndexes", scipLoaded.Load(), "elapsed", time.Since(start).Round(time.Millisecond))
+}
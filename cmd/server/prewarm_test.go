@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"code-browser/internal/analysis"
+	"code-browser/internal/core"
+	"code-browser/internal/lru"
+	"code-browser/internal/repo"
+	"code-browser/internal/repo/repotest"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/patrickmn/go-cache"
+	"github.com/sourcegraph/scip/bindings/go/scip"
+	"google.golang.org/protobuf/proto"
+)
+
+// newPrewarmRepo 创建一个包含单个文件的 git 仓库，withIndex 时在数据目录写入 SCIP 索引
+func newPrewarmRepo(t *testing.T, id uint32, withIndex bool) repo.Repository {
+	t.Helper()
+	src := t.TempDir()
+	r, err := git.PlainInit(src, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.Add("main.go"); err != nil {
+		t.Fatal(err)
+	}
+	sig := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+	if _, err := wt.Commit("init", &git.CommitOptions{Author: sig}); err != nil {
+		t.Fatal(err)
+	}
+
+	info := repo.Repository{RepoID: id, Name: fmt.Sprintf("repo%d", id), SourcePath: src, DataPath: t.TempDir()}
+	if withIndex {
+		data, err := proto.Marshal(&scip.Index{Documents: []*scip.Document{{RelativePath: "main.go"}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(filepath.Dir(info.ScipIndexPath()), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(info.ScipIndexPath(), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return info
+}
+
+func TestPrewarm(t *testing.T) {
+	disabled := newPrewarmRepo(t, 4, true)
+	disabled.Disabled = true
+	provider := repotest.New(
+		newPrewarmRepo(t, 1, true),
+		newPrewarmRepo(t, 2, false),
+		newPrewarmRepo(t, 3, true),
+		disabled,
+	)
+	treeCache := cache.New(time.Minute, time.Minute)
+	coreService := core.NewService(provider, treeCache, lru.New(0, 0, 0))
+	// 只能放下一个 SCIP 索引: 另一个应被跳过而不是淘汰已加载的
+	scipCache := lru.New(1, 0, 0)
+	analysisService := analysis.NewService(provider, nil, coreService, scipCache)
+
+	prewarm(provider.GetAll(), coreService, analysisService, 2)
+
+	for _, id := range []uint32{1, 2, 3} {
+		if _, found := treeCache.Get(fmt.Sprintf("filelist:%d", id)); !found {
+			t.Errorf("file list of repo %d not prewarmed", id)
+		}
+	}
+	if _, found := treeCache.Get("filelist:4"); found {
+		t.Errorf("disabled repo should be skipped")
+	}
+	if scipCache.Len() != 1 {
+		t.Fatalf("scip cache len = %d, want 1", scipCache.Len())
+	}
+	r1, _ := provider.GetRepo(1)
+	r3, _ := provider.GetRepo(3)
+	_, found1 := scipCache.Get(r1.ScipIndexPath())
+	_, found3 := scipCache.Get(r3.ScipIndexPath())
+	if found1 == found3 {
+		t.Fatalf("exactly one of the two indexes should be cached: repo1=%t repo3=%t", found1, found3)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+
+	"code-browser/internal/core"
+	"code-browser/internal/repo"
+)
+
+// reloadRepos 从数据库重新加载仓库列表并清除仓库设置缓存，用于数据库或配置在进程外被修改之后
+func reloadRepos(p *repo.Provider, coreService *core.Service) error {
+	before := p.Count()
+	if err := p.Reload(); err != nil {
+		return err
+	}
+	coreService.InvalidateSettings()
+	slog.Info("已重新加载仓库列表", "before", before, "after", p.Count())
+	return nil
+}
+
+// reloadOnSignal 每收到一个信号 (SIGHUP) 执行一次 reloadRepos，直到 signals 关闭
+func reloadOnSignal(signals <-chan os.Signal, p *repo.Provider, coreService *core.Service) {
+	for range signals {
+		slog.Info("收到 SIGHUP，重新加载仓库列表")
+		if err := reloadRepos(p, coreService); err != nil {
+			slog.Error("重新加载仓库列表失败", "err", err)
+		}
+	}
+}
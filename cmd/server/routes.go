@@ -0,0 +1,13 @@
+package main
+
+import "net/http"
+
+// withCloneProgress 在 mux 外层注册 GET /api/repositories/clone-progress/{jobId}，其余请求交给 mux。
+// 该路由不能直接注册在 mux 中: 它与 /api/repositories/{id}/... 路由都能匹配 /api/repositories/clone-progress/scip
+// 这样的路径且互不更具体，ServeMux 会在注册时 panic
+func withCloneProgress(mux *http.ServeMux, progress http.HandlerFunc) http.Handler {
+	root := http.NewServeMux()
+	root.HandleFunc("GET /api/repositories/clone-progress/{jobId}", progress)
+	root.Handle("/", mux)
+	return root
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithCloneProgress(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/repositories/{id}/scip", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("scip " + r.PathValue("id")))
+	})
+	handler := withCloneProgress(mux, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("progress " + r.PathValue("jobId")))
+	})
+
+	for path, want := range map[string]string{
+		"/api/repositories/clone-progress/job-1": "progress job-1",
+		"/api/repositories/clone-progress/scip":  "progress scip",
+		"/api/repositories/7/scip":               "scip 7",
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != want {
+			t.Fatalf("GET %s = %d %q, want %q", path, rec.Code, rec.Body.String(), want)
+		}
+	}
+}
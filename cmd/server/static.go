@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"code-browser/web"
+)
+
+// staticFileSystem 返回前端静态文件的来源
+// dir 为空时使用内嵌文件；否则使用本地目录 (开发时可直接修改页面无需重新编译)，目录不存在时回退到内嵌文件。
+func staticFileSystem(dir string) http.FileSystem {
+	if dir == "" {
+		return http.FS(web.FS())
+	}
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		slog.Warn("静态文件目录不可用，回退到内嵌文件", "dir", dir, "err", err)
+		return http.FS(web.FS())
+	}
+	slog.Info("使用本地静态文件目录", "dir", dir)
+	return http.Dir(dir)
+}
+
+// hashedAssetPattern 匹配文件名中带内容哈希的静态资源，例如 app.3f2a9c1b.js
+var hashedAssetPattern = regexp.MustCompile(`\.[0-9a-fA-F]{8,}\.[A-Za-z0-9]+$`)
+
+// staticHandler 在 http.FileServer 之上补充缓存相关的响应头:
+//   - 带内容哈希的资源: 长期缓存 (immutable)
+//   - 其余文件 (包括 index.html): no-cache，每次通过 ETag 向服务端确认
+//
+// ETag 为文件内容的 sha256，内嵌文件没有修改时间，因此不能依赖 Last-Modified。
+// FileServer 会读取已设置的 ETag 头并处理 If-None-Match，命中时返回 304。
+func staticHandler(fsys http.FileSystem) http.Handler {
+	files := http.FileServer(fsys)
+	etags := &etagCache{entries: make(map[string]etagEntry)}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + r.URL.Path)
+		if strings.HasSuffix(r.URL.Path, "/") {
+			name = path.Join(name, "index.html")
+		}
+		if etag, ok := etags.get(fsys, name); ok {
+			w.Header().Set("ETag", etag)
+			if hashedAssetPattern.MatchString(name) {
+				w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+			} else {
+				w.Header().Set("Cache-Control", "no-cache")
+			}
+		}
+		files.ServeHTTP(w, r)
+	})
+}
+
+type etagEntry struct {
+	size    int64
+	modTime time.Time
+	etag    string
+}
+
+// etagCache 缓存每个文件的 ETag，文件大小或修改时间变化时重新计算 (-web-dir 下编辑文件后立即生效)
+type etagCache struct {
+	mu      sync.Mutex
+	entries map[string]etagEntry
+}
+
+func (c *etagCache) get(fsys http.FileSystem, name string) (string, bool) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return "", false
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[name]
+	c.mu.Unlock()
+	if ok && entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+		return entry.etag, true
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", false
+	}
+	etag := `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+	c.mu.Lock()
+	c.entries[name] = etagEntry{size: info.Size(), modTime: info.ModTime(), etag: etag}
+	c.mu.Unlock()
+	return etag, true
+}
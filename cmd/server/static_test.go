@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestStaticHandler_CacheHeaders(t *testing.T) {
+	fsys := http.FS(fstest.MapFS{
+		"index.html":      {Data: []byte("<html></html>")},
+		"app.3f2a9c1b.js": {Data: []byte("console.log(1)")},
+		"admin.html":      {Data: []byte("<html>admin</html>")},
+	})
+	h := staticHandler(fsys)
+
+	cases := []struct {
+		path, cacheControl string
+	}{
+		{"/", "no-cache"},
+		{"/admin.html", "no-cache"},
+		{"/app.3f2a9c1b.js", "public, max-age=31536000, immutable"},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", c.path, rec.Code)
+		}
+		if got := rec.Header().Get("Cache-Control"); got != c.cacheControl {
+			t.Fatalf("%s: unexpected Cache-Control %q", c.path, got)
+		}
+		if rec.Header().Get("ETag") == "" {
+			t.Fatalf("%s: missing ETag", c.path)
+		}
+	}
+}
+
+func TestStaticHandler_IfNoneMatch(t *testing.T) {
+	h := staticHandler(http.FS(fstest.MapFS{"index.html": {Data: []byte("<html></html>")}}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	etag := rec.Header().Get("ETag")
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("expected 304, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", `"stale"`)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for stale etag, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// validateTLSFlags 检查 TLS 相关参数的组合是否合法
+func validateTLSFlags(certFile, keyFile, redirectAddr string) error {
+	if (certFile == "") != (keyFile == "") {
+		return fmt.Errorf("-tls-cert 和 -tls-key 必须同时指定")
+	}
+	if redirectAddr != "" && certFile == "" {
+		return fmt.Errorf("-http-redirect 需要同时启用 TLS (-tls-cert/-tls-key)")
+	}
+	return nil
+}
+
+// newRedirectServer 创建一个只负责把 HTTP 请求 301 重定向到 HTTPS 的服务器
+// httpsAddr 为 HTTPS 监听地址，端口不是 443 时会保留在重定向目标中
+func newRedirectServer(addr, httpsAddr string) *http.Server {
+	_, httpsPort, _ := net.SplitHostPort(httpsAddr)
+	return &http.Server{
+		Addr:         addr,
+		Handler:      redirectToHTTPS(httpsPort),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+		IdleTimeout:  30 * time.Second,
+	}
+}
+
+// redirectToHTTPS 返回把请求重定向到相同主机、相同路径的 https 地址的 handler
+func redirectToHTTPS(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	})
+}
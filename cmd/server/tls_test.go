@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectToHTTPS(t *testing.T) {
+	cases := []struct {
+		port, host, target, want string
+	}{
+		{"443", "example.com", "/api/repositories?x=1", "https://example.com/api/repositories?x=1"},
+		{"443", "example.com:80", "/", "https://example.com/"},
+		{"8088", "example.com:8080", "/admin.html", "https://example.com:8088/admin.html"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, c.target, nil)
+		req.Host = c.host
+		rec := httptest.NewRecorder()
+		redirectToHTTPS(c.port).ServeHTTP(rec, req)
+		if rec.Code != http.StatusMovedPermanently {
+			t.Fatalf("expected 301, got %d", rec.Code)
+		}
+		if got := rec.Header().Get("Location"); got != c.want {
+			t.Fatalf("expected %q, got %q", c.want, got)
+		}
+	}
+}
+
+func TestValidateTLSFlags(t *testing.T) {
+	if err := validateTLSFlags("", "", ""); err != nil {
+		t.Fatalf("plain http should be valid: %v", err)
+	}
+	if err := validateTLSFlags("cert.pem", "", ""); err == nil {
+		t.Fatal("expected error when key is missing")
+	}
+	if err := validateTLSFlags("", "", ":80"); err == nil {
+		t.Fatal("expected error for redirect without TLS")
+	}
+	if err := validateTLSFlags("cert.pem", "key.pem", ":80"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
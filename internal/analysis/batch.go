@@ -0,0 +1,96 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"code-browser/internal/search"
+)
+
+// MaxDefinitionsBatch definitions-batch 一次最多查询的位置数
+const MaxDefinitionsBatch = 500
+
+// definitionsBatchConcurrency 批量查询时同时解析的位置数 (回退到搜索时每个位置会请求一次搜索引擎)
+const definitionsBatchConcurrency = 8
+
+// ErrBatchTooLarge 批量请求的位置数超过 MaxDefinitionsBatch
+var ErrBatchTooLarge = errors.New("批量请求的位置过多")
+
+// BatchPosition definitions-batch 请求中的一个位置 (0-based，与 DefinitionRequest 相同)
+type BatchPosition struct {
+	FilePath  string `json:"filePath"`
+	Line      int32  `json:"line"`
+	Character int32  `json:"character"`
+}
+
+// BatchDefinitionResult 与请求中的位置一一对应的结果；单个位置失败时只设置 Error，不影响其它位置
+type BatchDefinitionResult struct {
+	Definitions []AnalysisResult `json:"definitions"`
+	Error       string           `json:"error,omitempty"`
+}
+
+// GetDefinitionsBatch 批量查找定义，结果与 positions 按下标对应。
+// req 提供仓库和公共选项 (CrossRepo、IncludePreview)，其中的位置字段被忽略。
+// SCIP 索引只在开始时加载一次，所有位置共用；位置并发解析，最多 definitionsBatchConcurrency 个。
+// 仓库不存在、被禁用或位置过多时整个请求失败，单个位置的错误记录在对应结果中；
+// ctx 结束后不再启动新的查询并返回 ctx.Err()，任一位置遇到 search.ErrSearchBusy 时返回该错误
+func (s *Service) GetDefinitionsBatch(ctx context.Context, req DefinitionRequest, positions []BatchPosition) ([]BatchDefinitionResult, error) {
+	if len(positions) > MaxDefinitionsBatch {
+		return nil, ErrBatchTooLarge
+	}
+	repoInfo, err := s.resolveRepo(req.RepoID)
+	if err != nil {
+		return nil, err
+	}
+	index := s.currentSCIPIndex(repoInfo.ScipIndexPath())
+
+	results := make([]BatchDefinitionResult, len(positions))
+	sem := make(chan struct{}, definitionsBatchConcurrency)
+	var (
+		wg   sync.WaitGroup
+		busy atomic.Bool // 有位置因搜索名额已满而失败
+	)
+	for i, pos := range positions {
+		// 客户端断开或请求超时后不再启动新的查询
+		if ctx.Err() != nil {
+			break
+		}
+		if pos.FilePath == "" {
+			results[i] = BatchDefinitionResult{Definitions: []AnalysisResult{}, Error: "缺少 filePath"}
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			item := req
+			item.FilePath, item.Line, item.Character = pos.FilePath, pos.Line, pos.Character
+			defs, err := s.definitionAt(ctx, repoInfo, index, item)
+			if err != nil {
+				if errors.Is(err, search.ErrSearchBusy) {
+					busy.Store(true)
+				}
+				results[i] = BatchDefinitionResult{Definitions: []AnalysisResult{}, Error: err.Error()}
+				return
+			}
+			if req.IncludePreview {
+				defs = s.attachPreviews(defs)
+			}
+			results[i] = BatchDefinitionResult{Definitions: defs}
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// 部分位置因搜索繁忙没有结果，整个请求按繁忙处理，由客户端稍后重试
+	if busy.Load() {
+		return nil, search.ErrSearchBusy
+	}
+	return results, nil
+}
@@ -0,0 +1,80 @@
+package analysis
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sourcegraph/scip/bindings/go/scip"
+)
+
+// crossRepoDefinitions 当前仓库的索引中没有光标处符号的定义时 (通常是外部依赖的符号)，
+// 在其它已注册仓库的 SCIP 索引中查找该符号的定义，结果的 RepoID 为定义所在的仓库。
+// 符号按 scheme、包管理器、包名和描述符匹配，忽略版本: 依赖方记录的是依赖的发布版本，
+// 而被依赖仓库自己的索引里通常是开发版本或提交哈希。局部符号不会跨仓库匹配。
+// 只查找 ScipCache 中已加载的其它仓库索引 (cachedSCIPIndex)，不会为此加载索引或改变缓存的淘汰顺序:
+// 逐个解析所有仓库的索引代价过高，并会挤掉正在使用的索引。
+func (s *Service) crossRepoDefinitions(currentID uint32, scipPath string, req DefinitionRequest) []AnalysisResult {
+	index, err := s.loadSCIPIndex(scipPath)
+	if err != nil {
+		return nil
+	}
+	doc := findDocument(index, req.FilePath)
+	if doc == nil {
+		return nil
+	}
+	symbol := findSymbolAtPosition(doc, req.Line, req.Character)
+	if symbol == "" || scip.IsLocalSymbol(symbol) {
+		return nil
+	}
+	key := symbolKeyIgnoringVersion(symbol)
+
+	var results []AnalysisResult
+	for _, r := range s.RepoProvider.GetAll() {
+		if r.RepoID == currentID || r.CheckEnabled() != nil {
+			continue
+		}
+		other, ok := s.cachedSCIPIndex(r.ScipIndexPath())
+		if !ok {
+			continue
+		}
+		results = append(results, collectDefinitionsByKey(other, key, strconv.FormatUint(uint64(r.RepoID), 10))...)
+	}
+	return results
+}
+
+// collectDefinitionsByKey 收集索引中与 key (symbolKeyIgnoringVersion) 相同的符号的定义
+func collectDefinitionsByKey(index *scip.Index, key, repoIDStr string) []AnalysisResult {
+	var results []AnalysisResult
+	matched := make(map[string]bool) // 符号 → 是否匹配，同一个符号只解析一次
+	for _, doc := range index.Documents {
+		for _, occ := range doc.Occurrences {
+			if occ.SymbolRoles&int32(scip.SymbolRole_Definition) == 0 || scip.IsLocalSymbol(occ.Symbol) {
+				continue
+			}
+			ok, seen := matched[occ.Symbol]
+			if !seen {
+				ok = symbolKeyIgnoringVersion(occ.Symbol) == key
+				matched[occ.Symbol] = ok
+			}
+			if ok {
+				results = append(results, occurrenceResult(doc, occ, "definition", repoIDStr))
+			}
+		}
+	}
+	return results
+}
+
+// symbolKeyIgnoringVersion 返回去掉包版本后的符号标识；无法解析的符号原样返回
+func symbolKeyIgnoringVersion(symbol string) string {
+	sym, err := scip.ParseSymbol(symbol)
+	if err != nil || sym.Package == nil {
+		return symbol
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s %s", sym.Scheme, sym.Package.Manager, sym.Package.Name)
+	for _, d := range sym.Descriptors {
+		fmt.Fprintf(&b, " %s/%d/%s", d.Name, d.Suffix, d.Disambiguator)
+	}
+	return b.String()
+}
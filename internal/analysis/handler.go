@@ -2,8 +2,13 @@ package analysis
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
 	"net/http"
+	"strconv"
+
+	"code-browser/internal/logging"
+	"code-browser/internal/repo"
+	"code-browser/internal/search"
 )
 
 // Handlers 封装了 Analysis 服务的所有 HTTP 处理器
@@ -11,6 +16,30 @@ type Handlers struct {
 	Service *Service
 }
 
+// errorStatus 将服务错误映射为 HTTP 状态码；"没有结果" 不是错误，由服务返回空列表 (200)
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrRepoNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repo.ErrRepoDisabled):
+		return http.StatusForbidden
+	case errors.Is(err, ErrBatchTooLarge):
+		return http.StatusBadRequest
+	case errors.Is(err, search.ErrSearchBusy):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// writeError 按 errorStatus 写出错误；搜索繁忙 (503) 时与搜索接口一样提示客户端稍后重试
+func writeError(w http.ResponseWriter, err error) {
+	status := errorStatus(err)
+	if status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", "1")
+	}
+	http.Error(w, err.Error(), status)
+}
+
 // GetDefinitionHandler 查找定义
 func (h *Handlers) GetDefinitionHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -30,12 +59,29 @@ func (h *Handlers) GetDefinitionHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	definitions, err := h.Service.GetDefinition(req)
+	if _, _, err := parseBase(req.Base); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// crossRepo、includePreview 也可以作为查询参数传入 (?crossRepo=true&includePreview=true)
+	for name, dst := range map[string]*bool{"crossRepo": &req.CrossRepo, "includePreview": &req.IncludePreview} {
+		if v := r.URL.Query().Get(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				http.Error(w, "Invalid "+name+" parameter", http.StatusBadRequest)
+				return
+			}
+			*dst = b
+		}
+	}
+
+	definitions, err := h.Service.GetDefinition(r.Context(), req)
+	if err == nil {
+		definitions, err = ConvertBase(definitions, req.Base)
+	}
 	if err != nil {
-		log.Printf("获取定义失败: %v", err)
-		// 区分错误类型：如果是索引不存在，返回 404；如果是解析错误，返回 500
-		// 这里简化处理
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logging.FromContext(r.Context()).Error("获取定义失败", "repo", req.RepoID, "file", req.FilePath, "err", err)
+		writeError(w, err)
 		return
 	}
 
@@ -43,6 +89,47 @@ func (h *Handlers) GetDefinitionHandler(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(definitions)
 }
 
+// GetDefinitionsBatchHandler 处理 POST /api/repositories/{id}/definitions-batch
+// 请求体为位置数组 [{filePath, line, character}]，返回与之一一对应的 [{definitions, error?}]；
+// base、crossRepo、includePreview 作为查询参数，对所有位置生效
+func (h *Handlers) GetDefinitionsBatchHandler(w http.ResponseWriter, r *http.Request) {
+	var positions []BatchPosition
+	if err := json.NewDecoder(r.Body).Decode(&positions); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query()
+	req := DefinitionRequest{RepoID: r.PathValue("id"), Base: query.Get("base")}
+	if _, _, err := parseBase(req.Base); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for name, dst := range map[string]*bool{"crossRepo": &req.CrossRepo, "includePreview": &req.IncludePreview} {
+		if v := query.Get(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				http.Error(w, "Invalid "+name+" parameter", http.StatusBadRequest)
+				return
+			}
+			*dst = b
+		}
+	}
+
+	results, err := h.Service.GetDefinitionsBatch(r.Context(), req, positions)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("批量获取定义失败", "repo", req.RepoID, "positions", len(positions), "err", err)
+		writeError(w, err)
+		return
+	}
+	for i := range results {
+		// base 已校验，转换不会失败
+		results[i].Definitions, _ = ConvertBase(results[i].Definitions, req.Base)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
 // GetReferencesHandler 查找引用
 func (h *Handlers) GetReferencesHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -58,12 +145,93 @@ func (h *Handlers) GetReferencesHandler(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Missing required fields: repoId, filePath", http.StatusBadRequest)
 		return
 	}
-	refs, err := h.Service.GetReferences(req)
+	if _, _, err := parseBase(req.Base); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 分页与过滤参数走 query string，body 中的 filePath 是光标所在文件
+	query := r.URL.Query()
+	var q ReferencesQuery
+	q.FilePath = query.Get("filePath")
+	for name, dst := range map[string]*int{"limit": &q.Limit, "offset": &q.Offset} {
+		if v := query.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				http.Error(w, "Invalid "+name+" parameter", http.StatusBadRequest)
+				return
+			}
+			*dst = n
+		}
+	}
+
+	refs, err := h.Service.GetReferences(r.Context(), req)
+	if err == nil {
+		refs, err = ConvertBase(refs, req.Base)
+	}
 	if err != nil {
-		log.Printf("获取引用失败: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logging.FromContext(r.Context()).Error("获取引用失败", "repo", req.RepoID, "file", req.FilePath, "err", err)
+		writeError(w, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(refs)
+	// flat=true 保持旧的扁平数组格式，兼容已有调用方
+	if query.Get("flat") == "true" {
+		json.NewEncoder(w).Encode(refs)
+		return
+	}
+	json.NewEncoder(w).Encode(GroupReferences(refs, q))
+}
+
+// GetSymbolActionsHandler 处理 POST /api/repositories/{id}/symbol-actions
+// 一次返回定义、引用数量和悬浮信息，避免前端多次往返
+func (h *Handlers) GetSymbolActionsHandler(w http.ResponseWriter, r *http.Request) {
+	var req DefinitionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	// 仓库 ID 以路径参数为准
+	req.RepoID = r.PathValue("id")
+	if req.RepoID == "" || req.FilePath == "" {
+		http.Error(w, "Missing required fields: id, filePath", http.StatusBadRequest)
+		return
+	}
+	if _, _, err := parseBase(req.Base); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.Service.GetSymbolActions(r.Context(), req)
+	if err == nil {
+		result.Definitions, err = ConvertBase(result.Definitions, req.Base)
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("获取符号信息失败", "repo", req.RepoID, "file", req.FilePath, "err", err)
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetRelatedFilesHandler 处理 GET /api/repositories/{id}/related?path=
+// 返回当前文件依赖的文件路径 (按引用次数排序)，没有 SCIP 索引时返回 []
+func (h *Handlers) GetRelatedFilesHandler(w http.ResponseWriter, r *http.Request) {
+	repoID := r.PathValue("id")
+	filePath := r.URL.Query().Get("path")
+	if repoID == "" || filePath == "" {
+		http.Error(w, "Missing required parameters: id, path", http.StatusBadRequest)
+		return
+	}
+
+	files, err := h.Service.GetRelatedFiles(repoID, filePath)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("获取相关文件失败", "repo", repoID, "file", filePath, "err", err)
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(files)
 }
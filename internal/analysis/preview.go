@@ -0,0 +1,58 @@
+package analysis
+
+import (
+	"bufio"
+	"bytes"
+	"log/slog"
+	"strconv"
+)
+
+// PreviewContextLines 定义预览在定义起始行前后各包含的行数
+const PreviewContextLines = 2
+
+// attachPreviews 为每个结果填充定义所在位置前后的源码行
+// 文件通过 CoreService.GetFileContent 读取 (复用其缓存与文件大小限制)，同一文件只读取一次；
+// 读取失败 (文件过大、不存在等) 的结果不带预览，不影响定义本身的返回。
+func (s *Service) attachPreviews(results []AnalysisResult) []AnalysisResult {
+	contents := make(map[string][]byte)
+	for i, r := range results {
+		repoID, err := strconv.ParseUint(r.RepoID, 10, 32)
+		if err != nil {
+			continue
+		}
+		key := r.RepoID + ":" + r.FilePath
+		content, ok := contents[key]
+		if !ok {
+			content, _, err = s.CoreService.GetFileContent(uint32(repoID), r.FilePath)
+			if err != nil {
+				slog.Debug("读取定义预览失败", "repo", r.RepoID, "file", r.FilePath, "err", err)
+			}
+			contents[key] = content
+		}
+		if content == nil {
+			continue
+		}
+		// 结果的行号基准为 Range.LineBase，这里换算成 0-based
+		start := r.Range.StartLine - r.Range.LineBase - PreviewContextLines
+		if start < 0 {
+			start = 0
+		}
+		end := r.Range.StartLine - r.Range.LineBase + PreviewContextLines
+		results[i].Preview = previewLines(content, start, end)
+		results[i].PreviewStartLine = start + r.Range.LineBase
+	}
+	return results
+}
+
+// previewLines 返回 content 中 0-based 行号 [start, end] 的行，只扫描到 end 为止
+func previewLines(content []byte, start, end int32) []string {
+	lines := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(nil, len(content)+1)
+	for line := int32(0); line <= end && scanner.Scan(); line++ {
+		if line >= start {
+			lines = append(lines, scanner.Text())
+		}
+	}
+	return lines
+}
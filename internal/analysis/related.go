@@ -0,0 +1,78 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/sourcegraph/scip/bindings/go/scip"
+)
+
+// GetRelatedFiles 根据 SCIP 索引返回 filePath 所依赖的文件: 收集该文件中引用的符号，
+// 找到定义这些符号的文档，按引用次数从多到少排序 (次数相同按路径)
+// 文件自身、局部符号和定义不在索引中的外部符号不计入；没有 SCIP 索引或文件不在索引中时返回空切片
+func (s *Service) GetRelatedFiles(repoID, filePath string) ([]string, error) {
+	repoInfo, err := s.resolveRepo(repoID)
+	if err != nil {
+		return nil, err
+	}
+	scipPath := repoInfo.ScipIndexPath()
+	if !s.hasSCIPIndex(scipPath) {
+		return []string{}, nil
+	}
+	index, err := s.loadSCIPIndex(scipPath)
+	if err != nil {
+		return nil, fmt.Errorf("加载 SCIP 索引失败: %w", err)
+	}
+	return relatedFiles(index, filePath), nil
+}
+
+// relatedFiles 统计 filePath 中的引用指向的定义文档
+func relatedFiles(index *scip.Index, filePath string) []string {
+	doc := findDocument(index, filePath)
+	if doc == nil {
+		return []string{}
+	}
+
+	// 只关心本文件引用到的符号的定义位置
+	counts := make(map[string]int)
+	for _, occ := range doc.Occurrences {
+		if occ.Symbol == "" || scip.IsLocalSymbol(occ.Symbol) || occ.SymbolRoles&int32(scip.SymbolRole_Definition) != 0 {
+			continue
+		}
+		counts[occ.Symbol]++
+	}
+	if len(counts) == 0 {
+		return []string{}
+	}
+	definedIn := make(map[string]string, len(counts))
+	for _, d := range index.Documents {
+		for _, occ := range d.Occurrences {
+			if occ.SymbolRoles&int32(scip.SymbolRole_Definition) == 0 {
+				continue
+			}
+			if _, ok := counts[occ.Symbol]; ok {
+				if _, seen := definedIn[occ.Symbol]; !seen {
+					definedIn[occ.Symbol] = d.RelativePath
+				}
+			}
+		}
+	}
+
+	fileCounts := make(map[string]int)
+	for symbol, n := range counts {
+		if p, ok := definedIn[symbol]; ok && p != filePath {
+			fileCounts[p] += n
+		}
+	}
+	files := make([]string, 0, len(fileCounts))
+	for p := range fileCounts {
+		files = append(files, p)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if fileCounts[files[i]] != fileCounts[files[j]] {
+			return fileCounts[files[i]] > fileCounts[files[j]]
+		}
+		return files[i] < files[j]
+	})
+	return files
+}
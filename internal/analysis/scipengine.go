@@ -0,0 +1,178 @@
+package analysis
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"code-browser/internal/repo"
+	"code-browser/internal/search"
+
+	"github.com/sourcegraph/scip/bindings/go/scip"
+)
+
+// ScipEngine 基于 SCIP 索引的 "语义" 搜索引擎，实现 search.Engine:
+// 查询被当作符号名 (大小写不敏感的子串) 匹配，返回符号的定义位置。
+// 复用 Service 的 SCIP 索引加载与缓存；仓库没有 SCIP 索引时返回空结果。
+type ScipEngine struct {
+	Service *Service
+}
+
+var _ search.Engine = (*ScipEngine)(nil)
+
+// NewScipEngine 创建使用 s 加载索引的 SCIP 搜索引擎
+func NewScipEngine(s *Service) *ScipEngine {
+	return &ScipEngine{Service: s}
+}
+
+// Name 实现 search.Engine
+func (e *ScipEngine) Name() string { return search.EngineScip }
+
+// SyntaxHelp 实现 search.Engine；查询只按符号名匹配，没有其他操作符
+func (e *ScipEngine) SyntaxHelp() []search.SyntaxItem {
+	return []search.SyntaxItem{
+		{Syntax: "name", Description: "按符号名 (函数、类型、方法等的短名称) 做子字符串匹配，忽略大小写；与查询完全相同的符号排在前面", Example: "NewService"},
+		{Syntax: "/regex/, f:, lang:", Description: "不支持正则和过滤操作符；只搜索有 SCIP 索引的仓库中的定义，没有索引时返回空结果"},
+	}
+}
+
+// symbolDefinition 一个匹配查询的符号定义
+type symbolDefinition struct {
+	path  string
+	rng   []int32 // SCIP 原始范围 (0-based)
+	name  string
+	exact bool // 符号名与查询完全相同 (忽略大小写)
+}
+
+// symbolName 返回 SCIP 符号的短名称 (最后一个描述符)，局部符号和无法解析的符号返回空字符串
+func symbolName(symbol string) string {
+	if scip.IsLocalSymbol(symbol) {
+		return ""
+	}
+	parsed, err := scip.ParseSymbol(symbol)
+	if err != nil || len(parsed.Descriptors) == 0 {
+		return ""
+	}
+	return parsed.Descriptors[len(parsed.Descriptors)-1].Name
+}
+
+// findDefinitions 在仓库的 SCIP 索引中查找名称包含 query 的符号定义，按 (完全匹配优先, 路径, 行) 排序
+func (e *ScipEngine) findDefinitions(r repo.Repository, query string) ([]symbolDefinition, error) {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return nil, nil
+	}
+	scipPath := r.ScipIndexPath()
+	if _, err := os.Stat(scipPath); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	index, err := e.Service.loadSCIPIndex(scipPath)
+	if err != nil {
+		return nil, fmt.Errorf("加载 SCIP 索引失败: %w", err)
+	}
+
+	// 同一符号名只解析一次
+	names := make(map[string]string)
+	var defs []symbolDefinition
+	for _, doc := range index.Documents {
+		for _, occ := range doc.Occurrences {
+			if occ.SymbolRoles&int32(scip.SymbolRole_Definition) == 0 || len(occ.Range) < 3 {
+				continue
+			}
+			name, ok := names[occ.Symbol]
+			if !ok {
+				name = symbolName(occ.Symbol)
+				names[occ.Symbol] = name
+			}
+			lower := strings.ToLower(name)
+			if name == "" || !strings.Contains(lower, query) {
+				continue
+			}
+			defs = append(defs, symbolDefinition{path: doc.RelativePath, rng: occ.Range, name: name, exact: lower == query})
+		}
+	}
+	sort.SliceStable(defs, func(i, j int) bool {
+		if defs[i].exact != defs[j].exact {
+			return defs[i].exact
+		}
+		if defs[i].path != defs[j].path {
+			return defs[i].path < defs[j].path
+		}
+		return defs[i].rng[0] < defs[j].rng[0]
+	})
+	return defs, nil
+}
+
+// SearchContent 实现 search.Engine，每个匹配的符号定义返回一条结果 (最多 search.ZoektMaxMatchCount 条，
+// 超出时设置 opts.Truncated)。opts 中的其它选项对 SCIP 搜索无意义，被忽略
+func (e *ScipEngine) SearchContent(ctx context.Context, r repo.Repository, query string, opts search.SearchOptions) ([]search.SearchResult, error) {
+	defs, err := e.findDefinitions(r, query)
+	if err != nil {
+		return nil, err
+	}
+	if len(defs) > search.ZoektMaxMatchCount {
+		defs = defs[:search.ZoektMaxMatchCount]
+		if opts.Truncated != nil {
+			*opts.Truncated = true
+		}
+	}
+
+	lines := make(map[string][][]byte) // 每个文件只读取一次
+	results := make([]search.SearchResult, 0, len(defs))
+	for _, d := range defs {
+		res := search.SearchResult{Path: d.path, LineNum: int(d.rng[0]) + 1}
+		fileLines, ok := lines[d.path]
+		if !ok && e.Service.CoreService != nil {
+			if content, _, err := e.Service.CoreService.GetFileContent(r.RepoID, d.path); err == nil {
+				fileLines = bytes.Split(content, []byte("\n"))
+			}
+			lines[d.path] = fileLines
+		}
+		if int(d.rng[0]) < len(fileLines) {
+			res.LineText = strings.TrimRight(string(fileLines[d.rng[0]]), "\r")
+		}
+		// 单行范围 [line, startCol, endCol] 才能表示为行内片段
+		if len(d.rng) == 3 {
+			res.Fragments = []search.SearchFragment{{Offset: int(d.rng[1]), Length: int(d.rng[2] - d.rng[1])}}
+		} else {
+			res.Fragments = []search.SearchFragment{}
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// CountContent 实现 search.Engine，按文件统计匹配的符号定义数
+func (e *ScipEngine) CountContent(ctx context.Context, r repo.Repository, query string, opts search.SearchOptions) (*search.CountResult, error) {
+	defs, err := e.findDefinitions(r, query)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int)
+	for _, d := range defs {
+		counts[d.path]++
+	}
+	result := &search.CountResult{Files: make([]search.FileCount, 0, len(counts)), Total: len(defs)}
+	for path, n := range counts {
+		result.Files = append(result.Files, search.FileCount{Path: path, Count: n})
+	}
+	sort.Slice(result.Files, func(i, j int) bool { return result.Files[i].Path < result.Files[j].Path })
+	return result, nil
+}
+
+// SearchFiles 实现 search.Engine，返回定义了匹配符号的文件 (查询按符号名而非路径匹配，opts.Mode 被忽略)
+func (e *ScipEngine) SearchFiles(ctx context.Context, r repo.Repository, query string, opts search.FileSearchOptions) (*search.FileSearchResult, error) {
+	defs, err := e.findDefinitions(r, query)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(defs))
+	for _, d := range defs {
+		files = append(files, d.path)
+	}
+	return search.NewFileSearchResult(files, opts.Limit, false), nil
+}
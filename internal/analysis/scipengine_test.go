@@ -0,0 +1,108 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"code-browser/internal/lru"
+	"code-browser/internal/repo"
+	"code-browser/internal/search"
+
+	"github.com/sourcegraph/scip/bindings/go/scip"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestScipEngine(t *testing.T) {
+	const (
+		getRepo  = "scip-go gomod example 1.0 `example/repo`/Provider#GetRepo()."
+		provider = "scip-go gomod example 1.0 `example/repo`/Provider#"
+		getAll   = "scip-go gomod example 1.0 `example/repo`/Provider#GetAll()."
+	)
+	def := int32(scip.SymbolRole_Definition)
+	index := &scip.Index{Documents: []*scip.Document{
+		{RelativePath: "repo/provider.go", Occurrences: []*scip.Occurrence{
+			{Range: []int32{4, 5, 13}, Symbol: provider, SymbolRoles: def},
+			{Range: []int32{10, 19, 26}, Symbol: getRepo, SymbolRoles: def},
+			{Range: []int32{20, 19, 25}, Symbol: getAll, SymbolRoles: def},
+			{Range: []int32{30, 1, 8}, Symbol: getRepo}, // 引用，不计入
+			{Range: []int32{31, 1, 4}, Symbol: "local 3", SymbolRoles: def},
+		}},
+		{RelativePath: "cmd/getrepo.go", Occurrences: []*scip.Occurrence{
+			{Range: []int32{2, 5, 12}, Symbol: "scip-go gomod example 1.0 `example/cmd`/getRepoName().", SymbolRoles: def},
+		}},
+	}}
+
+	writeIndex := func(r repo.Repository, index *scip.Index) {
+		t.Helper()
+		data, err := proto.Marshal(index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(filepath.Dir(r.ScipIndexPath()), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(r.ScipIndexPath(), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := repo.Repository{RepoID: 1, Name: "example", DataPath: t.TempDir()}
+	writeIndex(r, index)
+
+	engine := NewScipEngine(&Service{ScipCache: lru.New(0, 0, 0)})
+	if engine.Name() != search.EngineScip {
+		t.Fatalf("Name() = %q", engine.Name())
+	}
+
+	truncated := false
+	results, err := engine.SearchContent(context.Background(), r, "getrepo", search.SearchOptions{Truncated: &truncated})
+	if err != nil || truncated {
+		t.Fatalf("SearchContent: %v (truncated=%v)", err, truncated)
+	}
+	// 完全匹配的 GetRepo 排在前面，其次是 getRepoName；引用和局部符号不返回
+	if len(results) != 2 {
+		t.Fatalf("expected 2 definitions, got %+v", results)
+	}
+	if results[0].Path != "repo/provider.go" || results[0].LineNum != 11 || results[0].Fragments[0].Offset != 19 || results[0].Fragments[0].Length != 7 {
+		t.Fatalf("unexpected first result: %+v", results[0])
+	}
+	if results[1].Path != "cmd/getrepo.go" {
+		t.Fatalf("unexpected second result: %+v", results[1])
+	}
+
+	counts, err := engine.CountContent(context.Background(), r, "provider", search.SearchOptions{})
+	if err != nil || counts.Total != 1 || len(counts.Files) != 1 {
+		t.Fatalf("CountContent = %+v, %v", counts, err)
+	}
+
+	files, err := engine.SearchFiles(context.Background(), r, "get", search.FileSearchOptions{})
+	if err != nil {
+		t.Fatalf("SearchFiles: %v", err)
+	}
+	if len(files.Files) != 2 || files.Files[0] != "cmd/getrepo.go" || files.Files[1] != "repo/provider.go" {
+		t.Fatalf("SearchFiles = %+v", files.Files)
+	}
+
+	// 超出结果数上限时截断并通过 opts.Truncated 报告，计数不受上限影响
+	var many []*scip.Occurrence
+	for i := range search.ZoektMaxMatchCount + 1 {
+		many = append(many, &scip.Occurrence{Range: []int32{int32(i), 5, 9}, Symbol: fmt.Sprintf("scip-go gomod example 1.0 `example/big`/Func%d().", i), SymbolRoles: def})
+	}
+	big := repo.Repository{RepoID: 3, Name: "big", DataPath: t.TempDir()}
+	writeIndex(big, &scip.Index{Documents: []*scip.Document{{RelativePath: "big.go", Occurrences: many}}})
+	results, err = engine.SearchContent(context.Background(), big, "func", search.SearchOptions{Truncated: &truncated})
+	if err != nil || len(results) != search.ZoektMaxMatchCount || !truncated {
+		t.Fatalf("big index: %d results, truncated=%v, err=%v", len(results), truncated, err)
+	}
+	if counts, err := engine.CountContent(context.Background(), big, "func", search.SearchOptions{}); err != nil || counts.Total != search.ZoektMaxMatchCount+1 {
+		t.Fatalf("big index: CountContent = %+v, %v", counts, err)
+	}
+
+	// 没有 SCIP 索引时返回空结果而非错误
+	empty := repo.Repository{RepoID: 2, DataPath: t.TempDir()}
+	if results, err := engine.SearchContent(context.Background(), empty, "getrepo", search.SearchOptions{}); err != nil || len(results) != 0 {
+		t.Fatalf("no index: SearchContent = %+v, %v", results, err)
+	}
+}
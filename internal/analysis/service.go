@@ -3,34 +3,45 @@ package analysis
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
-	"path/filepath"
+	"sort"
 	"strconv"
+	"time"
 	"unicode"
 
 	"code-browser/internal/core" // ★ 引入 core 包
+	"code-browser/internal/lru"
 	"code-browser/internal/repo"
 	"code-browser/internal/search"
 
-	"github.com/patrickmn/go-cache" // ★ 引入 cache 包
 	"github.com/sourcegraph/scip/bindings/go/scip"
 	"google.golang.org/protobuf/proto"
 )
 
+var (
+	// ErrRepoNotFound 请求中的仓库 ID 无法解析为已注册的仓库
+	ErrRepoNotFound = errors.New("仓库未找到")
+	// errNoSymbol 光标处没有可解析的符号 (或文件不在 SCIP 索引中)，表示 "没有结果" 而不是失败
+	errNoSymbol = errors.New("光标处未找到有效符号")
+	// ErrCacheFull 预热时 SCIP 索引缓存已没有空间，继续加载会淘汰已缓存的索引
+	ErrCacheFull = errors.New("SCIP 索引缓存已满")
+)
+
 type Service struct {
-	RepoProvider *repo.Provider
+	RepoProvider repo.RepoProvider
 	SearchEngine search.Engine
 	CoreService  *core.Service // ★ 注入 CoreService
-	ScipCache    *cache.Cache  // ★ SCIP 索引缓存
+	ScipCache    *lru.Cache    // ★ SCIP 索引缓存 (有界 LRU)
 }
 
 // NewService 创建一个新的分析服务
-func NewService(repoProvider *repo.Provider, searchEngine search.Engine, coreService *core.Service) *Service {
-	// SCIP 索引文件通常较大，但解析结构体相对较小，且访问频率高。
-	// 设置较长的过期时间，例如 1 小时。
-	scipCache := cache.New(cache.NoExpiration, cache.NoExpiration)
+// SCIP 索引文件通常较大，但解析结构体相对较小，且访问频率高。
+// scipCache 的容量与过期策略由调用方配置，超出容量时淘汰最久未使用的索引。
+func NewService(repoProvider repo.RepoProvider, searchEngine search.Engine, coreService *core.Service, scipCache *lru.Cache) *Service {
 	return &Service{
 		RepoProvider: repoProvider,
 		SearchEngine: searchEngine,
@@ -39,44 +50,116 @@ func NewService(repoProvider *repo.Provider, searchEngine search.Engine, coreSer
 	}
 }
 
-// GetDefinition 查找给定位置符号的定义
-func (s *Service) GetDefinition(req DefinitionRequest) ([]AnalysisResult, error) {
-	repoID := s.RepoProvider.GetRepoIDByString(req.RepoID)
+// resolveRepo 通过 repo.Provider 将请求中的字符串仓库 ID 解析为仓库配置
+// 仓库列表只以 Provider (SQLite) 为准，分析服务不维护自己的路径映射
+func (s *Service) resolveRepo(idStr string) (repo.Repository, error) {
+	repoID := s.RepoProvider.GetRepoIDByString(idStr)
 	if repoID == 0 {
-		return nil, fmt.Errorf("仓库 '%s' 未找到", req.RepoID)
+		return repo.Repository{}, fmt.Errorf("%w: '%s'", ErrRepoNotFound, idStr)
 	}
-
 	repoInfo, ok := s.RepoProvider.GetRepo(repoID)
 	if !ok {
-		return nil, fmt.Errorf("仓库 ID '%d' 未找到", repoID)
+		return repo.Repository{}, fmt.Errorf("%w: ID '%d'", ErrRepoNotFound, repoID)
 	}
+	if err := repoInfo.CheckEnabled(); err != nil {
+		return repo.Repository{}, err
+	}
+	return repoInfo, nil
+}
 
-	scipPath := filepath.Join(repoInfo.DataPath, "scip", "index.scip")
+// GetDefinition 查找给定位置符号的定义
+// 光标处没有符号或找不到定义时返回空切片和 nil error；仓库不存在、无法读取文件等真正的失败才返回错误
+// req.IncludePreview 为 true 时每个定义附带前后几行源码 (见 attachPreviews)。
+// 回退到搜索时使用 ctx (请求的上下文)，客户端断开后搜索随之取消；搜索名额已满时返回 search.ErrSearchBusy
+func (s *Service) GetDefinition(ctx context.Context, req DefinitionRequest) ([]AnalysisResult, error) {
+	defs, err := s.getDefinition(ctx, req)
+	if err != nil || !req.IncludePreview {
+		return defs, err
+	}
+	return s.attachPreviews(defs), nil
+}
 
-	log.Printf("DEBUG: 检查 SCIP 参数 (%s) (%d) (%d) (%s)", scipPath, req.Line, req.Character, req.RepoID)
-	// ★ 优化: 优先检查缓存，如果缓存没有再检查文件状态
-	if _, found := s.ScipCache.Get(scipPath); found {
-		return s.getDefinitionFromSCIP(scipPath, req.FilePath, req.Line, req.Character, req.RepoID)
+func (s *Service) getDefinition(ctx context.Context, req DefinitionRequest) ([]AnalysisResult, error) {
+	repoInfo, err := s.resolveRepo(req.RepoID)
+	if err != nil {
+		return nil, err
 	}
+	scipPath := repoInfo.ScipIndexPath()
 
-	if _, err := os.Stat(scipPath); err == nil {
-		log.Printf("DEBUG: 检查 SCIP 参数 (%s) (%d) (%d) (%s)", scipPath, req.Line, req.Character, req.RepoID)
-		defs, err := s.getDefinitionFromSCIP(scipPath, req.FilePath, req.Line, req.Character, req.RepoID)
+	slog.Debug("检查 SCIP 参数", "scip", scipPath, "line", req.Line, "char", req.Character, "repo", req.RepoID)
+	return s.definitionAt(ctx, repoInfo, s.currentSCIPIndex(scipPath), req)
+}
+
+// currentSCIPIndex 返回仓库当前的 SCIP 索引，没有索引或加载失败时返回 nil (调用方回退到搜索)
+func (s *Service) currentSCIPIndex(scipPath string) *scip.Index {
+	if !s.hasSCIPIndex(scipPath) {
+		return nil
+	}
+	index, err := s.loadSCIPIndex(scipPath)
+	if err != nil {
+		slog.Debug("加载 SCIP 索引失败，回退到搜索", "scip", scipPath, "err", err)
+		return nil
+	}
+	return index
+}
+
+// definitionAt 在已加载的 SCIP 索引 (可以为 nil) 中查找 req 位置的定义，
+// 索引中找不到文档或符号时同样回退到搜索
+func (s *Service) definitionAt(ctx context.Context, repoInfo repo.Repository, index *scip.Index, req DefinitionRequest) ([]AnalysisResult, error) {
+	if index != nil {
+		defs, err := definitionsInIndex(index, req.FilePath, req.Line, req.Character, req.RepoID)
 		if err == nil && len(defs) > 0 {
-			log.Printf("DEBUG: SCIP 命中定义 (%s)", req.FilePath)
+			slog.Debug("SCIP 命中定义", "file", req.FilePath)
 			return defs, nil
 		}
+		if req.CrossRepo {
+			if defs := s.crossRepoDefinitions(repoInfo.RepoID, repoInfo.ScipIndexPath(), req); len(defs) > 0 {
+				slog.Debug("其它仓库的 SCIP 索引命中定义", "file", req.FilePath)
+				return defs, nil
+			}
+		}
+		slog.Debug("SCIP 未找到定义，回退到搜索", "file", req.FilePath, "err", err)
 	}
 
-	return s.getDefinitionFromSearch(repoInfo, req.FilePath, req.Line, req.Character)
+	defs, err := s.getDefinitionFromSearch(ctx, repoInfo, req.FilePath, req.Line, req.Character)
+	return emptyIfNoSymbol(defs, err)
+}
+
+// hasSCIPIndex 判断 SCIP 索引文件是否存在 (缓存的索引在文件删除后不再有效，所以总是检查磁盘)
+func (s *Service) hasSCIPIndex(scipPath string) bool {
+	_, err := os.Stat(scipPath)
+	return err == nil
+}
+
+// emptyIfNoSymbol 把 "没有结果" (errNoSymbol 或 nil 切片) 统一为空切片，调用方序列化为 []
+func emptyIfNoSymbol(results []AnalysisResult, err error) ([]AnalysisResult, error) {
+	if errors.Is(err, errNoSymbol) {
+		return []AnalysisResult{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if results == nil {
+		results = []AnalysisResult{}
+	}
+	return results, nil
 }
 
 // getDefinitionFromSearch 使用搜索引擎尝试查找定义
-func (s *Service) getDefinitionFromSearch(repoInfo repo.Repository, filePath string, line, char int32) ([]AnalysisResult, error) {
+func (s *Service) getDefinitionFromSearch(ctx context.Context, repoInfo repo.Repository, filePath string, line, char int32) ([]AnalysisResult, error) {
+	symbol, err := s.symbolAtCursor(repoInfo, filePath, line, char)
+	if err != nil {
+		return nil, err
+	}
+	return s.searchDefinitions(ctx, repoInfo, symbol)
+}
+
+// symbolAtCursor 读取源文件并提取光标处的单词作为符号名
+func (s *Service) symbolAtCursor(repoInfo repo.Repository, filePath string, line, char int32) (string, error) {
 	// ★ 优化: 使用 CoreService 获取文件内容，利用其缓存 ★
 	content, _, err := s.CoreService.GetFileContent(repoInfo.RepoID, filePath)
 	if err != nil {
-		return nil, fmt.Errorf("无法读取源文件以提取符号: %w", err)
+		return "", fmt.Errorf("无法读取源文件以提取符号: %w", err)
 	}
 
 	// 使用 bytes.Reader 和 bufio 读取内存中的内容
@@ -95,24 +178,29 @@ func (s *Service) getDefinitionFromSearch(repoInfo repo.Repository, filePath str
 
 	symbol := extractWordAtPosition(lineText, int(char))
 	if symbol == "" {
-		return nil, fmt.Errorf("光标处未找到有效符号")
+		return "", errNoSymbol
 	}
-	log.Printf("DEBUG: Fallback 搜索符号: %s", symbol)
+	return symbol, nil
+}
+
+// searchDefinitions 使用搜索引擎按符号名查找可能的定义位置
+func (s *Service) searchDefinitions(ctx context.Context, repoInfo repo.Repository, symbol string) ([]AnalysisResult, error) {
+	slog.Debug("Fallback 搜索符号", "symbol", symbol)
 
 	var query string
-	if _, ok := s.SearchEngine.(*search.ZoektEngine); ok {
+	if s.SearchEngine.Name() == search.EngineZoekt {
 		query = fmt.Sprintf("sym:%s", symbol)
 	} else {
 		query = fmt.Sprintf("\\b%s\\b", symbol)
 	}
 
-	searchResults, err := s.SearchEngine.SearchContent(repoInfo, query)
+	searchResults, err := s.SearchEngine.SearchContent(ctx, repoInfo, query, search.SearchOptions{})
 
 	if err != nil || len(searchResults) == 0 {
-		if _, ok := s.SearchEngine.(*search.ZoektEngine); ok {
-			log.Printf("DEBUG: 符号搜索无结果，尝试纯文本全字匹配")
+		if s.SearchEngine.Name() == search.EngineZoekt {
+			slog.Debug("符号搜索无结果，尝试纯文本全字匹配", "symbol", symbol)
 			query = fmt.Sprintf("\\b%s\\b", symbol)
-			searchResults, err = s.SearchEngine.SearchContent(repoInfo, query)
+			searchResults, err = s.SearchEngine.SearchContent(ctx, repoInfo, query, search.SearchOptions{})
 		}
 	}
 
@@ -139,71 +227,160 @@ func (s *Service) getDefinitionFromSearch(repoInfo repo.Repository, filePath str
 	return definitions, nil
 }
 
-// getDefinitionFromSCIP 封装原有的 SCIP 逻辑
-func (s *Service) getDefinitionFromSCIP(scipPath, filePath string, line, char int32, repoIDStr string) ([]AnalysisResult, error) {
+// definitionsInIndex 在已加载的索引中查找位置处符号的定义
+func definitionsInIndex(index *scip.Index, filePath string, line, char int32, repoIDStr string) ([]AnalysisResult, error) {
+	slog.Debug("SCIP 搜索符号", "file", filePath, "line", line, "char", char)
+
+	targetDoc := findDocument(index, filePath)
+	if targetDoc == nil {
+		return nil, fmt.Errorf("%w: 文件不在 SCIP 索引中", errNoSymbol)
+	}
+
+	symbol := findSymbolAtPosition(targetDoc, line, char)
+	if symbol == "" {
+		return nil, errNoSymbol
+	}
+
+	return collectOccurrences(index, symbol, repoIDStr, true), nil
+}
+
+// PrewarmSCIP 把仓库的 SCIP 索引加载到缓存，仓库没有索引时返回 false。
+// 缓存放不下该索引 (按条目数或按文件大小估算) 时不加载并返回 ErrCacheFull，预热不会淘汰已缓存的索引。
+// 写入使用 ScipCache.SetIfFits，并发预热的多个仓库不会一起挤掉已有条目
+func (s *Service) PrewarmSCIP(repoInfo repo.Repository) (bool, error) {
+	scipPath := repoInfo.ScipIndexPath()
+	info, err := os.Stat(scipPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if _, ok := s.cachedSCIPIndex(scipPath); ok {
+		return true, nil
+	}
+	// 先粗略检查，明显放不下时不必解析
+	if !s.ScipCache.Fits(info.Size()) {
+		return false, ErrCacheFull
+	}
+	index, err := readSCIPIndex(scipPath)
+	if err != nil {
+		return false, err
+	}
+	// 解析期间其它预热或请求可能已占用空间，写入时再检查一次
+	if !s.ScipCache.SetIfFits(scipPath, scipCacheEntry{index: index, modTime: info.ModTime(), size: info.Size()}, info.Size()) {
+		return false, ErrCacheFull
+	}
+	return true, nil
+}
+
+// scipCacheEntry ScipCache 中的条目: 解析后的索引及加载时索引文件的修改时间和大小
+type scipCacheEntry struct {
+	index   *scip.Index
+	modTime time.Time
+	size    int64
+}
+
+// loadSCIPIndex 从缓存读取解析后的 SCIP 索引，未命中时从磁盘加载并缓存
+// 每次读取都会 stat 索引文件: 文件的修改时间或大小变化 (重新索引、上传新索引) 后重新加载，文件被删除时移除缓存。
+func (s *Service) loadSCIPIndex(scipPath string) (*scip.Index, error) {
+	info, err := os.Stat(scipPath)
+	if err != nil {
+		s.ScipCache.Delete(scipPath)
+		return nil, err
+	}
 	// ★ 优化: 从缓存读取 SCIP 索引 ★
-	var index *scip.Index
 	if data, found := s.ScipCache.Get(scipPath); found {
-		index = data.(*scip.Index)
-	} else {
-		log.Printf("DEBUG: 加载 SCIP 索引到缓存: %s", scipPath)
-		var err error
-		index, err = readSCIPIndex(scipPath)
-		if err != nil {
-			return nil, err
+		entry := data.(scipCacheEntry)
+		if entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+			return entry.index, nil
 		}
-		// 缓存解析后的对象
-		s.ScipCache.Set(scipPath, index, cache.DefaultExpiration)
+		slog.Debug("SCIP 索引文件已变化，重新加载", "scip", scipPath)
+	}
+	slog.Debug("加载 SCIP 索引到缓存", "scip", scipPath)
+	index, err := readSCIPIndex(scipPath)
+	if err != nil {
+		return nil, err
 	}
+	// 缓存解析后的对象，以索引文件大小近似其内存占用
+	s.ScipCache.Set(scipPath, scipCacheEntry{index: index, modTime: info.ModTime(), size: info.Size()}, info.Size())
+	return index, nil
+}
 
-	log.Printf("DEBUG: SCIP 搜索符号: %s (%d) (%d)", filePath, line, char)
+// cachedSCIPIndex 返回 ScipCache 中已有且与文件一致的索引，不加载、不改变缓存的使用顺序
+func (s *Service) cachedSCIPIndex(scipPath string) (*scip.Index, bool) {
+	data, found := s.ScipCache.Peek(scipPath)
+	if !found {
+		return nil, false
+	}
+	info, err := os.Stat(scipPath)
+	if err != nil {
+		return nil, false
+	}
+	entry := data.(scipCacheEntry)
+	if !entry.modTime.Equal(info.ModTime()) || entry.size != info.Size() {
+		return nil, false
+	}
+	return entry.index, true
+}
 
-	var targetDoc *scip.Document
+// findDocument 在索引中查找相对路径对应的文档
+func findDocument(index *scip.Index, filePath string) *scip.Document {
 	for _, doc := range index.Documents {
 		if doc.RelativePath == filePath {
-			targetDoc = doc
-			break
+			return doc
 		}
 	}
-	if targetDoc == nil {
-		return nil, fmt.Errorf("doc not found")
-	}
+	return nil
+}
 
-	symbol := findSymbolAtPosition(targetDoc, line, char)
-	if symbol == "" {
-		return nil, fmt.Errorf("symbol not found")
+// collectOccurrences 收集 symbol 在整个索引中的定义 (definitions=true) 或引用位置
+func collectOccurrences(index *scip.Index, symbol, repoIDStr string, definitions bool) []AnalysisResult {
+	kind := "reference"
+	if definitions {
+		kind = "definition"
 	}
 
-	var definitions []AnalysisResult
+	var results []AnalysisResult
 	for _, doc := range index.Documents {
 		for _, occ := range doc.Occurrences {
-			if occ.Symbol == symbol && (occ.SymbolRoles&int32(scip.SymbolRole_Definition) != 0) {
-				def := AnalysisResult{
-					Kind:     "definition",
-					RepoID:   repoIDStr,
-					FilePath: doc.RelativePath,
-					Range: Location{
-						StartLine:   occ.Range[0] + 1,
-						StartColumn: occ.Range[1],
-						EndLine:     occ.Range[0] + 1,
-						EndColumn:   occ.Range[1],
-						LineBase:    1,
-						ColumnBase:  0,
-					},
-					Source: "scip",
-				}
-				if len(occ.Range) == 4 {
-					def.Range.EndLine = occ.Range[2] + 1
-					def.Range.EndColumn = occ.Range[3]
-				} else if len(occ.Range) == 3 {
-					def.Range.EndLine = occ.Range[0] + 1
-					def.Range.EndColumn = occ.Range[2]
-				}
-				definitions = append(definitions, def)
+			if occ.Symbol != symbol {
+				continue
+			}
+			isDef := occ.SymbolRoles&int32(scip.SymbolRole_Definition) != 0
+			if isDef != definitions {
+				continue
 			}
+			results = append(results, occurrenceResult(doc, occ, kind, repoIDStr))
 		}
 	}
-	return definitions, nil
+	return results
+}
+
+// occurrenceResult 把 SCIP occurrence 转换为结果 (行号 1-based，列号 0-based)
+func occurrenceResult(doc *scip.Document, occ *scip.Occurrence, kind, repoIDStr string) AnalysisResult {
+	res := AnalysisResult{
+		Kind:     kind,
+		RepoID:   repoIDStr,
+		FilePath: doc.RelativePath,
+		Range: Location{
+			StartLine:   occ.Range[0] + 1,
+			StartColumn: occ.Range[1],
+			EndLine:     occ.Range[0] + 1,
+			EndColumn:   occ.Range[1],
+			LineBase:    1,
+			ColumnBase:  0,
+		},
+		Source: "scip",
+	}
+	if len(occ.Range) == 4 {
+		res.Range.EndLine = occ.Range[2] + 1
+		res.Range.EndColumn = occ.Range[3]
+	} else if len(occ.Range) == 3 {
+		res.Range.EndLine = occ.Range[0] + 1
+		res.Range.EndColumn = occ.Range[2]
+	}
+	return res
 }
 
 func readSCIPIndex(path string) (*scip.Index, error) {
@@ -227,12 +404,12 @@ func (s *Service) getDefinitionFromSCIPForTest(index *scip.Index, filePath strin
 		}
 	}
 	if targetDoc == nil {
-		return nil, fmt.Errorf("doc not found")
+		return nil, fmt.Errorf("%w: 文件不在 SCIP 索引中", errNoSymbol)
 	}
 
 	symbol := findSymbolAtPosition(targetDoc, line, char)
 	if symbol == "" {
-		return nil, fmt.Errorf("symbol not found")
+		return nil, errNoSymbol
 	}
 
 	var definitions []AnalysisResult
@@ -265,116 +442,210 @@ func (s *Service) getDefinitionFromSCIPForTest(index *scip.Index, filePath strin
 	return definitions, nil
 }
 
-// GetReferences 查找符号的引用位置
-func (s *Service) GetReferences(req DefinitionRequest) ([]AnalysisResult, error) {
-	repoID := s.RepoProvider.GetRepoIDByString(req.RepoID)
-	if repoID == 0 {
-		return nil, fmt.Errorf("仓库 '%s' 未找到", req.RepoID)
+// GetSymbolActions 解析一次光标处的符号，同时返回定义、引用数量和悬浮信息
+// 优先使用 SCIP 索引 (符号只解析一次，三类查询复用)，无索引或未命中时回退到搜索引擎
+func (s *Service) GetSymbolActions(ctx context.Context, req DefinitionRequest) (*SymbolActionsResult, error) {
+	repoInfo, err := s.resolveRepo(req.RepoID)
+	if err != nil {
+		return nil, err
 	}
-	repoInfo, ok := s.RepoProvider.GetRepo(repoID)
-	if !ok {
-		return nil, fmt.Errorf("仓库 ID '%d' 未找到", repoID)
+	scipPath := repoInfo.ScipIndexPath()
+	if _, err := os.Stat(scipPath); err == nil {
+		result, err := s.symbolActionsFromSCIP(scipPath, req)
+		if err == nil && len(result.Definitions) > 0 {
+			return result, nil
+		}
+		slog.Debug("SCIP 未能解析符号，回退到搜索", "file", req.FilePath, "line", req.Line, "char", req.Character, "err", err)
 	}
-	scipPath := filepath.Join(repoInfo.DataPath, "scip", "index.scip")
-	if _, found := s.ScipCache.Get(scipPath); found {
-		refs, err := s.getReferencesFromSCIP(scipPath, req.FilePath, req.Line, req.Character, req.RepoID)
-		if err == nil && len(refs) > 0 {
-			return refs, nil
+
+	symbol, err := s.symbolAtCursor(repoInfo, req.FilePath, req.Line, req.Character)
+	if errors.Is(err, errNoSymbol) {
+		return &SymbolActionsResult{Definitions: []AnalysisResult{}, Source: "search"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defs, err := s.searchDefinitions(ctx, repoInfo, symbol)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := s.searchReferences(ctx, repoInfo, symbol)
+	if err != nil {
+		return nil, err
+	}
+	if defs == nil {
+		defs = []AnalysisResult{}
+	}
+	return &SymbolActionsResult{
+		Definitions:    defs,
+		ReferenceCount: len(refs),
+		Hover:          &HoverInfo{Symbol: symbol, DisplayName: symbol},
+		Source:         "search",
+	}, nil
+}
+
+// symbolActionsFromSCIP 在 SCIP 索引中解析符号并复用于定义、引用计数和悬浮信息
+func (s *Service) symbolActionsFromSCIP(scipPath string, req DefinitionRequest) (*SymbolActionsResult, error) {
+	index, err := s.loadSCIPIndex(scipPath)
+	if err != nil {
+		return nil, err
+	}
+	targetDoc := findDocument(index, req.FilePath)
+	if targetDoc == nil {
+		return nil, fmt.Errorf("%w: 文件不在 SCIP 索引中", errNoSymbol)
+	}
+	symbol := findSymbolAtPosition(targetDoc, req.Line, req.Character)
+	if symbol == "" {
+		return nil, errNoSymbol
+	}
+
+	hover := &HoverInfo{Symbol: symbol}
+	if info := findSymbolInformation(index, symbol); info != nil {
+		hover.DisplayName = info.DisplayName
+		hover.Documentation = info.Documentation
+		if info.Kind != scip.SymbolInformation_UnspecifiedKind {
+			hover.Kind = info.Kind.String()
 		}
 	}
-	if _, err := os.Stat(scipPath); err == nil {
+	hover.EnclosingSymbol = enclosingDisplayName(index, targetDoc, req.Line, req.Character, symbol)
+
+	return &SymbolActionsResult{
+		Definitions:    collectOccurrences(index, symbol, req.RepoID, true),
+		ReferenceCount: len(collectOccurrences(index, symbol, req.RepoID, false)),
+		Hover:          hover,
+		Source:         "scip",
+	}, nil
+}
+
+// findSymbolInformation 在各文档及外部符号表中查找符号的元信息
+func findSymbolInformation(index *scip.Index, symbol string) *scip.SymbolInformation {
+	for _, doc := range index.Documents {
+		for _, info := range doc.Symbols {
+			if info.Symbol == symbol {
+				return info
+			}
+		}
+	}
+	for _, info := range index.ExternalSymbols {
+		if info.Symbol == symbol {
+			return info
+		}
+	}
+	return nil
+}
+
+// GetReferences 查找符号的引用位置，"没有结果" 的约定同 GetDefinition
+func (s *Service) GetReferences(ctx context.Context, req DefinitionRequest) ([]AnalysisResult, error) {
+	repoInfo, err := s.resolveRepo(req.RepoID)
+	if err != nil {
+		return nil, err
+	}
+	scipPath := repoInfo.ScipIndexPath()
+	if s.hasSCIPIndex(scipPath) {
 		refs, err := s.getReferencesFromSCIP(scipPath, req.FilePath, req.Line, req.Character, req.RepoID)
 		if err == nil && len(refs) > 0 {
 			return refs, nil
 		}
 	}
-	return s.getReferencesFromSearch(repoInfo, req.FilePath, req.Line, req.Character)
+	refs, err := s.getReferencesFromSearch(ctx, repoInfo, req.FilePath, req.Line, req.Character)
+	return emptyIfNoSymbol(refs, err)
 }
 
-func (s *Service) getReferencesFromSCIP(scipPath, filePath string, line, char int32, repoIDStr string) ([]AnalysisResult, error) {
-	var index *scip.Index
-	if data, found := s.ScipCache.Get(scipPath); found {
-		index = data.(*scip.Index)
-	} else {
-		var err error
-		index, err = readSCIPIndex(scipPath)
-		if err != nil {
-			return nil, err
+// DefaultReferencesLimit 分组引用结果每页默认返回的引用条数
+const DefaultReferencesLimit = 100
+
+// MaxReferencesLimit 分组引用结果每页最多返回的引用条数
+const MaxReferencesLimit = 1000
+
+// GroupReferences 将引用按 (文件路径, 位置) 排序后按文件分组，
+// offset/limit 作用于过滤后的展开列表，每组的 Count 为该文件的引用总数
+func GroupReferences(refs []AnalysisResult, q ReferencesQuery) *ReferencesPage {
+	if q.Limit <= 0 {
+		q.Limit = DefaultReferencesLimit
+	}
+	if q.Limit > MaxReferencesLimit {
+		q.Limit = MaxReferencesLimit
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+
+	filtered := make([]AnalysisResult, 0, len(refs))
+	for _, ref := range refs {
+		if q.FilePath == "" || ref.FilePath == q.FilePath {
+			filtered = append(filtered, ref)
 		}
-		s.ScipCache.Set(scipPath, index, cache.DefaultExpiration)
 	}
-	var targetDoc *scip.Document
-	for _, doc := range index.Documents {
-		if doc.RelativePath == filePath {
-			targetDoc = doc
-			break
+	sort.SliceStable(filtered, func(i, j int) bool {
+		a, b := filtered[i], filtered[j]
+		if a.FilePath != b.FilePath {
+			return a.FilePath < b.FilePath
 		}
+		if a.Range.StartLine != b.Range.StartLine {
+			return a.Range.StartLine < b.Range.StartLine
+		}
+		return a.Range.StartColumn < b.Range.StartColumn
+	})
+
+	counts := make(map[string]int)
+	for _, ref := range filtered {
+		counts[ref.FilePath]++
 	}
-	if targetDoc == nil {
-		return nil, fmt.Errorf("doc not found")
+
+	page := &ReferencesPage{Groups: []ReferenceGroup{}, Total: len(filtered), Offset: q.Offset, Limit: q.Limit}
+	if len(filtered) > 0 {
+		page.Source = filtered[0].Source
 	}
-	symbol := findSymbolAtPosition(targetDoc, line, char)
-	if symbol == "" {
-		return nil, fmt.Errorf("symbol not found")
+	if q.Offset >= len(filtered) {
+		return page
 	}
-	var results []AnalysisResult
-	for _, doc := range index.Documents {
-		for _, occ := range doc.Occurrences {
-			if occ.Symbol == symbol && (occ.SymbolRoles&int32(scip.SymbolRole_Definition) == 0) {
-				res := AnalysisResult{
-					Kind:     "reference",
-					RepoID:   repoIDStr,
-					FilePath: doc.RelativePath,
-					Range: Location{
-						StartLine:   occ.Range[0] + 1,
-						StartColumn: occ.Range[1],
-						EndLine:     occ.Range[0] + 1,
-						EndColumn:   occ.Range[1],
-						LineBase:    1,
-						ColumnBase:  0,
-					},
-					Source: "scip",
-				}
-				if len(occ.Range) == 4 {
-					res.Range.EndLine = occ.Range[2] + 1
-					res.Range.EndColumn = occ.Range[3]
-				} else if len(occ.Range) == 3 {
-					res.Range.EndLine = occ.Range[0] + 1
-					res.Range.EndColumn = occ.Range[2]
-				}
-				results = append(results, res)
-			}
+	end := q.Offset + q.Limit
+	if end > len(filtered) {
+		end = len(filtered)
+	}
+	for _, ref := range filtered[q.Offset:end] {
+		n := len(page.Groups)
+		if n == 0 || page.Groups[n-1].FilePath != ref.FilePath {
+			page.Groups = append(page.Groups, ReferenceGroup{FilePath: ref.FilePath, Count: counts[ref.FilePath]})
+			n++
 		}
+		page.Groups[n-1].Ranges = append(page.Groups[n-1].Ranges, ref.Range)
 	}
-	return results, nil
+	return page
 }
 
-func (s *Service) getReferencesFromSearch(repoInfo repo.Repository, filePath string, line, char int32) ([]AnalysisResult, error) {
-	content, _, err := s.CoreService.GetFileContent(repoInfo.RepoID, filePath)
+func (s *Service) getReferencesFromSCIP(scipPath, filePath string, line, char int32, repoIDStr string) ([]AnalysisResult, error) {
+	index, err := s.loadSCIPIndex(scipPath)
 	if err != nil {
-		return nil, fmt.Errorf("无法读取源文件以提取符号: %w", err)
+		return nil, err
 	}
-	reader := bytes.NewReader(content)
-	scanner := bufio.NewScanner(reader)
-	currentLine := int32(0)
-	var lineText string
-	for scanner.Scan() {
-		if currentLine == line {
-			lineText = scanner.Text()
-			break
-		}
-		currentLine++
+	targetDoc := findDocument(index, filePath)
+	if targetDoc == nil {
+		return nil, fmt.Errorf("%w: 文件不在 SCIP 索引中", errNoSymbol)
 	}
-	symbol := extractWordAtPosition(lineText, int(char))
+	symbol := findSymbolAtPosition(targetDoc, line, char)
 	if symbol == "" {
-		return nil, fmt.Errorf("光标处未找到有效符号")
+		return nil, errNoSymbol
+	}
+	return collectOccurrences(index, symbol, repoIDStr, false), nil
+}
+
+func (s *Service) getReferencesFromSearch(ctx context.Context, repoInfo repo.Repository, filePath string, line, char int32) ([]AnalysisResult, error) {
+	symbol, err := s.symbolAtCursor(repoInfo, filePath, line, char)
+	if err != nil {
+		return nil, err
 	}
+	return s.searchReferences(ctx, repoInfo, symbol)
+}
+
+// searchReferences 使用搜索引擎按符号名进行全字匹配，作为引用结果
+func (s *Service) searchReferences(ctx context.Context, repoInfo repo.Repository, symbol string) ([]AnalysisResult, error) {
 	query := fmt.Sprintf("\\b%s\\b", symbol)
-	if _, ok := s.SearchEngine.(*search.ZoektEngine); ok {
+	if s.SearchEngine.Name() == search.EngineZoekt {
 		// Zoekt can use sym: for symbol-aware searches but references vary; use text fallback
 		query = fmt.Sprintf("\\b%s\\b", symbol)
 	}
-	results, err := s.SearchEngine.SearchContent(repoInfo, query)
+	results, err := s.SearchEngine.SearchContent(ctx, repoInfo, query, search.SearchOptions{})
 	if err != nil {
 		return nil, err
 	}
@@ -397,38 +668,70 @@ func (s *Service) getReferencesFromSearch(repoInfo repo.Repository, filePath str
 
 func findSymbolAtPosition(doc *scip.Document, line, char int32) string {
 	for _, occ := range doc.Occurrences {
-		startLine := occ.Range[0]
-		startChar := occ.Range[1]
-		endLine := startLine
-		endChar := int32(0)
-		if len(occ.Range) == 3 {
-			endChar = occ.Range[2]
-		} else {
-			endLine = occ.Range[2]
-			endChar = occ.Range[3]
+		if rangeContains(occ.Range, line, char) {
+			return occ.Symbol
+		}
+	}
+	return ""
+}
+
+// scipRangeBounds 展开 SCIP 范围: [line, startChar, endChar] 或 [startLine, startChar, endLine, endChar]
+func scipRangeBounds(rng []int32) (startLine, startChar, endLine, endChar int32, ok bool) {
+	switch len(rng) {
+	case 3:
+		return rng[0], rng[1], rng[0], rng[2], true
+	case 4:
+		return rng[0], rng[1], rng[2], rng[3], true
+	}
+	return 0, 0, 0, 0, false
+}
+
+// rangeContains 判断 (line, char) 是否落在 SCIP 范围内 (结束位置不包含)
+func rangeContains(rng []int32, line, char int32) bool {
+	startLine, startChar, endLine, endChar, ok := scipRangeBounds(rng)
+	if !ok || line < startLine || line > endLine {
+		return false
+	}
+	if line == startLine && char < startChar {
+		return false
+	}
+	if line == endLine && char >= endChar {
+		return false
+	}
+	return true
+}
+
+// enclosingDisplayName 返回包含该位置的最内层定义的展示名称 (优先 SymbolInformation.DisplayName，否则取符号短名)
+func enclosingDisplayName(index *scip.Index, doc *scip.Document, line, char int32, symbol string) string {
+	enclosing := findEnclosingDefinition(doc, line, char, symbol)
+	if enclosing == "" {
+		return ""
+	}
+	if info := findSymbolInformation(index, enclosing); info != nil && info.DisplayName != "" {
+		return info.DisplayName
+	}
+	return symbolName(enclosing)
+}
+
+// findEnclosingDefinition 在文档的定义中查找包含该位置的最内层作用域 (函数、类等)，返回其符号
+// 使用定义的 EnclosingRange (整个定义体的范围)；exclude 为光标处的符号本身，局部符号被忽略
+func findEnclosingDefinition(doc *scip.Document, line, char int32, exclude string) string {
+	best := ""
+	var bestLine, bestChar int32
+	for _, occ := range doc.Occurrences {
+		if occ.SymbolRoles&int32(scip.SymbolRole_Definition) == 0 || occ.Symbol == exclude || scip.IsLocalSymbol(occ.Symbol) {
+			continue
 		}
-		if line < startLine || line > endLine {
+		if !rangeContains(occ.EnclosingRange, line, char) {
 			continue
 		}
-		if startLine == endLine {
-			if char >= startChar && char < endChar {
-				return occ.Symbol
-			}
-		} else {
-			if line == startLine {
-				if char >= startChar {
-					return occ.Symbol
-				}
-			} else if line == endLine {
-				if char < endChar {
-					return occ.Symbol
-				}
-			} else {
-				return occ.Symbol
-			}
+		// 嵌套的作用域中起点最靠后的就是最内层
+		startLine, startChar, _, _, _ := scipRangeBounds(occ.EnclosingRange)
+		if best == "" || startLine > bestLine || (startLine == bestLine && startChar > bestChar) {
+			best, bestLine, bestChar = occ.Symbol, startLine, startChar
 		}
 	}
-	return ""
+	return best
 }
 
 func extractWordAtPosition(lineText string, column int) string {
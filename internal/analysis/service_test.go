@@ -1,8 +1,29 @@
 package analysis
 
 import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "slices"
+    "strings"
     "testing"
+    "time"
+
+    "code-browser/internal/core"
+    "code-browser/internal/lru"
+    "code-browser/internal/repo"
+    "code-browser/internal/repo/repotest"
+    "code-browser/internal/search"
+    "github.com/go-git/go-git/v5"
+    "github.com/go-git/go-git/v5/plumbing/object"
+    "github.com/patrickmn/go-cache"
     "github.com/sourcegraph/scip/bindings/go/scip"
+    "google.golang.org/protobuf/proto"
 )
 
 func TestGetDefinitionFromSCIP_LineNumberMapping_SingleLine(t *testing.T) {
@@ -59,3 +80,651 @@ func TestGetDefinitionFromSCIP_LineNumberMapping_MultiLine(t *testing.T) {
         t.Fatalf("columns mismatch: %d..%d", d.Range.StartColumn, d.Range.EndColumn)
     }
 }
+
+func TestConvertBase(t *testing.T) {
+    results := []AnalysisResult{
+        // 单行: SCIP [0, 1, 5] 映射后
+        {Kind: "definition", FilePath: "a.go", Range: Location{StartLine: 1, StartColumn: 1, EndLine: 1, EndColumn: 5, LineBase: 1, ColumnBase: 0}},
+        // 多行: SCIP [2, 0, 4, 3] 映射后
+        {Kind: "definition", FilePath: "b.py", Range: Location{StartLine: 3, StartColumn: 0, EndLine: 5, EndColumn: 3, LineBase: 1, ColumnBase: 0}},
+    }
+
+    cases := []struct {
+        base string
+        want []Location
+    }{
+        {"", []Location{results[0].Range, results[1].Range}},
+        {BaseOneBased, []Location{results[0].Range, results[1].Range}},
+        {BaseZeroBased, []Location{
+            {StartLine: 0, StartColumn: 1, EndLine: 0, EndColumn: 5, LineBase: 0, ColumnBase: 0},
+            {StartLine: 2, StartColumn: 0, EndLine: 4, EndColumn: 3, LineBase: 0, ColumnBase: 0},
+        }},
+        {BaseLSP, []Location{
+            {StartLine: 0, StartColumn: 1, EndLine: 0, EndColumn: 5, LineBase: 0, ColumnBase: 0},
+            {StartLine: 2, StartColumn: 0, EndLine: 4, EndColumn: 3, LineBase: 0, ColumnBase: 0},
+        }},
+    }
+    for _, c := range cases {
+        got, err := ConvertBase(results, c.base)
+        if err != nil {
+            t.Fatalf("base %q: unexpected error: %v", c.base, err)
+        }
+        for i := range got {
+            if got[i].Range != c.want[i] {
+                t.Errorf("base %q: result %d = %+v, want %+v", c.base, i, got[i].Range, c.want[i])
+            }
+        }
+    }
+    // 原切片不应被修改
+    if results[0].Range.StartLine != 1 || results[1].Range.LineBase != 1 {
+        t.Fatalf("ConvertBase modified its input: %+v", results)
+    }
+
+    if _, err := ConvertBase(results, "2based"); !errors.Is(err, ErrInvalidBase) {
+        t.Fatalf("expected ErrInvalidBase, got %v", err)
+    }
+}
+
+func TestGroupReferences_PaginatesFlattenedList(t *testing.T) {
+    ref := func(file string, line int32) AnalysisResult {
+        return AnalysisResult{Kind: "reference", FilePath: file, Range: Location{StartLine: line}, Source: "scip"}
+    }
+    refs := []AnalysisResult{ref("b.go", 3), ref("a.go", 7), ref("b.go", 1), ref("a.go", 2), ref("c.go", 5)}
+
+    page := GroupReferences(refs, ReferencesQuery{Offset: 1, Limit: 2})
+    if page.Total != 5 || page.Offset != 1 || page.Limit != 2 || page.Source != "scip" {
+        t.Fatalf("unexpected page header: %+v", page)
+    }
+    // 展开顺序: a.go:2, a.go:7, b.go:1, b.go:3, c.go:5 → 第 1~2 条为 a.go:7, b.go:1
+    if len(page.Groups) != 2 {
+        t.Fatalf("expected 2 groups, got %+v", page.Groups)
+    }
+    if g := page.Groups[0]; g.FilePath != "a.go" || g.Count != 2 || len(g.Ranges) != 1 || g.Ranges[0].StartLine != 7 {
+        t.Fatalf("unexpected first group: %+v", g)
+    }
+    if g := page.Groups[1]; g.FilePath != "b.go" || g.Count != 2 || len(g.Ranges) != 1 || g.Ranges[0].StartLine != 1 {
+        t.Fatalf("unexpected second group: %+v", g)
+    }
+
+    page = GroupReferences(refs, ReferencesQuery{FilePath: "b.go"})
+    if page.Total != 2 || len(page.Groups) != 1 || len(page.Groups[0].Ranges) != 2 {
+        t.Fatalf("filePath filter not applied: %+v", page)
+    }
+
+    page = GroupReferences(refs, ReferencesQuery{Offset: 10})
+    if page.Total != 5 || len(page.Groups) != 0 || page.Limit != DefaultReferencesLimit {
+        t.Fatalf("offset past end should return an empty page: %+v", page)
+    }
+}
+
+func TestResolveRepo_UsesProvider(t *testing.T) {
+    p, err := repo.NewProvider(t.TempDir())
+    if err != nil {
+        t.Fatalf("NewProvider: %v", err)
+    }
+    defer p.Close()
+    if _, err := p.AddRepository(7, "seven", t.TempDir(), false); err != nil {
+        t.Fatalf("AddRepository: %v", err)
+    }
+    s := &Service{RepoProvider: p}
+
+    got, err := s.resolveRepo("7")
+    if err != nil {
+        t.Fatalf("resolveRepo: %v", err)
+    }
+    want, _ := p.GetRepo(7)
+    if got.RepoID != 7 || got.DataPath != want.DataPath || got.ScipIndexPath() != want.ScipIndexPath() {
+        t.Fatalf("resolveRepo returned %+v, want provider entry %+v", got, want)
+    }
+
+    for _, id := range []string{"8", "abc", ""} {
+        if _, err := s.resolveRepo(id); err == nil {
+            t.Errorf("resolveRepo(%q) should fail for a repo unknown to the provider", id)
+        }
+    }
+    if _, err := s.GetReferences(context.Background(), DefinitionRequest{RepoID: "8", FilePath: "a.go"}); err == nil {
+        t.Errorf("GetReferences should fail for a repo unknown to the provider")
+    }
+
+    // 删除后立即不可解析，说明没有另一份仓库列表
+    if err := p.DeleteRepository(7); err != nil {
+        t.Fatalf("DeleteRepository: %v", err)
+    }
+    if _, err := s.resolveRepo("7"); err == nil {
+        t.Errorf("resolveRepo should fail after the repo is deleted from the provider")
+    }
+}
+
+// recordingEngine 以任意名称出现的引擎 (例如包装了 Zoekt 的引擎)，记录收到的查询
+type recordingEngine struct {
+    name    string
+    queries []string
+    ctx     context.Context // 最近一次搜索收到的上下文
+    err     error           // 非 nil 时搜索返回该错误
+}
+
+func (e *recordingEngine) Name() string { return e.name }
+
+func (e *recordingEngine) SearchContent(ctx context.Context, r repo.Repository, query string, opts search.SearchOptions) ([]search.SearchResult, error) {
+    e.queries = append(e.queries, query)
+    e.ctx = ctx
+    return nil, e.err
+}
+
+func (e *recordingEngine) CountContent(ctx context.Context, r repo.Repository, query string, opts search.SearchOptions) (*search.CountResult, error) {
+    return &search.CountResult{}, nil
+}
+
+func (e *recordingEngine) SyntaxHelp() []search.SyntaxItem { return nil }
+
+func (e *recordingEngine) SearchFiles(ctx context.Context, r repo.Repository, query string, opts search.FileSearchOptions) (*search.FileSearchResult, error) {
+    return &search.FileSearchResult{}, nil
+}
+
+func TestSearchDefinitions_QuerySyntaxFollowsEngineName(t *testing.T) {
+    cases := map[string][]string{
+        search.EngineZoekt:   {"sym:Foo", `\bFoo\b`}, // 符号查询无结果时回退到全字匹配
+        search.EngineRipgrep: {`\bFoo\b`},
+    }
+    for name, want := range cases {
+        engine := &recordingEngine{name: name}
+        s := &Service{SearchEngine: engine}
+        if _, err := s.searchDefinitions(context.Background(), repo.Repository{RepoID: 1}, "Foo"); err != nil {
+            t.Fatalf("%s: searchDefinitions: %v", name, err)
+        }
+        if len(engine.queries) != len(want) {
+            t.Fatalf("%s: queries = %q, want %q", name, engine.queries, want)
+        }
+        for i := range want {
+            if engine.queries[i] != want[i] {
+                t.Fatalf("%s: queries = %q, want %q", name, engine.queries, want)
+            }
+        }
+    }
+}
+
+// newAnalysisHandlers 创建包含一个 git 仓库 (ID 1) 的分析服务，搜索引擎不返回任何结果
+func newAnalysisHandlers(t *testing.T, files map[string]string) *Handlers {
+    t.Helper()
+    src := t.TempDir()
+    r, err := git.PlainInit(src, false)
+    if err != nil {
+        t.Fatalf("PlainInit: %v", err)
+    }
+    wt, err := r.Worktree()
+    if err != nil {
+        t.Fatalf("Worktree: %v", err)
+    }
+    for name, content := range files {
+        if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0644); err != nil {
+            t.Fatal(err)
+        }
+        if _, err := wt.Add(name); err != nil {
+            t.Fatalf("Add %s: %v", name, err)
+        }
+    }
+    sig := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+    if _, err := wt.Commit("init", &git.CommitOptions{Author: sig}); err != nil {
+        t.Fatalf("Commit: %v", err)
+    }
+
+    provider := repotest.New(repo.Repository{RepoID: 1, Name: "a", SourcePath: src, DataPath: t.TempDir()})
+    coreService := core.NewService(provider, cache.New(time.Minute, time.Minute), lru.New(0, 0, 0))
+    s := NewService(provider, &recordingEngine{name: search.EngineRipgrep}, coreService, lru.New(0, 0, 0))
+    return &Handlers{Service: s}
+}
+
+func TestDefinitionHandlers_SearchContextAndBusy(t *testing.T) {
+    h := newAnalysisHandlers(t, map[string]string{"main.go": "package main\n\nfunc Foo() {}\n"})
+    engine := h.Service.SearchEngine.(*recordingEngine)
+
+    // 回退搜索使用请求的上下文
+    type ctxKey struct{}
+    ctx := context.WithValue(context.Background(), ctxKey{}, "request")
+    if _, err := h.Service.GetDefinition(ctx, DefinitionRequest{RepoID: "1", FilePath: "main.go", Line: 2, Character: 6}); err != nil {
+        t.Fatalf("GetDefinition: %v", err)
+    }
+    if engine.ctx == nil || engine.ctx.Value(ctxKey{}) != "request" {
+        t.Fatalf("search did not receive the request context")
+    }
+
+    // 搜索名额已满时返回 503 并带 Retry-After
+    engine.err = search.ErrSearchBusy
+    for name, tc := range map[string]struct {
+        handler      http.HandlerFunc
+        target, body string
+    }{
+        "definitions":        {h.GetDefinitionHandler, "/api/analysis/definitions", `{"repoId":"1","filePath":"main.go","line":2,"character":6}`},
+        "references":         {h.GetReferencesHandler, "/api/analysis/references", `{"repoId":"1","filePath":"main.go","line":2,"character":6}`},
+        "definitions-batch":  {h.GetDefinitionsBatchHandler, "/api/repositories/1/definitions-batch", `[{"filePath":"main.go","line":2,"character":6}]`},
+    } {
+        req := httptest.NewRequest(http.MethodPost, tc.target, bytes.NewBufferString(tc.body))
+        req.SetPathValue("id", "1")
+        rec := httptest.NewRecorder()
+        tc.handler(rec, req)
+        if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+            t.Errorf("%s: status = %d, Retry-After = %q", name, rec.Code, rec.Header().Get("Retry-After"))
+        }
+    }
+}
+
+func TestDefinitionHandlers_NoResultIsNotAnError(t *testing.T) {
+    h := newAnalysisHandlers(t, map[string]string{"main.go": "package main\n\nfunc Foo() {}\n"})
+
+    post := func(handler http.HandlerFunc, target, body string) *httptest.ResponseRecorder {
+        t.Helper()
+        rec := httptest.NewRecorder()
+        handler(rec, httptest.NewRequest(http.MethodPost, target, bytes.NewBufferString(body)))
+        return rec
+    }
+
+    cases := []struct {
+        name    string
+        handler http.HandlerFunc
+        target  string
+        body    string
+        status  int
+        want    string // 期望的响应体 (仅检查 200 的情况)
+    }{
+        // 光标在空行上: 没有符号
+        {"definition/no symbol", h.GetDefinitionHandler, "/", `{"repoId":"1","filePath":"main.go","line":1,"character":0}`, http.StatusOK, "[]"},
+        // 有符号但搜索引擎没有结果
+        {"definition/no match", h.GetDefinitionHandler, "/", `{"repoId":"1","filePath":"main.go","line":2,"character":6}`, http.StatusOK, "[]"},
+        {"references/no symbol", h.GetReferencesHandler, "/?flat=true", `{"repoId":"1","filePath":"main.go","line":1,"character":0}`, http.StatusOK, "[]"},
+        // 真正的失败仍返回错误
+        {"definition/unknown repo", h.GetDefinitionHandler, "/", `{"repoId":"9","filePath":"main.go"}`, http.StatusNotFound, ""},
+        {"definition/missing file", h.GetDefinitionHandler, "/", `{"repoId":"1","filePath":"missing.go"}`, http.StatusInternalServerError, ""},
+        {"references/unknown repo", h.GetReferencesHandler, "/", `{"repoId":"9","filePath":"main.go"}`, http.StatusNotFound, ""},
+        {"definition/invalid base", h.GetDefinitionHandler, "/", `{"repoId":"1","filePath":"main.go","base":"2based"}`, http.StatusBadRequest, ""},
+    }
+    for _, c := range cases {
+        rec := post(c.handler, c.target, c.body)
+        if rec.Code != c.status {
+            t.Errorf("%s: status = %d, want %d (%s)", c.name, rec.Code, c.status, rec.Body.String())
+            continue
+        }
+        if c.want != "" && strings.TrimSpace(rec.Body.String()) != c.want {
+            t.Errorf("%s: body = %q, want %q", c.name, rec.Body.String(), c.want)
+        }
+    }
+}
+
+func TestSymbolActionsFromSCIP_EnclosingSymbol(t *testing.T) {
+    const (
+        class  = "scip-go gomod example 1.0 `example`/Server#"
+        method = "scip-go gomod example 1.0 `example`/Server#Handle()."
+        callee = "scip-go gomod example 1.0 `example`/helper()."
+    )
+    def := int32(scip.SymbolRole_Definition)
+    doc := &scip.Document{
+        RelativePath: "server.go",
+        Occurrences: []*scip.Occurrence{
+            {Range: []int32{0, 5, 11}, Symbol: class, SymbolRoles: def, EnclosingRange: []int32{0, 0, 20, 1}},
+            {Range: []int32{2, 6, 12}, Symbol: method, SymbolRoles: def, EnclosingRange: []int32{2, 1, 5, 2}},
+            {Range: []int32{3, 4, 10}, Symbol: callee},
+            {Range: []int32{30, 5, 11}, Symbol: callee, SymbolRoles: def, EnclosingRange: []int32{30, 0, 32, 1}},
+        },
+        Symbols: []*scip.SymbolInformation{{Symbol: method, DisplayName: "Server.Handle"}},
+    }
+
+    cases := []struct {
+        line, char int32
+        want       string
+    }{
+        {3, 5, "Server.Handle"}, // 方法体内: 最内层是方法 (使用 DisplayName)
+        {2, 7, "Server"},        // 方法名本身: 排除自身，得到所在的类 (无 DisplayName 时取符号短名)
+        {10, 0, "Server"},
+        {25, 0, ""}, // 不在任何定义内
+    }
+    index := &scip.Index{Documents: []*scip.Document{doc}}
+    for _, c := range cases {
+        symbol := findSymbolAtPosition(doc, c.line, c.char)
+        if got := enclosingDisplayName(index, doc, c.line, c.char, symbol); got != c.want {
+            t.Errorf("(%d,%d): enclosing = %q, want %q", c.line, c.char, got, c.want)
+        }
+    }
+
+    // 通过 symbolActionsFromSCIP 返回在 hover 中
+    scipPath := filepath.Join(t.TempDir(), "index.scip")
+    data, err := proto.Marshal(index)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(scipPath, data, 0644); err != nil {
+        t.Fatal(err)
+    }
+    s := &Service{ScipCache: lru.New(0, 0, 0)}
+    result, err := s.symbolActionsFromSCIP(scipPath, DefinitionRequest{RepoID: "1", FilePath: "server.go", Line: 3, Character: 5})
+    if err != nil {
+        t.Fatalf("symbolActionsFromSCIP: %v", err)
+    }
+    if result.Hover.EnclosingSymbol != "Server.Handle" {
+        t.Fatalf("hover = %+v", result.Hover)
+    }
+}
+
+func TestRelatedFiles_RankedByReferenceCount(t *testing.T) {
+    const (
+        helper = "scip-go gomod example 1.0 `example/util`/Helper()."
+        other  = "scip-go gomod example 1.0 `example/util`/Other()."
+        typ    = "scip-go gomod example 1.0 `example/types`/Config#"
+        self   = "scip-go gomod example 1.0 `example`/main()."
+        fmtSym = "scip-go gomod github.com/golang/go/src go1.22 fmt/Println()."
+    )
+    def := int32(scip.SymbolRole_Definition)
+    index := &scip.Index{Documents: []*scip.Document{
+        {RelativePath: "main.go", Occurrences: []*scip.Occurrence{
+            {Range: []int32{0, 5, 9}, Symbol: self, SymbolRoles: def},
+            {Range: []int32{1, 0, 6}, Symbol: typ},
+            {Range: []int32{2, 0, 6}, Symbol: helper},
+            {Range: []int32{3, 0, 6}, Symbol: other},
+            {Range: []int32{4, 0, 6}, Symbol: self},     // 本文件定义的符号不计入
+            {Range: []int32{5, 0, 6}, Symbol: fmtSym},   // 定义不在索引中
+            {Range: []int32{6, 0, 6}, Symbol: "local 1"}, // 局部符号
+        }},
+        {RelativePath: "util/util.go", Occurrences: []*scip.Occurrence{
+            {Range: []int32{0, 5, 11}, Symbol: helper, SymbolRoles: def},
+            {Range: []int32{1, 5, 10}, Symbol: other, SymbolRoles: def},
+        }},
+        {RelativePath: "types/types.go", Occurrences: []*scip.Occurrence{
+            {Range: []int32{0, 5, 11}, Symbol: typ, SymbolRoles: def},
+        }},
+    }}
+
+    got := relatedFiles(index, "main.go")
+    want := []string{"util/util.go", "types/types.go"}
+    if !slices.Equal(got, want) {
+        t.Fatalf("related = %v, want %v", got, want)
+    }
+    if got := relatedFiles(index, "missing.go"); got == nil || len(got) != 0 {
+        t.Fatalf("missing file: got %#v, want empty", got)
+    }
+
+    // 没有 SCIP 索引时返回 []
+    h := newAnalysisHandlers(t, map[string]string{"main.go": "package main\n"})
+    rec := httptest.NewRecorder()
+    req := httptest.NewRequest(http.MethodGet, "/api/repositories/1/related?path=main.go", nil)
+    req.SetPathValue("id", "1")
+    h.GetRelatedFilesHandler(rec, req)
+    if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+        t.Fatalf("no index: %d %q", rec.Code, rec.Body.String())
+    }
+}
+
+func TestLoadSCIPIndex_LRUEvictionAndReload(t *testing.T) {
+    dir := t.TempDir()
+    write := func(name, doc string) string {
+        t.Helper()
+        data, err := proto.Marshal(&scip.Index{Documents: []*scip.Document{{RelativePath: doc}}})
+        if err != nil {
+            t.Fatal(err)
+        }
+        p := filepath.Join(dir, name)
+        if err := os.WriteFile(p, data, 0644); err != nil {
+            t.Fatal(err)
+        }
+        return p
+    }
+    a, b, c := write("a.scip", "a.go"), write("b.scip", "b.go"), write("c.scip", "c.go")
+
+    s := &Service{ScipCache: lru.New(2, 0, 0)}
+    load := func(p string) *scip.Index {
+        t.Helper()
+        index, err := s.loadSCIPIndex(p)
+        if err != nil {
+            t.Fatalf("loadSCIPIndex(%s): %v", p, err)
+        }
+        return index
+    }
+    first := load(a)
+    load(b)
+    if load(a) != first {
+        t.Fatal("a should be served from cache")
+    }
+    // 缓存已满，加载 c 淘汰最久未使用的 b，最近使用过的 a 保留
+    load(c)
+    if s.ScipCache.Len() != 2 {
+        t.Fatalf("cache len = %d, want 2", s.ScipCache.Len())
+    }
+    if _, found := s.ScipCache.Get(b); found {
+        t.Fatal("b should have been evicted")
+    }
+    if load(a) != first {
+        t.Fatal("a should still be resident")
+    }
+
+    // 索引文件变化后重新加载
+    write("a.scip", "a2.go")
+    later := time.Now().Add(time.Minute)
+    if err := os.Chtimes(a, later, later); err != nil {
+        t.Fatal(err)
+    }
+    if index := load(a); index == first || index.Documents[0].RelativePath != "a2.go" {
+        t.Fatalf("changed index not reloaded: %+v", index.Documents)
+    }
+
+    // 文件删除后不再返回缓存的索引
+    if err := os.Remove(a); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := s.loadSCIPIndex(a); err == nil {
+        t.Fatal("deleted index should not be served")
+    }
+    if s.hasSCIPIndex(a) {
+        t.Fatal("hasSCIPIndex should be false after delete")
+    }
+}
+
+func TestGetDefinition_CrossRepo(t *testing.T) {
+    const (
+        // 依赖方索引中记录的是依赖的发布版本，被依赖仓库自己的索引中是开发版本
+        used    = "scip-go gomod example.com/lib v1.2.0 `example.com/lib`/Helper()."
+        defined = "scip-go gomod example.com/lib 0f1e2d3c `example.com/lib`/Helper()."
+    )
+    writeIndex := func(r repo.Repository, doc *scip.Document) {
+        t.Helper()
+        data, err := proto.Marshal(&scip.Index{Documents: []*scip.Document{doc}})
+        if err != nil {
+            t.Fatal(err)
+        }
+        if err := os.MkdirAll(filepath.Dir(r.ScipIndexPath()), 0755); err != nil {
+            t.Fatal(err)
+        }
+        if err := os.WriteFile(r.ScipIndexPath(), data, 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    h := newAnalysisHandlers(t, map[string]string{"main.go": "package main\n\nfunc main() { lib.Helper() }\n"})
+    provider := h.Service.RepoProvider.(*repotest.Provider)
+    app, _ := provider.GetRepo(1)
+    writeIndex(app, &scip.Document{RelativePath: "main.go", Occurrences: []*scip.Occurrence{
+        {Range: []int32{2, 18, 24}, Symbol: used},
+    }})
+    lib := repo.Repository{RepoID: 2, Name: "lib", SourcePath: t.TempDir(), DataPath: t.TempDir()}
+    provider.Add(lib)
+    writeIndex(lib, &scip.Document{RelativePath: "helper.go", Occurrences: []*scip.Occurrence{
+        {Range: []int32{4, 5, 11}, Symbol: defined, SymbolRoles: int32(scip.SymbolRole_Definition)},
+        {Range: []int32{9, 1, 7}, Symbol: defined},
+    }})
+    // 停用的仓库不参与查找
+    disabled := repo.Repository{RepoID: 3, Name: "lib-copy", DataPath: t.TempDir(), Disabled: true}
+    provider.Add(disabled)
+    writeIndex(disabled, &scip.Document{RelativePath: "helper.go", Occurrences: []*scip.Occurrence{
+        {Range: []int32{0, 5, 11}, Symbol: defined, SymbolRoles: int32(scip.SymbolRole_Definition)},
+    }})
+
+    req := DefinitionRequest{RepoID: "1", FilePath: "main.go", Line: 2, Character: 20}
+    defs, err := h.Service.GetDefinition(context.Background(), req)
+    if err != nil {
+        t.Fatalf("GetDefinition: %v", err)
+    }
+    if len(defs) != 0 {
+        t.Fatalf("without crossRepo: %+v", defs)
+    }
+
+    // 只查找已缓存的索引: lib 的索引尚未加载时找不到，也不会因此被加载
+    req.CrossRepo = true
+    defs, err = h.Service.GetDefinition(context.Background(), req)
+    if err != nil {
+        t.Fatalf("GetDefinition(crossRepo): %v", err)
+    }
+    if len(defs) != 0 {
+        t.Fatalf("crossRepo defs with lib not cached = %+v", defs)
+    }
+    if _, ok := h.Service.ScipCache.Peek(lib.ScipIndexPath()); ok {
+        t.Fatalf("crossRepo lookup should not load other repositories' indexes")
+    }
+    for _, r := range []repo.Repository{lib, disabled} {
+        if _, err := h.Service.loadSCIPIndex(r.ScipIndexPath()); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    defs, err = h.Service.GetDefinition(context.Background(), req)
+    if err != nil {
+        t.Fatalf("GetDefinition(crossRepo): %v", err)
+    }
+    if len(defs) != 1 || defs[0].RepoID != "2" || defs[0].FilePath != "helper.go" || defs[0].Range.StartLine != 5 || defs[0].Source != "scip" {
+        t.Fatalf("crossRepo defs = %+v", defs)
+    }
+
+    // 查询参数形式
+    rec := httptest.NewRecorder()
+    h.GetDefinitionHandler(rec, httptest.NewRequest(http.MethodPost, "/?crossRepo=true",
+        bytes.NewBufferString(`{"repoId":"1","filePath":"main.go","line":2,"character":20}`)))
+    if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"repoId":"2"`) {
+        t.Fatalf("handler: %d %s", rec.Code, rec.Body.String())
+    }
+}
+
+func TestGetDefinition_IncludePreview(t *testing.T) {
+    const sym = "scip-go gomod example 1.0 `example`/Foo()."
+    src := "package main\n\n// Foo does things\nfunc Foo() {\n\treturn\n}\n\nfunc main() { Foo() }\n"
+    h := newAnalysisHandlers(t, map[string]string{"main.go": src})
+    r, _ := h.Service.RepoProvider.GetRepo(1)
+    data, err := proto.Marshal(&scip.Index{Documents: []*scip.Document{{RelativePath: "main.go", Occurrences: []*scip.Occurrence{
+        {Range: []int32{3, 5, 8}, Symbol: sym, SymbolRoles: int32(scip.SymbolRole_Definition)},
+        {Range: []int32{7, 14, 17}, Symbol: sym},
+    }}}})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := os.MkdirAll(filepath.Dir(r.ScipIndexPath()), 0755); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(r.ScipIndexPath(), data, 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    req := DefinitionRequest{RepoID: "1", FilePath: "main.go", Line: 7, Character: 15}
+    defs, err := h.Service.GetDefinition(context.Background(), req)
+    if err != nil || len(defs) != 1 {
+        t.Fatalf("GetDefinition: %+v %v", defs, err)
+    }
+    if defs[0].Preview != nil {
+        t.Fatalf("preview without includePreview: %q", defs[0].Preview)
+    }
+
+    req.IncludePreview = true
+    defs, err = h.Service.GetDefinition(context.Background(), req)
+    if err != nil || len(defs) != 1 {
+        t.Fatalf("GetDefinition(includePreview): %+v %v", defs, err)
+    }
+    want := []string{"", "// Foo does things", "func Foo() {", "\treturn", "}"}
+    if !slices.Equal(defs[0].Preview, want) || defs[0].PreviewStartLine != 2 {
+        t.Fatalf("preview = %q (start %d), want %q (start 2)", defs[0].Preview, defs[0].PreviewStartLine, want)
+    }
+
+    // 查询参数形式，预览起始行随 base 换算
+    rec := httptest.NewRecorder()
+    h.GetDefinitionHandler(rec, httptest.NewRequest(http.MethodPost, "/?includePreview=true",
+        bytes.NewBufferString(`{"repoId":"1","filePath":"main.go","line":7,"character":15,"base":"0based"}`)))
+    if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"previewStartLine":1`) || !strings.Contains(rec.Body.String(), `"func Foo() {"`) {
+        t.Fatalf("handler: %d %s", rec.Code, rec.Body.String())
+    }
+}
+
+func TestGetDefinitionsBatch(t *testing.T) {
+    const sym = "scip-go gomod example 1.0 `example`/Foo()."
+    src := "package main\n\n// Foo does things\nfunc Foo() {\n\treturn\n}\n\nfunc main() { Foo() }\n"
+    h := newAnalysisHandlers(t, map[string]string{"main.go": src})
+    r, _ := h.Service.RepoProvider.GetRepo(1)
+    data, err := proto.Marshal(&scip.Index{Documents: []*scip.Document{{RelativePath: "main.go", Occurrences: []*scip.Occurrence{
+        {Range: []int32{3, 5, 8}, Symbol: sym, SymbolRoles: int32(scip.SymbolRole_Definition)},
+        {Range: []int32{7, 14, 17}, Symbol: sym},
+    }}}})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := os.MkdirAll(filepath.Dir(r.ScipIndexPath()), 0755); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(r.ScipIndexPath(), data, 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    post := func(target, body string) *httptest.ResponseRecorder {
+        t.Helper()
+        req := httptest.NewRequest(http.MethodPost, target, bytes.NewBufferString(body))
+        req.SetPathValue("id", "1")
+        rec := httptest.NewRecorder()
+        h.GetDefinitionsBatchHandler(rec, req)
+        return rec
+    }
+
+    // 结果与位置按下标对应；单个位置失败不影响其它位置
+    rec := post("/api/repositories/1/definitions-batch?base=0based", `[
+        {"filePath": "main.go", "line": 7, "character": 15},
+        {"filePath": "main.go", "line": 1, "character": 0},
+        {"filePath": "missing.go", "line": 0, "character": 0},
+        {"line": 3, "character": 5},
+        {"filePath": "main.go", "line": 3, "character": 6}
+    ]`)
+    if rec.Code != http.StatusOK {
+        t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+    }
+    var results []BatchDefinitionResult
+    if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil {
+        t.Fatal(err)
+    }
+    if len(results) != 5 {
+        t.Fatalf("got %d results, want 5", len(results))
+    }
+    for _, i := range []int{0, 4} {
+        defs := results[i].Definitions
+        if results[i].Error != "" || len(defs) != 1 || defs[0].Source != "scip" || defs[0].Range.StartLine != 3 || defs[0].Range.LineBase != 0 {
+            t.Fatalf("result %d = %+v", i, results[i])
+        }
+    }
+    if results[1].Error != "" || results[1].Definitions == nil || len(results[1].Definitions) != 0 {
+        t.Fatalf("blank line: %+v", results[1])
+    }
+    for _, i := range []int{2, 3} {
+        if results[i].Error == "" || results[i].Definitions == nil {
+            t.Fatalf("result %d should carry an error and empty definitions: %+v", i, results[i])
+        }
+    }
+
+    // includePreview 对所有位置生效
+    results, err = h.Service.GetDefinitionsBatch(context.Background(), DefinitionRequest{RepoID: "1", IncludePreview: true}, []BatchPosition{{FilePath: "main.go", Line: 7, Character: 15}})
+    if err != nil || len(results) != 1 || len(results[0].Definitions) != 1 || results[0].Definitions[0].Preview == nil {
+        t.Fatalf("includePreview: %+v %v", results, err)
+    }
+
+    // 请求级别的错误使整个请求失败
+    tooMany := "[" + strings.TrimSuffix(strings.Repeat(`{"filePath":"main.go"},`, MaxDefinitionsBatch+1), ",") + "]"
+    for _, tc := range []struct{ target, body string }{
+        {"/api/repositories/1/definitions-batch", tooMany},
+        {"/api/repositories/1/definitions-batch", `{"filePath":"main.go"}`},
+        {"/api/repositories/1/definitions-batch?base=2based", `[]`},
+        {"/api/repositories/1/definitions-batch?crossRepo=x", `[]`},
+    } {
+        if rec := post(tc.target, tc.body); rec.Code != http.StatusBadRequest {
+            t.Errorf("%s %.20s: status = %d, want 400", tc.target, tc.body, rec.Code)
+        }
+    }
+    if rec := post("/api/repositories/1/definitions-batch", `[]`); rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+        t.Fatalf("empty batch: %d %s", rec.Code, rec.Body.String())
+    }
+}
@@ -1,11 +1,43 @@
 package analysis
 
+import (
+	"errors"
+	"fmt"
+)
+
 // DefinitionRequest 定义了前端发起的“跳转到定义”请求结构
 type DefinitionRequest struct {
 	RepoID    string `json:"repoId"`    // 仓库 ID
 	FilePath  string `json:"filePath"`  // 文件相对路径
 	Line      int32  `json:"line"`      // 光标所在行号 (0-based)
 	Character int32  `json:"character"` // 光标所在列号 (0-based)
+	// Base 响应中位置的基准: BaseOneBased (默认) | BaseZeroBased | BaseLSP，只影响返回的 Location
+	Base string `json:"base,omitempty"`
+	// CrossRepo 当前仓库的 SCIP 索引中没有定义时，到其它仓库的 SCIP 索引中查找 (仅 definitions 使用)
+	CrossRepo bool `json:"crossRepo,omitempty"`
+	// IncludePreview 为每个定义附带其前后几行源码 (仅 definitions 使用)
+	IncludePreview bool `json:"includePreview,omitempty"`
+}
+
+// 响应位置基准
+const (
+	BaseOneBased  = "1based" // 行号从 1 开始，列号从 0 开始 (默认，兼容已有调用方)
+	BaseZeroBased = "0based" // 行号和列号都从 0 开始
+	BaseLSP       = "lsp"    // 同 BaseZeroBased，与 LSP/Monaco 的 Position 约定一致
+)
+
+// ErrInvalidBase 请求中的 base 不是支持的取值
+var ErrInvalidBase = errors.New("无效的位置基准")
+
+// parseBase 返回 base 对应的行、列基准，空字符串表示默认的 BaseOneBased
+func parseBase(base string) (lineBase, columnBase int32, err error) {
+	switch base {
+	case "", BaseOneBased:
+		return 1, 0, nil
+	case BaseZeroBased, BaseLSP:
+		return 0, 0, nil
+	}
+	return 0, 0, fmt.Errorf("%w: '%s' (支持 %s, %s, %s)", ErrInvalidBase, base, BaseOneBased, BaseZeroBased, BaseLSP)
 }
 
 // Location 定义了代码中的一个位置范围
@@ -18,6 +50,36 @@ type Location struct {
 	ColumnBase  int32 `json:"columnBase"`
 }
 
+// WithBase 返回换算到指定行、列基准后的位置，LineBase/ColumnBase 随之更新
+func (l Location) WithBase(lineBase, columnBase int32) Location {
+	dl, dc := lineBase-l.LineBase, columnBase-l.ColumnBase
+	return Location{
+		StartLine:   l.StartLine + dl,
+		StartColumn: l.StartColumn + dc,
+		EndLine:     l.EndLine + dl,
+		EndColumn:   l.EndColumn + dc,
+		LineBase:    lineBase,
+		ColumnBase:  columnBase,
+	}
+}
+
+// ConvertBase 按 base (见 BaseOneBased 等) 换算结果中的所有位置，原切片不被修改
+func ConvertBase(results []AnalysisResult, base string) ([]AnalysisResult, error) {
+	lineBase, columnBase, err := parseBase(base)
+	if err != nil {
+		return nil, err
+	}
+	converted := make([]AnalysisResult, len(results))
+	for i, r := range results {
+		if r.Preview != nil {
+			r.PreviewStartLine += lineBase - r.Range.LineBase
+		}
+		r.Range = r.Range.WithBase(lineBase, columnBase)
+		converted[i] = r
+	}
+	return converted, nil
+}
+
 // AnalysisResult 为通用的分析结果返回结构（定义/引用等）
 type AnalysisResult struct {
 	Kind     string   `json:"kind"`     // 类型: "definition" | "reference"
@@ -25,4 +87,48 @@ type AnalysisResult struct {
 	FilePath string   `json:"filePath"` // 目标文件路径
 	Range    Location `json:"range"`    // 目标代码范围
 	Source   string   `json:"source"`   // 数据来源 ("scip" | "search")
+	// Preview 定义前后的几行源码 (仅在请求 includePreview 时填充)，PreviewStartLine 为其第一行的行号，基准同 Range
+	Preview          []string `json:"preview,omitempty"`
+	PreviewStartLine int32    `json:"previewStartLine,omitempty"`
+}
+
+// HoverInfo 为悬浮提示提供的符号信息
+type HoverInfo struct {
+	Symbol        string   `json:"symbol"`                  // SCIP 符号名 (fallback 时为光标处单词)
+	DisplayName   string   `json:"displayName,omitempty"`   // 展示名称
+	Kind          string   `json:"kind,omitempty"`          // 符号类型 (如 Function, Class)
+	Documentation []string `json:"documentation,omitempty"` // 文档 (通常为 Markdown)
+	// EnclosingSymbol 光标所在的最内层函数/类等定义的展示名称，没有时为空 (搜索回退时始终为空)
+	EnclosingSymbol string `json:"enclosingSymbol"`
+}
+
+// SymbolActionsResult 为一次点击符号所需的全部信息 (定义、引用数量、悬浮提示)
+type SymbolActionsResult struct {
+	Definitions    []AnalysisResult `json:"definitions"`
+	ReferenceCount int              `json:"referenceCount"`
+	Hover          *HoverInfo       `json:"hover"`
+	Source         string           `json:"source"` // 数据来源 ("scip" | "search")
+}
+
+// ReferenceGroup 为同一文件内的引用集合，类似编辑器 "查找所有引用" 的分组展示
+type ReferenceGroup struct {
+	FilePath string     `json:"filePath"`
+	Count    int        `json:"count"`  // 该文件的引用总数 (不受分页影响)
+	Ranges   []Location `json:"ranges"` // 当前页内属于该文件的引用范围
+}
+
+// ReferencesPage 为按文件分组的分页引用结果
+type ReferencesPage struct {
+	Groups []ReferenceGroup `json:"groups"`
+	Total  int              `json:"total"`  // 过滤后引用总数 (展开后的条数)
+	Offset int              `json:"offset"` // 本页在展开列表中的起始位置
+	Limit  int              `json:"limit"`
+	Source string           `json:"source,omitempty"` // 数据来源 ("scip" | "search")
+}
+
+// ReferencesQuery 为引用分页与过滤参数
+type ReferencesQuery struct {
+	FilePath string // 只返回该文件中的引用，空表示全部
+	Offset   int
+	Limit    int // <= 0 时使用 DefaultReferencesLimit
 }
@@ -0,0 +1,79 @@
+package buildinfo
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// 以下变量可在构建时通过 -ldflags 注入 (build.sh 会自动注入)，例如:
+//
+//	go build -ldflags "-X code-browser/internal/buildinfo.Version=v1.2.0" ./cmd/server
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// Info 描述当前二进制的版本与构建信息
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"buildTime,omitempty"`
+	Modified  bool   `json:"modified,omitempty"` // 构建时工作区存在未提交的修改
+	GoVersion string `json:"goVersion"`
+}
+
+// Get 返回构建信息；未通过 -ldflags 注入的字段从 Go 内嵌的 VCS 信息中补齐
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	// go install module@version 构建时带有模块版本
+	if info.Version == "dev" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		info.Version = bi.Main.Version
+	}
+	for _, setting := range bi.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			if info.BuildTime == "" {
+				info.BuildTime = setting.Value
+			}
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	return info
+}
+
+// String 返回 -version 输出的单行描述，如 "v1.2.0 (commit 3f2a9c1b, built 2024-01-01T00:00:00Z, go1.25.0)"
+func (i Info) String() string {
+	details := []string{}
+	if i.Commit != "" {
+		commit := i.Commit
+		if len(commit) > 12 {
+			commit = commit[:12]
+		}
+		if i.Modified {
+			commit += "-dirty"
+		}
+		details = append(details, "commit "+commit)
+	}
+	if i.BuildTime != "" {
+		details = append(details, "built "+i.BuildTime)
+	}
+	details = append(details, i.GoVersion)
+	return fmt.Sprintf("%s (%s)", i.Version, strings.Join(details, ", "))
+}
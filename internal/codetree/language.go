@@ -0,0 +1,61 @@
+package codetree
+
+import (
+	"path"
+	"slices"
+	"strings"
+)
+
+// Plaintext 无法按扩展名识别语言时 DetectLanguage 返回的名称
+const Plaintext = "plaintext"
+
+// languageByExt 文件扩展名到语言名称的映射，名称与前端高亮 (Prism) 使用的一致。
+// 文件高亮、语言统计、主要语言检测和搜索的 lang: 过滤共用这一份表
+var languageByExt = map[string]string{
+	"go": "go",
+	"py": "python", "pyi": "python",
+	"js": "javascript", "jsx": "javascript", "mjs": "javascript", "cjs": "javascript",
+	"ts": "typescript", "tsx": "typescript", "mts": "typescript", "cts": "typescript",
+	"java": "java", "kt": "kotlin", "kts": "kotlin", "scala": "scala",
+	"c": "c", "h": "c",
+	"cc": "cpp", "cpp": "cpp", "cxx": "cpp", "hh": "cpp", "hpp": "cpp", "hxx": "cpp",
+	"cs": "csharp", "rs": "rust", "rb": "ruby", "php": "php",
+	"sh": "bash", "bash": "bash",
+	"html": "html", "htm": "html", "css": "css", "scss": "scss",
+	"json": "json", "md": "markdown", "markdown": "markdown", "yaml": "yaml", "yml": "yaml",
+}
+
+// extsByLanguage languageByExt 的反向映射，扩展名按字母顺序排列
+var extsByLanguage = func() map[string][]string {
+	m := make(map[string][]string)
+	for ext, lang := range languageByExt {
+		m[lang] = append(m[lang], ext)
+	}
+	for _, exts := range m {
+		slices.Sort(exts)
+	}
+	return m
+}()
+
+// nonCodeLanguages 文档、配置和样式语言，不参与主要语言检测
+var nonCodeLanguages = map[string]bool{
+	"html": true, "css": true, "scss": true, "json": true, "markdown": true, "yaml": true,
+}
+
+// DetectLanguage 根据文件扩展名推断语言名称，未知时返回 Plaintext
+func DetectLanguage(filePath string) string {
+	if lang, ok := languageByExt[strings.ToLower(strings.TrimPrefix(path.Ext(filePath), "."))]; ok {
+		return lang
+	}
+	return Plaintext
+}
+
+// IsCodeLanguage 判断语言是否为源码语言 (文档、配置、样式和 Plaintext 不是)
+func IsCodeLanguage(lang string) bool {
+	return lang != Plaintext && !nonCodeLanguages[lang]
+}
+
+// Extensions 返回识别为 lang 的扩展名 (不带点，按字母顺序)，未知语言返回 nil
+func Extensions(lang string) []string {
+	return slices.Clone(extsByLanguage[lang])
+}
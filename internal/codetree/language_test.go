@@ -0,0 +1,36 @@
+package codetree
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	for path, want := range map[string]string{
+		"main.go":          "go",
+		"app/View.TSX":     "typescript",
+		"ui/button.jsx":    "javascript",
+		"include/lib.h":    "c",
+		"src/lib.hpp":      "cpp",
+		"build.gradle.kts": "kotlin",
+		"types/stub.pyi":   "python",
+		"README.markdown":  "markdown",
+		"Makefile":         Plaintext,
+		"data.unknown":     Plaintext,
+	} {
+		if got := DetectLanguage(path); got != want {
+			t.Errorf("DetectLanguage(%q) = %q, want %q", path, got, want)
+		}
+	}
+	for lang, want := range map[string]bool{"go": true, "cpp": true, "markdown": false, "yaml": false, Plaintext: false} {
+		if got := IsCodeLanguage(lang); got != want {
+			t.Errorf("IsCodeLanguage(%q) = %v, want %v", lang, got, want)
+		}
+	}
+	if got := Extensions("typescript"); !slices.Equal(got, []string{"cts", "mts", "ts", "tsx"}) {
+		t.Errorf("Extensions(typescript) = %q", got)
+	}
+	if got := Extensions("cobol"); got != nil {
+		t.Errorf("Extensions(cobol) = %q", got)
+	}
+}
@@ -0,0 +1,40 @@
+package codetree
+
+import (
+	"path"
+	"strings"
+)
+
+// MatchPattern 判断 pattern 是否匹配 filePath 或其上级目录 (.code-browser.json 中 hiddenPaths 的规则):
+// 不含 / 的模式匹配任意一级的文件或目录名，含 / 的模式从仓库根目录匹配
+func MatchPattern(pattern, filePath string) bool {
+	pattern = strings.Trim(pattern, "/")
+	if pattern == "" {
+		return false
+	}
+	segments := strings.Split(strings.Trim(filePath, "/"), "/")
+	if !strings.Contains(pattern, "/") {
+		for _, seg := range segments {
+			if ok, _ := path.Match(pattern, seg); ok {
+				return true
+			}
+		}
+		return false
+	}
+	for i := range segments {
+		if ok, _ := path.Match(pattern, strings.Join(segments[:i+1], "/")); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchAnyPattern 判断路径或其上级目录是否匹配 patterns 中的任意一个 (规则同 MatchPattern)
+func MatchAnyPattern(patterns []string, filePath string) bool {
+	for _, pattern := range patterns {
+		if MatchPattern(pattern, filePath) {
+			return true
+		}
+	}
+	return false
+}
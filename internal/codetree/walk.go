@@ -0,0 +1,101 @@
+// Package codetree 提供 core 和 repo 共用的仓库目录树工具: 有界的遍历、按扩展名识别语言和路径模式匹配
+package codetree
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// 目录树遍历的默认限制，见 Limits
+const (
+	DefaultMaxFiles       = 200000
+	DefaultMaxBytes int64 = 8 << 30         // 8 GiB
+	DefaultTimeout        = 8 * time.Second // 低于服务端 10s 的写超时，截断的结果仍能返回给客户端
+)
+
+// 遍历被截断的原因 (Result.Reason)
+const (
+	LimitFiles   = "files"
+	LimitBytes   = "bytes"
+	LimitTimeout = "timeout"
+)
+
+// Limits 限制一次目录树遍历的规模，防止超大仓库让请求无限制地运行。零值字段使用默认值
+type Limits struct {
+	MaxFiles int           // 最多访问的文件数，默认 DefaultMaxFiles
+	MaxBytes int64         // 回调报告的字节数之和的上限，默认 DefaultMaxBytes
+	Timeout  time.Duration // 整个遍历的时间上限，默认 DefaultTimeout
+}
+
+// withDefaults 用默认值填充零值字段
+func (l Limits) withDefaults() Limits {
+	if l.MaxFiles <= 0 {
+		l.MaxFiles = DefaultMaxFiles
+	}
+	if l.MaxBytes <= 0 {
+		l.MaxBytes = DefaultMaxBytes
+	}
+	if l.Timeout <= 0 {
+		l.Timeout = DefaultTimeout
+	}
+	return l
+}
+
+// Result 遍历的统计信息
+type Result struct {
+	Files     int    // 访问的文件数
+	Bytes     int64  // 回调报告的字节数之和
+	Truncated bool   // 达到了某个限制，还有文件未访问
+	Reason    string // 截断原因: LimitFiles、LimitBytes 或 LimitTimeout
+}
+
+// Walk 按路径顺序遍历 tree 中的文件 (包括符号链接，跳过目录和 submodule)，对每个文件调用 fn。
+// fn 返回该文件计入 MaxBytes 的字节数，只列出路径、不读取内容的调用方返回 0。
+// 访问的文件数或字节数达到上限、Timeout 到期或 ctx 结束时停止，并在结果中标记 Truncated (不返回错误)；
+// fn 返回错误时中止遍历并返回该错误。git tree 中的符号链接不会被跟随，因此不存在循环
+func Walk(ctx context.Context, tree *object.Tree, limits Limits, fn func(name string, entry object.TreeEntry) (int64, error)) (Result, error) {
+	limits = limits.withDefaults()
+	ctx, cancel := context.WithTimeout(ctx, limits.Timeout)
+	defer cancel()
+
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+
+	var res Result
+	stop := func(reason string) (Result, error) {
+		res.Truncated, res.Reason = true, reason
+		return res, nil
+	}
+	for {
+		if ctx.Err() != nil {
+			return stop(LimitTimeout)
+		}
+		name, entry, err := walker.Next()
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return res, fmt.Errorf("遍历 Tree 失败: %w", err)
+		}
+		if !entry.Mode.IsFile() {
+			continue
+		}
+		// 还有文件未访问时才算截断
+		if res.Files >= limits.MaxFiles {
+			return stop(LimitFiles)
+		}
+		if res.Bytes >= limits.MaxBytes {
+			return stop(LimitBytes)
+		}
+		n, err := fn(name, entry)
+		if err != nil {
+			return res, err
+		}
+		res.Files++
+		res.Bytes += n
+	}
+}
@@ -0,0 +1,103 @@
+package codetree
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// newTestTree 把 files 提交到一个新仓库并返回 HEAD 的目录树
+func newTestTree(t *testing.T, files map[string]string) *object.Tree {
+	t.Helper()
+	dir := t.TempDir()
+	r, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("PlainInit: %v", err)
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := wt.Add(name); err != nil {
+			t.Fatalf("Add %s: %v", name, err)
+		}
+	}
+	sig := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+	hash, err := wt.Commit("init", &git.CommitOptions{Author: sig})
+	if err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	commit, err := r.CommitObject(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestWalk_Limits(t *testing.T) {
+	tree := newTestTree(t, map[string]string{
+		"a.go":     "1234567890", // 10
+		"b/b.go":   "1234567890", // 10
+		"b/c/c.go": "1234567890", // 10
+		"d.go":     "1234567890", // 10
+	})
+	walk := func(ctx context.Context, limits Limits) (Result, []string) {
+		t.Helper()
+		var names []string
+		res, err := Walk(ctx, tree, limits, func(name string, _ object.TreeEntry) (int64, error) {
+			names = append(names, name)
+			return 10, nil
+		})
+		if err != nil {
+			t.Fatalf("Walk: %v", err)
+		}
+		return res, names
+	}
+
+	// 没有达到限制: 访问所有文件 (不含目录)
+	if res, names := walk(context.Background(), Limits{}); res.Truncated || res.Files != 4 || res.Bytes != 40 || len(names) != 4 {
+		t.Fatalf("unbounded walk = %+v, %q", res, names)
+	}
+	// 文件数正好等于上限时不算截断
+	if res, _ := walk(context.Background(), Limits{MaxFiles: 4}); res.Truncated {
+		t.Fatalf("MaxFiles=4: %+v", res)
+	}
+	if res, names := walk(context.Background(), Limits{MaxFiles: 2}); !res.Truncated || res.Reason != LimitFiles || len(names) != 2 {
+		t.Fatalf("MaxFiles=2: %+v, %q", res, names)
+	}
+	if res, names := walk(context.Background(), Limits{MaxBytes: 25}); !res.Truncated || res.Reason != LimitBytes || res.Bytes != 30 || len(names) != 3 {
+		t.Fatalf("MaxBytes=25: %+v, %q", res, names)
+	}
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	if res, names := walk(expired, Limits{}); !res.Truncated || res.Reason != LimitTimeout || len(names) != 0 {
+		t.Fatalf("expired context: %+v, %q", res, names)
+	}
+	if res, _ := walk(context.Background(), Limits{Timeout: time.Nanosecond}); !res.Truncated || res.Reason != LimitTimeout {
+		t.Fatalf("Timeout=1ns: %+v", res)
+	}
+
+	// 回调的错误中止遍历
+	boom := errors.New("boom")
+	if _, err := Walk(context.Background(), tree, Limits{}, func(string, object.TreeEntry) (int64, error) { return 0, boom }); !errors.Is(err, boom) {
+		t.Fatalf("callback error: got %v", err)
+	}
+}
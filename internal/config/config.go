@@ -1,8 +1,15 @@
+// Package config 读取早期版本使用的 JSON 仓库列表 ([]Repo)。
+// 仓库的唯一数据源是 repo.Provider (SQLite)，这里只用于 repo-cli 的 import-config 迁移，
+// 不再提供按 ID 查询路径的接口，避免出现两份仓库列表。
 package config
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"strconv"
 	"sync"
 )
 
@@ -14,35 +21,139 @@ type Repo struct {
 }
 
 var (
-	loadedConfig []Repo      // 用于存储加载的配置
-	configOnce   sync.Once   // 确保配置只加载一次
-	configLock   sync.RWMutex  // 读写锁保护配置
+	loadedConfig []Repo       // 用于存储加载的配置
+	configLock   sync.RWMutex // 读写锁保护配置
 )
 
-// Load 从指定路径加载和解析 JSON 配置文件
+// Load 从指定路径加载和解析 JSON 配置文件，替换之前加载的配置
+// 每次调用都会重新读取文件 (可用于显式重新加载)；只检查 JSON 格式，条目的校验见 LoadStrict
 func Load(path string) error {
-	var loadErr error
-	configOnce.Do(func() {
-		file, err := os.ReadFile(path)
-		if err != nil {
-			loadErr = err
-			return
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var repos []Repo
+	if err := json.Unmarshal(data, &repos); err != nil {
+		return fmt.Errorf("%s: %w", describeOffset(path, data, err), err)
+	}
+	setRepos(repos)
+	return nil
+}
+
+// LoadStrict 加载配置文件并按格式校验所有条目，校验通过后替换已加载的配置
+// 校验规则: 顶层为数组；条目只能包含 id、name、path 字段且都不能为空；
+// id 为 1 到 4294967295 之间的整数且不重复；path 为已存在的目录。
+// 返回的错误用 errors.Join 列出所有问题，每个问题以 "<文件>:<行>:" 开头
+func LoadStrict(path string) ([]Repo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	repos, err := validate(path, data)
+	if err != nil {
+		return nil, err
+	}
+	setRepos(repos)
+	return repos, nil
+}
+
+func setRepos(repos []Repo) {
+	configLock.Lock()
+	loadedConfig = repos
+	configLock.Unlock()
+}
+
+// validate 逐个解析数组中的条目，收集格式和内容问题
+func validate(path string, data []byte) ([]Repo, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("%s: %w", describeOffset(path, data, err), err)
+	} else if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return nil, fmt.Errorf("%s:%d: 顶层必须是仓库数组", path, lineAt(data, 0))
+	}
+
+	var errs []error
+	var repos []Repo
+	seen := make(map[uint64]int) // id → 首次出现的行号
+	for i := 1; dec.More(); i++ {
+		line := lineAt(data, dec.InputOffset())
+		fail := func(format string, args ...any) {
+			errs = append(errs, fmt.Errorf("%s:%d: 仓库 #%d: %s", path, line, i, fmt.Sprintf(format, args...)))
 		}
 
-		var repos []Repo
-		if err := json.Unmarshal(file, &repos); err != nil {
-			loadErr = err
-			return
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			// 语法错误之后的内容无法继续解析
+			errs = append(errs, fmt.Errorf("%s: %w", describeOffset(path, data, err), err))
+			return nil, errors.Join(errs...)
+		}
+		var r Repo
+		entry := json.NewDecoder(bytes.NewReader(raw))
+		entry.DisallowUnknownFields()
+		if err := entry.Decode(&r); err != nil {
+			fail("%v", err)
+			continue
 		}
 
-		configLock.Lock()
-		loadedConfig = repos
-		configLock.Unlock()
-	})
-	return loadErr
+		if r.ID == "" {
+			fail("缺少 id")
+		} else if id, err := strconv.ParseUint(r.ID, 10, 32); err != nil || id == 0 {
+			fail("无效的 id '%s' (必须是 1-4294967295 之间的整数)", r.ID)
+		} else if first, dup := seen[id]; dup {
+			fail("id '%s' 与第 %d 行的仓库重复", r.ID, first)
+		} else {
+			seen[id] = line
+		}
+		if r.Name == "" {
+			fail("缺少 name")
+		}
+		if r.Path == "" {
+			fail("缺少 path")
+		} else if info, err := os.Stat(r.Path); err != nil {
+			fail("path '%s' 不可用: %v", r.Path, err)
+		} else if !info.IsDir() {
+			fail("path '%s' 不是目录", r.Path)
+		}
+		repos = append(repos, r)
+	}
+	if _, err := dec.Token(); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %w", describeOffset(path, data, err), err))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return repos, nil
+}
+
+// describeOffset 返回 "<文件>:<行>:<列>" 形式的错误位置，err 不带偏移量时只返回文件名
+func describeOffset(path string, data []byte, err error) string {
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	default:
+		return path
+	}
+	// Offset 是出错时已读取的字节数，出错的字符是最后读取的那个
+	offset = min(max(offset-1, 0), int64(len(data)))
+	line := bytes.Count(data[:offset], []byte("\n")) + 1
+	col := int(offset) - bytes.LastIndexByte(data[:offset], '\n')
+	return fmt.Sprintf("%s:%d:%d", path, line, col)
 }
 
-// GetRepos 返回所有已加载的仓库配置的副本，用于显示列表
+// lineAt 返回 offset 之后第一个非空白、非逗号字符所在的行号 (从 1 开始)
+func lineAt(data []byte, offset int64) int {
+	for offset < int64(len(data)) && bytes.IndexByte([]byte(" \t\r\n,"), data[offset]) >= 0 {
+		offset++
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// GetRepos 返回所有已加载的仓库配置的副本 (供 import-config 导入)
 func GetRepos() []Repo {
 	configLock.RLock()
 	defer configLock.RUnlock()
@@ -51,16 +162,3 @@ func GetRepos() []Repo {
 	copy(reposCopy, loadedConfig)
 	return reposCopy
 }
-
-// GetRepoPath 根据仓库 ID 返回其物理路径
-// 这是给搜索等模块使用的关键函数
-func GetRepoPath(id string) string {
-	configLock.RLock()
-	defer configLock.RUnlock()
-	for _, repo := range loadedConfig {
-		if repo.ID == id {
-			return repo.Path
-		}
-	}
-	return ""
-}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfig 把 content 写入临时目录中的 repos.json，{dir} 替换为一个已存在的目录
+func writeConfig(t *testing.T, content string) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "repos.json")
+	content = strings.ReplaceAll(content, "{dir}", filepath.ToSlash(dir))
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path, dir
+}
+
+func TestLoadStrict(t *testing.T) {
+	path, dir := writeConfig(t, `[
+  {"id": "1", "name": "api", "path": "{dir}"},
+  {"id": "2", "name": "web", "path": "{dir}"}
+]`)
+	repos, err := LoadStrict(path)
+	if err != nil {
+		t.Fatalf("LoadStrict: %v", err)
+	}
+	if len(repos) != 2 || repos[1].Name != "web" || repos[1].Path != filepath.ToSlash(dir) {
+		t.Fatalf("repos = %+v", repos)
+	}
+	if got := GetRepos(); len(got) != 2 {
+		t.Fatalf("GetRepos = %+v", got)
+	}
+}
+
+func TestLoadStrict_Malformed(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		want    []string // 错误信息中应包含的片段
+	}{
+		{"syntax error", "[\n  {\"id\": \"1\",}\n]", []string{"repos.json:2:14:"}},
+		{"not an array", `{"id": "1"}`, []string{"repos.json:1: 顶层必须是仓库数组"}},
+		{"wrong type", "[\n  {\"id\": 1, \"name\": \"a\", \"path\": \"{dir}\"}\n]", []string{"repos.json:2: 仓库 #1:", "string"}},
+		{"unknown field", "[\n  {\"id\": \"1\", \"name\": \"a\", \"path\": \"{dir}\", \"url\": \"x\"}\n]", []string{"repos.json:2: 仓库 #1:", `"url"`}},
+		{
+			"every problem is listed",
+			"[\n  {\"id\": \"1\", \"name\": \"a\", \"path\": \"{dir}\"},\n  {\"name\": \"b\", \"path\": \"{dir}\"},\n  {\"id\": \"x\", \"path\": \"{dir}/missing\"},\n  {\"id\": \"1\", \"name\": \"d\", \"path\": \"{dir}/repos.json\"}\n]",
+			[]string{
+				"repos.json:3: 仓库 #2: 缺少 id",
+				"repos.json:4: 仓库 #3: 无效的 id 'x'",
+				"repos.json:4: 仓库 #3: 缺少 name",
+				"repos.json:4: 仓库 #3: path '",
+				"repos.json:5: 仓库 #4: id '1' 与第 2 行的仓库重复",
+				"repos.json:5: 仓库 #4: path '",
+			},
+		},
+	}
+	for _, c := range cases {
+		path, _ := writeConfig(t, c.content)
+		_, err := LoadStrict(path)
+		if err == nil {
+			t.Errorf("%s: expected an error", c.name)
+			continue
+		}
+		for _, want := range c.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: error %q does not contain %q", c.name, err, want)
+			}
+		}
+	}
+}
+
+func TestLoad_Reloads(t *testing.T) {
+	first, _ := writeConfig(t, `[{"id": "1", "name": "a", "path": "/a"}]`)
+	second, _ := writeConfig(t, `[{"id": "2", "name": "b", "path": "/b"}, {"id": "3", "name": "c", "path": "/c"}]`)
+	if err := Load(first); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := Load(second); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := GetRepos(); len(got) != 2 || got[0].ID != "2" {
+		t.Fatalf("after reload: %+v", got)
+	}
+
+	bad, _ := writeConfig(t, "[\n  {\"id\": \"1\"\n]")
+	if err := Load(bad); err == nil || !strings.Contains(err.Error(), "repos.json:3:1:") {
+		t.Fatalf("Load(bad) = %v", err)
+	}
+	if got := GetRepos(); len(got) != 2 {
+		t.Fatalf("failed load replaced the config: %+v", got)
+	}
+}
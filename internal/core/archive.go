@@ -0,0 +1,285 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/patrickmn/go-cache"
+)
+
+var (
+	// ErrInvalidPath 路径非法 (例如包含 .. 越出仓库或归档根目录)
+	ErrInvalidPath = errors.New("非法路径")
+	// ErrNotArchive 文件不是支持的归档格式
+	ErrNotArchive = errors.New("不支持的归档格式")
+	// ErrArchiveEntryNotFound 归档中不存在该条目
+	ErrArchiveEntryNotFound = errors.New("归档条目不存在")
+)
+
+// MaxArchiveEntrySize 归档条目解压后的最大字节数，不受 MaxFileSize 为 0 (不限制) 的影响
+const MaxArchiveEntrySize = 64 << 20
+
+// archiveKind 支持的归档格式
+type archiveKind int
+
+const (
+	archiveZip archiveKind = iota + 1
+	archiveTar
+	archiveTarGz
+)
+
+// archiveEntry 归档内的一个文件
+type archiveEntry struct {
+	Name string // 归档内的规范化路径 (不含前导 /)
+	Size int64
+}
+
+// cleanRepoPath 规范化仓库内的相对路径，拒绝越出根目录的路径 (如 ../etc/passwd)
+// 前导 / 会被忽略，空路径和 "." 都表示根目录，返回 ""
+func cleanRepoPath(p string) (string, error) {
+	p = strings.ReplaceAll(p, "\\", "/")
+	for _, seg := range strings.Split(p, "/") {
+		if seg == ".." {
+			return "", fmt.Errorf("%w: '%s'", ErrInvalidPath, p)
+		}
+	}
+	return strings.TrimPrefix(path.Clean("/"+p), "/"), nil
+}
+
+// detectArchiveKind 根据扩展名判断归档格式
+func detectArchiveKind(name string) (archiveKind, error) {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".zip"), strings.HasSuffix(lower, ".jar"):
+		return archiveZip, nil
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return archiveTarGz, nil
+	case strings.HasSuffix(lower, ".tar"):
+		return archiveTar, nil
+	}
+	return 0, fmt.Errorf("%w: '%s' (支持 .zip, .jar, .tar, .tar.gz, .tgz)", ErrNotArchive, name)
+}
+
+// readArchive 读取仓库中的归档文件并返回其格式和原始内容
+// 归档本身受 MaxFileSize 限制，并复用文件内容缓存
+func (s *Service) readArchive(repoID uint32, archivePath string) (archiveKind, []byte, string, error) {
+	cleaned, err := cleanRepoPath(archivePath)
+	if err != nil {
+		return 0, nil, "", err
+	}
+	if cleaned == "" {
+		return 0, nil, "", fmt.Errorf("%w: 未指定归档文件", ErrInvalidPath)
+	}
+	kind, err := detectArchiveKind(cleaned)
+	if err != nil {
+		return 0, nil, "", err
+	}
+	data, _, err := s.GetFileContent(repoID, cleaned)
+	if err != nil {
+		return 0, nil, "", err
+	}
+	return kind, data, cleaned, nil
+}
+
+// walkArchive 依次访问归档中的普通文件，fn 返回 io.EOF 时提前结束遍历
+// 条目名称会被规范化，越出归档根目录的条目被跳过
+func walkArchive(kind archiveKind, data []byte, fn func(e archiveEntry, open func() (io.Reader, error)) error) error {
+	if kind == archiveZip {
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return fmt.Errorf("解析 zip 归档失败: %w", err)
+		}
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			name, err := cleanRepoPath(f.Name)
+			if err != nil || name == "" {
+				continue
+			}
+			f := f
+			open := func() (io.Reader, error) { return f.Open() }
+			if err := fn(archiveEntry{Name: name, Size: int64(f.UncompressedSize64)}, open); err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+		}
+		return nil
+	}
+
+	var r io.Reader = bytes.NewReader(data)
+	if kind == archiveTarGz {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("解析 gzip 失败: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("解析 tar 归档失败: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name, err := cleanRepoPath(hdr.Name)
+		if err != nil || name == "" {
+			continue
+		}
+		open := func() (io.Reader, error) { return tr, nil }
+		if err := fn(archiveEntry{Name: name, Size: hdr.Size}, open); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// archiveEntries 返回归档中所有文件条目 (带缓存)
+func (s *Service) archiveEntries(repoID uint32, archivePath string) ([]archiveEntry, error) {
+	if err := s.checkEnabled(repoID); err != nil {
+		return nil, err
+	}
+	cleaned, err := cleanRepoPath(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	cacheKey := fmt.Sprintf("archive:%d:%s", repoID, cleaned)
+	if cached, found := s.TreeCache.Get(cacheKey); found {
+		return cached.([]archiveEntry), nil
+	}
+	kind, data, _, err := s.readArchive(repoID, cleaned)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []archiveEntry
+	err = walkArchive(kind, data, func(e archiveEntry, _ func() (io.Reader, error)) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.TreeCache.Set(cacheKey, entries, cache.DefaultExpiration)
+	return entries, nil
+}
+
+// GetArchiveTree 列出归档文件 archivePath 中 inner 目录下的直接子节点，格式与 GetTree 一致
+// 返回的 Path 为归档内的相对路径
+func (s *Service) GetArchiveTree(repoID uint32, archivePath, inner string) ([]FileInfo, error) {
+	dir, err := cleanRepoPath(inner)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.archiveEntries(repoID, archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+	seen := make(map[string]bool)
+	files := make([]FileInfo, 0)
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name, prefix) {
+			continue
+		}
+		rest := e.Name[len(prefix):]
+		name, fileType := rest, "file"
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			// 归档中通常不单独记录目录，由文件路径推出中间目录
+			name, fileType = rest[:i], "directory"
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		files = append(files, FileInfo{Name: name, Path: prefix + name, Type: fileType})
+	}
+	if dir != "" && len(files) == 0 {
+		return nil, fmt.Errorf("%w: 目录 '%s'", ErrArchiveEntryNotFound, dir)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Type != files[j].Type {
+			return files[i].Type == "directory"
+		}
+		return files[i].Name < files[j].Name
+	})
+	return files, nil
+}
+
+// GetArchiveEntry 读取归档文件 archivePath 中的单个条目
+// 解压后的内容受 MaxFileSize 和 MaxArchiveEntrySize 中较小者限制，超出时返回 ErrFileTooLarge (按实际读取字节判断，不信任归档头中的大小)
+func (s *Service) GetArchiveEntry(repoID uint32, archivePath, inner string) ([]byte, string, error) {
+	name, err := cleanRepoPath(inner)
+	if err != nil {
+		return nil, "", err
+	}
+	if name == "" {
+		return nil, "", fmt.Errorf("%w: 未指定归档条目", ErrInvalidPath)
+	}
+	kind, data, _, err := s.readArchive(repoID, archivePath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// 解压后的大小与归档大小无关 (压缩炸弹)，即使 MaxFileSize 为 0 (不限制) 也使用固定上限
+	limit := int64(MaxArchiveEntrySize)
+	if s.MaxFileSize > 0 && s.MaxFileSize < limit {
+		limit = s.MaxFileSize
+	}
+	var content []byte
+	found := false
+	err = walkArchive(kind, data, func(e archiveEntry, open func() (io.Reader, error)) error {
+		if e.Name != name {
+			return nil
+		}
+		found = true
+		if e.Size > limit {
+			return fmt.Errorf("%w: '%s' 大小 %d 字节, 上限 %d 字节", ErrFileTooLarge, name, e.Size, limit)
+		}
+		r, err := open()
+		if err != nil {
+			return fmt.Errorf("打开归档条目失败: %w", err)
+		}
+		if rc, ok := r.(io.Closer); ok {
+			defer rc.Close()
+		}
+		content, err = io.ReadAll(io.LimitReader(r, limit+1))
+		if err != nil {
+			return fmt.Errorf("读取归档条目失败: %w", err)
+		}
+		if int64(len(content)) > limit {
+			return fmt.Errorf("%w: '%s' 解压后超过 %d 字节", ErrFileTooLarge, name, limit)
+		}
+		return io.EOF
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if !found {
+		return nil, "", fmt.Errorf("%w: '%s'", ErrArchiveEntryNotFound, name)
+	}
+	return content, "text/plain; charset=utf-8", nil
+}
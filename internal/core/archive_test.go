@@ -0,0 +1,117 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func buildZip(t *testing.T, files map[string]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func buildTarGz(t *testing.T, files map[string]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestArchiveBrowsing(t *testing.T) {
+	inner := map[string]string{
+		"README.md":       "hello",
+		"src/main.go":     "package main",
+		"src/lib/util.go": "package lib",
+		"../escape.txt":   "nope",
+	}
+	s := newTestService(t, 1, map[string]string{
+		"dist/pkg.zip":    buildZip(t, inner),
+		"dist/pkg.tar.gz": buildTarGz(t, inner),
+		"notes.txt":       "plain",
+	})
+
+	for _, archive := range []string{"dist/pkg.zip", "dist/pkg.tar.gz"} {
+		root, err := s.GetArchiveTree(1, archive, "")
+		if err != nil {
+			t.Fatalf("%s: GetArchiveTree: %v", archive, err)
+		}
+		var got []string
+		for _, f := range root {
+			got = append(got, f.Type+":"+f.Path)
+		}
+		if want := "directory:src,file:README.md"; strings.Join(got, ",") != want {
+			t.Fatalf("%s: root = %v, want %s", archive, got, want)
+		}
+
+		sub, err := s.GetArchiveTree(1, archive, "src")
+		if err != nil || len(sub) != 2 || sub[0].Path != "src/lib" || sub[1].Path != "src/main.go" {
+			t.Fatalf("%s: src listing = %+v, %v", archive, sub, err)
+		}
+
+		content, _, err := s.GetArchiveEntry(1, archive, "src/lib/util.go")
+		if err != nil || string(content) != "package lib" {
+			t.Fatalf("%s: GetArchiveEntry = %q, %v", archive, content, err)
+		}
+		if _, _, err := s.GetArchiveEntry(1, archive, "missing.go"); !errors.Is(err, ErrArchiveEntryNotFound) {
+			t.Fatalf("%s: expected ErrArchiveEntryNotFound, got %v", archive, err)
+		}
+		if _, _, err := s.GetArchiveEntry(1, archive, "../escape.txt"); !errors.Is(err, ErrInvalidPath) {
+			t.Fatalf("%s: expected ErrInvalidPath for inner traversal, got %v", archive, err)
+		}
+	}
+
+	if _, err := s.GetArchiveTree(1, "../dist/pkg.zip", ""); !errors.Is(err, ErrInvalidPath) {
+		t.Fatalf("expected ErrInvalidPath for outer traversal, got %v", err)
+	}
+	if _, err := s.GetArchiveTree(1, "notes.txt", ""); !errors.Is(err, ErrNotArchive) {
+		t.Fatalf("expected ErrNotArchive, got %v", err)
+	}
+
+	// 解压后的条目同样受大小上限约束 (归档本身已在上面读取并缓存)
+	s.MaxFileSize = 4
+	if _, _, err := s.GetArchiveEntry(1, "dist/pkg.zip", "README.md"); !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("expected ErrFileTooLarge, got %v", err)
+	}
+}
+
+func TestGetArchiveEntry_SizeCapWithoutMaxFileSize(t *testing.T) {
+	// 压缩后很小、解压后超过 MaxArchiveEntrySize 的条目，MaxFileSize 为 0 时也不能完整读入内存
+	bomb := buildZip(t, map[string]string{"zeros.bin": strings.Repeat("\x00", MaxArchiveEntrySize+1)})
+	s := newTestService(t, 1, map[string]string{"bomb.zip": bomb})
+	s.MaxFileSize = 0
+
+	if _, _, err := s.GetArchiveEntry(1, "bomb.zip", "zeros.bin"); !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("expected ErrFileTooLarge, got %v", err)
+	}
+}
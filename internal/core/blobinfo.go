@@ -0,0 +1,54 @@
+package core
+
+import (
+	"bytes"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// binarySniffLen 判断二进制时检查的前缀长度 (与 git 的启发式一致)
+const binarySniffLen = 8000
+
+// binaryExts 按扩展名视为二进制的文件 (图片、归档、可执行文件、办公文档、字体、音视频、数据库)
+// 文件内容接口和搜索的 textOnly 过滤共用这一份列表
+var binaryExts = map[string]bool{
+	"png": true, "jpg": true, "jpeg": true, "gif": true, "bmp": true, "ico": true, "webp": true, "tif": true, "tiff": true, "psd": true,
+	"zip": true, "gz": true, "tgz": true, "bz2": true, "xz": true, "7z": true, "rar": true, "tar": true, "jar": true, "war": true,
+	"exe": true, "dll": true, "so": true, "dylib": true, "a": true, "o": true, "obj": true, "lib": true, "class": true, "pyc": true, "wasm": true, "bin": true,
+	"pdf": true, "doc": true, "docx": true, "xls": true, "xlsx": true, "ppt": true, "pptx": true,
+	"ttf": true, "otf": true, "woff": true, "woff2": true, "eot": true,
+	"mp3": true, "mp4": true, "wav": true, "flac": true, "ogg": true, "avi": true, "mov": true, "mkv": true, "webm": true,
+	"db": true, "sqlite": true,
+}
+
+// IsBinaryPath 按扩展名判断文件是否为二进制 (不读取内容)
+func IsBinaryPath(filePath string) bool {
+	return binaryExts[strings.ToLower(strings.TrimPrefix(path.Ext(filePath), "."))]
+}
+
+// BlobInfo 文件内容及其元数据 (GetBlob 的 JSON 形式)
+type BlobInfo struct {
+	Content     string `json:"content"`     // 文本文件为原文，二进制文件为 base64
+	ContentType string `json:"contentType"` // 与原始响应的 Content-Type 相同
+	Size        int    `json:"size"`        // 原始内容的字节数
+	Language    string `json:"language"`    // 按扩展名推断的语言，未知时为 "plaintext"
+	IsBinary    bool   `json:"isBinary"`    // 为 true 时 Content 是 base64 编码
+	ETag        string `json:"etag"`        // 内容的 git blob 哈希 (带引号，可直接用于 If-None-Match)
+}
+
+// IsBinary 判断内容是否为二进制: 前 binarySniffLen 字节中含 NUL，或不是合法的 UTF-8
+func IsBinary(content []byte) bool {
+	head := content
+	if len(head) > binarySniffLen {
+		head = head[:binarySniffLen]
+	}
+	return bytes.IndexByte(head, 0) >= 0 || !utf8.Valid(content)
+}
+
+// BlobETag 返回内容的 ETag，即 git 对同样内容计算的 blob 哈希
+func BlobETag(content []byte) string {
+	return `"` + plumbing.ComputeHash(plumbing.BlobObject, content).String() + `"`
+}
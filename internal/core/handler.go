@@ -1,18 +1,22 @@
 package core
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"code-browser/internal/logging"
 	"code-browser/internal/repo"
 )
 
 // Handlers 封装了所有与核心浏览功能相关的 HTTP 处理器
 type Handlers struct {
-	RepoProvider *repo.Provider
+	RepoProvider repo.RepoProvider
 	Service      *Service // 依赖 Service
 }
 
@@ -28,16 +32,18 @@ func parseRepoIDHelper(r *http.Request) (uint32, error) {
 
 // ListRepositories 返回所有已配置的仓库列表
 func (h *Handlers) ListRepositories(w http.ResponseWriter, r *http.Request) {
-	repos, err := h.Service.ListRepositories()
+	repos, err := h.Service.ListRepositories(r.URL.Query().Get("q"), r.URL.Query().Get("tag"))
 	if err != nil {
-		log.Printf("获取仓库列表失败: %v", err)
+		logging.FromContext(r.Context()).Error("获取仓库列表失败", "err", err)
 		http.Error(w, "无法获取仓库列表", http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	// 便于通用表格组件获取总数 (不分页时即列表长度)
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(repos)))
 	if err := json.NewEncoder(w).Encode(repos); err != nil {
-		log.Printf("序列化仓库列表失败: %v", err)
+		logging.FromContext(r.Context()).Error("序列化仓库列表失败", "err", err)
 	}
 }
 
@@ -50,21 +56,70 @@ func (h *Handlers) GetTree(w http.ResponseWriter, r *http.Request) {
 	}
 	relativePath := r.URL.Query().Get("path")
 
-	files, err := h.Service.GetTree(repoID, relativePath)
+	// 未指定 limit/offset 时保持原有行为: 直接返回完整数组
+	limitStr := r.URL.Query().Get("limit")
+	offsetStr := r.URL.Query().Get("offset")
+	if limitStr == "" && offsetStr == "" {
+		files, err := h.Service.GetTree(repoID, relativePath)
+		if err != nil {
+			if writePathError(w, err) {
+				return
+			}
+			logging.FromContext(r.Context()).Error("获取目录树失败", "repo", repoID, "path", relativePath, "err", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(files); err != nil {
+			logging.FromContext(r.Context()).Error("序列化文件列表失败", "err", err)
+		}
+		return
+	}
+
+	limit, offset := 0, 0
+	if limitStr != "" {
+		if limit, err = strconv.Atoi(limitStr); err != nil || limit < 0 {
+			http.Error(w, fmt.Sprintf("无效的 limit 参数: '%s'", limitStr), http.StatusBadRequest)
+			return
+		}
+	}
+	if offsetStr != "" {
+		if offset, err = strconv.Atoi(offsetStr); err != nil || offset < 0 {
+			http.Error(w, fmt.Sprintf("无效的 offset 参数: '%s'", offsetStr), http.StatusBadRequest)
+			return
+		}
+	}
+
+	files, total, err := h.Service.GetTreePage(repoID, relativePath, offset, limit)
 	if err != nil {
-		log.Printf("获取目录树失败 (repo=%d, path=%s): %v", repoID, relativePath, err)
-		// 简单区分一下错误类型，实际项目中可以定义明确的 Error 类型
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if writePathError(w, err) {
+			return
+		}
+		logging.FromContext(r.Context()).Error("获取目录树失败", "repo", repoID, "path", relativePath, "err", err)
+		http.Error(w, err.Error(), errorStatus(err))
 		return
 	}
 
+	resp := struct {
+		Items  []FileInfo `json:"items"`
+		Total  int        `json:"total"`
+		Offset int        `json:"offset"`
+		Limit  int        `json:"limit"`
+	}{
+		Items:  files,
+		Total:  total,
+		Offset: offset,
+		Limit:  limit,
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(files); err != nil {
-		log.Printf("序列化文件列表失败: %v", err)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).Error("序列化文件列表失败", "err", err)
 	}
 }
 
-// GetBlob 返回指定文件的原始内容
+// GetBlob 返回指定文件的原始内容；Accept 包含 application/json 时 (且未指定 raw=true) 返回带元数据的 BlobInfo
 func (h *Handlers) GetBlob(w http.ResponseWriter, r *http.Request) {
 	repoID, err := parseRepoIDHelper(r)
 	if err != nil {
@@ -77,13 +132,367 @@ func (h *Handlers) GetBlob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	content, contentType, err := h.Service.GetFileContent(repoID, relativePath)
+	content, contentType, err := h.Service.GetFileContentAt(repoID, relativePath, r.URL.Query().Get("rev"))
+	if err != nil {
+		if errors.Is(err, ErrFileTooLarge) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if errors.Is(err, repo.ErrRevisionNotFound) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if writePathError(w, err) {
+			return
+		}
+		logging.FromContext(r.Context()).Error("获取文件内容失败", "repo", repoID, "path", relativePath, "err", err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	// 同一 URL 按 Accept 返回不同表示，缓存需区分
+	w.Header().Add("Vary", "Accept")
+	if !wantsJSONBlob(r) {
+		w.Header().Set("Content-Type", contentType)
+		w.Write(content)
+		return
+	}
+
+	info := BlobInfo{
+		ContentType: contentType,
+		Size:        len(content),
+		Language:    h.Service.GetRepoSettings(repoID).Language(relativePath),
+		IsBinary:    IsBinaryPath(relativePath) || IsBinary(content),
+		ETag:        BlobETag(content),
+	}
+	if info.IsBinary {
+		info.Content = base64.StdEncoding.EncodeToString(content)
+	} else {
+		info.Content = string(content)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", info.ETag)
+	if err := json.NewEncoder(w).Encode(info); err != nil {
+		logging.FromContext(r.Context()).Error("序列化文件内容失败", "err", err)
+	}
+}
+
+// renderCSP 预览 HTML 的内容安全策略: 内容已经过清理，直接打开时仍禁止脚本和除图片外的所有外部资源
+const renderCSP = "default-src 'none'; img-src data: https: http:; sandbox"
+
+// GetRender 处理 GET /api/repositories/{id}/render?path=，返回文件的 HTML 预览 (见 Service.RenderFile)
+// 可渲染的类型返回清理后的 HTML 片段，其余类型返回原始内容；X-Render-Format 给出实际使用的格式
+func (h *Handlers) GetRender(w http.ResponseWriter, r *http.Request) {
+	repoID, err := parseRepoIDHelper(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	relativePath := r.URL.Query().Get("path")
+	if relativePath == "" {
+		http.Error(w, "Query parameter 'path' is required", http.StatusBadRequest)
+		return
+	}
+
+	doc, err := h.Service.RenderFile(repoID, relativePath)
+	if err != nil {
+		if writePathError(w, err) {
+			return
+		}
+		status := errorStatus(err)
+		switch {
+		case errors.Is(err, ErrInvalidPath):
+			status = http.StatusBadRequest
+		case errors.Is(err, ErrFileTooLarge):
+			status = http.StatusRequestEntityTooLarge
+		}
+		if status == http.StatusInternalServerError {
+			logging.FromContext(r.Context()).Error("渲染文件预览失败", "repo", repoID, "path", relativePath, "err", err)
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("X-Render-Format", doc.Format)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if doc.Format == RenderRaw {
+		w.Header().Set("Content-Type", doc.ContentType)
+		w.Write(doc.Content)
+		return
+	}
+	if doc.Truncated {
+		w.Header().Set("X-Render-Truncated", "true")
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", renderCSP)
+	io.WriteString(w, doc.HTML)
+}
+
+// wantsJSONBlob 判断 GetBlob 是否返回 JSON 形式: Accept 中包含 application/json 且未指定 raw=true
+func wantsJSONBlob(r *http.Request) bool {
+	if r.URL.Query().Get("raw") == "true" {
+		return false
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// errorStatus 将浏览相关的服务错误映射为 HTTP 状态码: 仓库停用或符号链接指向仓库之外为 403，
+// 路径不存在或位于子模块中为 404，路径类型不符为 400，其余为 500
+func errorStatus(err error) int {
+	if errors.Is(err, repo.ErrRepoDisabled) || errors.Is(err, ErrSymlinkOutsideRepo) {
+		return http.StatusForbidden
+	}
+	if errors.Is(err, ErrInSubmodule) || errors.Is(err, ErrPathNotFound) {
+		return http.StatusNotFound
+	}
+	if errors.Is(err, ErrPathIsDirectory) || errors.Is(err, ErrPathIsFile) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// 路径错误响应中的 code，前端据此改为打开目录 (PATH_IS_DIRECTORY) 或文件 (PATH_IS_FILE)
+const (
+	codePathIsDirectory = "PATH_IS_DIRECTORY"
+	codePathIsFile      = "PATH_IS_FILE"
+	codeNotFound        = "NOT_FOUND"
+)
+
+// pathErrorCode 返回路径错误对应的 code，不是路径错误时返回空字符串
+func pathErrorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrPathIsDirectory):
+		return codePathIsDirectory
+	case errors.Is(err, ErrPathIsFile):
+		return codePathIsFile
+	case errors.Is(err, ErrPathNotFound):
+		return codeNotFound
+	}
+	return ""
+}
+
+// writePathError 路径错误时写入 {"error", "code"} 形式的 JSON 响应并返回 true；
+// 这些是客户端请求的问题，不记录错误日志
+func writePathError(w http.ResponseWriter, err error) bool {
+	code := pathErrorCode(err)
+	if code == "" {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(errorStatus(err))
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error(), "code": code})
+	return true
+}
+
+// archiveErrorStatus 将归档相关错误映射为 HTTP 状态码
+func archiveErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repo.ErrRepoDisabled):
+		return http.StatusForbidden
+	case errors.Is(err, ErrInvalidPath), errors.Is(err, ErrNotArchive):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrArchiveEntryNotFound), errors.Is(err, ErrPathNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrPathIsDirectory):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrFileTooLarge):
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusInternalServerError
+}
+
+// ResolvePath 处理 GET /api/repositories/{id}/resolve-path?path=，精确检查路径是否存在 (用于 "打开此路径")
+func (h *Handlers) ResolvePath(w http.ResponseWriter, r *http.Request) {
+	repoID, err := parseRepoIDHelper(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	relativePath := r.URL.Query().Get("path")
+
+	info, err := h.Service.ResolvePath(repoID, relativePath)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrPathNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, ErrInvalidPath):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			logging.FromContext(r.Context()).Error("解析路径失败", "repo", repoID, "path", relativePath, "err", err)
+			http.Error(w, err.Error(), errorStatus(err))
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+// GetLanguages 处理 GET /api/repositories/{id}/languages，返回仓库 HEAD 中各语言的字节数
+func (h *Handlers) GetLanguages(w http.ResponseWriter, r *http.Request) {
+	repoID, err := parseRepoIDHelper(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, ok := h.RepoProvider.GetRepo(repoID); !ok {
+		http.Error(w, fmt.Sprintf("仓库 ID '%d' 未找到", repoID), http.StatusNotFound)
+		return
+	}
+
+	stats, err := h.Service.GetLanguages(r.Context(), repoID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("统计仓库语言失败", "repo", repoID, "err", err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// GetBlobURL 处理 GET /api/repositories/{id}/blob-url?path=&startLine=&endLine=&rev=，返回文件行范围的规范链接
+func (h *Handlers) GetBlobURL(w http.ResponseWriter, r *http.Request) {
+	repoID, err := parseRepoIDHelper(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q := r.URL.Query()
+	relativePath := q.Get("path")
+	if relativePath == "" {
+		http.Error(w, "Query parameter 'path' is required", http.StatusBadRequest)
+		return
+	}
+	lines := map[string]int{}
+	for _, name := range []string{"startLine", "endLine"} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				http.Error(w, fmt.Sprintf("无效的 %s 参数: '%s'", name, v), http.StatusBadRequest)
+				return
+			}
+			lines[name] = n
+		}
+	}
+
+	link, err := h.Service.BlobURL(repoID, relativePath, lines["startLine"], lines["endLine"], q.Get("rev"))
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrPathNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, ErrInvalidPath), errors.Is(err, ErrInvalidLineRange), errors.Is(err, repo.ErrRevisionNotFound):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			logging.FromContext(r.Context()).Error("生成文件链接失败", "repo", repoID, "path", relativePath, "err", err)
+			http.Error(w, err.Error(), errorStatus(err))
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(link)
+}
+
+// GetArchiveTree 列出仓库中归档文件 (zip/tar) 内某个目录的条目
+func (h *Handlers) GetArchiveTree(w http.ResponseWriter, r *http.Request) {
+	repoID, err := parseRepoIDHelper(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	archivePath := r.URL.Query().Get("path")
+	inner := r.URL.Query().Get("inner")
+	if archivePath == "" {
+		http.Error(w, "Query parameter 'path' is required", http.StatusBadRequest)
+		return
+	}
+
+	files, err := h.Service.GetArchiveTree(repoID, archivePath, inner)
+	if err != nil {
+		status := archiveErrorStatus(err)
+		if status == http.StatusInternalServerError {
+			logging.FromContext(r.Context()).Error("获取归档目录失败", "repo", repoID, "path", archivePath, "inner", inner, "err", err)
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(files); err != nil {
+		logging.FromContext(r.Context()).Error("序列化文件列表失败", "err", err)
+	}
+}
+
+// GetArchiveBlob 返回仓库中归档文件 (zip/tar) 内单个条目的内容
+func (h *Handlers) GetArchiveBlob(w http.ResponseWriter, r *http.Request) {
+	repoID, err := parseRepoIDHelper(r)
 	if err != nil {
-		log.Printf("获取文件内容失败: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	archivePath := r.URL.Query().Get("path")
+	inner := r.URL.Query().Get("inner")
+	if archivePath == "" || inner == "" {
+		http.Error(w, "Query parameters 'path' and 'inner' are required", http.StatusBadRequest)
+		return
+	}
+
+	content, contentType, err := h.Service.GetArchiveEntry(repoID, archivePath, inner)
+	if err != nil {
+		status := archiveErrorStatus(err)
+		if status == http.StatusInternalServerError {
+			logging.FromContext(r.Context()).Error("读取归档条目失败", "repo", repoID, "path", archivePath, "inner", inner, "err", err)
+		}
+		http.Error(w, err.Error(), status)
 		return
 	}
 
 	w.Header().Set("Content-Type", contentType)
 	w.Write(content)
-}
\ No newline at end of file
+}
+
+// GetSettings 返回仓库根目录 .code-browser.json 中的设置 (文件不存在时为空对象)
+func (h *Handlers) GetSettings(w http.ResponseWriter, r *http.Request) {
+	repoID, err := parseRepoIDHelper(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, ok := h.RepoProvider.GetRepo(repoID); !ok {
+		http.Error(w, fmt.Sprintf("仓库 ID '%d' 未找到", repoID), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.Service.GetRepoSettings(repoID)); err != nil {
+		logging.FromContext(r.Context()).Error("序列化仓库设置失败", "err", err)
+	}
+}
+
+// ListFiles 返回仓库 HEAD 中的完整文件列表
+func (h *Handlers) ListFiles(w http.ResponseWriter, r *http.Request) {
+	repoID, err := parseRepoIDHelper(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	files, truncated, err := h.Service.ListAllFilesTruncated(r.Context(), repoID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("获取文件列表失败", "repo", repoID, "err", err)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	resp := struct {
+		Files     []string `json:"files"`
+		Truncated bool     `json:"truncated"`
+	}{
+		Files:     files,
+		Truncated: truncated,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).Error("序列化文件列表失败", "err", err)
+	}
+}
@@ -0,0 +1,372 @@
+package core
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"code-browser/internal/lru"
+	"code-browser/internal/repo"
+	"code-browser/internal/repo/repotest"
+
+	"github.com/patrickmn/go-cache"
+)
+
+func TestListRepositories(t *testing.T) {
+	provider := repotest.New(
+		repo.Repository{RepoID: 1, Name: "Café"},
+		repo.Repository{RepoID: 2, Name: "tools"},
+		repo.Repository{RepoID: 3, Name: "legacy", Archived: true},
+		repo.Repository{RepoID: 4, Name: "frozen", Disabled: true},
+	)
+	provider.SetIndexStatus(1, repo.IndexStatus{Zoekt: true})
+	s := NewService(provider, cache.New(time.Minute, time.Minute), lru.New(0, 0, 0))
+	h := &Handlers{RepoProvider: provider, Service: s}
+
+	list := func(target string) ([]RepositoryInfo, string) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.ListRepositories(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d", target, rec.Code)
+		}
+		var infos []RepositoryInfo
+		if err := json.NewDecoder(rec.Body).Decode(&infos); err != nil {
+			t.Fatalf("%s: decode: %v", target, err)
+		}
+		return infos, rec.Header().Get("X-Total-Count")
+	}
+
+	infos, total := list("/api/repositories")
+	if len(infos) != 3 || total != "3" {
+		t.Fatalf("expected 3 unarchived repos and X-Total-Count 3, got %d / %q", len(infos), total)
+	}
+	if !infos[0].Indexed || infos[1].Indexed {
+		t.Fatalf("index status not taken from the provider: %+v", infos)
+	}
+	// 停用的仓库仍然列出，只是带上状态
+	if !infos[0].Enabled || infos[2].Enabled || infos[2].Name != "frozen" {
+		t.Fatalf("enabled status not taken from the provider: %+v", infos)
+	}
+
+	// 浏览停用的仓库返回 403
+	for _, target := range []string{"/api/repositories/4/tree", "/api/repositories/4/blob?path=a.go", "/api/repositories/4/files"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.SetPathValue("id", "4")
+		rec := httptest.NewRecorder()
+		switch {
+		case strings.Contains(target, "/tree"):
+			h.GetTree(rec, req)
+		case strings.Contains(target, "/blob"):
+			h.GetBlob(rec, req)
+		default:
+			h.ListFiles(rec, req)
+		}
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s: status = %d, want 403 (%s)", target, rec.Code, rec.Body.String())
+		}
+	}
+
+	infos, total = list("/api/repositories?q=cafe")
+	if len(infos) != 1 || infos[0].ID != "1" || total != "1" {
+		t.Fatalf("q filter: got %+v, X-Total-Count %q", infos, total)
+	}
+}
+
+func TestInvalidateRepoOnProviderChange(t *testing.T) {
+	provider := repotest.New(repo.Repository{RepoID: 1, Name: "a"})
+	s := NewService(provider, cache.New(time.Minute, time.Minute), lru.New(0, 0, 0))
+	s.BlobCache.Set("blob:1:main.go", blobCacheEntry{Content: []byte("x")}, 1)
+	s.TreeCache.Set("tree:1:", []FileInfo{}, cache.DefaultExpiration)
+
+	provider.NotifyChanged(1)
+	if s.BlobCache.Len() != 0 || s.TreeCache.ItemCount() != 0 {
+		t.Fatalf("caches not cleared on repo change: blob=%d tree=%d", s.BlobCache.Len(), s.TreeCache.ItemCount())
+	}
+}
+
+func TestGetBlobNegotiation(t *testing.T) {
+	s := newTestService(t, 1, map[string]string{
+		"main.go":  "package main\n",
+		"logo.bin": "\x89PNG\x00\x01",
+	})
+	h := &Handlers{RepoProvider: s.RepoProvider, Service: s}
+
+	get := func(target, accept string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.SetPathValue("id", "1")
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		h.GetBlob(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", target, rec.Code, rec.Body.String())
+		}
+		return rec
+	}
+
+	// 默认及 raw=true 时返回原始内容
+	if rec := get("/blob?path=main.go", ""); rec.Body.String() != "package main\n" {
+		t.Fatalf("raw body = %q", rec.Body.String())
+	}
+	if rec := get("/blob?path=main.go&raw=true", "application/json"); rec.Body.String() != "package main\n" {
+		t.Fatalf("raw=true body = %q", rec.Body.String())
+	}
+	if rec := get("/blob?path=main.go&rev=HEAD", ""); rec.Body.String() != "package main\n" {
+		t.Fatalf("rev=HEAD body = %q", rec.Body.String())
+	}
+	req := httptest.NewRequest(http.MethodGet, "/blob?path=main.go&rev=no-such", nil)
+	req.SetPathValue("id", "1")
+	rec := httptest.NewRecorder()
+	h.GetBlob(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("unknown rev: status = %d", rec.Code)
+	}
+
+	decode := func(rec *httptest.ResponseRecorder) BlobInfo {
+		t.Helper()
+		var info BlobInfo
+		if err := json.NewDecoder(rec.Body).Decode(&info); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return info
+	}
+
+	rec = get("/blob?path=main.go", "application/json, text/plain")
+	info := decode(rec)
+	if info.Content != "package main\n" || info.IsBinary || info.Language != "go" || info.Size != 13 {
+		t.Fatalf("text info = %+v", info)
+	}
+	// git hash-object 对 "package main\n" 的结果
+	if want := `"06ab7d0f9a35a7d1070711496d6ca1cb892a258f"`; info.ETag != want || rec.Header().Get("ETag") != want {
+		t.Fatalf("ETag = %q (header %q), want %s", info.ETag, rec.Header().Get("ETag"), want)
+	}
+
+	info = decode(get("/blob?path=logo.bin", "application/json"))
+	if !info.IsBinary || info.Content != base64.StdEncoding.EncodeToString([]byte("\x89PNG\x00\x01")) || info.Language != "plaintext" {
+		t.Fatalf("binary info = %+v", info)
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	s := newTestService(t, 1, map[string]string{
+		"cmd/server/main.go": "package main\n",
+		"assets/logo.png":    "\x89PNG",
+		"vendor/lib/lib.go":  "package lib\n",
+		".code-browser.json": `{"hiddenPaths": ["vendor"]}`,
+	})
+	h := &Handlers{RepoProvider: s.RepoProvider, Service: s}
+
+	get := func(p string) (*httptest.ResponseRecorder, PathInfo) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/resolve-path?path="+url.QueryEscape(p), nil)
+		req.SetPathValue("id", "1")
+		rec := httptest.NewRecorder()
+		h.ResolvePath(rec, req)
+		var info PathInfo
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&info); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+		}
+		return rec, info
+	}
+
+	if rec, info := get("/cmd/server/main.go"); rec.Code != http.StatusOK || info.Type != "file" || info.Path != "cmd/server/main.go" || info.Name != "main.go" || info.Size != 13 || info.Language != "go" || info.IsBinary {
+		t.Fatalf("file: %d %+v", rec.Code, info)
+	}
+	if rec, info := get("cmd/server/"); rec.Code != http.StatusOK || info.Type != "directory" || info.Path != "cmd/server" || info.Size != 0 {
+		t.Fatalf("directory: %d %+v", rec.Code, info)
+	}
+	if _, info := get("assets/logo.png"); !info.IsBinary {
+		t.Fatalf("png should be binary: %+v", info)
+	}
+	if rec, info := get(""); rec.Code != http.StatusOK || info.Type != "directory" || info.Path != "" {
+		t.Fatalf("root: %d %+v", rec.Code, info)
+	}
+
+	for p, want := range map[string]int{
+		"cmd/missing.go":    http.StatusNotFound,
+		"cmd/server/main":   http.StatusNotFound,
+		"vendor/lib/lib.go": http.StatusNotFound, // 被 hiddenPaths 隐藏
+		"../etc/passwd":     http.StatusBadRequest,
+	} {
+		if rec, _ := get(p); rec.Code != want {
+			t.Errorf("%s: status = %d, want %d", p, rec.Code, want)
+		}
+	}
+}
+
+func TestPathTypeErrors(t *testing.T) {
+	s := newTestService(t, 1, map[string]string{
+		"cmd/server/main.go": "package main\n",
+	})
+	h := &Handlers{RepoProvider: s.RepoProvider, Service: s}
+
+	cases := []struct {
+		name    string
+		handler http.HandlerFunc
+		target  string
+		status  int
+		code    string
+	}{
+		{"blob/directory", h.GetBlob, "/blob?path=cmd/server", http.StatusBadRequest, "PATH_IS_DIRECTORY"},
+		{"blob/missing", h.GetBlob, "/blob?path=cmd/missing.go", http.StatusNotFound, "NOT_FOUND"},
+		{"blob/under file", h.GetBlob, "/blob?path=cmd/server/main.go/x", http.StatusNotFound, "NOT_FOUND"},
+		{"tree/file", h.GetTree, "/tree?path=cmd/server/main.go", http.StatusBadRequest, "PATH_IS_FILE"},
+		{"tree/file paged", h.GetTree, "/tree?path=cmd/server/main.go&limit=10", http.StatusBadRequest, "PATH_IS_FILE"},
+		{"tree/missing", h.GetTree, "/tree?path=pkg", http.StatusNotFound, "NOT_FOUND"},
+		{"tree/under file", h.GetTree, "/tree?path=cmd/server/main.go/x", http.StatusNotFound, "NOT_FOUND"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, c.target, nil)
+			req.SetPathValue("id", "1")
+			rec := httptest.NewRecorder()
+			c.handler(rec, req)
+			var body struct {
+				Error string `json:"error"`
+				Code  string `json:"code"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if rec.Code != c.status || body.Code != c.code || body.Error == "" {
+				t.Fatalf("status = %d, body = %+v; want %d %s", rec.Code, body, c.status, c.code)
+			}
+		})
+	}
+}
+
+func TestGetRender(t *testing.T) {
+	s := newTestService(t, 1, map[string]string{
+		"README.md": "# Title\n\n<script>alert(1)</script>\n\n" +
+			"<img src=\"x.png\" onerror=\"alert(2)\">\n\n[click](javascript:alert(3))\n\n```go\nfunc main() {}\n```\n",
+		"docs/page.html": `<p style="color:red" onclick="x()">hi</p><iframe src="https://example.com"></iframe>`,
+		"notes.txt":      "a < b\n",
+		"data.csv":       "name,size\nmain.go,10\n",
+		"main.go":        "package main\n",
+		"big.md":         strings.Repeat("x", 64),
+	})
+	h := &Handlers{RepoProvider: s.RepoProvider, Service: s}
+
+	get := func(p string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/render?path="+url.QueryEscape(p), nil)
+		req.SetPathValue("id", "1")
+		rec := httptest.NewRecorder()
+		h.GetRender(rec, req)
+		return rec
+	}
+
+	rec := get("README.md")
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Render-Format") != "markdown" || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("markdown: %d %v", rec.Code, rec.Header())
+	}
+	if !strings.Contains(body, "<h1") || !strings.Contains(body, `<code class="language-go">`) {
+		t.Fatalf("markdown not rendered: %s", body)
+	}
+	for _, bad := range []string{"<script", "onerror", "javascript:"} {
+		if strings.Contains(body, bad) {
+			t.Fatalf("markdown output contains %q: %s", bad, body)
+		}
+	}
+	if rec.Header().Get("Content-Security-Policy") == "" {
+		t.Fatal("missing Content-Security-Policy")
+	}
+
+	if body := get("docs/page.html").Body.String(); !strings.Contains(body, "hi</p>") || strings.Contains(body, "onclick") || strings.Contains(body, "style") || strings.Contains(body, "iframe") {
+		t.Fatalf("html not sanitized: %s", body)
+	}
+	if body := get("notes.txt").Body.String(); body != "<pre>a &lt; b\n</pre>" {
+		t.Fatalf("text: %q", body)
+	}
+	if body := get("data.csv").Body.String(); !strings.Contains(body, "<th>name</th>") || !strings.Contains(body, "<td>main.go</td>") {
+		t.Fatalf("csv: %s", body)
+	}
+	// 未识别的类型返回原始内容
+	if rec := get("main.go"); rec.Header().Get("X-Render-Format") != "raw" || rec.Body.String() != "package main\n" || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("raw: %v %q", rec.Header(), rec.Body.String())
+	}
+
+	// big.md 超过文件大小上限
+	s.MaxFileSize = 32
+	for p, want := range map[string]int{
+		"big.md":        http.StatusRequestEntityTooLarge,
+		"../etc/passwd": http.StatusBadRequest,
+		"docs":          http.StatusBadRequest,
+		"missing.md":    http.StatusNotFound,
+	} {
+		if rec := get(p); rec.Code != want {
+			t.Errorf("%s: status = %d, want %d: %s", p, rec.Code, want, rec.Body.String())
+		}
+	}
+}
+
+func TestGetBlobURL(t *testing.T) {
+	s := newTestService(t, 1, map[string]string{
+		"cmd/server/main.go": "package main\n",
+		"vendor/lib/lib.go":  "package lib\n",
+		".code-browser.json": `{"hiddenPaths": ["vendor"]}`,
+	})
+	h := &Handlers{RepoProvider: s.RepoProvider, Service: s}
+
+	get := func(query string) (*httptest.ResponseRecorder, BlobURL) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/blob-url?"+query, nil)
+		req.SetPathValue("id", "1")
+		rec := httptest.NewRecorder()
+		h.GetBlobURL(rec, req)
+		var link BlobURL
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&link); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+		}
+		return rec, link
+	}
+
+	rec, link := get("path=/cmd/server/main.go&startLine=3&endLine=7")
+	if rec.Code != http.StatusOK || link.Path != "cmd/server/main.go" || link.StartLine != 3 || link.EndLine != 7 || link.Rev != "" {
+		t.Fatalf("range: %d %+v", rec.Code, link)
+	}
+	if want := "/?path=cmd%2Fserver%2Fmain.go&repo=1#L3-L7"; link.WebURL != want {
+		t.Errorf("webUrl = %q, want %q", link.WebURL, want)
+	}
+	if want := "/api/repositories/1/blob?path=cmd%2Fserver%2Fmain.go"; link.APIURL != want {
+		t.Errorf("apiUrl = %q, want %q", link.APIURL, want)
+	}
+	if _, link := get("path=cmd/server/main.go&startLine=5&endLine=5"); link.EndLine != 0 || !strings.HasSuffix(link.WebURL, "#L5") {
+		t.Errorf("single line: %+v", link)
+	}
+	if _, link := get("path=cmd/server/main.go"); strings.Contains(link.WebURL, "#") {
+		t.Errorf("no lines: %+v", link)
+	}
+	if rec, link := get("path=cmd/server/main.go&rev=HEAD&startLine=1"); rec.Code != http.StatusOK || len(link.Rev) != 40 || !strings.Contains(link.WebURL, "rev="+link.Rev) || !strings.Contains(link.APIURL, "rev="+link.Rev) {
+		t.Errorf("rev: %d %+v", rec.Code, link)
+	}
+
+	for query, want := range map[string]int{
+		"path=cmd/server":                               http.StatusBadRequest, // 目录
+		"path=../etc/passwd":                            http.StatusBadRequest,
+		"path=cmd/server/main.go&endLine=3":             http.StatusBadRequest,
+		"path=cmd/server/main.go&startLine=5&endLine=2": http.StatusBadRequest,
+		"path=cmd/server/main.go&startLine=x":           http.StatusBadRequest,
+		"path=cmd/server/main.go&rev=no-such":           http.StatusBadRequest,
+		"path=cmd/missing.go":                           http.StatusNotFound,
+		"path=vendor/lib/lib.go":                        http.StatusNotFound,
+		"path=cmd/missing.go&rev=HEAD":                  http.StatusNotFound,
+	} {
+		if rec, _ := get(query); rec.Code != want {
+			t.Errorf("%s: status = %d, want %d", query, rec.Code, want)
+		}
+	}
+}
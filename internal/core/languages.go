@@ -0,0 +1,95 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"code-browser/internal/codetree"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/patrickmn/go-cache"
+)
+
+// LanguageStats 仓库 HEAD 中各语言的代码量 (按文件字节数)，用于仓库概览的语言比例条
+type LanguageStats struct {
+	Languages map[string]int64 `json:"languages"` // 语言 → 字节数，语言名称与 codetree.DetectLanguage 一致
+	Total     int64            `json:"total"`     // Languages 的字节数之和
+	Files     int              `json:"files"`     // 计入统计的文件数
+	Commit    string           `json:"commit"`    // 统计所基于的 HEAD 提交
+	Truncated bool             `json:"truncated"` // 遍历超出 WalkLimits (文件数、字节数或时间)，只统计了一部分
+}
+
+// GetLanguages 统计仓库 HEAD 中各语言的字节数。
+// 只统计扩展名能识别语言的文本文件；隐藏路径、依赖和生成代码 (IgnoredPaths 或仓库的 searchIgnore) 不计入。
+// 结果按 HEAD 提交缓存，HEAD 变化后自动重新统计；因超时或 ctx 结束而截断的结果不缓存。
+func (s *Service) GetLanguages(ctx context.Context, repoID uint32) (LanguageStats, error) {
+	if err := s.checkEnabled(repoID); err != nil {
+		return LanguageStats{}, err
+	}
+	repoInfo, ok := s.RepoProvider.GetRepo(repoID)
+	if !ok {
+		return LanguageStats{}, fmt.Errorf("仓库 ID '%d' 未找到", repoID)
+	}
+	r, err := git.PlainOpen(repoInfo.SourcePath)
+	if err != nil {
+		return LanguageStats{}, fmt.Errorf("打开 Git 仓库失败: %w", err)
+	}
+	ref, err := r.Head()
+	if err != nil {
+		return LanguageStats{}, fmt.Errorf("获取 HEAD 引用失败: %w", err)
+	}
+	cacheKey := fmt.Sprintf("languages:%d:%s", repoID, ref.Hash())
+	if data, found := s.TreeCache.Get(cacheKey); found {
+		return data.(LanguageStats), nil
+	}
+
+	commit, err := r.CommitObject(ref.Hash())
+	if err != nil {
+		return LanguageStats{}, fmt.Errorf("获取 Commit 对象失败: %w", err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return LanguageStats{}, fmt.Errorf("获取 Tree 失败: %w", err)
+	}
+
+	settings := s.GetRepoSettings(repoID)
+	ignored := s.IgnoredPaths
+	if settings.SearchIgnore != nil {
+		ignored = settings.SearchIgnore
+	}
+
+	stats := LanguageStats{Languages: make(map[string]int64), Commit: ref.Hash().String()}
+	res, err := codetree.Walk(ctx, tree, s.WalkLimits, func(name string, entry object.TreeEntry) (int64, error) {
+		// 只统计普通文件，符号链接的内容只是链接目标
+		if entry.Mode == filemode.Symlink {
+			return 0, nil
+		}
+		lang := codetree.DetectLanguage(name)
+		if lang == codetree.Plaintext || settings.IsHidden(name) || codetree.MatchAnyPattern(ignored, name) {
+			return 0, nil
+		}
+		size, err := r.Storer.EncodedObjectSize(entry.Hash)
+		if err != nil {
+			return 0, fmt.Errorf("读取文件 '%s' 大小失败: %w", name, err)
+		}
+		stats.Languages[lang] += size
+		stats.Total += size
+		stats.Files++
+		return size, nil
+	})
+	if err != nil {
+		return LanguageStats{}, err
+	}
+	if res.Truncated {
+		stats.Truncated = true
+		slog.Warn("遍历超出限制，语言统计只包含部分文件", "repo", repoID, "limit", res.Reason, "files", res.Files)
+	}
+
+	if res.Reason != codetree.LimitTimeout {
+		s.TreeCache.Set(cacheKey, stats, cache.DefaultExpiration)
+	}
+	return stats, nil
+}
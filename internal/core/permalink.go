@@ -0,0 +1,127 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"code-browser/internal/repo"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// ErrInvalidLineRange 行号不是正整数，或 endLine 小于 startLine / 缺少 startLine
+var ErrInvalidLineRange = errors.New("无效的行范围")
+
+// BlobURL 文件 (及可选行范围) 的规范链接，由服务端统一生成，前端的 "复制链接" 直接使用
+type BlobURL struct {
+	WebURL    string `json:"webUrl"` // 前端路由 (相对服务根路径): /?repo=<id>&path=<path>[&rev=<commit>][#L<start>[-L<end>]]
+	APIURL    string `json:"apiUrl"` // 文件内容接口 (HEAD，指定 rev 时为该提交)
+	Path      string `json:"path"`   // 规范化后的路径
+	StartLine int    `json:"startLine,omitempty"`
+	EndLine   int    `json:"endLine,omitempty"`
+	Rev       string `json:"rev,omitempty"` // rev 解析后的完整提交哈希，链接不随分支移动而变化
+}
+
+// BlobURL 校验路径和行范围并生成规范链接。
+// 路径必须是 HEAD (指定 rev 时为该提交) 中未被隐藏的文件；越出仓库根目录的路径返回 ErrInvalidPath，
+// 不存在的路径返回 ErrPathNotFound，无法解析的 rev 返回 repo.ErrRevisionNotFound。
+// endLine 为 0 表示单行；startLine 为 0 表示不带行号。
+func (s *Service) BlobURL(repoID uint32, relPath string, startLine, endLine int, rev string) (BlobURL, error) {
+	if startLine < 0 || endLine < 0 || (endLine > 0 && (startLine == 0 || endLine < startLine)) {
+		return BlobURL{}, fmt.Errorf("%w: %d-%d", ErrInvalidLineRange, startLine, endLine)
+	}
+	if endLine == startLine {
+		endLine = 0
+	}
+
+	var cleaned, commit string
+	if rev == "" {
+		info, err := s.ResolvePath(repoID, relPath)
+		if err != nil {
+			return BlobURL{}, err
+		}
+		if info.Type != "file" {
+			return BlobURL{}, fmt.Errorf("%w: '%s' 不是文件", ErrInvalidPath, info.Path)
+		}
+		cleaned = info.Path
+	} else {
+		var err error
+		if cleaned, commit, err = s.resolveAtRevision(repoID, relPath, rev); err != nil {
+			return BlobURL{}, err
+		}
+	}
+
+	id := strconv.FormatUint(uint64(repoID), 10)
+	query := url.Values{"repo": {id}, "path": {cleaned}}
+	if commit != "" {
+		query.Set("rev", commit)
+	}
+	web := "/?" + query.Encode()
+	if startLine > 0 {
+		web += "#L" + strconv.Itoa(startLine)
+		if endLine > 0 {
+			web += "-L" + strconv.Itoa(endLine)
+		}
+	}
+	blobQuery := url.Values{"path": {cleaned}}
+	if commit != "" {
+		blobQuery.Set("rev", commit)
+	}
+	return BlobURL{
+		WebURL:    web,
+		APIURL:    "/api/repositories/" + id + "/blob?" + blobQuery.Encode(),
+		Path:      cleaned,
+		StartLine: startLine,
+		EndLine:   endLine,
+		Rev:       commit,
+	}, nil
+}
+
+// resolveAtRevision 检查 relPath 是 rev 对应提交中未被隐藏的文件，返回规范化路径和完整提交哈希
+func (s *Service) resolveAtRevision(repoID uint32, relPath, rev string) (string, string, error) {
+	if err := s.checkEnabled(repoID); err != nil {
+		return "", "", err
+	}
+	cleaned, err := cleanRepoPath(relPath)
+	if err != nil {
+		return "", "", err
+	}
+	if cleaned == "" || s.GetRepoSettings(repoID).IsHidden(cleaned) {
+		return "", "", fmt.Errorf("%w: '%s'", ErrPathNotFound, cleaned)
+	}
+	repoInfo, ok := s.RepoProvider.GetRepo(repoID)
+	if !ok {
+		return "", "", fmt.Errorf("仓库 ID '%d' 未找到", repoID)
+	}
+	r, err := git.PlainOpen(repoInfo.SourcePath)
+	if err != nil {
+		return "", "", fmt.Errorf("打开 Git 仓库失败: %w", err)
+	}
+	hash, err := r.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return "", "", fmt.Errorf("%w: '%s'", repo.ErrRevisionNotFound, rev)
+	}
+	c, err := r.CommitObject(*hash)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: '%s' 不是提交", repo.ErrRevisionNotFound, rev)
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return "", "", fmt.Errorf("获取 Tree 失败: %w", err)
+	}
+	entry, err := tree.FindEntry(cleaned)
+	if errors.Is(err, object.ErrEntryNotFound) || errors.Is(err, object.ErrDirectoryNotFound) {
+		return "", "", fmt.Errorf("%w: '%s' @ %s", ErrPathNotFound, cleaned, rev)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("查找路径 '%s' 失败: %w", cleaned, err)
+	}
+	if !entry.Mode.IsFile() {
+		return "", "", fmt.Errorf("%w: '%s' 不是文件", ErrInvalidPath, cleaned)
+	}
+	return cleaned, hash.String(), nil
+}
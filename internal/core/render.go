@@ -0,0 +1,171 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	goldmarkhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+// 预览格式 (RenderedDoc.Format)
+const (
+	RenderMarkdown = "markdown"
+	RenderHTML     = "html"
+	RenderText     = "text"
+	RenderCSV      = "csv"
+	RenderImage    = "image"
+	RenderRaw      = "raw" // 未识别的类型，返回原始内容
+)
+
+// maxRenderCSVRows CSV/TSV 预览最多渲染的行数 (含表头)，超出部分截断
+const maxRenderCSVRows = 1000
+
+// renderFormatByExt 扩展名对应的预览格式
+var renderFormatByExt = map[string]string{
+	"md": RenderMarkdown, "markdown": RenderMarkdown, "mdown": RenderMarkdown, "mkd": RenderMarkdown,
+	"html": RenderHTML, "htm": RenderHTML,
+	"txt": RenderText, "text": RenderText, "rst": RenderText, "adoc": RenderText, "asciidoc": RenderText,
+	"csv": RenderCSV, "tsv": RenderCSV,
+	"png": RenderImage, "jpg": RenderImage, "jpeg": RenderImage, "gif": RenderImage, "webp": RenderImage, "svg": RenderImage,
+}
+
+// imageContentTypes 图片预览使用的 MIME 类型
+var imageContentTypes = map[string]string{
+	"png": "image/png", "jpg": "image/jpeg", "jpeg": "image/jpeg", "gif": "image/gif", "webp": "image/webp", "svg": "image/svg+xml",
+}
+
+// markdown 允许原始 HTML (README 中常见)，输出统一经过 docPolicy 清理
+var markdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithRendererOptions(goldmarkhtml.WithUnsafe()),
+)
+
+// docPolicy 渲染结果的 HTML 白名单: 在用户内容策略的基础上允许代码块的语言 class 和任务列表的复选框。
+// 脚本、事件属性、iframe、style 以及 javascript: 等链接都会被移除
+var docPolicy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#-]+$`)).OnElements("code")
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	return p
+}()
+
+// RenderedDoc 文件的预览
+type RenderedDoc struct {
+	Format string
+	// HTML 清理后的 HTML 片段；Format 为 RenderRaw 时为空，此时 Content 为原始内容
+	HTML        string
+	Content     []byte
+	ContentType string
+	Truncated   bool // CSV/TSV 超过 maxRenderCSVRows 行，只渲染了前面的部分
+}
+
+// RenderFile 按扩展名把文件渲染为 HTML 预览: Markdown 转换为 HTML，HTML 文档直接清理，纯文本文档放入 <pre>，
+// CSV/TSV 转换为表格，图片生成 data: URI 的 <img>；其余类型返回原始内容 (Format 为 RenderRaw)。
+// 内容来自 GetFileContent，受 MaxFileSize 限制；越出仓库根目录的路径返回 ErrInvalidPath
+func (s *Service) RenderFile(repoID uint32, relPath string) (RenderedDoc, error) {
+	cleaned, err := cleanRepoPath(relPath)
+	if err != nil {
+		return RenderedDoc{}, err
+	}
+	if cleaned == "" {
+		return RenderedDoc{}, fmt.Errorf("%w: ''", ErrPathIsDirectory)
+	}
+	content, contentType, err := s.GetFileContent(repoID, cleaned)
+	if err != nil {
+		return RenderedDoc{}, err
+	}
+
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(cleaned), "."))
+	doc := RenderedDoc{Format: renderFormatByExt[ext]}
+	switch doc.Format {
+	case RenderMarkdown:
+		var buf bytes.Buffer
+		if err := markdown.Convert(content, &buf); err != nil {
+			return RenderedDoc{}, fmt.Errorf("渲染 Markdown 失败: %w", err)
+		}
+		doc.HTML = docPolicy.Sanitize(buf.String())
+	case RenderHTML:
+		doc.HTML = docPolicy.Sanitize(string(content))
+	case RenderText:
+		doc.HTML = "<pre>" + html.EscapeString(string(content)) + "</pre>"
+	case RenderCSV:
+		comma := ','
+		if ext == "tsv" {
+			comma = '\t'
+		}
+		doc.HTML, doc.Truncated, err = renderTable(content, comma)
+		if err != nil {
+			return RenderedDoc{}, err
+		}
+	case RenderImage:
+		src := "data:" + imageContentTypes[ext] + ";base64," + base64.StdEncoding.EncodeToString(content)
+		doc.HTML = fmt.Sprintf(`<img src="%s" alt="%s">`, src, html.EscapeString(path.Base(cleaned)))
+	default:
+		doc.Format = RenderRaw
+		doc.Content, doc.ContentType = content, contentType
+	}
+	return doc, nil
+}
+
+// renderTable 把 CSV/TSV 转换为 <table>，第一行作为表头；各行的列数可以不同
+func renderTable(content []byte, comma rune) (string, bool, error) {
+	r := csv.NewReader(bytes.NewReader(content))
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	var rows [][]string
+	truncated := false
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", false, fmt.Errorf("解析表格失败: %w", err)
+		}
+		if len(rows) == maxRenderCSVRows {
+			truncated = true
+			break
+		}
+		rows = append(rows, record)
+	}
+
+	var b strings.Builder
+	b.WriteString("<table>")
+	for i, row := range rows {
+		cell := "td"
+		switch i {
+		case 0:
+			cell = "th"
+			b.WriteString("<thead>")
+		case 1:
+			b.WriteString("<tbody>")
+		}
+		b.WriteString("<tr>")
+		for _, field := range row {
+			fmt.Fprintf(&b, "<%s>%s</%s>", cell, html.EscapeString(field), cell)
+		}
+		b.WriteString("</tr>")
+		if i == 0 {
+			b.WriteString("</thead>")
+		}
+	}
+	if len(rows) > 1 {
+		b.WriteString("</tbody>")
+	}
+	b.WriteString("</table>")
+	return b.String(), truncated, nil
+}
@@ -0,0 +1,110 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// 路径错误: 不存在，或类型与请求的不符 (对目录请求文件内容、对文件请求目录列表)
+var (
+	// ErrPathNotFound 路径在 HEAD 中不存在 (或被仓库设置隐藏)
+	ErrPathNotFound = errors.New("路径不存在")
+	// ErrPathIsDirectory 请求文件内容的路径是目录
+	ErrPathIsDirectory = errors.New("路径是一个目录")
+	// ErrPathIsFile 请求目录列表的路径是文件
+	ErrPathIsFile = errors.New("路径是一个文件")
+)
+
+// PathInfo 精确路径解析的结果
+type PathInfo struct {
+	Name string `json:"name"`
+	Path string `json:"path"` // 规范化后的路径，根目录为 ""
+	Type string `json:"type"` // "file" | "directory" | "submodule"，与目录树一致
+	// 以下仅对文件有效
+	Size       int64  `json:"size,omitempty"`
+	Language   string `json:"language,omitempty"`
+	IsBinary   bool   `json:"isBinary,omitempty"`
+	Executable bool   `json:"executable,omitempty"`
+	Symlink    bool   `json:"symlink,omitempty"`
+}
+
+// ResolvePath 检查 relPath 是否是 HEAD 中的文件或目录并返回其类型和元数据。
+// 越出仓库根目录的路径返回 ErrInvalidPath；不存在或被 hiddenPaths 隐藏的路径返回 ErrPathNotFound。
+// 不读取文件内容，IsBinary 只按扩展名判断。
+func (s *Service) ResolvePath(repoID uint32, relPath string) (PathInfo, error) {
+	if err := s.checkEnabled(repoID); err != nil {
+		return PathInfo{}, err
+	}
+	cleaned, err := cleanRepoPath(relPath)
+	if err != nil {
+		return PathInfo{}, err
+	}
+	settings := s.GetRepoSettings(repoID)
+	if cleaned == "" {
+		return PathInfo{Type: "directory"}, nil
+	}
+	if settings.IsHidden(cleaned) {
+		return PathInfo{}, fmt.Errorf("%w: '%s'", ErrPathNotFound, cleaned)
+	}
+
+	repoInfo, ok := s.RepoProvider.GetRepo(repoID)
+	if !ok {
+		return PathInfo{}, fmt.Errorf("仓库 ID '%d' 未找到", repoID)
+	}
+	tree, err := openHeadTree(repoInfo.SourcePath)
+	if err != nil {
+		return PathInfo{}, err
+	}
+	if err := checkNotInSubmodule(tree, cleaned); err != nil {
+		return PathInfo{}, fmt.Errorf("%w: %w", ErrPathNotFound, err)
+	}
+	entry, err := findEntry(tree, cleaned)
+	if err != nil {
+		return PathInfo{}, err
+	}
+
+	info := PathInfo{Name: path.Base(cleaned), Path: cleaned, Type: "directory"}
+	if entry.Mode == filemode.Submodule {
+		info.Type = "submodule"
+		return info, nil
+	}
+	if !entry.Mode.IsFile() {
+		return info, nil
+	}
+	info.Type = "file"
+	info.Language = settings.Language(cleaned)
+	info.IsBinary = IsBinaryPath(cleaned)
+	info.Executable = entry.Mode == filemode.Executable
+	info.Symlink = entry.Mode == filemode.Symlink
+	if size, err := tree.Size(cleaned); err == nil {
+		info.Size = size
+	}
+	return info, nil
+}
+
+// findEntry 查找 tree 中的条目。路径不存在时 (包括中间某一级是文件而不是目录) 返回 ErrPathNotFound，
+// 其余错误 (如对象损坏) 原样包装返回
+func findEntry(tree *object.Tree, p string) (*object.TreeEntry, error) {
+	entry, err := tree.FindEntry(p)
+	if err == nil {
+		return entry, nil
+	}
+	if errors.Is(err, object.ErrEntryNotFound) || errors.Is(err, object.ErrDirectoryNotFound) || throughFile(tree, p) {
+		return nil, fmt.Errorf("%w: '%s'", ErrPathNotFound, p)
+	}
+	return nil, fmt.Errorf("查找路径 '%s' 失败: %w", p, err)
+}
+
+// throughFile 判断 p 的某一级上级路径是否是文件 (如 "main.go/x")
+func throughFile(tree *object.Tree, p string) bool {
+	for dir := path.Dir(p); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if entry, err := tree.FindEntry(dir); err == nil && entry.Mode != filemode.Dir {
+			return true
+		}
+	}
+	return false
+}
@@ -1,70 +1,187 @@
 package core
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"code-browser/internal/codetree"
+	"code-browser/internal/lru"
 	"code-browser/internal/repo"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/patrickmn/go-cache"
 )
 
 // Service 提供文件系统操作的核心逻辑，包含缓存
 type Service struct {
-	RepoProvider *repo.Provider
-	Cache        *cache.Cache
+	RepoProvider repo.RepoProvider
+	TreeCache    *cache.Cache // 目录树与文件列表缓存
+	BlobCache    *lru.Cache   // 文件内容缓存 (按字节数有界的 LRU，避免大文件撑爆内存)
+	MaxFileSize  int64        // GetFileContent 允许读取的最大文件字节数 (0 表示不限制)
+
+	// ContentAddressedBlobs 为 true 时文件内容按 git blob 哈希缓存，路径只映射到哈希，
+	// 不同路径/仓库中内容相同的文件 (如 monorepo 中重复 vendor 的文件) 在内存中只保存一份
+	ContentAddressedBlobs bool
+
+	// PlainSymlinks 为 true 时符号链接按普通文件显示，内容为链接目标文本 (不跟随、不标记)；
+	// 默认跟随指向仓库内部的链接，拒绝指向仓库之外的链接 (见 resolveSymlinks)
+	PlainSymlinks bool
+
+	// IgnoredPaths 依赖和生成代码的路径模式 (服务端的 -search-ignore 列表)，语言统计不计入；
+	// 仓库设置了 searchIgnore 时使用仓库的列表
+	IgnoredPaths []string
+
+	// WalkLimits 限制遍历整个目录树的操作 (文件列表、语言统计)，零值使用默认限制 (见 codetree.Walk)
+	WalkLimits codetree.Limits
 }
 
+// ErrFileTooLarge 表示文件超过 MaxFileSize，不返回其内容
+var ErrFileTooLarge = errors.New("文件过大")
+
 // blobCacheEntry 用于缓存文件内容及其类型
 type blobCacheEntry struct {
 	Content     []byte
 	ContentType string
 }
 
+// blobRefKey 返回内容寻址模式下 路径→哈希 映射的缓存键
+func blobRefKey(repoID uint32, relPath string) string {
+	return fmt.Sprintf("blobref:%d:%s", repoID, relPath)
+}
+
+// blobHashKey 返回内容寻址模式下文件内容的缓存键
+func blobHashKey(hash string) string {
+	return "blobhash:" + hash
+}
+
+// MaxListFiles 是 ListAllFiles 返回的文件数量上限，防止超大仓库撑爆内存和响应
+const MaxListFiles = codetree.DefaultMaxFiles
+
 // NewService 创建核心服务
-func NewService(repoProvider *repo.Provider, cache *cache.Cache) *Service {
-	return &Service{
+func NewService(repoProvider repo.RepoProvider, treeCache *cache.Cache, blobCache *lru.Cache) *Service {
+	s := &Service{
 		RepoProvider: repoProvider,
-		Cache:        cache,
+		TreeCache:    treeCache,
+		BlobCache:    blobCache,
 	}
+	// 仓库重新索引或删除后，清理该仓库相关的缓存
+	repoProvider.OnRepoChanged(s.InvalidateRepo)
+	return s
 }
 
 // RepositoryInfo 用于 ListRepositories 返回的简化结构
 type RepositoryInfo struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Indexed bool     `json:"indexed"` // 是否已有 Zoekt 索引 (未索引时 Zoekt 搜索不可用)
+	HasScip bool     `json:"hasScip"` // 是否已注册 SCIP 索引 (精确跳转)
+	Tags    []string `json:"tags"`    // 仓库标签
+
+	DefaultBranch string `json:"defaultBranch"` // 默认分支，未知时为空
+	Enabled       bool   `json:"enabled"`       // 为 false 时仓库仍然列出，但浏览和搜索返回 403
 }
 
+// indexStatusTTL 索引状态的缓存时间；索引完成时会通过 InvalidateRepo 立即刷新
+const indexStatusTTL = 30 * time.Second
+
 // FileInfo 用于 GetTree 返回的文件信息
 type FileInfo struct {
 	Name string `json:"name"`
 	Path string `json:"path"`
 	Type string `json:"type"`
+
+	// 符号链接条目 (PlainSymlinks 时不设置)；Target 为链接中保存的原始目标
+	Symlink bool   `json:"symlink,omitempty"`
+	Target  string `json:"target,omitempty"`
+
+	// 子模块条目 (Type 为 "submodule") 的地址和提交
+	Submodule *SubmoduleInfo `json:"submodule,omitempty"`
 }
 
 // ListRepositories 获取所有仓库列表（带缓存）
-func (s *Service) ListRepositories() ([]RepositoryInfo, error) {
-	repos := s.RepoProvider.GetAll()
+// query 非空时只返回名称匹配的仓库 (忽略大小写和变音符号)，tag 非空时只返回带该标签的仓库
+func (s *Service) ListRepositories(query, tag string) ([]RepositoryInfo, error) {
+	repos := repo.FilterReposByTag(s.RepoProvider.FindRepos(query), tag)
 	infos := make([]RepositoryInfo, len(repos))
 	for i, repo := range repos {
+		status := s.indexStatus(repo.RepoID)
 		infos[i] = RepositoryInfo{
-			ID:   strconv.FormatUint(uint64(repo.RepoID), 10),
-			Name: repo.Name,
+			ID:      strconv.FormatUint(uint64(repo.RepoID), 10),
+			Name:    repo.Name,
+			Indexed: status.Zoekt,
+			HasScip: status.Scip,
+			Tags:    append([]string{}, repo.Tags...),
+
+			DefaultBranch: repo.DefaultBranch,
+			Enabled:       !repo.Disabled,
 		}
 	}
 	return infos, nil
 }
 
+// checkEnabled 仓库已停用时返回 repo.ErrRepoDisabled。
+// 在读取缓存之前调用，保证停用立即生效；仓库不存在的情况留给后续查找报告
+func (s *Service) checkEnabled(repoID uint32) error {
+	if repoInfo, ok := s.RepoProvider.GetRepo(repoID); ok {
+		return repoInfo.CheckEnabled()
+	}
+	return nil
+}
+
+// indexStatus 返回仓库的索引状态 (短暂缓存，避免每次列表请求都扫描索引目录)
+func (s *Service) indexStatus(repoID uint32) repo.IndexStatus {
+	cacheKey := fmt.Sprintf("indexstatus:%d", repoID)
+	if data, found := s.TreeCache.Get(cacheKey); found {
+		return data.(repo.IndexStatus)
+	}
+	status, err := s.RepoProvider.GetIndexStatus(repoID)
+	if err != nil {
+		slog.Warn("获取仓库索引状态失败", "repo", repoID, "err", err)
+		return status
+	}
+	s.TreeCache.Set(cacheKey, status, indexStatusTTL)
+	return status
+}
+
 // GetTree 获取指定仓库和路径下的文件树（带缓存）
 // 使用 go-git 读取 HEAD commit 中的文件树，天然支持 gitignore 且不依赖本地文件系统状态
+// 仓库设置 (.code-browser.json) 中 hiddenPaths 匹配的条目不会返回
 func (s *Service) GetTree(repoID uint32, relPath string) ([]FileInfo, error) {
+	if err := s.checkEnabled(repoID); err != nil {
+		return nil, err
+	}
+	files, err := s.getTree(repoID, relPath)
+	if err != nil {
+		return nil, err
+	}
+	settings := s.GetRepoSettings(repoID)
+	if len(settings.HiddenPaths) == 0 {
+		return files, nil
+	}
+	visible := make([]FileInfo, 0, len(files))
+	for _, f := range files {
+		if !settings.IsHidden(f.Path) {
+			visible = append(visible, f)
+		}
+	}
+	return visible, nil
+}
+
+// getTree 实现 GetTree，缓存未过滤的完整列表，使设置变化后无需重新读取目录
+func (s *Service) getTree(repoID uint32, relPath string) ([]FileInfo, error) {
 	cacheKey := fmt.Sprintf("tree:%d:%s", repoID, relPath)
-	if data, found := s.Cache.Get(cacheKey); found {
+	if data, found := s.TreeCache.Get(cacheKey); found {
 		return data.([]FileInfo), nil
 	}
 
@@ -105,21 +222,33 @@ func (s *Service) GetTree(repoID uint32, relPath string) ([]FileInfo, error) {
 		// relPath 应该是相对根目录的路径，不包含前导 /
 		gitPath := filepath.ToSlash(cleanRelPath)
 		gitPath = strings.TrimPrefix(gitPath, "/")
-
-		entry, err := tree.FindEntry(gitPath)
-		if err != nil {
-			// 如果找不到路径，或者路径不是一个目录，返回错误或空列表
-			// object.ErrEntryNotFound
-			return nil, fmt.Errorf("路径 '%s' 在 HEAD 中未找到: %w", gitPath, err)
-		}
-
-		if entry.Mode != 16384 && entry.Mode.String() != "040000" {
-			return nil, fmt.Errorf("路径 '%s' 不是一个目录", gitPath)
+		if !s.PlainSymlinks {
+			// 路径经过指向仓库内目录的符号链接时，列出链接目标目录
+			if gitPath, err = resolveSymlinks(tree, gitPath); err != nil {
+				return nil, err
+			}
 		}
 
-		targetTree, err = tree.Tree(gitPath)
-		if err != nil {
-			return nil, fmt.Errorf("获取子 Tree 失败: %w", err)
+		if gitPath != "" {
+			if err := checkNotInSubmodule(tree, gitPath); err != nil {
+				return nil, err
+			}
+			entry, err := findEntry(tree, gitPath)
+			if err != nil {
+				return nil, err
+			}
+
+			if entry.Mode == filemode.Submodule {
+				return nil, fmt.Errorf("%w: '%s' 是子模块，其内容不在此仓库中", ErrInSubmodule, gitPath)
+			}
+			if entry.Mode != 16384 && entry.Mode.String() != "040000" {
+				return nil, fmt.Errorf("%w: '%s'", ErrPathIsFile, gitPath)
+			}
+
+			targetTree, err = tree.Tree(gitPath)
+			if err != nil {
+				return nil, fmt.Errorf("获取子 Tree 失败: %w", err)
+			}
 		}
 	}
 
@@ -135,25 +264,82 @@ func (s *Service) GetTree(repoID uint32, relPath string) ([]FileInfo, error) {
 		// 构建相对路径用于前端导航
 		entryPath := filepath.Join(relPath, entry.Name)
 
-		files = append(files, FileInfo{
+		info := FileInfo{
 			Name: entry.Name,
 			Path: filepath.ToSlash(entryPath),
 			Type: fileType,
-		})
+		}
+		if entry.Mode == filemode.Symlink && !s.PlainSymlinks {
+			describeSymlink(tree, entry, strings.TrimPrefix(info.Path, "/"), &info)
+		}
+		if entry.Mode == filemode.Submodule {
+			// 子模块 (gitlink) 只记录提交哈希，内容在另一个仓库中，不能作为目录展开
+			info.Type = "submodule"
+			info.Submodule = &SubmoduleInfo{Commit: entry.Hash.String()}
+		}
+		files = append(files, info)
 	}
+	s.describeSubmodules(tree, files)
+
+	// 目录 (和子模块) 优先、同类按名称排序，保证分页结果稳定
+	sort.Slice(files, func(i, j int) bool {
+		if di, dj := files[i].Type != "file", files[j].Type != "file"; di != dj {
+			return di
+		}
+		return files[i].Name < files[j].Name
+	})
 
-	s.Cache.Set(cacheKey, files, cache.DefaultExpiration)
+	s.TreeCache.Set(cacheKey, files, cache.DefaultExpiration)
 	return files, nil
 }
 
+// GetTreePage 返回目录列表的一页以及该目录的条目总数
+// 完整列表只读取并缓存一次，分页仅在缓存结果上切片
+func (s *Service) GetTreePage(repoID uint32, relPath string, offset, limit int) ([]FileInfo, int, error) {
+	files, err := s.GetTree(repoID, relPath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total := len(files)
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > total {
+		offset = total
+	}
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+	return files[offset:end], total, nil
+}
+
 // GetFileContent 获取指定仓库和路径的文件内容（带缓存）
 // 返回内容字节和推断的 Content-Type
 func (s *Service) GetFileContent(repoID uint32, relPath string) ([]byte, string, error) {
+	return s.GetFileContentAt(repoID, relPath, "")
+}
+
+// GetFileContentAt 同 GetFileContent，rev 非空时读取 rev 对应提交中的文件。
+// 无法解析的 rev 返回 repo.ErrRevisionNotFound；指定提交的内容不会变化，按提交哈希缓存。
+func (s *Service) GetFileContentAt(repoID uint32, relPath, rev string) ([]byte, string, error) {
+	if err := s.checkEnabled(repoID); err != nil {
+		return nil, "", err
+	}
 	cacheKey := fmt.Sprintf("blob:%d:%s", repoID, relPath)
-	if data, found := s.Cache.Get(cacheKey); found {
-		log.Printf("DEBUG: 文件内容缓存命中: %s", cacheKey)
-		entry := data.(blobCacheEntry)
-		return entry.Content, entry.ContentType, nil
+	if rev == "" {
+		if s.ContentAddressedBlobs {
+			// 路径→哈希 映射命中时无需打开仓库
+			if hash, found := s.BlobCache.Get(blobRefKey(repoID, relPath)); found {
+				cacheKey = blobHashKey(hash.(string))
+			}
+		}
+		if data, found := s.BlobCache.Get(cacheKey); found {
+			slog.Debug("文件内容缓存命中", "key", cacheKey)
+			entry := data.(blobCacheEntry)
+			return entry.Content, entry.ContentType, nil
+		}
 	}
 
 	repoInfo, ok := s.RepoProvider.GetRepo(repoID)
@@ -167,15 +353,33 @@ func (s *Service) GetFileContent(repoID uint32, relPath string) ([]byte, string,
 		return nil, "", fmt.Errorf("打开 Git 仓库失败: %w", err)
 	}
 
-	// 2. 获取 HEAD 引用
-	ref, err := r.Head()
-	if err != nil {
-		return nil, "", fmt.Errorf("获取 HEAD 引用失败: %w", err)
+	// 2. 获取 HEAD (或 rev) 指向的提交哈希
+	var commitHash plumbing.Hash
+	if rev == "" {
+		ref, err := r.Head()
+		if err != nil {
+			return nil, "", fmt.Errorf("获取 HEAD 引用失败: %w", err)
+		}
+		commitHash = ref.Hash()
+	} else {
+		hash, err := r.ResolveRevision(plumbing.Revision(rev))
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: '%s'", repo.ErrRevisionNotFound, rev)
+		}
+		commitHash = *hash
+		cacheKey = fmt.Sprintf("blob:%d:%s@%s", repoID, relPath, commitHash)
+		if data, found := s.BlobCache.Get(cacheKey); found {
+			entry := data.(blobCacheEntry)
+			return entry.Content, entry.ContentType, nil
+		}
 	}
 
-	// 3. 获取 HEAD 指向的 Commit 对象
-	commit, err := r.CommitObject(ref.Hash())
+	// 3. 获取对应的 Commit 对象
+	commit, err := r.CommitObject(commitHash)
 	if err != nil {
+		if rev != "" {
+			return nil, "", fmt.Errorf("%w: '%s' 不是提交", repo.ErrRevisionNotFound, rev)
+		}
 		return nil, "", fmt.Errorf("获取 Commit 对象失败: %w", err)
 	}
 
@@ -189,14 +393,41 @@ func (s *Service) GetFileContent(repoID uint32, relPath string) ([]byte, string,
 	cleanRelPath := filepath.Clean(relPath)
 	gitPath := filepath.ToSlash(cleanRelPath)
 	gitPath = strings.TrimPrefix(gitPath, "/")
+	if !s.PlainSymlinks {
+		// 指向仓库内文件的符号链接返回目标文件的内容
+		if gitPath, err = resolveSymlinks(tree, gitPath); err != nil {
+			return nil, "", err
+		}
+	}
 
-	entry, err := tree.FindEntry(gitPath)
+	if err := checkNotInSubmodule(tree, gitPath); err != nil {
+		return nil, "", err
+	}
+	entry, err := findEntry(tree, gitPath)
 	if err != nil {
-		return nil, "", fmt.Errorf("文件 '%s' 未找到: %w", gitPath, err)
+		return nil, "", err
+	}
+
+	switch {
+	case entry.Mode == filemode.Submodule:
+		return nil, "", fmt.Errorf("%w: '%s' 是子模块，其内容不在此仓库中", ErrInSubmodule, gitPath)
+	case !entry.Mode.IsFile():
+		return nil, "", fmt.Errorf("%w: '%s'", ErrPathIsDirectory, gitPath)
 	}
 
-	if !entry.Mode.IsFile() {
-		return nil, "", fmt.Errorf("路径 '%s' 不是一个文件", gitPath)
+	if s.ContentAddressedBlobs {
+		// git blob 哈希即内容哈希，直接取自 tree entry，无需再读一遍内容计算
+		hash := entry.Hash.String()
+		if rev == "" {
+			refKey := blobRefKey(repoID, relPath)
+			s.BlobCache.Set(refKey, hash, int64(len(refKey)+len(hash)))
+		}
+		cacheKey = blobHashKey(hash)
+		if data, found := s.BlobCache.Get(cacheKey); found {
+			slog.Debug("文件内容缓存命中 (相同内容)", "key", cacheKey, "path", gitPath)
+			entry := data.(blobCacheEntry)
+			return entry.Content, entry.ContentType, nil
+		}
 	}
 
 	// 6. 获取 Blob 对象并读取内容
@@ -210,6 +441,10 @@ func (s *Service) GetFileContent(repoID uint32, relPath string) ([]byte, string,
 		}
 	}
 
+	if s.MaxFileSize > 0 && blob.Size > s.MaxFileSize {
+		return nil, "", fmt.Errorf("%w: '%s' 大小 %d 字节, 上限 %d 字节", ErrFileTooLarge, gitPath, blob.Size, s.MaxFileSize)
+	}
+
 	reader, err := blob.Reader()
 	if err != nil {
 		return nil, "", fmt.Errorf("创建 Blob Reader 失败: %w", err)
@@ -231,7 +466,122 @@ func (s *Service) GetFileContent(repoID uint32, relPath string) ([]byte, string,
 		Content:     content,
 		ContentType: contentType,
 	}
-	s.Cache.Set(cacheKey, entryCache, cache.DefaultExpiration)
+	s.BlobCache.Set(cacheKey, entryCache, int64(len(content)))
 
 	return content, contentType, nil
 }
+
+// ListAllFiles 返回仓库 HEAD 中所有文件的相对路径（带缓存）
+// 遍历 git tree 而非工作区，因此天然跳过 .git 和被 gitignore 忽略的文件。
+// 遍历受 WalkLimits 限制 (默认最多 MaxListFiles 个文件)，超出部分会被截断；ctx 结束时同样停止遍历并返回截断的列表。
+// 仓库设置中隐藏的路径不会返回。
+func (s *Service) ListAllFiles(ctx context.Context, repoID uint32) ([]string, error) {
+	files, _, err := s.ListAllFilesTruncated(ctx, repoID)
+	return files, err
+}
+
+// ListAllFilesTruncated 同 ListAllFiles，另外返回列表是否因遍历限制被截断
+func (s *Service) ListAllFilesTruncated(ctx context.Context, repoID uint32) ([]string, bool, error) {
+	if err := s.checkEnabled(repoID); err != nil {
+		return nil, false, err
+	}
+	list, err := s.listAllFiles(ctx, repoID)
+	if err != nil {
+		return nil, false, err
+	}
+	return s.GetRepoSettings(repoID).FilterHidden(list.files), list.truncated, nil
+}
+
+// fileList 缓存的文件列表及其是否被截断
+type fileList struct {
+	files     []string
+	truncated bool
+}
+
+// listAllFiles 实现 ListAllFiles，缓存未过滤的完整列表。
+// 因超时 (或 ctx 结束) 截断的列表只返回给本次调用、不缓存，下次请求重新遍历
+func (s *Service) listAllFiles(ctx context.Context, repoID uint32) (fileList, error) {
+	cacheKey := fmt.Sprintf("filelist:%d", repoID)
+	if data, found := s.TreeCache.Get(cacheKey); found {
+		return data.(fileList), nil
+	}
+
+	repoInfo, ok := s.RepoProvider.GetRepo(repoID)
+	if !ok {
+		return fileList{}, fmt.Errorf("仓库 ID '%d' 未找到", repoID)
+	}
+
+	tree, err := openHeadTree(repoInfo.SourcePath)
+	if err != nil {
+		return fileList{}, err
+	}
+
+	// 只收集文件 (包括可执行文件和符号链接)，目录和 submodule 由 codetree.Walk 跳过
+	files := make([]string, 0)
+	res, err := codetree.Walk(ctx, tree, s.WalkLimits, func(name string, _ object.TreeEntry) (int64, error) {
+		files = append(files, name)
+		return 0, nil
+	})
+	if err != nil {
+		return fileList{}, err
+	}
+	if res.Truncated {
+		slog.Warn("遍历超出限制，文件列表已截断", "repo", repoID, "limit", res.Reason, "files", res.Files)
+	}
+
+	list := fileList{files: files, truncated: res.Truncated}
+	if res.Reason != codetree.LimitTimeout {
+		s.TreeCache.Set(cacheKey, list, cache.DefaultExpiration)
+	}
+	return list, nil
+}
+
+// InvalidateRepo 清除指定仓库的所有缓存 (目录树、文件内容、文件列表、索引状态、仓库设置)
+func (s *Service) InvalidateRepo(repoID uint32) {
+	s.TreeCache.Delete(fmt.Sprintf("filelist:%d", repoID))
+	s.TreeCache.Delete(fmt.Sprintf("indexstatus:%d", repoID))
+	s.TreeCache.Delete(fmt.Sprintf("settings:%d", repoID))
+	deleteByPrefix(s.TreeCache, fmt.Sprintf("tree:%d:", repoID))
+	deleteByPrefix(s.TreeCache, fmt.Sprintf("archive:%d:", repoID))
+	deleteByPrefix(s.TreeCache, fmt.Sprintf("languages:%d:", repoID))
+	s.BlobCache.DeletePrefix(fmt.Sprintf("blob:%d:", repoID))
+	// 内容寻址模式: 同时清除该仓库引用的内容条目 (其他仓库的相同内容会在下次读取时重新加载)
+	for _, hash := range s.BlobCache.DeletePrefixValues(fmt.Sprintf("blobref:%d:", repoID)) {
+		s.BlobCache.Delete(blobHashKey(hash.(string)))
+	}
+}
+
+// InvalidateSettings 清除所有仓库的 .code-browser.json 设置缓存，下次读取时重新加载
+func (s *Service) InvalidateSettings() {
+	deleteByPrefix(s.TreeCache, "settings:")
+}
+
+// deleteByPrefix 删除缓存中所有以 prefix 开头的键
+func deleteByPrefix(c *cache.Cache, prefix string) {
+	for key := range c.Items() {
+		if strings.HasPrefix(key, prefix) {
+			c.Delete(key)
+		}
+	}
+}
+
+// openHeadTree 打开仓库并返回 HEAD commit 对应的 Tree
+func openHeadTree(sourcePath string) (*object.Tree, error) {
+	r, err := git.PlainOpen(sourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("打开 Git 仓库失败: %w", err)
+	}
+	ref, err := r.Head()
+	if err != nil {
+		return nil, fmt.Errorf("获取 HEAD 引用失败: %w", err)
+	}
+	commit, err := r.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("获取 Commit 对象失败: %w", err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("获取 Tree 失败: %w", err)
+	}
+	return tree, nil
+}
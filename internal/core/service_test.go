@@ -0,0 +1,483 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"code-browser/internal/codetree"
+	"code-browser/internal/lru"
+	"code-browser/internal/repo"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/patrickmn/go-cache"
+)
+
+// newTestService 创建一个包含单个 git 仓库的服务，files 为提交到 HEAD 的文件内容
+func newTestService(t *testing.T, id uint32, files map[string]string) *Service {
+	t.Helper()
+	src := t.TempDir()
+	r, err := git.PlainInit(src, false)
+	if err != nil {
+		t.Fatalf("PlainInit: %v", err)
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		t.Fatalf("Worktree: %v", err)
+	}
+	for name, content := range files {
+		if commit, ok := strings.CutPrefix(content, "submodule:"); ok {
+			// "submodule:<commit>" 在索引中添加子模块条目 (gitlink)，不检出内容
+			idx, err := r.Storer.Index()
+			if err != nil {
+				t.Fatal(err)
+			}
+			e := idx.Add(name)
+			e.Mode = filemode.Submodule
+			e.Hash = plumbing.NewHash(commit)
+			if err := r.Storer.SetIndex(idx); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if target, ok := strings.CutPrefix(content, "symlink:"); ok {
+			// "symlink:<target>" 创建符号链接
+			if err := os.Symlink(target, path); err != nil {
+				t.Fatal(err)
+			}
+		} else if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := wt.Add(name); err != nil {
+			t.Fatalf("Add %s: %v", name, err)
+		}
+	}
+	sig := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+	if _, err := wt.Commit("init", &git.CommitOptions{Author: sig}); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	p, err := repo.NewProvider(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	t.Cleanup(func() { p.Close() })
+	if _, err := p.AddRepository(id, "test", src, false); err != nil {
+		t.Fatalf("AddRepository: %v", err)
+	}
+	return NewService(p, cache.New(time.Minute, time.Minute), lru.New(0, 0, 0))
+}
+
+func TestGetFileContent_ContentAddressedDedupesIdenticalFiles(t *testing.T) {
+	const content = "package dup\n"
+	s := newTestService(t, 1, map[string]string{
+		"a/dup.go":        content,
+		"vendor/x/dup.go": content,
+	})
+	s.ContentAddressedBlobs = true
+
+	for _, path := range []string{"a/dup.go", "vendor/x/dup.go"} {
+		got, _, err := s.GetFileContent(1, path)
+		if err != nil {
+			t.Fatalf("GetFileContent(%s): %v", path, err)
+		}
+		if string(got) != content {
+			t.Fatalf("GetFileContent(%s) = %q", path, got)
+		}
+	}
+	// 两条 路径→哈希 映射 + 一份内容
+	if n := s.BlobCache.Len(); n != 3 {
+		t.Fatalf("expected 3 cache entries (2 refs + 1 blob), got %d", n)
+	}
+
+	// 命中缓存时仍返回相同内容
+	if got, _, err := s.GetFileContent(1, "vendor/x/dup.go"); err != nil || string(got) != content {
+		t.Fatalf("cached read = %q, %v", got, err)
+	}
+
+	s.InvalidateRepo(1)
+	if n := s.BlobCache.Len(); n != 0 {
+		t.Fatalf("expected cache to be empty after invalidation, got %d entries", n)
+	}
+}
+
+func TestGetFileContentAt_Revision(t *testing.T) {
+	s := newTestService(t, 1, map[string]string{"main.go": "package v1\n"})
+	info, _ := s.RepoProvider.GetRepo(1)
+	r, err := git.PlainOpen(info.SourcePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(info.SourcePath, "main.go"), []byte("package v2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.Add("main.go"); err != nil {
+		t.Fatal(err)
+	}
+	sig := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+	if _, err := wt.Commit("v2", &git.CommitOptions{Author: sig}); err != nil {
+		t.Fatal(err)
+	}
+
+	for rev, want := range map[string]string{"": "package v2\n", "HEAD": "package v2\n", "HEAD~1": "package v1\n"} {
+		// 两次读取: 第二次命中缓存
+		for range 2 {
+			got, _, err := s.GetFileContentAt(1, "main.go", rev)
+			if err != nil || string(got) != want {
+				t.Fatalf("rev %q: %q, %v; want %q", rev, got, err, want)
+			}
+		}
+	}
+	if _, _, err := s.GetFileContentAt(1, "main.go", "no-such"); !errors.Is(err, repo.ErrRevisionNotFound) {
+		t.Fatalf("unknown rev: %v", err)
+	}
+}
+
+func TestRepoSettings(t *testing.T) {
+	s := newTestService(t, 1, map[string]string{
+		RepoSettingsFile: `{
+			"hiddenPaths": ["vendor", "docs/gen*"],
+			"languageOverrides": {"*.tpl": "html", "templates/*.tpl": "go"},
+			"unknownField": true
+		}`,
+		"main.go":             "package main\n",
+		"vendor/lib/x.go":     "package lib\n",
+		"docs/generated/a.md": "# a\n",
+		"docs/guide.md":       "# guide\n",
+		"templates/page.tpl":  "{{.}}\n",
+	})
+
+	settings := s.GetRepoSettings(1)
+	if len(settings.HiddenPaths) != 2 {
+		t.Fatalf("settings = %+v", settings)
+	}
+	if got := settings.Language("templates/page.tpl"); got != "go" {
+		t.Errorf("Language(templates/page.tpl) = %q, want the longer pattern's go", got)
+	}
+	if got := settings.Language("other/x.tpl"); got != "html" {
+		t.Errorf("Language(other/x.tpl) = %q", got)
+	}
+	if got := settings.Language("main.go"); got != "go" {
+		t.Errorf("Language(main.go) = %q", got)
+	}
+
+	root, err := s.GetTree(1, "")
+	if err != nil {
+		t.Fatalf("GetTree: %v", err)
+	}
+	for _, f := range root {
+		if f.Name == "vendor" {
+			t.Fatalf("vendor should be hidden: %+v", root)
+		}
+	}
+	docs, err := s.GetTree(1, "docs")
+	if err != nil || len(docs) != 1 || docs[0].Name != "guide.md" {
+		t.Fatalf("GetTree(docs) = %+v, %v", docs, err)
+	}
+
+	files, err := s.ListAllFiles(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("ListAllFiles: %v", err)
+	}
+	if strings.Join(files, ",") != ".code-browser.json,docs/guide.md,main.go,templates/page.tpl" {
+		t.Fatalf("ListAllFiles = %v", files)
+	}
+}
+
+func TestRepoSettings_ReloadedOnNewCommit(t *testing.T) {
+	s := newTestService(t, 1, map[string]string{
+		RepoSettingsFile: `{"hiddenPaths": ["vendor"]}`,
+		"main.go":        "package main\n",
+	})
+	info, _ := s.RepoProvider.GetRepo(1)
+	r, err := git.PlainOpen(info.SourcePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(info.SourcePath, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := wt.Add(name); err != nil {
+			t.Fatal(err)
+		}
+		sig := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+		if _, err := wt.Commit("change", &git.CommitOptions{Author: sig}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := s.GetRepoSettings(1).HiddenPaths; len(got) != 1 || got[0] != "vendor" {
+		t.Fatalf("initial HiddenPaths = %v", got)
+	}
+
+	// 提交不涉及设置文件: blob 哈希不变，沿用已解析的设置
+	commit("main.go", "package main // v2\n")
+	if got := s.GetRepoSettings(1).HiddenPaths; len(got) != 1 || got[0] != "vendor" {
+		t.Fatalf("after unrelated commit HiddenPaths = %v", got)
+	}
+
+	// 修改设置文件后立即生效，无需等待缓存过期
+	commit(RepoSettingsFile, `{"hiddenPaths": ["docs"]}`)
+	if got := s.GetRepoSettings(1).HiddenPaths; len(got) != 1 || got[0] != "docs" {
+		t.Fatalf("after settings commit HiddenPaths = %v", got)
+	}
+}
+
+func TestRepoSettings_InvalidFileIgnored(t *testing.T) {
+	s := newTestService(t, 1, map[string]string{
+		RepoSettingsFile: `{"hiddenPaths": [`,
+		"vendor/x.go":    "package x\n",
+	})
+	if settings := s.GetRepoSettings(1); len(settings.HiddenPaths) != 0 {
+		t.Fatalf("invalid settings should be ignored, got %+v", settings)
+	}
+	if files, _ := s.ListAllFiles(context.Background(), 1); len(files) != 2 {
+		t.Fatalf("ListAllFiles = %v", files)
+	}
+}
+
+func TestSymlinks(t *testing.T) {
+	s := newTestService(t, 1, map[string]string{
+		"real/a.go":       "package real\n",
+		"link-dir":        "symlink:real",
+		"link-file":       "symlink:real/a.go",
+		"docs/up":         "symlink:../real/a.go",
+		"escape-abs":      "symlink:/etc/passwd",
+		"escape-rel":      "symlink:../../etc/passwd",
+		"dangling":        "symlink:missing",
+		"loop":            "symlink:loop",
+		"real/escape-dir": "symlink:../..",
+	})
+
+	root, err := s.GetTree(1, "")
+	if err != nil {
+		t.Fatalf("GetTree: %v", err)
+	}
+	entries := make(map[string]FileInfo)
+	for _, f := range root {
+		entries[f.Name] = f
+	}
+	if e := entries["link-dir"]; !e.Symlink || e.Target != "real" || e.Type != "directory" {
+		t.Fatalf("link-dir: %+v", e)
+	}
+	if e := entries["link-file"]; !e.Symlink || e.Type != "file" {
+		t.Fatalf("link-file: %+v", e)
+	}
+	for _, name := range []string{"escape-abs", "escape-rel", "dangling", "loop"} {
+		if e := entries[name]; !e.Symlink || e.Type != "file" {
+			t.Fatalf("%s: %+v", name, e)
+		}
+	}
+	if e := entries["real"]; e.Symlink || e.Target != "" {
+		t.Fatalf("real: %+v", e)
+	}
+
+	// 指向仓库内部的链接被跟随
+	files, err := s.GetTree(1, "link-dir")
+	if err != nil || len(files) != 2 || files[0].Path != "link-dir/a.go" {
+		t.Fatalf("GetTree(link-dir) = %+v, %v", files, err)
+	}
+	for _, p := range []string{"link-file", "link-dir/a.go", "docs/up"} {
+		if content, _, err := s.GetFileContent(1, p); err != nil || string(content) != "package real\n" {
+			t.Fatalf("GetFileContent(%s) = %q, %v", p, content, err)
+		}
+	}
+
+	// 指向仓库之外的链接不被跟随
+	for _, p := range []string{"escape-abs", "escape-rel", "real/escape-dir/x"} {
+		if _, _, err := s.GetFileContent(1, p); !errors.Is(err, ErrSymlinkOutsideRepo) {
+			t.Fatalf("GetFileContent(%s): got %v, want ErrSymlinkOutsideRepo", p, err)
+		}
+	}
+	if _, err := s.GetTree(1, "real/escape-dir"); !errors.Is(err, ErrSymlinkOutsideRepo) {
+		t.Fatalf("GetTree(real/escape-dir): got %v, want ErrSymlinkOutsideRepo", err)
+	}
+	if _, _, err := s.GetFileContent(1, "loop"); err == nil {
+		t.Fatal("expected an error for a symlink cycle")
+	}
+
+	// PlainSymlinks: 链接是普通文件，内容为链接目标
+	plain := newTestService(t, 2, map[string]string{"escape-abs": "symlink:/etc/passwd", "link-dir": "symlink:real", "real/a.go": "x"})
+	plain.PlainSymlinks = true
+	if content, _, err := plain.GetFileContent(2, "escape-abs"); err != nil || !strings.HasSuffix(string(content), "/etc/passwd") {
+		t.Fatalf("plain GetFileContent = %q, %v", content, err)
+	}
+	files, err = plain.GetTree(2, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if f.Symlink || f.Target != "" || f.Name == "link-dir" && f.Type != "file" {
+			t.Fatalf("plain entry %+v", f)
+		}
+	}
+}
+
+func TestSubmodules(t *testing.T) {
+	const libCommit = "0123456789abcdef0123456789abcdef01234567"
+	s := newTestService(t, 1, map[string]string{
+		"main.go":         "package main\n",
+		"third_party/lib": "submodule:" + libCommit,
+		"vendor/uninit":   "submodule:" + libCommit, // 不在 .gitmodules 中
+		".gitmodules":     "[submodule \"lib\"]\n\tpath = third_party/lib\n\turl = git@github.com:Example/Lib.git\n",
+	})
+
+	// 远程地址与子模块地址相同的已注册仓库
+	src := t.TempDir()
+	r, err := git.PlainInit(src, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{"https://github.com/example/lib"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RepoProvider.(*repo.Provider).AddRepository(2, "lib", src, false); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := s.GetTree(1, "third_party")
+	if err != nil {
+		t.Fatalf("GetTree: %v", err)
+	}
+	if len(files) != 1 || files[0].Type != "submodule" || files[0].Submodule == nil {
+		t.Fatalf("third_party = %+v", files)
+	}
+	if sub := *files[0].Submodule; sub.Commit != libCommit || sub.URL != "git@github.com:Example/Lib.git" || sub.RepoID != "2" {
+		t.Fatalf("submodule = %+v", sub)
+	}
+	files, err = s.GetTree(1, "vendor")
+	if err != nil {
+		t.Fatalf("GetTree(vendor): %v", err)
+	}
+	if len(files) != 1 || files[0].Type != "submodule" || files[0].Submodule.URL != "" || files[0].Submodule.RepoID != "" {
+		t.Fatalf("uninitialized submodule = %+v", files)
+	}
+
+	if _, err := s.GetTree(1, "third_party/lib"); !errors.Is(err, ErrInSubmodule) {
+		t.Fatalf("GetTree(submodule) err = %v", err)
+	}
+	if _, _, err := s.GetFileContent(1, "third_party/lib/lib.go"); !errors.Is(err, ErrInSubmodule) {
+		t.Fatalf("GetFileContent(in submodule) err = %v", err)
+	}
+	if info, err := s.ResolvePath(1, "third_party/lib"); err != nil || info.Type != "submodule" {
+		t.Fatalf("ResolvePath(submodule) = %+v, %v", info, err)
+	}
+	if _, err := s.ResolvePath(1, "third_party/lib/lib.go"); !errors.Is(err, ErrPathNotFound) {
+		t.Fatalf("ResolvePath(in submodule) err = %v", err)
+	}
+}
+
+func TestGetLanguages(t *testing.T) {
+	s := newTestService(t, 1, map[string]string{
+		"main.go":            "package main\n", // 13
+		"util/util.go":       "package util\n", // 13
+		"web/app.js":         "let a = 1;\n",   // 11
+		"web/app.min.js":     "let a=1;",       // 生成文件
+		"vendor/lib/lib.go":  "package lib\n",  // 依赖
+		"docs/secret.md":     "# hidden\n",     // hiddenPaths
+		"README":             "no extension\n", // 无法识别语言
+		"logo.png":           "\x89PNG",        // 二进制
+		".code-browser.json": `{"hiddenPaths": ["docs"]}`,
+	})
+	s.IgnoredPaths = []string{"vendor", "*.min.js"}
+
+	stats, err := s.GetLanguages(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("GetLanguages: %v", err)
+	}
+	if stats.Languages["go"] != 26 || stats.Languages["javascript"] != 11 || len(stats.Languages) != 3 {
+		t.Fatalf("languages = %v", stats.Languages)
+	}
+	// .code-browser.json 也是 json 文件
+	if stats.Languages["json"] == 0 || stats.Files != 4 || stats.Total != 37+stats.Languages["json"] || stats.Truncated || len(stats.Commit) != 40 {
+		t.Fatalf("stats = %+v", stats)
+	}
+
+	// 新的提交改变 HEAD 后重新统计
+	repoInfo, _ := s.RepoProvider.GetRepo(1)
+	r, err := git.PlainOpen(repoInfo.SourcePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repoInfo.SourcePath, "more.go"), []byte("package more\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.Add("more.go"); err != nil {
+		t.Fatal(err)
+	}
+	sig := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+	if _, err := wt.Commit("more", &git.CommitOptions{Author: sig}); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := s.GetLanguages(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("GetLanguages after commit: %v", err)
+	}
+	if updated.Languages["go"] != 39 || updated.Commit == stats.Commit {
+		t.Fatalf("after commit: %+v", updated)
+	}
+}
+
+func TestWalkLimits(t *testing.T) {
+	s := newTestService(t, 1, map[string]string{
+		"a.go":     "1234567890", // 10
+		"b/b.go":   "1234567890", // 10
+		"b/c/c.go": "1234567890", // 10
+		"d.go":     "1234567890", // 10
+	})
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	// 文件列表和语言统计使用服务的 WalkLimits，并报告截断
+	s.WalkLimits = codetree.Limits{MaxFiles: 3}
+	files, truncated, err := s.ListAllFilesTruncated(context.Background(), 1)
+	if err != nil || !truncated || len(files) != 3 {
+		t.Fatalf("ListAllFilesTruncated = %q, %v, %v", files, truncated, err)
+	}
+	s.WalkLimits = codetree.Limits{MaxBytes: 15}
+	stats, err := s.GetLanguages(context.Background(), 1)
+	if err != nil || !stats.Truncated || stats.Files != 2 {
+		t.Fatalf("GetLanguages = %+v, %v", stats, err)
+	}
+
+	// 因 ctx 结束而截断的结果不缓存，下一次请求重新遍历
+	s.WalkLimits = codetree.Limits{}
+	s.InvalidateRepo(1)
+	if files, truncated, err := s.ListAllFilesTruncated(expired, 1); err != nil || !truncated || len(files) != 0 {
+		t.Fatalf("ListAllFilesTruncated(expired) = %q, %v, %v", files, truncated, err)
+	}
+	if files, truncated, err := s.ListAllFilesTruncated(context.Background(), 1); err != nil || truncated || len(files) == 0 {
+		t.Fatalf("ListAllFilesTruncated after timeout = %q, %v, %v", files, truncated, err)
+	}
+	if stats, err := s.GetLanguages(expired, 1); err != nil || !stats.Truncated {
+		t.Fatalf("GetLanguages(expired) = %+v, %v", stats, err)
+	}
+	if stats, err := s.GetLanguages(context.Background(), 1); err != nil || stats.Truncated {
+		t.Fatalf("GetLanguages after timeout = %+v, %v", stats, err)
+	}
+}
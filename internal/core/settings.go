@@ -0,0 +1,130 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"code-browser/internal/codetree"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/patrickmn/go-cache"
+)
+
+// RepoSettingsFile 仓库根目录下的可选配置文件，仓库所有者可以用它调整浏览行为而无需修改服务端配置
+const RepoSettingsFile = ".code-browser.json"
+
+// RepoSettings 是 .code-browser.json 的结构，未知字段被忽略
+//
+//	{
+//	  "hiddenPaths": ["vendor", "docs/generated/*"],
+//	  "languageOverrides": {"*.tpl": "html", "BUILD": "python"}
+//	}
+type RepoSettings struct {
+	// HiddenPaths 默认隐藏的路径 (path.Match 模式)。不含 / 的模式匹配任意一级的文件或目录名，
+	// 含 / 的模式从仓库根目录匹配；目录被隐藏时其下所有文件一并隐藏
+	HiddenPaths []string `json:"hiddenPaths,omitempty"`
+	// LanguageOverrides 模式 → 高亮语言，匹配规则同 HiddenPaths，多个模式匹配时取最长的模式
+	LanguageOverrides map[string]string `json:"languageOverrides,omitempty"`
+	// SearchIgnore 搜索默认排除的路径 (匹配规则同 HiddenPaths)。设置后替换服务端的 -search-ignore 列表，
+	// [] 表示该仓库不排除任何路径；未设置 (nil) 时使用服务端列表
+	SearchIgnore []string `json:"searchIgnore,omitempty"`
+}
+
+// IsHidden 判断路径是否被 HiddenPaths 隐藏
+func (rs RepoSettings) IsHidden(filePath string) bool {
+	return codetree.MatchAnyPattern(rs.HiddenPaths, filePath)
+}
+
+// Language 返回路径的高亮语言: 优先使用 LanguageOverrides，否则按扩展名推断 (见 codetree.DetectLanguage)
+func (rs RepoSettings) Language(filePath string) string {
+	best, lang := "", ""
+	for pattern, l := range rs.LanguageOverrides {
+		if codetree.MatchPattern(pattern, filePath) && (len(pattern) > len(best) || (len(pattern) == len(best) && pattern < best)) {
+			best, lang = pattern, l
+		}
+	}
+	if lang != "" {
+		return lang
+	}
+	return codetree.DetectLanguage(filePath)
+}
+
+// FilterHidden 返回 paths 中未被隐藏的路径
+func (rs RepoSettings) FilterHidden(paths []string) []string {
+	if len(rs.HiddenPaths) == 0 {
+		return paths
+	}
+	visible := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if !rs.IsHidden(p) {
+			visible = append(visible, p)
+		}
+	}
+	return visible
+}
+
+// repoSettingsEntry 缓存的仓库设置，以及读取时的 HEAD 提交和设置文件的 blob 哈希 (文件不存在时为零值)
+type repoSettingsEntry struct {
+	head     plumbing.Hash
+	blob     plumbing.Hash
+	settings RepoSettings
+}
+
+// GetRepoSettings 读取仓库 HEAD 中的 .code-browser.json (带缓存)
+// 每次调用都检查 HEAD: HEAD 不变时直接使用缓存；HEAD 变化但设置文件的 blob 哈希不变时沿用已解析的设置，
+// 否则重新解析，因此提交新的配置文件后立即生效。
+// 文件不存在、无法读取或格式错误时返回空设置 (格式错误会记录警告)，不影响正常浏览
+func (s *Service) GetRepoSettings(repoID uint32) RepoSettings {
+	repoInfo, ok := s.RepoProvider.GetRepo(repoID)
+	if !ok {
+		return RepoSettings{}
+	}
+	r, err := git.PlainOpen(repoInfo.SourcePath)
+	if err != nil {
+		return RepoSettings{}
+	}
+	ref, err := r.Head()
+	if err != nil {
+		return RepoSettings{}
+	}
+
+	cacheKey := fmt.Sprintf("settings:%d", repoID)
+	var prev repoSettingsEntry
+	if cached, found := s.TreeCache.Get(cacheKey); found {
+		prev = cached.(repoSettingsEntry)
+		if prev.head == ref.Hash() {
+			return prev.settings
+		}
+	}
+
+	commit, err := r.CommitObject(ref.Hash())
+	if err != nil {
+		return RepoSettings{}
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return RepoSettings{}
+	}
+	entry := repoSettingsEntry{head: ref.Hash()}
+	if file, err := tree.File(RepoSettingsFile); err == nil {
+		entry.blob = file.Hash
+		if !prev.head.IsZero() && prev.blob == entry.blob {
+			entry.settings = prev.settings
+		} else {
+			content, err := file.Contents()
+			if err != nil {
+				// 读取失败可能是暂时的，不缓存
+				slog.Warn("读取仓库设置文件失败", "repo", repoID, "file", RepoSettingsFile, "err", err)
+				return RepoSettings{}
+			}
+			if err := json.Unmarshal([]byte(content), &entry.settings); err != nil {
+				slog.Warn("仓库设置文件格式错误，已忽略", "repo", repoID, "file", RepoSettingsFile, "err", err)
+				entry.settings = RepoSettings{}
+			}
+		}
+	}
+	s.TreeCache.Set(cacheKey, entry, cache.NoExpiration)
+	return entry.settings
+}
@@ -0,0 +1,121 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// ErrInSubmodule 路径位于子模块内: 子模块的文件属于另一个仓库，不在当前仓库的对象库中 (HTTP 404)
+var ErrInSubmodule = errors.New("路径位于子模块中")
+
+// SubmoduleInfo 目录列表中子模块条目的信息
+type SubmoduleInfo struct {
+	URL    string `json:"url,omitempty"` // .gitmodules 中配置的地址，缺少配置时为空
+	Commit string `json:"commit"`        // 父仓库 HEAD 记录的子模块提交
+	// RepoID 远程地址与 URL 相同的已注册仓库，可以切换过去浏览 (显示该仓库的 HEAD，不一定是 Commit)；没有时为空
+	RepoID string `json:"repoId,omitempty"`
+}
+
+// readGitmodules 读取 HEAD tree 中的 .gitmodules，返回 子模块路径 → 地址。
+// 文件不存在或格式错误时返回空表: 子模块仍然会被识别 (依据 tree 条目的模式)，只是没有地址。
+func readGitmodules(tree *object.Tree) map[string]string {
+	urls := make(map[string]string)
+	f, err := tree.File(".gitmodules")
+	if err != nil {
+		return urls
+	}
+	content, err := f.Contents()
+	if err != nil {
+		return urls
+	}
+	modules := config.NewModules()
+	if err := modules.Unmarshal([]byte(content)); err != nil {
+		return urls
+	}
+	for _, m := range modules.Submodules {
+		urls[path.Clean(m.Path)] = m.URL
+	}
+	return urls
+}
+
+// checkNotInSubmodule 检查 gitPath 的上级目录中是否有子模块。
+// 子模块条目在 tree 中只记录提交哈希，go-git 查找其下的路径会报 "对象不存在"，这里提前返回明确的错误。
+func checkNotInSubmodule(tree *object.Tree, gitPath string) error {
+	parts := strings.Split(gitPath, "/")
+	for i := 1; i < len(parts); i++ {
+		prefix := strings.Join(parts[:i], "/")
+		entry, err := tree.FindEntry(prefix)
+		if err != nil {
+			return nil // 交给调用方按 "未找到" 处理
+		}
+		if entry.Mode == filemode.Submodule {
+			return fmt.Errorf("%w: '%s' 是子模块，其内容不在此仓库中", ErrInSubmodule, prefix)
+		}
+	}
+	return nil
+}
+
+// describeSubmodules 为目录列表中的子模块条目填写地址、提交和对应的已注册仓库
+func (s *Service) describeSubmodules(tree *object.Tree, files []FileInfo) {
+	var urls map[string]string
+	for i := range files {
+		if files[i].Submodule == nil {
+			continue
+		}
+		if urls == nil {
+			urls = readGitmodules(tree)
+		}
+		sub := files[i].Submodule
+		sub.URL = urls[strings.TrimPrefix(files[i].Path, "/")]
+		if sub.URL != "" {
+			sub.RepoID = s.findRepoByRemote(sub.URL)
+		}
+	}
+}
+
+// findRepoByRemote 返回远程地址与 url 相同 (忽略协议、用户名和 .git 后缀) 的已注册仓库 ID，没有时返回空
+func (s *Service) findRepoByRemote(url string) string {
+	want := normalizeRemoteURL(url)
+	for _, r := range s.RepoProvider.GetAll() {
+		g, err := git.PlainOpen(r.SourcePath)
+		if err != nil {
+			continue
+		}
+		remotes, err := g.Remotes()
+		if err != nil {
+			continue
+		}
+		for _, remote := range remotes {
+			for _, u := range remote.Config().URLs {
+				if normalizeRemoteURL(u) == want {
+					return strconv.FormatUint(uint64(r.RepoID), 10)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// normalizeRemoteURL 把 https://user@host/a/b.git、git@host:a/b 等写法统一为 host/a/b
+func normalizeRemoteURL(u string) string {
+	u = strings.ToLower(strings.TrimSpace(u))
+	if i := strings.Index(u, "://"); i >= 0 {
+		u = u[i+3:]
+	} else if i := strings.Index(u, ":"); i >= 0 && !strings.Contains(u[:i], "/") {
+		// scp 风格: git@host:a/b
+		u = u[:i] + "/" + u[i+1:]
+	}
+	if i := strings.Index(u, "@"); i >= 0 && i < strings.Index(u+"/", "/") {
+		u = u[i+1:]
+	}
+	u = strings.TrimSuffix(strings.TrimSuffix(u, "/"), ".git")
+	return u
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// ErrSymlinkOutsideRepo 符号链接指向仓库根目录之外 (绝对路径或 .. 越界)，不会被跟随
+var ErrSymlinkOutsideRepo = errors.New("符号链接指向仓库之外")
+
+// maxSymlinkHops 解析一个路径时最多跟随的符号链接数，防止链接成环 (与 Linux 的 ELOOP 上限一致)
+const maxSymlinkHops = 40
+
+// resolveSymlinks 在 HEAD tree 中解析 p (已规范化的相对路径) 经过的所有符号链接，返回实际路径 ("" 表示根目录)。
+// 与 filepath.EvalSymlinks 类似，但链接目标取自 git blob 而非文件系统，且每一步都检查是否仍在仓库内:
+// 绝对路径目标或越出根目录的目标返回 ErrSymlinkOutsideRepo。
+func resolveSymlinks(tree *object.Tree, p string) (string, error) {
+	var parts []string
+	if p != "" {
+		parts = strings.Split(p, "/")
+	}
+	resolved := ""
+	hops := 0
+	for len(parts) > 0 {
+		cur := path.Join(resolved, parts[0])
+		parts = parts[1:]
+		entry, err := findEntry(tree, cur)
+		if err != nil {
+			return "", err
+		}
+		if entry.Mode == filemode.Submodule && len(parts) > 0 {
+			return "", fmt.Errorf("%w: '%s' 是子模块，其内容不在此仓库中", ErrInSubmodule, cur)
+		}
+		if entry.Mode != filemode.Symlink {
+			resolved = cur
+			continue
+		}
+
+		if hops++; hops > maxSymlinkHops {
+			return "", fmt.Errorf("路径 '%s' 经过的符号链接过多", p)
+		}
+		target, err := readSymlink(tree, entry, cur)
+		if err != nil {
+			return "", err
+		}
+		joined := path.Join(resolved, target)
+		if path.IsAbs(target) || joined == ".." || strings.HasPrefix(joined, "../") {
+			return "", fmt.Errorf("%w: '%s' -> '%s'", ErrSymlinkOutsideRepo, cur, target)
+		}
+		// 从根目录重新解析链接目标，再接上剩余部分
+		resolved = ""
+		if joined != "." {
+			parts = append(strings.Split(joined, "/"), parts...)
+		}
+	}
+	return resolved, nil
+}
+
+// readSymlink 读取符号链接条目的目标 (blob 内容)
+func readSymlink(tree *object.Tree, entry *object.TreeEntry, p string) (string, error) {
+	f, err := tree.TreeEntryFile(entry)
+	if err != nil {
+		return "", fmt.Errorf("读取符号链接 '%s' 失败: %w", p, err)
+	}
+	target, err := f.Contents()
+	if err != nil {
+		return "", fmt.Errorf("读取符号链接 '%s' 失败: %w", p, err)
+	}
+	return target, nil
+}
+
+// describeSymlink 标记目录列表中的符号链接条目: 记录链接目标，指向仓库内目录的链接显示为目录。
+// 指向仓库外、目标不存在或成环的链接保持为文件条目，打开时返回相应错误。
+func describeSymlink(tree *object.Tree, entry object.TreeEntry, gitPath string, info *FileInfo) {
+	info.Symlink = true
+	target, err := readSymlink(tree, &entry, gitPath)
+	if err != nil {
+		return
+	}
+	info.Target = target
+	resolved, err := resolveSymlinks(tree, gitPath)
+	if err != nil {
+		return
+	}
+	if resolved == "" {
+		info.Type = "directory"
+		return
+	}
+	if e, err := tree.FindEntry(resolved); err == nil && e.Mode == filemode.Dir {
+		info.Type = "directory"
+	}
+}
@@ -0,0 +1,289 @@
+package feedback
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AttachmentsSubDir is the directory under the data dir that holds attachments,
+// one sub-directory per feedback id: <dataDir>/feedback-attachments/<id>/<name>
+const AttachmentsSubDir = "feedback-attachments"
+
+const (
+	MaxAttachments          = 5        // attachments per feedback
+	MaxAttachmentBytes      = 5 << 20  // per attachment
+	MaxTotalAttachmentBytes = 10 << 20 // all attachments of one feedback
+)
+
+// allowedAttachmentTypes are the sniffed content types accepted as attachments (images only)
+var allowedAttachmentTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+var (
+	ErrInvalidAttachment   = errors.New("invalid attachment")
+	ErrAttachmentTooLarge  = errors.New("attachments too large")
+	ErrAttachmentNotFound  = errors.New("attachment not found")
+	ErrAttachmentsDisabled = errors.New("attachments are not enabled")
+	unsafeAttachmentNameRe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+	storedAttachmentNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+)
+
+// Attachment describes a stored attachment; the content is served by
+// GET /api/admin/feedbacks/{id}/attachments/{name}
+type Attachment struct {
+	Name        string    `json:"name"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at,omitempty"`
+}
+
+// upload is a validated attachment that has been read into memory
+type upload struct {
+	Attachment
+	data []byte
+}
+
+func (s *Service) initAttachmentSchema() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS feedback_attachments (
+		feedback_id INTEGER NOT NULL REFERENCES feedbacks(id) ON DELETE CASCADE,
+		name TEXT NOT NULL,
+		content_type TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (feedback_id, name)
+	);
+	`
+	_, err := s.db.Exec(query)
+	return err
+}
+
+// readAttachments validates the uploaded files (count, size, image type) and reads them into memory.
+// Stored names are sanitized, prefixed with their position and therefore unique within a feedback.
+func readAttachments(files []*multipart.FileHeader) ([]upload, error) {
+	if len(files) > MaxAttachments {
+		return nil, fmt.Errorf("%w: at most %d attachments are allowed", ErrInvalidAttachment, MaxAttachments)
+	}
+	var (
+		uploads []upload
+		total   int64
+	)
+	for i, fh := range files {
+		if fh.Size > MaxAttachmentBytes {
+			return nil, fmt.Errorf("%w: '%s' exceeds %d bytes", ErrAttachmentTooLarge, fh.Filename, MaxAttachmentBytes)
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read attachment '%s': %w", fh.Filename, err)
+		}
+		// Size in the header comes from the client; enforce the limit on the bytes actually read
+		data, err := io.ReadAll(io.LimitReader(f, MaxAttachmentBytes+1))
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read attachment '%s': %w", fh.Filename, err)
+		}
+		if len(data) > MaxAttachmentBytes {
+			return nil, fmt.Errorf("%w: '%s' exceeds %d bytes", ErrAttachmentTooLarge, fh.Filename, MaxAttachmentBytes)
+		}
+		total += int64(len(data))
+		if total > MaxTotalAttachmentBytes {
+			return nil, fmt.Errorf("%w: total size exceeds %d bytes", ErrAttachmentTooLarge, MaxTotalAttachmentBytes)
+		}
+
+		contentType := http.DetectContentType(data)
+		if !allowedAttachmentTypes[contentType] {
+			return nil, fmt.Errorf("%w: '%s' is %s, only PNG, JPEG, GIF and WebP images are allowed", ErrInvalidAttachment, fh.Filename, contentType)
+		}
+
+		uploads = append(uploads, upload{
+			Attachment: Attachment{
+				Name:        attachmentName(i, fh.Filename),
+				ContentType: contentType,
+				Size:        int64(len(data)),
+			},
+			data: data,
+		})
+	}
+	return uploads, nil
+}
+
+// attachmentName turns a client file name into a safe, unique stored name such as "1-screen_shot.png"
+func attachmentName(index int, filename string) string {
+	base := filepath.Base(strings.ReplaceAll(filename, "\\", "/"))
+	base = strings.Trim(unsafeAttachmentNameRe.ReplaceAllString(base, "_"), "._")
+	if base == "" {
+		base = "attachment"
+	}
+	if len(base) > 100 {
+		base = base[len(base)-100:]
+	}
+	return strconv.Itoa(index+1) + "-" + base
+}
+
+// attachmentDir returns <AttachmentDir>/<feedbackID>
+func (s *Service) attachmentDir(feedbackID int64) string {
+	return filepath.Join(s.AttachmentDir, strconv.FormatInt(feedbackID, 10))
+}
+
+// SaveFeedbackWithAttachments stores the feedback together with its image attachments.
+// All files are validated before anything is written; the feedback row, attachment rows
+// and files are either all stored or none of them.
+func (s *Service) SaveFeedbackWithAttachments(f *Feedback, files []*multipart.FileHeader) error {
+	if len(files) == 0 {
+		return s.SaveFeedback(f)
+	}
+	if s.AttachmentDir == "" {
+		return ErrAttachmentsDisabled
+	}
+	if err := s.validateContext(&f.Context); err != nil {
+		return err
+	}
+	uploads, err := readAttachments(files)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertFeedback(tx, f); err != nil {
+		return err
+	}
+	dir := s.attachmentDir(f.ID)
+	if err := s.AttachmentModes.MkdirAll(dir); err != nil {
+		return fmt.Errorf("failed to create attachment directory: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	for _, u := range uploads {
+		if err := s.AttachmentModes.WriteFile(filepath.Join(dir, u.Name), u.data); err != nil {
+			return fmt.Errorf("failed to store attachment '%s': %w", u.Name, err)
+		}
+		_, err := tx.Exec(`INSERT INTO feedback_attachments (feedback_id, name, content_type, size) VALUES (?, ?, ?, ?)`,
+			f.ID, u.Name, u.ContentType, u.Size)
+		if err != nil {
+			return fmt.Errorf("failed to insert attachment '%s': %w", u.Name, err)
+		}
+		f.Attachments = append(f.Attachments, u.Attachment)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit feedback: %w", err)
+	}
+	committed = true
+	s.notify(*f)
+	return nil
+}
+
+// OpenAttachment returns the metadata and an open file for one attachment of a feedback.
+// The caller must close the file.
+func (s *Service) OpenAttachment(feedbackID int64, name string) (Attachment, *os.File, error) {
+	if !storedAttachmentNameRe.MatchString(name) {
+		return Attachment{}, nil, ErrAttachmentNotFound
+	}
+	var a Attachment
+	var createdAt sql.NullTime
+	err := s.db.QueryRow(`SELECT name, content_type, size, created_at FROM feedback_attachments WHERE feedback_id = ? AND name = ?`,
+		feedbackID, name).Scan(&a.Name, &a.ContentType, &a.Size, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Attachment{}, nil, ErrAttachmentNotFound
+	}
+	if err != nil {
+		return Attachment{}, nil, err
+	}
+	if createdAt.Valid {
+		a.CreatedAt = createdAt.Time
+	}
+	if s.AttachmentDir == "" {
+		return Attachment{}, nil, ErrAttachmentNotFound
+	}
+	file, err := os.Open(filepath.Join(s.attachmentDir(feedbackID), a.Name))
+	if os.IsNotExist(err) {
+		return Attachment{}, nil, ErrAttachmentNotFound
+	}
+	if err != nil {
+		return Attachment{}, nil, err
+	}
+	return a, file, nil
+}
+
+// attachmentQueryBatch is the number of feedback ids per attachment query, well below SQLite's bound-parameter limit
+const attachmentQueryBatch = 500
+
+// loadAttachments returns the attachments of the given feedback, keyed by feedback id
+func (s *Service) loadAttachments(ids []int64) (map[int64][]Attachment, error) {
+	attachments := make(map[int64][]Attachment)
+	for len(ids) > 0 {
+		batch := ids[:min(len(ids), attachmentQueryBatch)]
+		ids = ids[len(batch):]
+
+		args := make([]any, len(batch))
+		for i, id := range batch {
+			args[i] = id
+		}
+		rows, err := s.db.Query(`SELECT feedback_id, name, content_type, size, created_at FROM feedback_attachments
+			WHERE feedback_id IN (?`+strings.Repeat(", ?", len(batch)-1)+`) ORDER BY feedback_id, name`, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id int64
+			var a Attachment
+			var createdAt sql.NullTime
+			if err := rows.Scan(&id, &a.Name, &a.ContentType, &a.Size, &createdAt); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if createdAt.Valid {
+				a.CreatedAt = createdAt.Time
+			}
+			attachments[id] = append(attachments[id], a)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return attachments, nil
+}
+
+// withAttachments fills in Attachments for the given feedback
+func (s *Service) withAttachments(feedbacks []Feedback) ([]Feedback, error) {
+	if len(feedbacks) == 0 {
+		return feedbacks, nil
+	}
+	ids := make([]int64, len(feedbacks))
+	for i, f := range feedbacks {
+		ids[i] = f.ID
+	}
+	attachments, err := s.loadAttachments(ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range feedbacks {
+		feedbacks[i].Attachments = attachments[feedbacks[i].ID]
+	}
+	return feedbacks, nil
+}
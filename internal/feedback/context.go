@@ -0,0 +1,102 @@
+package feedback
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// ErrInvalidContext is returned when a submission references a repository that
+// does not exist or a path that cannot be inside it (HTTP 400)
+var ErrInvalidContext = errors.New("invalid feedback context")
+
+// validateContext checks c against the repositories and tags it:
+//   - an empty repoId keeps the context as is (context is optional), but a path requires a repoId
+//   - an unknown repoId or a path escaping the repository is rejected with ErrInvalidContext
+//   - a path that is not in the repository's HEAD is accepted with Verified=false and logged,
+//     since the file may have been moved since the reporter looked at it
+//
+// Without Repos no validation is done and Verified stays false.
+func (s *Service) validateContext(c *FeedbackContext) error {
+	c.Verified = false
+	c.RepoName = ""
+	if c.RepoID == "" {
+		if c.Path != "" {
+			return fmt.Errorf("%w: path requires repoId", ErrInvalidContext)
+		}
+		return nil
+	}
+	if s.Repos == nil {
+		return nil
+	}
+
+	id := s.Repos.GetRepoIDByString(c.RepoID)
+	if id == 0 {
+		return fmt.Errorf("%w: repository %q not found", ErrInvalidContext, c.RepoID)
+	}
+	r, ok := s.Repos.GetRepo(id)
+	if !ok {
+		return fmt.Errorf("%w: repository %q not found", ErrInvalidContext, c.RepoID)
+	}
+	c.RepoName = r.Name
+
+	if c.Path == "" {
+		c.Verified = true
+		return nil
+	}
+	p := strings.ReplaceAll(c.Path, "\\", "/")
+	for _, seg := range strings.Split(p, "/") {
+		if seg == ".." {
+			return fmt.Errorf("%w: path %q is outside the repository", ErrInvalidContext, c.Path)
+		}
+	}
+	c.Path = strings.TrimPrefix(path.Clean("/"+p), "/")
+	if c.Path == "" {
+		c.Verified = true
+		return nil
+	}
+
+	found, err := pathInHead(r.SourcePath, c.Path)
+	if err != nil {
+		slog.Warn("Failed to verify feedback context path", "repoId", c.RepoID, "path", c.Path, "error", err)
+		return nil
+	}
+	if !found {
+		slog.Warn("Feedback references a path that is not in the repository", "repoId", c.RepoID, "path", c.Path)
+		return nil
+	}
+	c.Verified = true
+	return nil
+}
+
+// pathInHead reports whether relPath (a file or directory) exists in the HEAD commit of the repository
+func pathInHead(sourcePath, relPath string) (bool, error) {
+	r, err := git.PlainOpen(sourcePath)
+	if err != nil {
+		return false, fmt.Errorf("failed to open repository: %w", err)
+	}
+	ref, err := r.Head()
+	if err != nil {
+		return false, fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+	commit, err := r.CommitObject(ref.Hash())
+	if err != nil {
+		return false, fmt.Errorf("failed to read HEAD commit: %w", err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return false, fmt.Errorf("failed to read HEAD tree: %w", err)
+	}
+	if _, err := tree.FindEntry(relPath); err != nil {
+		if errors.Is(err, object.ErrEntryNotFound) || errors.Is(err, object.ErrDirectoryNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,86 @@
+package feedback
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"code-browser/internal/repo"
+	"code-browser/internal/repo/repotest"
+)
+
+func TestSaveFeedback_ValidatesContext(t *testing.T) {
+	src := t.TempDir()
+	r, err := git.PlainInit(src, false)
+	if err != nil {
+		t.Fatalf("PlainInit: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(src, "pkg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "pkg", "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.Add("pkg/main.go"); err != nil {
+		t.Fatal(err)
+	}
+	sig := &object.Signature{Name: "t", Email: "t@example.com", When: time.Now()}
+	if _, err := wt.Commit("init", &git.CommitOptions{Author: sig}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestService(t)
+	s.Repos = repotest.New(repo.Repository{RepoID: 1, Name: "demo", SourcePath: src})
+
+	tests := []struct {
+		name     string
+		ctx      FeedbackContext
+		wantErr  bool
+		verified bool
+		path     string
+	}{
+		{"no context", FeedbackContext{URL: "http://x"}, false, false, ""},
+		{"repo only", FeedbackContext{RepoID: "1"}, false, true, ""},
+		{"existing file", FeedbackContext{RepoID: "1", Path: "/pkg/main.go"}, false, true, "pkg/main.go"},
+		{"existing dir", FeedbackContext{RepoID: "1", Path: "pkg/"}, false, true, "pkg"},
+		{"missing file", FeedbackContext{RepoID: "1", Path: "pkg/gone.go"}, false, false, "pkg/gone.go"},
+		{"client cannot claim verified", FeedbackContext{RepoID: "1", Path: "nope", Verified: true}, false, false, "nope"},
+		{"unknown repo", FeedbackContext{RepoID: "2"}, true, false, ""},
+		{"bad repo id", FeedbackContext{RepoID: "abc"}, true, false, ""},
+		{"escaping path", FeedbackContext{RepoID: "1", Path: "../etc/passwd"}, true, false, ""},
+		{"path without repo", FeedbackContext{Path: "pkg/main.go"}, true, false, ""},
+	}
+	for _, tt := range tests {
+		f := Feedback{Type: "bug", Title: tt.name, Description: "d", Context: tt.ctx}
+		err := s.SaveFeedback(&f)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidContext) {
+				t.Errorf("%s: expected ErrInvalidContext, got %v", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		got, err := s.GetFeedback(f.ID)
+		if err != nil {
+			t.Fatalf("%s: GetFeedback: %v", tt.name, err)
+		}
+		if got.Context.Verified != tt.verified || got.Context.Path != tt.path {
+			t.Errorf("%s: got verified=%v path=%q, want %v %q", tt.name, got.Context.Verified, got.Context.Path, tt.verified, tt.path)
+		}
+		if tt.ctx.RepoID == "1" && got.Context.RepoName != "demo" {
+			t.Errorf("%s: expected repoName demo, got %q", tt.name, got.Context.RepoName)
+		}
+	}
+}
@@ -2,10 +2,15 @@ package feedback
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"strconv"
-	"strings"
+	"time"
+
+	"code-browser/internal/repo"
 )
 
 type Handler struct {
@@ -20,20 +25,12 @@ func NewHandler(s *Service, adminToken string) *Handler {
 	}
 }
 
-// AuthMiddleware checks for the correct admin token
+// AuthMiddleware checks for the correct admin token (same check as the repository admin API)
 func (h *Handler) AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if h.AdminToken != "" {
-			authHeader := r.Header.Get("Authorization")
-			if !strings.HasPrefix(authHeader, "Bearer ") {
-				http.Error(w, "Unauthorized: Missing or invalid token", http.StatusUnauthorized)
-				return
-			}
-			token := strings.TrimPrefix(authHeader, "Bearer ")
-			if token != h.AdminToken {
-				http.Error(w, "Unauthorized: Invalid token", http.StatusUnauthorized)
-				return
-			}
+		if err := repo.CheckAdminToken(r, h.AdminToken); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
 		}
 		next(w, r)
 	}
@@ -45,8 +42,37 @@ func (h *Handler) HandleSubmit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var f Feedback
-	if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
+	var (
+		f     Feedback
+		files []*multipart.FileHeader
+	)
+	if isMultipart(r) {
+		// Form fields are small; leave some headroom above the attachment cap for them
+		r.Body = http.MaxBytesReader(w, r.Body, MaxTotalAttachmentBytes+1<<20)
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Attachments exceed %d bytes in total", MaxTotalAttachmentBytes))
+				return
+			}
+			writeError(w, http.StatusBadRequest, "Invalid multipart form")
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+		f = Feedback{
+			Type:        r.FormValue("type"),
+			Title:       r.FormValue("title"),
+			Description: r.FormValue("description"),
+			Email:       r.FormValue("email"),
+		}
+		if ctx := r.FormValue("context"); ctx != "" {
+			if err := json.Unmarshal([]byte(ctx), &f.Context); err != nil {
+				writeError(w, http.StatusBadRequest, "Invalid context")
+				return
+			}
+		}
+		files = r.MultipartForm.File["attachments"]
+	} else if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
 		return
@@ -58,26 +84,82 @@ func (h *Handler) HandleSubmit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.Service.SaveFeedback(&f); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Internal server error"})
+	if err := h.Service.SaveFeedbackWithAttachments(&f, files); err != nil {
+		switch {
+		case errors.Is(err, ErrAttachmentTooLarge):
+			writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+		case errors.Is(err, ErrInvalidAttachment), errors.Is(err, ErrAttachmentsDisabled), errors.Is(err, ErrInvalidContext):
+			writeError(w, http.StatusBadRequest, err.Error())
+		default:
+			writeError(w, http.StatusInternalServerError, "Internal server error")
+		}
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"message": "Feedback received",
+		"success":     true,
+		"message":     "Feedback received",
+		"attachments": len(f.Attachments),
 	})
 }
 
+// isMultipart reports whether the request body is a multipart form (feedback with attachments)
+func isMultipart(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "multipart/form-data"
+}
+
+// writeError writes a JSON error body, as HandleSubmit's clients expect
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// HandleGetAttachment handles GET /api/admin/feedbacks/{id}/attachments/{name}
+func (h *Handler) HandleGetAttachment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	attachment, file, err := h.Service.OpenAttachment(id, r.PathValue("name"))
+	if err != nil {
+		if errors.Is(err, ErrAttachmentNotFound) {
+			http.Error(w, "Attachment not found", http.StatusNotFound)
+		} else {
+			http.Error(w, fmt.Sprintf("Failed to open attachment: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+	defer file.Close()
+
+	// The type was sniffed and restricted to images on upload; never let the browser re-sniff
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", attachment.Name))
+	http.ServeContent(w, r, attachment.Name, attachment.CreatedAt, file)
+}
+
+// NextCursorHeader carries the cursor of the next page of GET /api/admin/feedbacks
+const NextCursorHeader = "X-Next-Cursor"
+
 // HandleList handles GET /api/admin/feedbacks
+// Without paging parameters the whole list is returned. ?limit=&offset= or ?limit=&cursor=
+// return one page; the cursor for the next page is sent in the X-Next-Cursor header.
 func (h *Handler) HandleList(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	q := r.URL.Query()
+	if q.Has("limit") || q.Has("offset") || q.Has("cursor") {
+		h.handleListPage(w, r)
+		return
+	}
+
 	feedbacks, err := h.Service.ListFeedbacks()
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to list feedbacks: %v", err), http.StatusInternalServerError)
@@ -85,9 +167,92 @@ func (h *Handler) HandleList(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(feedbacks)))
 	json.NewEncoder(w).Encode(feedbacks)
 }
 
+func (h *Handler) handleListPage(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	opts := ListOptions{Cursor: q.Get("cursor")}
+	for name, dst := range map[string]*int{"limit": &opts.Limit, "offset": &opts.Offset} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				http.Error(w, fmt.Sprintf("Invalid %s", name), http.StatusBadRequest)
+				return
+			}
+			*dst = n
+		}
+	}
+	if opts.Cursor != "" && opts.Offset > 0 {
+		http.Error(w, "Parameters 'cursor' and 'offset' cannot be combined", http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.Service.ListFeedbackPage(opts)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCursor) {
+			http.Error(w, "Invalid cursor, restart from the first page", http.StatusBadRequest)
+		} else {
+			http.Error(w, fmt.Sprintf("Failed to list feedbacks: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	if page.NextCursor != "" {
+		w.Header().Set(NextCursorHeader, page.NextCursor)
+	}
+	json.NewEncoder(w).Encode(page.Items)
+}
+
+// HandleListByContext handles GET /api/admin/feedbacks/by-context?repoId=&path=
+// Lists feedback filed against a repository, or a single file when path is given
+func (h *Handler) HandleListByContext(w http.ResponseWriter, r *http.Request) {
+	repoID := r.URL.Query().Get("repoId")
+	if repoID == "" {
+		http.Error(w, "Query parameter 'repoId' is required", http.StatusBadRequest)
+		return
+	}
+
+	feedbacks, err := h.Service.ListByContext(repoID, r.URL.Query().Get("path"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to list feedbacks: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if feedbacks == nil {
+		feedbacks = []Feedback{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(feedbacks)))
+	json.NewEncoder(w).Encode(feedbacks)
+}
+
+// HandleGet handles GET /api/admin/feedbacks/{id}
+// The response includes nextStatuses, the statuses the UI may offer for this feedback
+func (h *Handler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	f, err := h.Service.GetFeedback(id)
+	if err != nil {
+		if errors.Is(err, ErrFeedbackNotFound) {
+			http.Error(w, "Feedback not found", http.StatusNotFound)
+		} else {
+			http.Error(w, fmt.Sprintf("Failed to get feedback: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(f)
+}
+
 // HandleUpdateStatus handles PATCH /api/admin/feedbacks/{id}
 func (h *Handler) HandleUpdateStatus(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPatch {
@@ -102,8 +267,11 @@ func (h *Handler) HandleUpdateStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// updatedAt is optional; when given (the value from the list), the update only
+	// succeeds if nobody changed the feedback in the meantime
 	var req struct {
-		Status string `json:"status"`
+		Status    string     `json:"status"`
+		UpdatedAt *time.Time `json:"updatedAt"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -115,17 +283,38 @@ func (h *Handler) HandleUpdateStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.Service.UpdateFeedbackStatus(id, req.Status); err != nil {
-		if err.Error() == "feedback not found" {
+	var pre StatusPrecondition
+	if req.UpdatedAt != nil {
+		pre.UpdatedAt = *req.UpdatedAt
+	}
+	if v := r.Header.Get("If-Unmodified-Since"); v != "" {
+		t, err := http.ParseTime(v)
+		if err != nil {
+			http.Error(w, "Invalid If-Unmodified-Since header", http.StatusBadRequest)
+			return
+		}
+		pre.UnmodifiedSince = t
+	}
+
+	updatedAt, err := h.Service.UpdateFeedbackStatus(id, req.Status, pre)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrFeedbackNotFound):
 			http.Error(w, "Feedback not found", http.StatusNotFound)
-		} else {
+		case errors.Is(err, ErrInvalidTransition):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, ErrStatusConflict):
+			http.Error(w, "Feedback was modified by someone else; reload and try again", http.StatusConflict)
+		default:
 			http.Error(w, fmt.Sprintf("Failed to update status: %v", err), http.StatusInternalServerError)
 		}
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Last-Modified", updatedAt.Format(http.TimeFormat))
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	json.NewEncoder(w).Encode(map[string]any{"status": "ok", "updatedAt": updatedAt})
 }
 
 // HandleDelete handles DELETE /api/admin/feedbacks/{id}
@@ -143,7 +332,7 @@ func (h *Handler) HandleDelete(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.Service.DeleteFeedback(id); err != nil {
-		if err.Error() == "feedback not found" {
+		if errors.Is(err, ErrFeedbackNotFound) {
 			http.Error(w, "Feedback not found", http.StatusNotFound)
 		} else {
 			http.Error(w, fmt.Sprintf("Failed to delete feedback: %v", err), http.StatusInternalServerError)
@@ -0,0 +1,193 @@
+package feedback
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// notifyTimeout bounds a single notification; it runs after the submission response has been sent
+const notifyTimeout = 10 * time.Second
+
+// Notifier is told about each new feedback. Notify runs asynchronously after the feedback is
+// stored; errors are logged and never affect the submission.
+type Notifier interface {
+	Notify(ctx context.Context, f Feedback) error
+}
+
+// Notifiers sends to every notifier in turn and joins their errors
+type Notifiers []Notifier
+
+func (ns Notifiers) Notify(ctx context.Context, f Feedback) error {
+	var errs []error
+	for _, n := range ns {
+		if err := n.Notify(ctx, f); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// notify sends f to the configured notifier in the background
+func (s *Service) notify(f Feedback) {
+	if s.Notifier == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+		if err := s.Notifier.Notify(ctx, f); err != nil {
+			slog.Warn("Failed to send feedback notification", "id", f.ID, "err", err)
+		}
+	}()
+}
+
+// notification is the payload of a webhook and the content of an email
+type notification struct {
+	ID      int64           `json:"id"`
+	Type    string          `json:"type"`
+	Title   string          `json:"title"`
+	Link    string          `json:"link,omitempty"` // admin page listing the feedback
+	Context FeedbackContext `json:"context"`
+}
+
+func newNotification(f Feedback, link string) notification {
+	return notification{ID: f.ID, Type: f.Type, Title: f.Title, Link: link, Context: f.Context}
+}
+
+// WebhookNotifier POSTs a JSON notification to URL
+type WebhookNotifier struct {
+	URL    string
+	Link   string       // included as "link", e.g. https://code.example.com/admin.html
+	Client *http.Client // http.DefaultClient when nil
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, f Feedback) error {
+	body, err := json.Marshal(newNotification(f, n.Link))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// SMTPNotifier emails a short plain-text notification through an SMTP server.
+// PLAIN auth is used when Username is set.
+type SMTPNotifier struct {
+	Addr     string // host:port
+	Username string
+	Password string
+	From     string
+	To       []string
+	Link     string
+}
+
+func (n *SMTPNotifier) Notify(ctx context.Context, f Feedback) error {
+	msg := newNotification(f, n.Link)
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", n.From)
+	fmt.Fprintf(&body, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&body, "Subject: [feedback/%s] %s\r\n", sanitizeHeader(msg.Type), sanitizeHeader(msg.Title))
+	body.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&body, "New %s feedback #%d: %s\r\n", msg.Type, msg.ID, msg.Title)
+	if msg.Context.RepoID != "" {
+		fmt.Fprintf(&body, "Repository: %s\r\n", msg.Context.RepoID)
+	}
+	if msg.Context.Path != "" {
+		fmt.Fprintf(&body, "File: %s\r\n", msg.Context.Path)
+	}
+	if msg.Context.URL != "" {
+		fmt.Fprintf(&body, "Page: %s\r\n", msg.Context.URL)
+	}
+	if msg.Link != "" {
+		fmt.Fprintf(&body, "\r\nReview: %s\r\n", msg.Link)
+	}
+
+	if err := n.send(ctx, []byte(body.String())); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// send is smtp.SendMail with the connection bound to ctx's deadline, so a stuck server cannot hang it
+func (n *SMTPNotifier) send(ctx context.Context, msg []byte) error {
+	host, _, err := net.SplitHostPort(n.Addr)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP address %q: %w", n.Addr, err)
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", n.Addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if n.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", n.Username, n.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(n.From); err != nil {
+		return err
+	}
+	for _, to := range n.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// sanitizeHeader keeps user-provided text from injecting extra mail headers
+func sanitizeHeader(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
@@ -0,0 +1,90 @@
+package feedback
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type fakeNotifier struct {
+	got chan Feedback
+	err error
+}
+
+func (n *fakeNotifier) Notify(ctx context.Context, f Feedback) error {
+	n.got <- f
+	return n.err
+}
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "app.db")+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s, err := NewService(db)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	return s
+}
+
+func TestSaveFeedback_Notifies(t *testing.T) {
+	s := newTestService(t)
+	// A failing notifier must not fail the submission
+	n := &fakeNotifier{got: make(chan Feedback, 1), err: errors.New("smtp down")}
+	s.Notifier = n
+
+	f := Feedback{Type: "bug", Title: "broken link", Description: "d", Context: FeedbackContext{RepoID: "1", Path: "a.go"}}
+	if err := s.SaveFeedback(&f); err != nil {
+		t.Fatalf("SaveFeedback: %v", err)
+	}
+
+	select {
+	case got := <-n.got:
+		if got.ID != f.ID || got.ID == 0 || got.Title != "broken link" || got.Context.Path != "a.go" {
+			t.Fatalf("unexpected notification: %+v", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("notifier was not called")
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	got := make(chan notification, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg notification
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		got <- msg
+	}))
+	defer srv.Close()
+
+	n := &WebhookNotifier{URL: srv.URL, Link: "https://code.example.com/admin.html"}
+	if err := n.Notify(context.Background(), Feedback{ID: 7, Type: "idea", Title: "dark mode"}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	msg := <-got
+	if msg.ID != 7 || msg.Type != "idea" || msg.Title != "dark mode" || msg.Link != n.Link {
+		t.Fatalf("unexpected payload: %+v", msg)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	if err := (&WebhookNotifier{URL: failing.URL}).Notify(context.Background(), Feedback{}); err == nil {
+		t.Fatal("expected error for non-2xx webhook response")
+	}
+}
@@ -0,0 +1,151 @@
+package feedback
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+// createdAtFormat is how SQLite's CURRENT_TIMESTAMP stores created_at (UTC, second precision).
+// Cursors hold created_at in this format so it can be compared with the column as text.
+const createdAtFormat = "2006-01-02 15:04:05"
+
+// ErrInvalidCursor is returned for a cursor that was altered, is malformed, or was issued
+// by another server process (the signing key is not persisted)
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListOptions selects one page of the feedback list, newest first.
+// Cursor and Offset are mutually exclusive; a zero Limit means DefaultPageSize.
+type ListOptions struct {
+	Limit  int
+	Offset int
+	Cursor string // NextCursor of the previous page
+}
+
+type FeedbackPage struct {
+	Items []Feedback
+	Total int // all feedback, independent of the page
+	// NextCursor continues after the last item; empty on the last page
+	NextCursor string
+}
+
+// pageCursor is the position of the last item of a page in (created_at DESC, id DESC) order
+type pageCursor struct {
+	CreatedAt time.Time
+	ID        int64
+}
+
+func newCursorKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("failed to generate cursor key: %v", err))
+	}
+	return key
+}
+
+// encodeCursor returns "<payload>.<hmac>", both base64url, so clients cannot forge positions
+func (s *Service) encodeCursor(c pageCursor) string {
+	payload := c.CreatedAt.UTC().Format(createdAtFormat) + "|" + strconv.FormatInt(c.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(s.cursorMAC([]byte(payload)))
+}
+
+func (s *Service) decodeCursor(token string) (pageCursor, error) {
+	encPayload, encMAC, ok := strings.Cut(token, ".")
+	if !ok {
+		return pageCursor{}, ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encPayload)
+	if err != nil {
+		return pageCursor{}, ErrInvalidCursor
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encMAC)
+	if err != nil || !hmac.Equal(mac, s.cursorMAC(payload)) {
+		return pageCursor{}, ErrInvalidCursor
+	}
+
+	createdAt, id, ok := strings.Cut(string(payload), "|")
+	if !ok {
+		return pageCursor{}, ErrInvalidCursor
+	}
+	var c pageCursor
+	if c.CreatedAt, err = time.Parse(createdAtFormat, createdAt); err != nil {
+		return pageCursor{}, ErrInvalidCursor
+	}
+	if c.ID, err = strconv.ParseInt(id, 10, 64); err != nil {
+		return pageCursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+func (s *Service) cursorMAC(payload []byte) []byte {
+	m := hmac.New(sha256.New, s.cursorKey)
+	m.Write(payload)
+	return m.Sum(nil)
+}
+
+// ListFeedbackPage returns one page of the feedback list, newest first.
+// With a cursor, paging is by (created_at, id), so feedback submitted while a client
+// pages through the list neither shifts later pages nor shows up twice.
+func (s *Service) ListFeedbackPage(opts ListOptions) (FeedbackPage, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	limit = min(limit, MaxPageSize)
+	if opts.Cursor != "" && opts.Offset > 0 {
+		return FeedbackPage{}, fmt.Errorf("cursor and offset cannot be combined")
+	}
+
+	var page FeedbackPage
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM feedbacks`).Scan(&page.Total); err != nil {
+		return FeedbackPage{}, err
+	}
+
+	query := `SELECT ` + feedbackColumns + ` FROM feedbacks`
+	var args []any
+	if opts.Cursor != "" {
+		c, err := s.decodeCursor(opts.Cursor)
+		if err != nil {
+			return FeedbackPage{}, err
+		}
+		createdAt := c.CreatedAt.Format(createdAtFormat)
+		query += ` WHERE created_at < ? OR (created_at = ? AND id < ?)`
+		args = append(args, createdAt, createdAt, c.ID)
+	}
+	// One extra row tells whether there is a next page
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?`
+	args = append(args, limit+1, max(opts.Offset, 0))
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return FeedbackPage{}, err
+	}
+	feedbacks, err := scanFeedbacks(rows)
+	if err != nil {
+		return FeedbackPage{}, err
+	}
+	if len(feedbacks) > limit {
+		feedbacks = feedbacks[:limit]
+		last := feedbacks[limit-1]
+		page.NextCursor = s.encodeCursor(pageCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+	if page.Items, err = s.complete(feedbacks); err != nil {
+		return FeedbackPage{}, err
+	}
+	if page.Items == nil {
+		page.Items = []Feedback{}
+	}
+	return page, nil
+}
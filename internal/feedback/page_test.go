@@ -0,0 +1,130 @@
+package feedback
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestListFeedbackPage_InsertDuringPaging(t *testing.T) {
+	s := newTestService(t)
+	save := func(title string) {
+		t.Helper()
+		if err := s.SaveFeedback(&Feedback{Type: "bug", Title: title, Description: "d"}); err != nil {
+			t.Fatalf("SaveFeedback: %v", err)
+		}
+	}
+	for i := range 5 {
+		save(fmt.Sprintf("old-%d", i))
+	}
+
+	var seen []string
+	page, err := s.ListFeedbackPage(ListOptions{Limit: 2})
+	for {
+		if err != nil {
+			t.Fatalf("ListFeedbackPage: %v", err)
+		}
+		for _, f := range page.Items {
+			seen = append(seen, f.Title)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		// New submissions arrive between page loads
+		save(fmt.Sprintf("new-%d", len(seen)))
+		page, err = s.ListFeedbackPage(ListOptions{Limit: 2, Cursor: page.NextCursor})
+	}
+
+	want := "old-4 old-3 old-2 old-1 old-0"
+	if got := strings.Join(seen, " "); got != want {
+		t.Fatalf("paged titles = %q, want %q", got, want)
+	}
+	if page.Total != 7 {
+		t.Fatalf("total = %d, want 7", page.Total)
+	}
+
+	// Offset paging still works
+	page, err = s.ListFeedbackPage(ListOptions{Limit: 3, Offset: 6})
+	if err != nil || len(page.Items) != 1 || page.Items[0].Title != "old-0" || page.NextCursor != "" {
+		t.Fatalf("offset page: %v %+v", err, page)
+	}
+}
+
+func TestListFeedbackPage_TamperedCursor(t *testing.T) {
+	s := newTestService(t)
+	for range 3 {
+		if err := s.SaveFeedback(&Feedback{Type: "bug", Title: "t", Description: "d"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	page, err := s.ListFeedbackPage(ListOptions{Limit: 1})
+	if err != nil || page.NextCursor == "" {
+		t.Fatalf("first page: %v %+v", err, page)
+	}
+
+	payload, mac, _ := strings.Cut(page.NextCursor, ".")
+	for _, cursor := range []string{
+		payload,
+		payload + "." + mac + "x",
+		strings.ToUpper(payload) + "." + mac,
+		newTestService(t).encodeCursor(pageCursor{ID: 1}), // signed with another key
+	} {
+		if _, err := s.ListFeedbackPage(ListOptions{Cursor: cursor}); !errors.Is(err, ErrInvalidCursor) {
+			t.Fatalf("cursor %q: got %v, want ErrInvalidCursor", cursor, err)
+		}
+	}
+
+	h := NewHandler(s, "")
+	list := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.HandleList(rec, httptest.NewRequest(http.MethodGet, "/api/admin/feedbacks?"+query, nil))
+		return rec
+	}
+	rec := list("limit=2")
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Total-Count") != "3" || rec.Header().Get(NextCursorHeader) == "" {
+		t.Fatalf("limit=2: %d %v", rec.Code, rec.Header())
+	}
+	if rec := list("limit=2&cursor=" + rec.Header().Get(NextCursorHeader)); rec.Code != http.StatusOK || rec.Header().Get(NextCursorHeader) != "" {
+		t.Fatalf("last page: %d %v", rec.Code, rec.Header())
+	}
+	for _, query := range []string{"cursor=" + payload + ".AAAA", "limit=-1", "offset=1&cursor=" + page.NextCursor} {
+		if rec := list(query); rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status = %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+func TestListFeedbackPage_Attachments(t *testing.T) {
+	s := newTestService(t)
+	var ids []int64
+	for i := range 3 {
+		f := &Feedback{Type: "bug", Title: fmt.Sprintf("f-%d", i), Description: "d"}
+		if err := s.SaveFeedback(f); err != nil {
+			t.Fatalf("SaveFeedback: %v", err)
+		}
+		ids = append(ids, f.ID)
+	}
+	for _, id := range ids[:2] {
+		if _, err := s.db.Exec(`INSERT INTO feedback_attachments (feedback_id, name, content_type, size) VALUES (?, ?, 'image/png', 1)`,
+			id, fmt.Sprintf("0-shot-%d.png", id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	page, err := s.ListFeedbackPage(ListOptions{Limit: 3})
+	if err != nil {
+		t.Fatalf("ListFeedbackPage: %v", err)
+	}
+	for _, f := range page.Items {
+		want := 0
+		if f.ID != ids[2] {
+			want = 1
+		}
+		if len(f.Attachments) != want || (want == 1 && f.Attachments[0].Name != fmt.Sprintf("0-shot-%d.png", f.ID)) {
+			t.Fatalf("feedback %d: Attachments = %+v", f.ID, f.Attachments)
+		}
+	}
+}
@@ -3,16 +3,43 @@ package feedback
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"strings"
+	"time"
+
+	"code-browser/internal/fileperm"
+	"code-browser/internal/repo"
 )
 
 type Service struct {
 	db *sql.DB
+
+	// AttachmentDir is where attachment files are stored (<dataDir>/feedback-attachments).
+	// Attachments are rejected when it is empty.
+	AttachmentDir string
+	// AttachmentModes are the permissions for new attachment directories and files (zero value: 0755/0644)
+	AttachmentModes fileperm.Modes
+
+	// Notifier, if set, is told about each new feedback (asynchronously, see notify)
+	Notifier Notifier
+
+	// Transitions restricts status changes; nil allows any change
+	Transitions Transitions
+
+	// Repos, if set, is used to validate the repository and path in submitted contexts
+	Repos repo.RepoProvider
+
+	// cursorKey signs list cursors (see ListFeedbackPage)
+	cursorKey []byte
 }
 
+// NewService creates the feedback tables in db if needed. db is normally the repository
+// provider's pool (repo.Provider.GetDB), so both share one connection pool and WAL file;
+// the Service never closes it.
 func NewService(db *sql.DB) (*Service, error) {
-	s := &Service{db: db}
+	s := &Service{db: db, cursorKey: newCursorKey()}
 	if err := s.initSchema(); err != nil {
 		return nil, err
 	}
@@ -46,8 +73,26 @@ func (s *Service) initSchema() error {
 	if err := s.addColumnIfNotExists("updated_at", "DATETIME"); err != nil {
 		return err
 	}
+	// Denormalized copies of context.repoId / context.path for indexed lookups.
+	// NULL means "not backfilled yet" (rows written before these columns existed), see backfillContext.
+	if err := s.addColumnIfNotExists("context_repo_id", "TEXT"); err != nil {
+		return err
+	}
+	if err := s.addColumnIfNotExists("context_path", "TEXT"); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_feedbacks_context ON feedbacks (context_repo_id, context_path)`); err != nil {
+		return err
+	}
+	// updated_at is the optimistic concurrency token of status updates, so every row needs one
+	// in a single canonical format (see timestampFormat). Older rows have NULL or
+	// CURRENT_TIMESTAMP values with second precision.
+	if _, err := s.db.Exec(`UPDATE feedbacks SET updated_at = strftime('%Y-%m-%d %H:%M:%f', COALESCE(updated_at, created_at, CURRENT_TIMESTAMP))
+		WHERE updated_at IS NULL OR updated_at NOT LIKE '____-__-__ __:__:__.___'`); err != nil {
+		return err
+	}
 
-	return nil
+	return s.initAttachmentSchema()
 }
 
 func (s *Service) addColumnIfNotExists(colName, colType string) error {
@@ -65,25 +110,162 @@ func (s *Service) addColumnIfNotExists(colName, colType string) error {
 }
 
 func (s *Service) SaveFeedback(f *Feedback) error {
+	if err := s.validateContext(&f.Context); err != nil {
+		return err
+	}
+	if err := insertFeedback(s.db, f); err != nil {
+		return err
+	}
+	s.notify(*f)
+	return nil
+}
+
+// execer is implemented by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// insertFeedback inserts f with status 'open' and sets f.ID and f.Status
+func insertFeedback(db execer, f *Feedback) error {
 	contextBytes, err := json.Marshal(f.Context)
 	if err != nil {
 		return fmt.Errorf("failed to marshal context: %w", err)
 	}
 
-	query := `INSERT INTO feedbacks (type, title, description, email, context_json, status) VALUES (?, ?, ?, ?, ?, 'open')`
-	_, err = s.db.Exec(query, f.Type, f.Title, f.Description, f.Email, string(contextBytes))
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	query := `INSERT INTO feedbacks (type, title, description, email, context_json, context_repo_id, context_path, status, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, 'open', ?)`
+	result, err := db.Exec(query, f.Type, f.Title, f.Description, f.Email, string(contextBytes), f.Context.RepoID, normalizeContextPath(f.Context.Path), now.Format(timestampFormat))
 	if err != nil {
 		return fmt.Errorf("failed to insert feedback: %w", err)
 	}
+	if f.ID, err = result.LastInsertId(); err != nil {
+		return fmt.Errorf("failed to read feedback id: %w", err)
+	}
+	f.Status = "open"
+	f.UpdatedAt = now
 	return nil
 }
 
+const feedbackColumns = `id, type, title, description, email, status, context_json, created_at, updated_at`
+
 func (s *Service) ListFeedbacks() ([]Feedback, error) {
-	query := `SELECT id, type, title, description, email, status, context_json, created_at, updated_at FROM feedbacks ORDER BY created_at DESC`
+	query := `SELECT ` + feedbackColumns + ` FROM feedbacks ORDER BY created_at DESC, id DESC`
 	rows, err := s.db.Query(query)
 	if err != nil {
 		return nil, err
 	}
+	feedbacks, err := scanFeedbacks(rows)
+	if err != nil {
+		return nil, err
+	}
+	return s.complete(feedbacks)
+}
+
+// GetFeedback returns a single feedback, including its attachments and allowed next statuses
+func (s *Service) GetFeedback(id int64) (Feedback, error) {
+	rows, err := s.db.Query(`SELECT `+feedbackColumns+` FROM feedbacks WHERE id = ?`, id)
+	if err != nil {
+		return Feedback{}, err
+	}
+	feedbacks, err := scanFeedbacks(rows)
+	if err != nil {
+		return Feedback{}, err
+	}
+	if len(feedbacks) == 0 {
+		return Feedback{}, ErrFeedbackNotFound
+	}
+	feedbacks, err = s.complete(feedbacks)
+	if err != nil {
+		return Feedback{}, err
+	}
+	return feedbacks[0], nil
+}
+
+// complete fills in the fields that are not stored in the feedbacks row
+func (s *Service) complete(feedbacks []Feedback) ([]Feedback, error) {
+	feedbacks, err := s.withAttachments(feedbacks)
+	if err != nil {
+		return nil, err
+	}
+	for i := range feedbacks {
+		feedbacks[i].NextStatuses = s.Transitions.Next(feedbacks[i].Status)
+	}
+	return feedbacks, nil
+}
+
+// ListByContext returns the feedback filed against a repository, newest first.
+// If path is non-empty, only feedback for that exact file is returned.
+func (s *Service) ListByContext(repoID, path string) ([]Feedback, error) {
+	if repoID == "" {
+		return nil, fmt.Errorf("repoId is required")
+	}
+	if err := s.backfillContext(); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + feedbackColumns + ` FROM feedbacks WHERE context_repo_id = ?`
+	args := []any{repoID}
+	if path = normalizeContextPath(path); path != "" {
+		query += ` AND context_path = ?`
+		args = append(args, path)
+	}
+	rows, err := s.db.Query(query+` ORDER BY created_at DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	feedbacks, err := scanFeedbacks(rows)
+	if err != nil {
+		return nil, err
+	}
+	return s.complete(feedbacks)
+}
+
+// backfillContext fills context_repo_id / context_path from context_json for rows
+// written before those columns existed. Rows are only backfilled once.
+func (s *Service) backfillContext() error {
+	rows, err := s.db.Query(`SELECT id, context_json FROM feedbacks WHERE context_repo_id IS NULL`)
+	if err != nil {
+		return err
+	}
+	type pending struct {
+		id      int64
+		context FeedbackContext
+	}
+	var todo []pending
+	for rows.Next() {
+		var p pending
+		var contextJSON sql.NullString
+		if err := rows.Scan(&p.id, &contextJSON); err != nil {
+			rows.Close()
+			return err
+		}
+		if contextJSON.String != "" {
+			_ = json.Unmarshal([]byte(contextJSON.String), &p.context)
+		}
+		todo = append(todo, p)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	rows.Close()
+
+	for _, p := range todo {
+		_, err := s.db.Exec(`UPDATE feedbacks SET context_repo_id = ?, context_path = ? WHERE id = ?`,
+			p.context.RepoID, normalizeContextPath(p.context.Path), p.id)
+		if err != nil {
+			return fmt.Errorf("failed to backfill feedback %d context: %w", p.id, err)
+		}
+	}
+	return nil
+}
+
+// normalizeContextPath makes "/a/b.go" and "a/b.go" refer to the same file
+func normalizeContextPath(path string) string {
+	return strings.TrimPrefix(strings.TrimSpace(path), "/")
+}
+
+func scanFeedbacks(rows *sql.Rows) ([]Feedback, error) {
 	defer rows.Close()
 
 	var feedbacks []Feedback
@@ -107,23 +289,80 @@ func (s *Service) ListFeedbacks() ([]Feedback, error) {
 		}
 		feedbacks = append(feedbacks, f)
 	}
-	return feedbacks, nil
+	return feedbacks, rows.Err()
+}
+
+// timestampFormat is how updated_at is stored: UTC with millisecond precision, the same
+// format as SQLite's strftime('%Y-%m-%d %H:%M:%f'), so it can be compared as text
+const timestampFormat = "2006-01-02 15:04:05.000"
+
+var (
+	ErrFeedbackNotFound = errors.New("feedback not found")
+	// ErrStatusConflict is returned when the feedback was changed after the client's precondition
+	ErrStatusConflict = errors.New("feedback was modified concurrently")
+)
+
+// StatusPrecondition guards a status update against concurrent changes.
+// The zero value means the update is unconditional.
+type StatusPrecondition struct {
+	UpdatedAt       time.Time // the exact updatedAt the client last saw
+	UnmodifiedSince time.Time // an If-Unmodified-Since header (second precision)
 }
 
-func (s *Service) UpdateFeedbackStatus(id int64, status string) error {
-	query := `UPDATE feedbacks SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
-	result, err := s.db.Exec(query, status, id)
+// UpdateFeedbackStatus sets the status and returns the new updatedAt.
+// It returns ErrInvalidTransition if s.Transitions does not allow the change from the
+// current status, and ErrStatusConflict if the precondition no longer holds.
+func (s *Service) UpdateFeedbackStatus(id int64, status string, pre StatusPrecondition) (time.Time, error) {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	if !now.After(pre.UpdatedAt) {
+		// Two changes within one millisecond must still get different tokens
+		now = pre.UpdatedAt.UTC().Truncate(time.Millisecond).Add(time.Millisecond)
+	}
+	query := `UPDATE feedbacks SET status = ?, updated_at = ? WHERE id = ?`
+	args := []any{status, now.Format(timestampFormat), id}
+	if !pre.UpdatedAt.IsZero() {
+		query += ` AND updated_at = ?`
+		args = append(args, pre.UpdatedAt.UTC().Format(timestampFormat))
+	}
+	if !pre.UnmodifiedSince.IsZero() {
+		// Unmodified since T (whole seconds): last modified before T+1s
+		query += ` AND updated_at < ?`
+		args = append(args, pre.UnmodifiedSince.UTC().Truncate(time.Second).Add(time.Second).Format(timestampFormat))
+	}
+	// Checking the transition in the WHERE clause keeps it atomic with the update
+	if from := s.Transitions.sources(status); from != nil {
+		query += ` AND status IN (?` + strings.Repeat(", ?", len(from)-1) + `)`
+		for _, f := range from {
+			args = append(args, f)
+		}
+	}
+	result, err := s.db.Exec(query, args...)
 	if err != nil {
-		return err
+		return time.Time{}, err
 	}
 	rows, err := result.RowsAffected()
 	if err != nil {
-		return err
+		return time.Time{}, err
 	}
 	if rows == 0 {
-		return fmt.Errorf("feedback not found")
+		var current sql.NullString
+		if err := s.db.QueryRow(`SELECT status FROM feedbacks WHERE id = ?`, id).Scan(&current); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return time.Time{}, ErrFeedbackNotFound
+			}
+			return time.Time{}, err
+		}
+		if !s.Transitions.Allowed(current.String, status) {
+			next := s.Transitions.Next(current.String)
+			if len(next) == 0 {
+				return time.Time{}, fmt.Errorf("%w: status %q is final", ErrInvalidTransition, current.String)
+			}
+			return time.Time{}, fmt.Errorf("%w: cannot change status from %q to %q (allowed: %s)",
+				ErrInvalidTransition, current.String, status, strings.Join(next, ", "))
+		}
+		return time.Time{}, ErrStatusConflict
 	}
-	return nil
+	return now, nil
 }
 
 func (s *Service) DeleteFeedback(id int64) error {
@@ -137,7 +376,11 @@ func (s *Service) DeleteFeedback(id int64) error {
 		return err
 	}
 	if rows == 0 {
-		return fmt.Errorf("feedback not found")
+		return ErrFeedbackNotFound
+	}
+	// Attachment rows are removed by ON DELETE CASCADE; remove the files as well
+	if s.AttachmentDir != "" {
+		os.RemoveAll(s.attachmentDir(id))
 	}
 	return nil
 }
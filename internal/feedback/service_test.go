@@ -0,0 +1,179 @@
+package feedback
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpdateFeedbackStatus_StaleUpdate(t *testing.T) {
+	s := newTestService(t)
+	f := Feedback{Type: "bug", Title: "t", Description: "d"}
+	if err := s.SaveFeedback(&f); err != nil {
+		t.Fatalf("SaveFeedback: %v", err)
+	}
+	list, err := s.ListFeedbacks()
+	if err != nil || len(list) != 1 {
+		t.Fatalf("ListFeedbacks: %v %+v", err, list)
+	}
+	seen := list[0].UpdatedAt // both admins loaded the list at this point
+
+	// Admin A closes the feedback
+	updatedAt, err := s.UpdateFeedbackStatus(f.ID, "closed", StatusPrecondition{UpdatedAt: seen})
+	if err != nil {
+		t.Fatalf("first update: %v", err)
+	}
+	if !updatedAt.After(seen) {
+		t.Fatalf("expected updatedAt to advance: %v -> %v", seen, updatedAt)
+	}
+
+	// Admin B still holds the old updatedAt
+	if _, err := s.UpdateFeedbackStatus(f.ID, "in_progress", StatusPrecondition{UpdatedAt: seen}); !errors.Is(err, ErrStatusConflict) {
+		t.Fatalf("expected ErrStatusConflict for stale update, got %v", err)
+	}
+	// Retrying with the fresh value succeeds
+	if _, err := s.UpdateFeedbackStatus(f.ID, "in_progress", StatusPrecondition{UpdatedAt: updatedAt}); err != nil {
+		t.Fatalf("update with fresh updatedAt: %v", err)
+	}
+	// Unconditional updates keep working
+	if _, err := s.UpdateFeedbackStatus(f.ID, "open", StatusPrecondition{}); err != nil {
+		t.Fatalf("unconditional update: %v", err)
+	}
+	if _, err := s.UpdateFeedbackStatus(f.ID+1, "open", StatusPrecondition{UpdatedAt: seen}); !errors.Is(err, ErrFeedbackNotFound) {
+		t.Fatalf("expected ErrFeedbackNotFound, got %v", err)
+	}
+
+	h := NewHandler(s, "")
+	patch := func(body string, header http.Header) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPatch, "/", bytes.NewBufferString(body))
+		req.SetPathValue("id", strconv.FormatInt(f.ID, 10))
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		h.HandleUpdateStatus(rec, req)
+		return rec
+	}
+
+	stale, _ := json.Marshal(map[string]any{"status": "closed", "updatedAt": seen})
+	if rec := patch(string(stale), nil); rec.Code != http.StatusConflict {
+		t.Fatalf("stale PATCH: status = %d, want 409 (%s)", rec.Code, rec.Body.String())
+	}
+	// An If-Unmodified-Since before the last change is stale as well
+	old := http.Header{"If-Unmodified-Since": {seen.Add(-time.Hour).Format(http.TimeFormat)}}
+	if rec := patch(`{"status":"closed"}`, old); rec.Code != http.StatusConflict {
+		t.Fatalf("stale If-Unmodified-Since: status = %d, want 409", rec.Code)
+	}
+
+	list, _ = s.ListFeedbacks()
+	fresh, _ := json.Marshal(map[string]any{"status": "closed", "updatedAt": list[0].UpdatedAt})
+	rec := patch(string(fresh), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("fresh PATCH: status = %d (%s)", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		UpdatedAt time.Time `json:"updatedAt"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || resp.UpdatedAt.IsZero() {
+		t.Fatalf("expected updatedAt in response, got %v / %+v", err, resp)
+	}
+	if list, _ := s.ListFeedbacks(); !list[0].UpdatedAt.Equal(resp.UpdatedAt) {
+		t.Fatalf("returned updatedAt %v does not match stored %v", resp.UpdatedAt, list[0].UpdatedAt)
+	}
+}
+
+func TestParseTransitions(t *testing.T) {
+	tr, err := ParseTransitions(" open=in_progress|closed ; in_progress=closed;closed=")
+	if err != nil {
+		t.Fatalf("ParseTransitions: %v", err)
+	}
+	if !tr.Allowed("open", "closed") || !tr.Allowed("in_progress", "closed") {
+		t.Fatalf("expected configured transitions to be allowed: %v", tr)
+	}
+	if tr.Allowed("closed", "open") || tr.Allowed("in_progress", "open") || tr.Allowed("unknown", "open") {
+		t.Fatalf("expected unlisted transitions to be rejected: %v", tr)
+	}
+	if !tr.Allowed("closed", "closed") {
+		t.Fatalf("setting the current status must always be allowed")
+	}
+	if next := tr.Next("closed"); len(next) != 0 {
+		t.Fatalf("expected closed to be final, got %v", next)
+	}
+
+	if tr, err := ParseTransitions(""); err != nil || tr != nil {
+		t.Fatalf("expected nil (permissive) transitions for empty spec, got %v, %v", tr, err)
+	}
+	var permissive Transitions
+	if !permissive.Allowed("closed", "whatever") {
+		t.Fatalf("nil transitions must allow any change")
+	}
+	if next := permissive.Next(StatusOpen); !slices.Equal(next, []string{StatusInProgress, StatusClosed}) {
+		t.Fatalf("permissive next statuses = %v", next)
+	}
+
+	for _, bad := range []string{"open", "=closed", "open=closed;open=in_progress"} {
+		if _, err := ParseTransitions(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestUpdateFeedbackStatus_Transitions(t *testing.T) {
+	s := newTestService(t)
+	s.Transitions, _ = ParseTransitions("open=in_progress|closed;in_progress=closed;closed=open")
+	f := Feedback{Type: "bug", Title: "t", Description: "d"}
+	if err := s.SaveFeedback(&f); err != nil {
+		t.Fatalf("SaveFeedback: %v", err)
+	}
+
+	got, err := s.GetFeedback(f.ID)
+	if err != nil {
+		t.Fatalf("GetFeedback: %v", err)
+	}
+	if !slices.Equal(got.NextStatuses, []string{StatusInProgress, StatusClosed}) {
+		t.Fatalf("nextStatuses for open = %v", got.NextStatuses)
+	}
+
+	steps := []struct {
+		to string
+		ok bool
+	}{
+		{StatusInProgress, true},
+		{StatusOpen, false}, // in_progress -> open is not configured
+		{StatusClosed, true},
+		{StatusInProgress, false}, // closed -> in_progress is not configured
+		{StatusClosed, true},      // no-op
+		{StatusOpen, true},
+	}
+	for _, step := range steps {
+		_, err := s.UpdateFeedbackStatus(f.ID, step.to, StatusPrecondition{})
+		if step.ok && err != nil {
+			t.Fatalf("-> %s: unexpected error %v", step.to, err)
+		}
+		if !step.ok && !errors.Is(err, ErrInvalidTransition) {
+			t.Fatalf("-> %s: expected ErrInvalidTransition, got %v", step.to, err)
+		}
+	}
+
+	// The handler reports an illegal transition as 400 with the allowed statuses
+	s.Transitions, _ = ParseTransitions("open=closed")
+	req := httptest.NewRequest(http.MethodPatch, "/", bytes.NewBufferString(`{"status":"in_progress"}`))
+	req.SetPathValue("id", strconv.FormatInt(f.ID, 10))
+	rec := httptest.NewRecorder()
+	NewHandler(s, "").HandleUpdateStatus(rec, req)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "allowed: closed") {
+		t.Fatalf("illegal transition: status = %d, body = %q", rec.Code, rec.Body.String())
+	}
+
+	if _, err := s.GetFeedback(f.ID + 1); !errors.Is(err, ErrFeedbackNotFound) {
+		t.Fatalf("expected ErrFeedbackNotFound, got %v", err)
+	}
+}
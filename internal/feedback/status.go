@@ -0,0 +1,94 @@
+package feedback
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Feedback statuses used by the admin UI
+const (
+	StatusOpen       = "open"
+	StatusInProgress = "in_progress"
+	StatusClosed     = "closed"
+)
+
+// KnownStatuses are offered as next states when no transitions are configured
+var KnownStatuses = []string{StatusOpen, StatusInProgress, StatusClosed}
+
+// ErrInvalidTransition is returned when the configured transitions do not allow a status change
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// Transitions maps a status to the statuses it may change to.
+// A nil map is permissive: any change is allowed, as before transitions existed.
+// Setting a feedback to its current status is always allowed.
+type Transitions map[string][]string
+
+// Allowed reports whether a feedback in status from may change to status to
+func (t Transitions) Allowed(from, to string) bool {
+	if t == nil || from == to {
+		return true
+	}
+	return slices.Contains(t[from], to)
+}
+
+// Next returns the statuses a feedback in status from may change to, for rendering buttons
+func (t Transitions) Next(from string) []string {
+	next := []string{}
+	if t == nil {
+		for _, s := range KnownStatuses {
+			if s != from {
+				next = append(next, s)
+			}
+		}
+		return next
+	}
+	return append(next, t[from]...)
+}
+
+// sources returns the statuses from which a change to status to is allowed (including to itself).
+// nil means any status (permissive).
+func (t Transitions) sources(to string) []string {
+	if t == nil {
+		return nil
+	}
+	from := []string{to}
+	for s, targets := range t {
+		if s != to && slices.Contains(targets, to) {
+			from = append(from, s)
+		}
+	}
+	return from
+}
+
+// ParseTransitions parses "open=in_progress|closed;in_progress=open|closed;closed=open".
+// An empty spec returns nil (permissive).
+func ParseTransitions(spec string) (Transitions, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+	t := Transitions{}
+	for _, rule := range strings.Split(spec, ";") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		from, targets, ok := strings.Cut(rule, "=")
+		from = strings.TrimSpace(from)
+		if !ok || from == "" {
+			return nil, fmt.Errorf("invalid transition rule %q: expected from=to1|to2", rule)
+		}
+		if _, dup := t[from]; dup {
+			return nil, fmt.Errorf("duplicate transition rule for status %q", from)
+		}
+		t[from] = []string{}
+		for _, to := range strings.Split(targets, "|") {
+			if to = strings.TrimSpace(to); to != "" {
+				t[from] = append(t[from], to)
+			}
+		}
+	}
+	return t, nil
+}
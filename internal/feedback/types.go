@@ -6,6 +6,10 @@ type FeedbackContext struct {
 	RepoID string `json:"repoId,omitempty"`
 	Path   string `json:"path,omitempty"`
 	URL    string `json:"url,omitempty"`
+
+	// Set by the server on submit (see Service.validateContext), never taken from the client
+	RepoName string `json:"repoName,omitempty"`
+	Verified bool   `json:"verified"` // repoId exists and path is in its HEAD
 }
 
 type Feedback struct {
@@ -18,4 +22,7 @@ type Feedback struct {
 	Context     FeedbackContext `json:"context,omitempty"`
 	CreatedAt   time.Time       `json:"created_at,omitempty"`
 	UpdatedAt   time.Time       `json:"updated_at,omitempty"`
+	Attachments []Attachment    `json:"attachments,omitempty"`
+	// NextStatuses are the statuses this feedback may change to (see Service.Transitions)
+	NextStatuses []string `json:"nextStatuses"`
 }
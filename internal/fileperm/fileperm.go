@@ -0,0 +1,146 @@
+// Package fileperm 数据目录中新建目录和文件使用的权限 (-dir-mode / -file-mode)。
+// 写入数据目录的代码都通过 Modes 创建目录和文件，使配置的权限在各处一致生效。
+package fileperm
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 未配置时的默认权限，与之前各处写死的值相同
+const (
+	DefaultDirMode  os.FileMode = 0755
+	DefaultFileMode os.FileMode = 0644
+)
+
+// Modes 新建目录和文件的权限。零值字段使用默认值
+type Modes struct {
+	Dir  os.FileMode
+	File os.FileMode
+	// IgnoreUmask 为 true 时创建后再 chmod 为上面的权限，结果不受进程 umask 影响；
+	// 为 false 时与 os.MkdirAll/os.OpenFile 一样，实际权限为配置的权限去掉 umask 中的位
+	IgnoreUmask bool
+}
+
+// withDefaults 用默认值填充零值字段
+func (m Modes) withDefaults() Modes {
+	if m.Dir == 0 {
+		m.Dir = DefaultDirMode
+	}
+	if m.File == 0 {
+		m.File = DefaultFileMode
+	}
+	return m
+}
+
+// Parse 解析八进制的权限 (如 "0750" 或 "750")，只允许权限位 (0 到 0777)
+func Parse(s string) (os.FileMode, error) {
+	n, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || n > 0o777 {
+		return 0, fmt.Errorf("无效的权限 '%s' (应为 0 到 0777 之间的八进制数)", s)
+	}
+	return os.FileMode(n), nil
+}
+
+// Value 把 *os.FileMode 包装为 flag.Value，以八进制读写
+type Value struct{ Mode *os.FileMode }
+
+func (v Value) String() string {
+	if v.Mode == nil {
+		return ""
+	}
+	return fmt.Sprintf("%#o", uint32(*v.Mode))
+}
+
+func (v Value) Set(s string) error {
+	mode, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*v.Mode = mode
+	return nil
+}
+
+// MkdirAll 同 os.MkdirAll，新建的各级目录使用 Dir 权限；已存在的目录不会被修改
+func (m Modes) MkdirAll(dir string) error {
+	m = m.withDefaults()
+	var created []string
+	if m.IgnoreUmask {
+		// 记录尚不存在的各级目录，创建后逐个 chmod
+		for d := filepath.Clean(dir); ; d = filepath.Dir(d) {
+			if _, err := os.Lstat(d); err == nil {
+				break
+			}
+			created = append(created, d)
+			if filepath.Dir(d) == d {
+				break
+			}
+		}
+	}
+	if err := os.MkdirAll(dir, m.Dir); err != nil {
+		return err
+	}
+	for _, d := range created {
+		if err := os.Chmod(d, m.Dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OpenFile 同 os.OpenFile，新建的文件使用 File 权限
+func (m Modes) OpenFile(name string, flag int) (*os.File, error) {
+	m = m.withDefaults()
+	f, err := os.OpenFile(name, flag, m.File)
+	if err != nil {
+		return nil, err
+	}
+	if m.IgnoreUmask {
+		if err := f.Chmod(m.File); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Create 同 os.Create，新建的文件使用 File 权限
+func (m Modes) Create(name string) (*os.File, error) {
+	return m.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC)
+}
+
+// WriteFile 同 os.WriteFile，新建的文件使用 File 权限
+func (m Modes) WriteFile(name string, data []byte) error {
+	f, err := m.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// CreateTemp 同 os.CreateTemp，但新建的文件使用 File 权限 (os.CreateTemp 总是使用 0600)。
+// pattern 中最后一个 "*" 替换为随机串，没有 "*" 时随机串追加在末尾
+func (m Modes) CreateTemp(dir, pattern string) (*os.File, error) {
+	prefix, suffix := pattern, ""
+	if i := strings.LastIndex(pattern, "*"); i >= 0 {
+		prefix, suffix = pattern[:i], pattern[i+1:]
+	}
+	for range 10000 {
+		name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10)+suffix)
+		f, err := m.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		return f, err
+	}
+	return nil, &os.PathError{Op: "createtemp", Path: filepath.Join(dir, prefix+"*"+suffix), Err: os.ErrExist}
+}
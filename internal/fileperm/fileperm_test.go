@@ -0,0 +1,56 @@
+package fileperm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for s, want := range map[string]os.FileMode{"0750": 0750, "750": 0750, "0": 0, "0777": 0777} {
+		if got, err := Parse(s); err != nil || got != want {
+			t.Errorf("Parse(%q) = %#o, %v; want %#o", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "abc", "0800", "01777", "-1"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Parse(%q): expected error", s)
+		}
+	}
+}
+
+func TestModes_IgnoreUmask(t *testing.T) {
+	base := t.TempDir()
+	if err := os.Chmod(base, 0755); err != nil {
+		t.Fatal(err)
+	}
+	m := Modes{Dir: 0750, File: 0640, IgnoreUmask: true}
+
+	// 只修改新建的目录，已存在的上级目录保持不变
+	dir := filepath.Join(base, "a", "b")
+	if err := m.MkdirAll(dir); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	for path, want := range map[string]os.FileMode{base: 0755, filepath.Join(base, "a"): 0750, dir: 0750} {
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != want {
+			t.Errorf("%s: mode %v, %v; want %#o", path, info.Mode().Perm(), err, want)
+		}
+	}
+
+	if err := m.WriteFile(filepath.Join(dir, "f"), []byte("x")); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	tmp, err := m.CreateTemp(dir, "upload-*.tmp")
+	if err != nil {
+		t.Fatalf("CreateTemp: %v", err)
+	}
+	tmp.Close()
+	if filepath.Ext(tmp.Name()) != ".tmp" {
+		t.Errorf("temp name %s", tmp.Name())
+	}
+	for _, path := range []string{filepath.Join(dir, "f"), tmp.Name()} {
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0640 {
+			t.Errorf("%s: mode %v, %v; want 0640", path, info.Mode().Perm(), err)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package lru
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache 是一个线程安全、容量有界的 LRU 缓存
+// 同时支持按条目数 (maxItems) 和按字节数 (maxBytes) 限制，超出时淘汰最久未使用的条目。
+// ttl 大于 0 时条目会在过期后失效 (惰性检查)。
+type Cache struct {
+	maxItems int
+	maxBytes int64
+	ttl      time.Duration
+
+	mu    sync.Mutex
+	ll    *list.List               // 队首为最近使用
+	items map[string]*list.Element // key -> 链表节点
+	bytes int64                    // 当前已占用的字节数
+}
+
+type entry struct {
+	key       string
+	value     any
+	size      int64
+	expiresAt time.Time
+}
+
+// New 创建一个 LRU 缓存。maxItems / maxBytes / ttl 为 0 表示不限制。
+func New(maxItems int, maxBytes int64, ttl time.Duration) *Cache {
+	return &Cache{
+		maxItems: maxItems,
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get 读取缓存条目，命中时将其标记为最近使用
+func (c *Cache) Get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*entry)
+	if !e.expiresAt.IsZero() && time.Now().After(e.expiresAt) {
+		c.removeElement(el)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return e.value, true
+}
+
+// Peek 读取缓存条目但不改变其使用顺序，用于只查看已缓存数据、不应影响淘汰的场景
+func (c *Cache) Peek(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*entry)
+	if !e.expiresAt.IsZero() && time.Now().After(e.expiresAt) {
+		return nil, false
+	}
+	return e.value, true
+}
+
+// Set 写入缓存条目，size 为该条目的估算字节数 (用于 maxBytes 限制)
+// 单个条目超过 maxBytes 时不会被缓存。
+func (c *Cache) Set(key string, value any, size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.maxBytes > 0 && size > c.maxBytes {
+		if el, ok := c.items[key]; ok {
+			c.removeElement(el)
+		}
+		return
+	}
+
+	var expiresAt time.Time
+	if c.ttl > 0 {
+		expiresAt = time.Now().Add(c.ttl)
+	}
+
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry)
+		c.bytes += size - e.size
+		e.value, e.size, e.expiresAt = value, size, expiresAt
+		c.ll.MoveToFront(el)
+	} else {
+		el := c.ll.PushFront(&entry{key: key, value: value, size: size, expiresAt: expiresAt})
+		c.items[key] = el
+		c.bytes += size
+	}
+	c.evict()
+}
+
+// Delete 删除指定条目
+func (c *Cache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+// DeletePrefix 删除所有以 prefix 开头的条目
+func (c *Cache) DeletePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, el := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.removeElement(el)
+		}
+	}
+}
+
+// DeletePrefixValues 删除所有以 prefix 开头的条目并返回被删除条目的值 (包括已过期的条目)
+func (c *Cache) DeletePrefixValues(prefix string) []any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var values []any
+	for key, el := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			values = append(values, el.Value.(*entry).value)
+			c.removeElement(el)
+		}
+	}
+	return values
+}
+
+// Len 返回当前条目数
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// Bytes 返回当前占用的估算字节数
+func (c *Cache) Bytes() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.bytes
+}
+
+// Fits 判断再写入一个 size 字节的新条目是否不会淘汰任何已有条目 (用于预热等不应挤掉现有数据的场景)
+func (c *Cache) Fits(size int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.maxItems > 0 && c.ll.Len()+1 > c.maxItems {
+		return false
+	}
+	return c.maxBytes <= 0 || c.bytes+size <= c.maxBytes
+}
+
+// SetIfFits 仅在写入不会淘汰任何其它条目时写入并返回 true，检查和写入在同一次加锁中完成，
+// 并发调用不会一起越过容量限制。key 已存在时按替换后的大小计算
+func (c *Cache) SetIfFits(key string, value any, size int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	items, bytes := c.ll.Len()+1, c.bytes+size
+	el, exists := c.items[key]
+	if exists {
+		items, bytes = c.ll.Len(), bytes-el.Value.(*entry).size
+	}
+	if (c.maxItems > 0 && items > c.maxItems) || (c.maxBytes > 0 && bytes > c.maxBytes) {
+		return false
+	}
+
+	var expiresAt time.Time
+	if c.ttl > 0 {
+		expiresAt = time.Now().Add(c.ttl)
+	}
+	if exists {
+		e := el.Value.(*entry)
+		e.value, e.size, e.expiresAt = value, size, expiresAt
+		c.ll.MoveToFront(el)
+	} else {
+		c.items[key] = c.ll.PushFront(&entry{key: key, value: value, size: size, expiresAt: expiresAt})
+	}
+	c.bytes = bytes
+	return true
+}
+
+// evict 从队尾淘汰条目直到满足容量限制 (调用方需持有锁)
+func (c *Cache) evict() {
+	for c.ll.Len() > 0 {
+		overItems := c.maxItems > 0 && c.ll.Len() > c.maxItems
+		overBytes := c.maxBytes > 0 && c.bytes > c.maxBytes
+		if !overItems && !overBytes {
+			return
+		}
+		c.removeElement(c.ll.Back())
+	}
+}
+
+// removeElement 移除一个节点 (调用方需持有锁)
+func (c *Cache) removeElement(el *list.Element) {
+	e := el.Value.(*entry)
+	c.ll.Remove(el)
+	delete(c.items, e.key)
+	c.bytes -= e.size
+}
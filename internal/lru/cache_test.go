@@ -0,0 +1,151 @@
+package lru
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCache_EvictsLeastRecentlyUsedByCount(t *testing.T) {
+	c := New(3, 0, 0)
+	c.Set("a", 1, 1)
+	c.Set("b", 2, 1)
+	c.Set("c", 3, 1)
+
+	// 访问 a，使 b 成为最久未使用
+	if _, ok := c.Get("a"); !ok {
+		t.Fatalf("expected a to be cached")
+	}
+	c.Set("d", 4, 1)
+
+	if _, ok := c.Get("b"); ok {
+		t.Fatalf("expected b to be evicted")
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if _, ok := c.Get(key); !ok {
+			t.Fatalf("expected %s to survive eviction", key)
+		}
+	}
+	if c.Len() != 3 {
+		t.Fatalf("expected 3 items, got %d", c.Len())
+	}
+}
+
+func TestCache_EvictsByBytes(t *testing.T) {
+	c := New(0, 100, 0)
+	for i := 0; i < 10; i++ {
+		c.Set(fmt.Sprintf("blob:%d", i), make([]byte, 30), 30)
+	}
+
+	if c.Bytes() > 100 {
+		t.Fatalf("expected at most 100 bytes, got %d", c.Bytes())
+	}
+	// 最近写入的 3 个条目 (90 字节) 应该保留
+	for i := 7; i < 10; i++ {
+		if _, ok := c.Get(fmt.Sprintf("blob:%d", i)); !ok {
+			t.Fatalf("expected most recent blob:%d to survive", i)
+		}
+	}
+	if _, ok := c.Get("blob:0"); ok {
+		t.Fatalf("expected oldest entry to be evicted")
+	}
+
+	// 超过上限的单个条目不缓存
+	c.Set("huge", make([]byte, 200), 200)
+	if _, ok := c.Get("huge"); ok {
+		t.Fatalf("expected oversized entry to be rejected")
+	}
+}
+
+func TestCache_TTLAndDeletePrefix(t *testing.T) {
+	c := New(0, 0, 10*time.Millisecond)
+	c.Set("blob:1:a.go", "a", 1)
+	c.Set("blob:1:b.go", "b", 1)
+	c.Set("blob:2:a.go", "c", 1)
+
+	c.DeletePrefix("blob:1:")
+	if c.Len() != 1 {
+		t.Fatalf("expected 1 item after prefix delete, got %d", c.Len())
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := c.Get("blob:2:a.go"); ok {
+		t.Fatalf("expected entry to expire")
+	}
+}
+
+func TestCache_Fits(t *testing.T) {
+	c := New(2, 100, 0)
+	if !c.Fits(60) || c.Fits(101) {
+		t.Fatalf("empty cache: Fits(60) should be true, Fits(101) false")
+	}
+	c.Set("a", "a", 60)
+	if c.Fits(50) || !c.Fits(40) {
+		t.Fatalf("byte limit: Fits(50) should be false, Fits(40) true")
+	}
+	c.Set("b", "b", 10)
+	if c.Fits(1) {
+		t.Fatalf("item limit: cache with 2 of 2 items should not fit another")
+	}
+	if !New(0, 0, 0).Fits(1 << 40) {
+		t.Fatalf("unbounded cache should always fit")
+	}
+}
+
+func TestCache_SetIfFits(t *testing.T) {
+	c := New(2, 100, 0)
+	if !c.SetIfFits("a", "a", 60) {
+		t.Fatalf("empty cache should accept a 60-byte entry")
+	}
+	if c.SetIfFits("b", "b", 50) {
+		t.Fatalf("byte limit: a 50-byte entry should not fit next to 60 bytes")
+	}
+	if _, ok := c.Get("a"); !ok || c.Len() != 1 {
+		t.Fatalf("a rejected write must not evict anything")
+	}
+	// 替换已有条目时按替换后的大小计算
+	if !c.SetIfFits("a", "a2", 90) || c.Bytes() != 90 {
+		t.Fatalf("replacing a: bytes = %d", c.Bytes())
+	}
+	c.Set("b", "b", 10)
+	if c.SetIfFits("c", "c", 0) {
+		t.Fatalf("item limit: cache with 2 of 2 items should not accept another")
+	}
+
+	// 并发写入时总量不超过容量，也不淘汰任何条目
+	c = New(0, 100, 0)
+	var wg sync.WaitGroup
+	var accepted atomic.Int32
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if c.SetIfFits(fmt.Sprintf("k%d", i), i, 30) {
+				accepted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if accepted.Load() != 3 || c.Len() != 3 || c.Bytes() != 90 {
+		t.Fatalf("concurrent SetIfFits: accepted %d, len %d, bytes %d", accepted.Load(), c.Len(), c.Bytes())
+	}
+}
+
+func TestCache_PeekKeepsOrder(t *testing.T) {
+	c := New(2, 0, 0)
+	c.Set("a", 1, 1)
+	c.Set("b", 2, 1)
+	if v, ok := c.Peek("a"); !ok || v != 1 {
+		t.Fatalf("Peek(a) = %v, %v", v, ok)
+	}
+	// Peek 不把 a 标记为最近使用，写入 c 时仍淘汰 a
+	c.Set("c", 3, 1)
+	if _, ok := c.Peek("a"); ok {
+		t.Fatalf("a should have been evicted")
+	}
+	if _, ok := c.Peek("missing"); ok {
+		t.Fatalf("Peek(missing) should miss")
+	}
+}
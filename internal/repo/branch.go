@@ -0,0 +1,85 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// ErrBranchNotFound 仓库中不存在指定分支
+var ErrBranchNotFound = errors.New("分支不存在")
+
+// detectDefaultBranch 返回源仓库 HEAD 指向的分支名 (如 "main")
+// 空仓库的 HEAD 也指向一个尚无提交的分支，同样返回其名称；
+// 非 Git 仓库或 HEAD 分离 (detached) 时返回空字符串
+func detectDefaultBranch(sourcePath string) string {
+	r, err := git.PlainOpen(sourcePath)
+	if err != nil {
+		return ""
+	}
+	head, err := r.Reference(plumbing.HEAD, false)
+	if err != nil {
+		return ""
+	}
+	if head.Type() == plumbing.SymbolicReference && head.Target().IsBranch() {
+		return head.Target().Short()
+	}
+	return ""
+}
+
+// SetDefaultBranch 设置仓库浏览时默认显示的分支，branch 必须是源仓库中已存在的本地分支
+func (p *Provider) SetDefaultBranch(id uint32, branch string) error {
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
+	branch = strings.TrimPrefix(strings.TrimSpace(branch), "refs/heads/")
+	if branch == "" {
+		return fmt.Errorf("%w: 分支名不能为空", ErrBranchNotFound)
+	}
+	repoInfo, ok := p.GetRepo(id)
+	if !ok {
+		return fmt.Errorf("仓库 ID '%d' 未找到", id)
+	}
+	r, err := git.PlainOpen(repoInfo.SourcePath)
+	if err != nil {
+		return fmt.Errorf("仓库 '%d' 的源路径 '%s' 不是有效的 Git 仓库: %w", id, repoInfo.SourcePath, err)
+	}
+	if _, err := r.Reference(plumbing.NewBranchReferenceName(branch), false); err != nil {
+		return fmt.Errorf("%w: 仓库 '%d' 中没有分支 '%s'", ErrBranchNotFound, id, branch)
+	}
+	if err := p.storeDefaultBranch(id, branch); err != nil {
+		return err
+	}
+	return p.loadReposFromDB()
+}
+
+// storeDefaultBranch 把默认分支写入数据库，不刷新缓存
+func (p *Provider) storeDefaultBranch(id uint32, branch string) error {
+	if _, err := p.db.Exec("UPDATE repositories SET default_branch = ? WHERE repo_id = ?", branch, id); err != nil {
+		return fmt.Errorf("更新仓库 '%d' 的默认分支失败: %w", id, err)
+	}
+	log.Printf("仓库 %d 的默认分支: %s", id, branch)
+	return nil
+}
+
+// ensureDefaultBranch 仓库尚未记录默认分支时 (如添加时源路径还不是 Git 仓库)，从 HEAD 检测并保存
+func (p *Provider) ensureDefaultBranch(repoInfo Repository) {
+	if repoInfo.DefaultBranch != "" {
+		return
+	}
+	branch := detectDefaultBranch(repoInfo.SourcePath)
+	if branch == "" {
+		return
+	}
+	if err := p.storeDefaultBranch(repoInfo.RepoID, branch); err != nil {
+		log.Printf("警告: %v", err)
+		return
+	}
+	if err := p.loadReposFromDB(); err != nil {
+		log.Printf("警告: 刷新仓库缓存失败: %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package repo
+
+import "log"
+
+// BulkAddItem 批量添加中的一个仓库
+type BulkAddItem struct {
+	ID   uint32 `json:"id"`
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// BulkAddResult 批量添加中单个仓库的结果
+type BulkAddResult struct {
+	ID     uint32 `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"` // "ok" | "error" | "skipped" (前面的仓库失败且未设置 continueOnError)
+	Error  string `json:"error,omitempty"`
+}
+
+// AddRepositories 依次添加多个仓库，校验规则与 AddRepository 相同。
+// 每个仓库单独写入数据库 (尽力而为，已成功的不会回滚)；continueOnError 为 false 时遇到第一个错误即停止，
+// 其余仓库标记为 skipped。内存缓存只在最后刷新一次。
+func (p *Provider) AddRepositories(items []BulkAddItem, continueOnError bool) ([]BulkAddResult, error) {
+	if err := p.checkWritable(); err != nil {
+		return nil, err
+	}
+	results := make([]BulkAddResult, len(items))
+	stopped := false
+	added := 0
+	for i, item := range items {
+		results[i] = BulkAddResult{ID: item.ID, Name: item.Name, Status: "skipped"}
+		if stopped {
+			continue
+		}
+		if _, err := p.insertRepository(item.ID, item.Name, item.Path); err != nil {
+			results[i].Status = "error"
+			results[i].Error = err.Error()
+			stopped = !continueOnError
+			continue
+		}
+		results[i].Status = "ok"
+		added++
+	}
+
+	if added > 0 {
+		if err := p.loadReposFromDB(); err != nil {
+			return results, err
+		}
+	}
+	log.Printf("批量添加仓库完成: 成功 %d 个, 共 %d 个", added, len(items))
+	return results, nil
+}
@@ -0,0 +1,78 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// JobKindClone 克隆远程仓库的任务
+const JobKindClone = "clone"
+
+// clonesSubDir 子目录，存放克隆的远程仓库 (<DataDir>/clones/<ID>)
+const clonesSubDir = "clones"
+
+// CloneRepository 在后台把远程仓库 url 克隆到 <DataDir>/clones/<id>，成功后以该目录添加仓库。
+// 克隆进度 (go-git 的 sideband 输出，行内刷新用 \r 分隔) 写入任务输出，可通过 JobOutputSince 增量读取；
+// progress 非 nil 时同时写入 progress (CLI 直接输出到终端)。
+// autoIndex 为 true 时添加成功后启动索引任务 (启动失败只记录日志)。
+// 克隆失败或被取消时删除已克隆的目录，不添加仓库。
+func (p *Provider) CloneRepository(id uint32, name, url string, autoIndex bool, progress io.Writer) (Job, error) {
+	if err := p.checkWritable(); err != nil {
+		return Job{}, err
+	}
+	if id == 0 {
+		return Job{}, fmt.Errorf("仓库 ID 不能为 0")
+	}
+	if name == "" {
+		return Job{}, fmt.Errorf("仓库名称不能为空")
+	}
+	if url == "" {
+		return Job{}, fmt.Errorf("克隆地址不能为空")
+	}
+	if _, ok := p.GetRepo(id); ok {
+		return Job{}, fmt.Errorf("仓库 ID '%d' 已存在", id)
+	}
+	dest := filepath.Join(p.DataDir, clonesSubDir, strconv.FormatUint(uint64(id), 10))
+	if _, err := os.Stat(dest); err == nil {
+		return Job{}, fmt.Errorf("克隆目录 '%s' 已存在", dest)
+	}
+
+	return p.jobs.Start(JobKindClone, id, func(ctx context.Context, rec *JobRecorder) error {
+		var out io.Writer = rec
+		if progress != nil {
+			out = io.MultiWriter(rec, progress)
+		}
+		fmt.Fprintf(out, "Cloning %s into %s\n", url, dest)
+		if _, err := git.PlainCloneContext(ctx, dest, false, &git.CloneOptions{URL: url, Progress: out}); err != nil {
+			os.RemoveAll(dest)
+			log.Printf("克隆仓库 '%d' (%s) 失败: %v", id, url, err)
+			return fmt.Errorf("克隆 '%s' 失败: %w", url, err)
+		}
+		if _, err := p.AddRepository(id, name, dest, false); err != nil {
+			os.RemoveAll(dest)
+			return err
+		}
+		fmt.Fprintf(out, "Added repository %d (%s)\n", id, name)
+		if autoIndex {
+			job, err := p.StartIndexJob(id)
+			if err != nil {
+				log.Printf("警告: 为仓库 '%d' 启动自动索引失败: %v", id, err)
+				return nil
+			}
+			fmt.Fprintf(out, "Started index job %s\n", job.ID)
+		}
+		return nil
+	})
+}
+
+// JobOutputSince 返回任务从累计偏移 offset 开始的输出及下一次读取的偏移 (见 JobManager.OutputSince)
+func (p *Provider) JobOutputSince(jobID string, offset int64) ([]byte, int64, bool) {
+	return p.jobs.OutputSince(jobID, offset)
+}
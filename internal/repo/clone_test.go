@@ -0,0 +1,197 @@
+package repo
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// newCloneSource 创建一个包含一次提交的本地仓库，作为克隆的源
+func newCloneSource(t *testing.T) string {
+	t.Helper()
+	src := t.TempDir()
+	r, err := git.PlainInit(src, false)
+	if err != nil {
+		t.Fatalf("PlainInit: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.Add("main.go"); err != nil {
+		t.Fatal(err)
+	}
+	sig := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+	if _, err := wt.Commit("initial", &git.CommitOptions{Author: sig}); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	return src
+}
+
+func TestCloneRepository(t *testing.T) {
+	p, err := NewProvider(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	defer p.Close()
+
+	var progress bytes.Buffer
+	job, err := p.CloneRepository(3, "cloned", newCloneSource(t), false, &progress)
+	if err != nil {
+		t.Fatalf("CloneRepository: %v", err)
+	}
+	if job.Kind != JobKindClone || job.RepoID != 3 {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+	if final, _ := p.WaitJob(job.ID); final.Status != JobSucceeded {
+		t.Fatalf("expected clone to succeed, got %+v", final)
+	}
+
+	r, ok := p.GetRepo(3)
+	if !ok {
+		t.Fatal("cloned repository was not added")
+	}
+	if want := filepath.Join(p.DataDir, clonesSubDir, "3"); r.SourcePath != want {
+		t.Fatalf("expected source path %s, got %s", want, r.SourcePath)
+	}
+	if _, err := os.Stat(filepath.Join(r.SourcePath, "main.go")); err != nil {
+		t.Fatalf("cloned file missing: %v", err)
+	}
+	if !strings.Contains(progress.String(), "Cloning") {
+		t.Fatalf("progress writer did not receive output: %q", progress.String())
+	}
+
+	// ID 已存在时拒绝
+	if _, err := p.CloneRepository(3, "again", newCloneSource(t), false, nil); err == nil {
+		t.Fatal("expected error for existing repository ID")
+	}
+}
+
+func TestCloneRepository_FailureRemovesDir(t *testing.T) {
+	p, err := NewProvider(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	defer p.Close()
+
+	job, err := p.CloneRepository(5, "missing", filepath.Join(t.TempDir(), "does-not-exist"), false, nil)
+	if err != nil {
+		t.Fatalf("CloneRepository: %v", err)
+	}
+	if final, _ := p.WaitJob(job.ID); final.Status != JobFailed {
+		t.Fatalf("expected clone to fail, got %+v", final)
+	}
+	if _, ok := p.GetRepo(5); ok {
+		t.Fatal("failed clone should not add the repository")
+	}
+	if _, err := os.Stat(filepath.Join(p.DataDir, clonesSubDir, "5")); !os.IsNotExist(err) {
+		t.Fatalf("clone directory should be removed, stat err: %v", err)
+	}
+}
+
+func TestJobManager_OutputSince(t *testing.T) {
+	m := NewJobManager()
+	release := make(chan struct{})
+	job, err := m.Start(JobKindClone, 1, func(_ context.Context, rec *JobRecorder) error {
+		rec.Write([]byte("hello "))
+		<-release
+		rec.Write([]byte("world"))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	var out []byte
+	var offset int64
+	for len(out) == 0 {
+		out, offset, _ = m.OutputSince(job.ID, 0)
+	}
+	if string(out) != "hello " || offset != 6 {
+		t.Fatalf("unexpected first read: %q, %d", out, offset)
+	}
+	close(release)
+	m.Wait(job.ID)
+	out, offset, ok := m.OutputSince(job.ID, offset)
+	if !ok || string(out) != "world" || offset != 11 {
+		t.Fatalf("unexpected second read: %q, %d, %v", out, offset, ok)
+	}
+	if out, _, _ := m.OutputSince(job.ID, offset); len(out) != 0 {
+		t.Fatalf("expected no new output, got %q", out)
+	}
+	if _, _, ok := m.OutputSince("missing", 0); ok {
+		t.Fatal("expected unknown job to be reported")
+	}
+}
+
+func TestHandleJobProgress(t *testing.T) {
+	p, err := NewProvider(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	defer p.Close()
+	h := &Handlers{Provider: p}
+
+	job, err := p.CloneRepository(8, "streamed", newCloneSource(t), false, nil)
+	if err != nil {
+		t.Fatalf("CloneRepository: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/repositories/clone-progress/"+job.ID, nil)
+	req.SetPathValue("jobId", job.ID)
+	rec := httptest.NewRecorder()
+	h.HandleJobProgress(rec, req)
+
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "data: Cloning") || !strings.Contains(body, "event: done") || !strings.Contains(body, `"status":"succeeded"`) {
+		t.Fatalf("unexpected stream:\n%s", body)
+	}
+
+	req = httptest.NewRequest("GET", "/api/repositories/clone-progress/missing", nil)
+	req.SetPathValue("jobId", "missing")
+	rec = httptest.NewRecorder()
+	h.HandleJobProgress(rec, req)
+	if rec.Code != 404 {
+		t.Fatalf("expected 404 for unknown job, got %d", rec.Code)
+	}
+}
+
+func TestStreamAuthMiddleware(t *testing.T) {
+	h := &Handlers{AdminToken: "secret"}
+	handler := h.StreamAuthMiddleware(func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, tc := range []struct {
+		url, header string
+		want        int
+	}{
+		{"/api/repositories/clone-progress/j", "", http.StatusUnauthorized},
+		{"/api/repositories/clone-progress/j?access_token=wrong", "", http.StatusUnauthorized},
+		{"/api/repositories/clone-progress/j?access_token=secret", "", http.StatusOK},
+		{"/api/repositories/clone-progress/j", "Bearer secret", http.StatusOK},
+	} {
+		req := httptest.NewRequest("GET", tc.url, nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != tc.want {
+			t.Fatalf("%s (Authorization %q): got %d, want %d", tc.url, tc.header, rec.Code, tc.want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"code-browser/internal/codetree"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"golang.org/x/sync/singleflight"
+)
+
+// maxFileAgesCommits 计算文件最后修改时间时最多回溯的提交数，超出后未确定的文件不出现在结果中
+const maxFileAgesCommits = 20000
+
+// fileAgesEntry 某个 HEAD 提交对应的计算结果
+type fileAgesEntry struct {
+	head plumbing.Hash
+	ages map[string]time.Time
+}
+
+// fileAgesCache 按仓库缓存 FileAges 的结果；HEAD 变化后自动重新计算。
+// 同一仓库和 HEAD 的并发未命中只计算一次，其余调用等待并共享结果
+type fileAgesCache struct {
+	mu      sync.Mutex
+	entries map[uint32]fileAgesEntry
+	flight  singleflight.Group
+}
+
+func (c *fileAgesCache) get(id uint32, head plumbing.Hash) (map[string]time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[id]
+	if !ok || e.head != head {
+		return nil, false
+	}
+	return e.ages, true
+}
+
+func (c *fileAgesCache) set(id uint32, head plumbing.Hash, ages map[string]time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[uint32]fileAgesEntry)
+	}
+	c.entries[id] = fileAgesEntry{head: head, ages: ages}
+}
+
+// FileAges 返回 HEAD 中每个文件最后一次被修改的提交时间 (提交者时间)
+// 从 HEAD 沿提交历史回溯，与第一个父提交比较找出每个提交修改的文件，直到所有文件都已确定
+// 或回溯了 maxFileAgesCommits 个提交。结果按仓库和 HEAD 缓存，返回的 map 不应被修改；
+// 缓存未命中时并发的调用共享同一次计算。
+func (p *Provider) FileAges(id uint32) (map[string]time.Time, error) {
+	repoInfo, ok := p.GetRepo(id)
+	if !ok {
+		return nil, fmt.Errorf("仓库 ID '%d' 未找到", id)
+	}
+	r, err := git.PlainOpen(repoInfo.SourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("打开 Git 仓库失败: %w", err)
+	}
+	head, err := r.Head()
+	if err != nil {
+		return nil, fmt.Errorf("获取 HEAD 引用失败: %w", err)
+	}
+	if ages, ok := p.fileAges.get(id, head.Hash()); ok {
+		return ages, nil
+	}
+
+	v, err, _ := p.fileAges.flight.Do(fmt.Sprintf("%d:%s", id, head.Hash()), func() (any, error) {
+		// 上一次计算可能在本次检查缓存之后刚刚完成
+		if ages, ok := p.fileAges.get(id, head.Hash()); ok {
+			return ages, nil
+		}
+		ages, err := computeFileAges(r, head.Hash())
+		if err != nil {
+			return nil, err
+		}
+		p.fileAges.set(id, head.Hash(), ages)
+		return ages, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("计算仓库 '%d' 的文件修改时间失败: %w", id, err)
+	}
+	return v.(map[string]time.Time), nil
+}
+
+// computeFileAges 实现 FileAges 的历史回溯
+func computeFileAges(r *git.Repository, head plumbing.Hash) (map[string]time.Time, error) {
+	headCommit, err := r.CommitObject(head)
+	if err != nil {
+		return nil, err
+	}
+	headTree, err := headCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	// 尚未确定修改时间的文件；列出 HEAD 的文件同样受 codetree.Walk 的限制，超出的文件不出现在结果中
+	pending := make(map[string]bool)
+	_, err = codetree.Walk(context.Background(), headTree, codetree.Limits{}, func(name string, _ object.TreeEntry) (int64, error) {
+		pending[name] = true
+		return 0, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ages := make(map[string]time.Time, len(pending))
+	iter, err := r.Log(&git.LogOptions{From: head, Order: git.LogOrderCommitterTime})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	walked := 0
+	err = iter.ForEach(func(c *object.Commit) error {
+		if len(pending) == 0 || walked >= maxFileAgesCommits {
+			return io.EOF
+		}
+		walked++
+		tree, err := c.Tree()
+		if err != nil {
+			return err
+		}
+		var parentTree *object.Tree
+		if c.NumParents() > 0 {
+			parent, err := c.Parent(0)
+			if err != nil {
+				return err
+			}
+			if parentTree, err = parent.Tree(); err != nil {
+				return err
+			}
+		}
+		changes, err := object.DiffTree(parentTree, tree)
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			name := change.To.Name
+			if pending[name] {
+				ages[name] = c.Committer.When
+				delete(pending, name)
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return ages, nil
+}
+
+// FilterFileAges 只保留 dir 目录 (仓库内相对路径，空表示全部) 下的文件
+func FilterFileAges(ages map[string]time.Time, dir string) map[string]time.Time {
+	dir = strings.Trim(dir, "/")
+	if dir == "" {
+		return ages
+	}
+	prefix := dir + "/"
+	scoped := make(map[string]time.Time)
+	for path, t := range ages {
+		if strings.HasPrefix(path, prefix) {
+			scoped[path] = t
+		}
+	}
+	return scoped
+}
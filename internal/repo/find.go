@@ -0,0 +1,78 @@
+package repo
+
+import (
+	"strings"
+	"unicode"
+)
+
+// diacriticFold 将带变音符号的拉丁字母映射为基本字母 (小写)
+// 标准库没有 Unicode 分解 (NFD)，这里覆盖 Latin-1 补充和拉丁扩展-A 中的常用字符
+var diacriticFold = buildDiacriticFold(map[rune]string{
+	'a': "àáâãäåāăą",
+	'c': "çćĉċč",
+	'd': "ďđ",
+	'e': "èéêëēĕėęě",
+	'g': "ĝğġģ",
+	'h': "ĥħ",
+	'i': "ìíîïĩīĭįı",
+	'j': "ĵ",
+	'k': "ķ",
+	'l': "ĺļľŀł",
+	'n': "ñńņňŉ",
+	'o': "òóôõöøōŏő",
+	'r': "ŕŗř",
+	's': "śŝşš",
+	't': "ţťŧ",
+	'u': "ùúûüũūŭůűų",
+	'w': "ŵ",
+	'y': "ýÿŷ",
+	'z': "źżž",
+})
+
+func buildDiacriticFold(table map[rune]string) map[rune]rune {
+	m := make(map[rune]rune)
+	for base, variants := range table {
+		for _, r := range variants {
+			m[r] = base
+		}
+	}
+	return m
+}
+
+// foldForMatch 将字符串转为小写并去掉变音符号，使 "Café" 与 "cafe" 等价
+func foldForMatch(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s))
+	for _, r := range strings.ToLower(s) {
+		// 组合用变音符号 (如 "é") 直接丢弃
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if base, ok := diacriticFold[r]; ok {
+			r = base
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// FilterRepos 返回名称 (matchPath 为 true 时也包括源路径) 包含 query 的仓库，
+// 匹配忽略大小写和变音符号，query 为空时返回全部
+func FilterRepos(repos []Repository, query string, matchPath bool) []Repository {
+	q := foldForMatch(strings.TrimSpace(query))
+	if q == "" {
+		return repos
+	}
+	matched := make([]Repository, 0)
+	for _, r := range repos {
+		if strings.Contains(foldForMatch(r.Name), q) || (matchPath && strings.Contains(foldForMatch(r.SourcePath), q)) {
+			matched = append(matched, r)
+		}
+	}
+	return matched
+}
+
+// FindRepos 按名称查找未归档的仓库，匹配规则见 FilterRepos
+func (p *Provider) FindRepos(query string) []Repository {
+	return FilterRepos(p.GetAll(), query, false)
+}
@@ -1,32 +1,108 @@
 package repo
 
 import (
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Handlers struct {
 	Provider   *Provider
 	AdminToken string
+	// MaxScipUploadBytes limits the body size of SCIP uploads (0 means DefaultMaxScipUploadBytes)
+	MaxScipUploadBytes int64
+	// ReindexConcurrency is the default number of parallel indexers for reindex-all (0 means DefaultReindexConcurrency)
+	ReindexConcurrency int
+	// Reload replaces Provider.Reload for /api/admin/reload, so the server can also clear its own caches
+	Reload func() error
+}
+
+// DefaultMaxScipUploadBytes is the default upload limit for SCIP indexes (1 GiB)
+const DefaultMaxScipUploadBytes int64 = 1 << 30
+
+// scipTransferTimeout replaces the server-wide read/write timeouts for SCIP uploads and downloads,
+// which are too short for large indexes
+const scipTransferTimeout = 10 * time.Minute
+
+// extendDeadlines lifts the server timeouts for long transfers (ignored when unsupported)
+func extendDeadlines(w http.ResponseWriter) {
+	rc := http.NewResponseController(w)
+	deadline := time.Now().Add(scipTransferTimeout)
+	_ = rc.SetReadDeadline(deadline)
+	_ = rc.SetWriteDeadline(deadline)
+}
+
+// Errors returned by CheckAdminToken; the messages are the 401 response bodies
+var (
+	ErrMissingAdminToken = errors.New("Unauthorized: Missing or invalid token")
+	ErrInvalidAdminToken = errors.New("Unauthorized: Invalid token")
+)
+
+// CheckAdminToken checks the request's "Authorization: Bearer <token>" header against adminToken
+// (in constant time). An empty adminToken disables auth. This is the single admin check shared by
+// the repository, feedback and search handlers
+func CheckAdminToken(r *http.Request, adminToken string) error {
+	if adminToken == "" {
+		return nil
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return ErrMissingAdminToken
+	}
+	return checkToken(token, adminToken)
+}
+
+// checkToken compares a presented token with the admin token in constant time
+func checkToken(token, adminToken string) error {
+	if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+		return ErrInvalidAdminToken
+	}
+	return nil
 }
 
 // AuthMiddleware checks for the correct admin token
 func (h *Handlers) AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if h.AdminToken != "" {
-			authHeader := r.Header.Get("Authorization")
-			if !strings.HasPrefix(authHeader, "Bearer ") {
-				http.Error(w, "Unauthorized: Missing or invalid token", http.StatusUnauthorized)
-				return
-			}
-			token := strings.TrimPrefix(authHeader, "Bearer ")
-			if token != h.AdminToken {
-				http.Error(w, "Unauthorized: Invalid token", http.StatusUnauthorized)
-				return
-			}
+		if err := CheckAdminToken(r, h.AdminToken); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// StreamAuthMiddleware is AuthMiddleware for server-sent event streams. A browser EventSource cannot
+// set the Authorization header, so the admin token may also be passed as ?access_token=
+func (h *Handlers) StreamAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	withHeader := h.AuthMiddleware(next)
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("access_token")
+		if h.AdminToken == "" || token == "" {
+			withHeader(w, r)
+			return
+		}
+		if err := checkToken(token, h.AdminToken); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// RequireWritable rejects requests with 403 when the provider is read-only (see ProviderOptions.ReadOnly)
+func (h *Handlers) RequireWritable(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.Provider.ReadOnly() {
+			http.Error(w, ErrReadOnly.Error(), http.StatusForbidden)
+			return
 		}
 		next(w, r)
 	}
@@ -35,45 +111,203 @@ func (h *Handlers) AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 // HandleAdd handles POST /api/repositories
 func (h *Handlers) HandleAdd(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		ID   uint32 `json:"id"`
-		Name string `json:"name"`
-		Path string `json:"path"`
+		ID        uint32 `json:"id"`
+		Name      string `json:"name"`
+		Path      string `json:"path"`
+		AutoIndex bool   `json:"autoIndex"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	if err := h.Provider.AddRepository(req.ID, req.Name, req.Path); err != nil {
+	job, err := h.Provider.AddRepository(req.ID, req.Name, req.Path, req.AutoIndex)
+	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to add repo: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	resp := map[string]string{"status": "ok"}
+	if job != nil {
+		resp["jobId"] = job.ID
+	}
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	json.NewEncoder(w).Encode(resp)
+}
+
+// HandleClone handles POST /api/repositories/clone
+// Body: {id, name, url, autoIndex}. Cloning runs as a job; follow it with
+// GET /api/repositories/clone-progress/{jobId}
+func (h *Handlers) HandleClone(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID        uint32 `json:"id"`
+		Name      string `json:"name"`
+		URL       string `json:"url"`
+		AutoIndex bool   `json:"autoIndex"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.Provider.CloneRepository(req.ID, req.Name, req.URL, req.AutoIndex, nil)
+	if err != nil {
+		var inProgress *ErrJobInProgress
+		if errors.As(err, &inProgress) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"status": "clone in progress", "jobId": inProgress.Job.ID})
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to clone repo: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"status": "clone started", "jobId": job.ID})
+}
+
+// jobProgressInterval is how often the progress stream polls the job output
+const jobProgressInterval = 250 * time.Millisecond
+
+// HandleJobProgress handles GET /api/repositories/clone-progress/{jobId} (also GET /api/jobs/{jobId}/progress)
+// Streams a job's output (clone progress, indexer output) as server-sent events: one "data:"
+// event per line (go-git rewrites clone counters in place with \r, each update becomes its own
+// event), then a final "done" event carrying the job as JSON. Connecting late replays the retained output.
+func (h *Handlers) HandleJobProgress(w http.ResponseWriter, r *http.Request) {
+	jobID := r.PathValue("jobId")
+	if _, ok := h.Provider.GetJob(jobID); !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// The stream lasts as long as the clone; lift the server's write timeout for it
+	rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(jobProgressInterval)
+	defer ticker.Stop()
+	var (
+		offset  int64
+		partial []byte
+	)
+	for {
+		// Read the status before the output so that a finished job's output is complete
+		job, ok := h.Provider.GetJob(jobID)
+		data, next, _ := h.Provider.JobOutputSince(jobID, offset)
+		offset = next
+		partial = writeProgressEvents(w, append(partial, data...))
+		if !ok || job.Status != JobRunning {
+			writeProgressEvents(w, append(partial, '\n'))
+			payload, _ := json.Marshal(job)
+			fmt.Fprintf(w, "event: done\ndata: %s\n\n", payload)
+			rc.Flush()
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// writeProgressEvents writes one SSE event per complete line in buf (lines end with \n or \r)
+// and returns the trailing incomplete line
+func writeProgressEvents(w io.Writer, buf []byte) []byte {
+	for {
+		i := strings.IndexAny(string(buf), "\r\n")
+		if i < 0 {
+			return buf
+		}
+		if line := strings.TrimSpace(string(buf[:i])); line != "" {
+			fmt.Fprintf(w, "data: %s\n\n", line)
+		}
+		buf = buf[i+1:]
+	}
+}
+
+// HandleBulkAdd handles POST /api/repositories/bulk
+// Body: [{id, name, path}, ...]; ?continueOnError=true keeps going after a failed item
+// Always responds 200 with a per-item result unless the request itself is invalid
+func (h *Handlers) HandleBulkAdd(w http.ResponseWriter, r *http.Request) {
+	var items []BulkAddItem
+	if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(items) == 0 {
+		http.Error(w, "Request body must be a non-empty array", http.StatusBadRequest)
+		return
+	}
+	continueOnError := r.URL.Query().Get("continueOnError") == "true"
+
+	results, err := h.Provider.AddRepositories(items, continueOnError)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to reload repositories: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	added := 0
+	for _, res := range results {
+		if res.Status == "ok" {
+			added++
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"added":   added,
+		"results": results,
+	})
 }
 
 // HandleListAdmin handles GET /api/admin/repositories
 // Returns full repository details including path (Protected)
+// Archived repositories are only listed with ?includeArchived=true
+// ?q= filters by name or path (case- and accent-insensitive substring)
 func (h *Handlers) HandleListAdmin(w http.ResponseWriter, r *http.Request) {
 	repos := h.Provider.GetAll()
-	
+	if r.URL.Query().Get("includeArchived") == "true" {
+		repos = h.Provider.GetAllIncludingArchived()
+	}
+	repos = FilterRepos(repos, r.URL.Query().Get("q"), true)
+	repos = FilterReposByTag(repos, r.URL.Query().Get("tag"))
+
 	type AdminRepoInfo struct {
-		ID   uint32 `json:"id"`
-		Name string `json:"name"`
-		Path string `json:"path"`
+		ID       uint32   `json:"id"`
+		Name     string   `json:"name"`
+		Path     string   `json:"path"`
+		Archived bool     `json:"archived"`
+		Enabled  bool     `json:"enabled"`
+		Tags     []string `json:"tags"`
+
+		DefaultBranch string `json:"defaultBranch"`
 	}
 
 	var infos []AdminRepoInfo
 	for _, repo := range repos {
 		infos = append(infos, AdminRepoInfo{
-			ID:   repo.RepoID,
-			Name: repo.Name,
-			Path: repo.SourcePath,
+			ID:       repo.RepoID,
+			Name:     repo.Name,
+			Path:     repo.SourcePath,
+			Archived: repo.Archived,
+			Enabled:  !repo.Disabled,
+			Tags:     append([]string{}, repo.Tags...),
+
+			DefaultBranch: repo.DefaultBranch,
 		})
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(infos)))
 	json.NewEncoder(w).Encode(infos)
 }
 
@@ -95,6 +329,174 @@ func (h *Handlers) HandleDelete(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
+// HandleAddTag handles POST /api/repositories/{id}/tags
+// Body: {"tag": "backend"}; tags are lowercased, adding an existing tag is a no-op
+func (h *Handlers) HandleAddTag(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Tag string `json:"tag"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if _, ok := h.Provider.GetRepo(uint32(id)); !ok {
+		http.Error(w, "Repository not found", http.StatusNotFound)
+		return
+	}
+	if err := h.Provider.AddTag(uint32(id), req.Tag); err != nil {
+		if errors.Is(err, ErrInvalidTag) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to add tag: %v", err), http.StatusInternalServerError)
+		return
+	}
+	h.writeTags(w, uint32(id))
+}
+
+// HandleRemoveTag handles DELETE /api/repositories/{id}/tags/{tag}
+func (h *Handlers) HandleRemoveTag(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	if _, ok := h.Provider.GetRepo(uint32(id)); !ok {
+		http.Error(w, "Repository not found", http.StatusNotFound)
+		return
+	}
+	if err := h.Provider.RemoveTag(uint32(id), r.PathValue("tag")); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to remove tag: %v", err), http.StatusInternalServerError)
+		return
+	}
+	h.writeTags(w, uint32(id))
+}
+
+// writeTags responds with the repository's current tags
+func (h *Handlers) writeTags(w http.ResponseWriter, id uint32) {
+	tags, err := h.Provider.GetTags(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"id": id, "tags": tags})
+}
+
+// HandleSetDefaultBranch handles PUT /api/repositories/{id}/default-branch
+// Body: {"branch": "main"}; the branch must exist in the source repository
+func (h *Handlers) HandleSetDefaultBranch(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Branch string `json:"branch"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if _, ok := h.Provider.GetRepo(uint32(id)); !ok {
+		http.Error(w, "Repository not found", http.StatusNotFound)
+		return
+	}
+	if err := h.Provider.SetDefaultBranch(uint32(id), req.Branch); err != nil {
+		if errors.Is(err, ErrBranchNotFound) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to set default branch: %v", err), http.StatusInternalServerError)
+		return
+	}
+	repo, _ := h.Provider.GetRepo(uint32(id))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"id": repo.RepoID, "defaultBranch": repo.DefaultBranch})
+}
+
+// HandleGitInfo handles GET /api/repositories/{id}/git-info
+// Edge cases such as a non-git source or an empty repository are reported in the body, not as errors
+func (h *Handlers) HandleGitInfo(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	info, err := h.Provider.GitInfo(r.Context(), uint32(id))
+	if err != nil {
+		http.Error(w, "Repository not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+// HandleSetEnabled handles PUT /api/repositories/{id}/enabled
+// Body: {"enabled": false}; a disabled repo stays listed but browsing and search return 403
+func (h *Handlers) HandleSetEnabled(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+		http.Error(w, "Invalid request body: 'enabled' is required", http.StatusBadRequest)
+		return
+	}
+	if _, ok := h.Provider.GetRepo(uint32(id)); !ok {
+		http.Error(w, "Repository not found", http.StatusNotFound)
+		return
+	}
+	if err := h.Provider.SetEnabled(uint32(id), *req.Enabled); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to update enabled state: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"id": id, "enabled": *req.Enabled})
+}
+
+// HandleArchive handles POST /api/repositories/{id}/archive
+// Soft delete: hides the repo from listings and search but keeps its data
+func (h *Handlers) HandleArchive(w http.ResponseWriter, r *http.Request) {
+	h.handleSetArchived(w, r, true)
+}
+
+// HandleUnarchive handles POST /api/repositories/{id}/unarchive
+func (h *Handlers) HandleUnarchive(w http.ResponseWriter, r *http.Request) {
+	h.handleSetArchived(w, r, false)
+}
+
+func (h *Handlers) handleSetArchived(w http.ResponseWriter, r *http.Request, archived bool) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if archived {
+		err = h.Provider.ArchiveRepository(uint32(id))
+	} else {
+		err = h.Provider.UnarchiveRepository(uint32(id))
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to update archive state: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
 // HandleIndex handles POST /api/repositories/{id}/index
 func (h *Handlers) HandleIndex(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
@@ -104,15 +506,297 @@ func (h *Handlers) HandleIndex(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Async indexing
-	go func() {
-		if err := h.Provider.IndexRepositoryZoekt(uint32(id)); err != nil {
-			fmt.Printf("Async index error for repo %d: %v\n", id, err)
+	if _, ok := h.Provider.GetRepo(uint32(id)); !ok {
+		http.Error(w, "Repository not found", http.StatusNotFound)
+		return
+	}
+
+	// Async indexing, tracked as a job
+	job, err := h.Provider.StartIndexJob(uint32(id))
+	if err != nil {
+		var inProgress *ErrJobInProgress
+		if errors.As(err, &inProgress) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"status": "indexing in progress", "jobId": inProgress.Job.ID})
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to start indexing: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"status": "indexing started", "jobId": job.ID})
+}
+
+// HandleReindexAll handles POST /api/repositories/reindex-all
+// Starts reindexing every non-archived repository in the background with at most
+// ?concurrency= (default ReindexConcurrency) indexers at a time. Progress is visible via GET /api/jobs.
+// Returns 409 while a previous reindex-all is still running.
+func (h *Handlers) HandleReindexAll(w http.ResponseWriter, r *http.Request) {
+	concurrency := h.ReindexConcurrency
+	if s := r.URL.Query().Get("concurrency"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid concurrency", http.StatusBadRequest)
+			return
 		}
-	}()
+		concurrency = n
+	}
+
+	if err := h.Provider.StartReindexAll(concurrency); err != nil {
+		if errors.Is(err, ErrReindexInProgress) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"status": "reindex in progress"})
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to start reindex: %v", err), http.StatusInternalServerError)
+		return
+	}
 
 	w.WriteHeader(http.StatusAccepted)
-	json.NewEncoder(w).Encode(map[string]string{"status": "indexing started"})
+	json.NewEncoder(w).Encode(map[string]any{"status": "reindex started", "repositories": len(h.Provider.GetAll())})
+}
+
+// DefaultFileAgesLimit and MaxFileAgesLimit bound the number of files returned by HandleFileAges
+const (
+	DefaultFileAgesLimit = 5000
+	MaxFileAgesLimit     = 50000
+)
+
+// HandleFileAges handles GET /api/repositories/{id}/file-ages
+// Returns each file's last-commit time, sorted by path. ?path= scopes to a subdirectory,
+// ?limit= caps the number of files (default DefaultFileAgesLimit, at most MaxFileAgesLimit)
+func (h *Handlers) HandleFileAges(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	limit := DefaultFileAgesLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(limit, MaxFileAgesLimit)
+	}
+	repoInfo, ok := h.Provider.GetRepo(uint32(id))
+	if !ok {
+		http.Error(w, "Repository not found", http.StatusNotFound)
+		return
+	}
+	if err := repoInfo.CheckEnabled(); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	ages, err := h.Provider.FileAges(uint32(id))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to compute file ages: %v", err), http.StatusInternalServerError)
+		return
+	}
+	ages = FilterFileAges(ages, r.URL.Query().Get("path"))
+
+	paths := make([]string, 0, len(ages))
+	for p := range ages {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	truncated := len(paths) > limit
+	if truncated {
+		paths = paths[:limit]
+	}
+
+	type fileAge struct {
+		Path       string    `json:"path"`
+		LastCommit time.Time `json:"lastCommit"`
+	}
+	files := make([]fileAge, 0, len(paths))
+	for _, p := range paths {
+		files = append(files, fileAge{Path: p, LastCommit: ages[p]})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"files": files, "total": len(ages), "truncated": truncated})
+}
+
+// MaxTreeDiffChanges caps the number of changes returned by HandleTreeDiff
+const MaxTreeDiffChanges = 10000
+
+// HandleTreeDiff handles GET /api/repositories/{id}/tree-diff?revA=&revB=
+// Lists the files added, modified, deleted or renamed between two revisions, sorted by path.
+// revB defaults to HEAD and revA to the first parent of revB.
+func (h *Handlers) HandleTreeDiff(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	repoInfo, ok := h.Provider.GetRepo(uint32(id))
+	if !ok {
+		http.Error(w, "Repository not found", http.StatusNotFound)
+		return
+	}
+	if err := repoInfo.CheckEnabled(); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	q := r.URL.Query()
+	changes, err := h.Provider.TreeDiff(uint32(id), q.Get("revA"), q.Get("revB"))
+	if err != nil {
+		if errors.Is(err, ErrRevisionNotFound) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, fmt.Sprintf("Failed to compare revisions: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+	total := len(changes)
+	truncated := total > MaxTreeDiffChanges
+	if truncated {
+		changes = changes[:MaxTreeDiffChanges]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"changes": changes, "total": total, "truncated": truncated})
+}
+
+// HandleIndexLog handles GET /api/repositories/{id}/index-log
+// Returns the latest indexing log as text, or the log of a specific run with ?job=<jobId>
+func (h *Handlers) HandleIndexLog(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	if _, ok := h.Provider.GetRepo(uint32(id)); !ok {
+		http.Error(w, "Repository not found", http.StatusNotFound)
+		return
+	}
+
+	var logPath string
+	if jobID := r.URL.Query().Get("job"); jobID != "" {
+		job, ok := h.Provider.GetJob(jobID)
+		if !ok || job.RepoID != uint32(id) {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		logPath = job.LogFile
+		if _, err := os.Stat(logPath); logPath == "" || err != nil {
+			// The log file was rotated away (or not created yet); fall back to the in-memory tail
+			output, _ := h.Provider.JobOutput(jobID)
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write(output)
+			return
+		}
+	} else {
+		logPath, err = h.Provider.LatestIndexLog(uint32(id))
+		if errors.Is(err, ErrIndexLogNotFound) {
+			http.Error(w, "No index log for this repository", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to find index log: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	f, err := os.Open(logPath)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to open index log: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.Copy(w, f)
+}
+
+// HandleCancelJob handles POST /api/jobs/{jobId}/cancel
+// Kills the indexer process; the job becomes "cancelled" once it has exited
+func (h *Handlers) HandleCancelJob(w http.ResponseWriter, r *http.Request) {
+	jobID := r.PathValue("jobId")
+	if err := h.Provider.CancelJob(jobID); err != nil {
+		switch {
+		case errors.Is(err, ErrJobNotFound):
+			http.Error(w, "Job not found", http.StatusNotFound)
+		case errors.Is(err, ErrJobNotRunning):
+			http.Error(w, "Job is not running", http.StatusConflict)
+		default:
+			http.Error(w, fmt.Sprintf("Failed to cancel job: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"status": "cancelling", "jobId": jobID})
+}
+
+// HandleReconcile handles POST /api/admin/reconcile
+// Compares the repositories table with the data directory and Zoekt shards.
+// With ?dryRun=true only the report is returned; otherwise the discrepancies are fixed as well.
+func (h *Handlers) HandleReconcile(w http.ResponseWriter, r *http.Request) {
+	dryRun := r.URL.Query().Get("dryRun") == "true"
+	report, err := h.Provider.Reconcile(dryRun)
+	if errors.Is(err, ErrReadOnly) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to reconcile: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// HandleReload handles GET/POST /api/admin/reload
+// Reloads the repository list from the database and returns the repository count before and after
+func (h *Handlers) HandleReload(w http.ResponseWriter, r *http.Request) {
+	reload := h.Reload
+	if reload == nil {
+		reload = h.Provider.Reload
+	}
+	before := h.Provider.Count()
+	if err := reload(); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to reload repositories: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"before": before, "count": h.Provider.Count()})
+}
+
+// HandleListJobs handles GET /api/jobs
+// Optional ?repoId= limits the list to one repository
+func (h *Handlers) HandleListJobs(w http.ResponseWriter, r *http.Request) {
+	var repoID uint32
+	if s := r.URL.Query().Get("repoId"); s != "" {
+		id, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			http.Error(w, "Invalid repoId", http.StatusBadRequest)
+			return
+		}
+		repoID = uint32(id)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.Provider.ListJobs(repoID))
+}
+
+// HandleGetJob handles GET /api/jobs/{jobId}
+func (h *Handlers) HandleGetJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.Provider.GetJob(r.PathValue("jobId"))
+	if !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
 }
 
 // HandleRegisterScip handles POST /api/repositories/{id}/scip
@@ -141,6 +825,116 @@ func (h *Handlers) HandleRegisterScip(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
+// HandleUploadScip handles POST /api/repositories/{id}/scip/upload
+// Accepts a multipart form with the index in the "file" field, so CI jobs can push an index without shell access.
+// The part is streamed to disk, validated and atomically swapped in.
+func (h *Handlers) HandleUploadScip(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	if _, ok := h.Provider.GetRepo(uint32(id)); !ok {
+		http.Error(w, fmt.Sprintf("Repository %d not found", id), http.StatusNotFound)
+		return
+	}
+
+	extendDeadlines(w)
+	maxBytes := h.MaxScipUploadBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxScipUploadBytes
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "Expected multipart/form-data body", http.StatusBadRequest)
+		return
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			http.Error(w, "Missing form field 'file'", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			writeUploadError(w, err)
+			return
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+
+		err = h.Provider.StoreScipIndex(uint32(id), part)
+		part.Close()
+		if err != nil {
+			writeUploadError(w, err)
+			return
+		}
+		break
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// writeUploadError maps upload failures to status codes: oversized bodies get 413, invalid indexes 400
+func writeUploadError(w http.ResponseWriter, err error) {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		http.Error(w, fmt.Sprintf("Upload exceeds limit of %d bytes", maxErr.Limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if errors.Is(err, ErrInvalidScipIndex) {
+		http.Error(w, fmt.Sprintf("Invalid SCIP index: %v", err), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, fmt.Sprintf("Failed to upload SCIP: %v", err), http.StatusInternalServerError)
+}
+
+// HandleDownloadScip handles GET /api/repositories/{id}/scip
+// Streams the registered SCIP index; http.ServeContent provides Range and conditional request support
+func (h *Handlers) HandleDownloadScip(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	repoInfo, ok := h.Provider.GetRepo(uint32(id))
+	if !ok {
+		http.Error(w, fmt.Sprintf("Repository %d not found", id), http.StatusNotFound)
+		return
+	}
+
+	f, err := os.Open(repoInfo.ScipIndexPath())
+	if os.IsNotExist(err) {
+		http.Error(w, fmt.Sprintf("No SCIP index registered for repository %d", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to open SCIP index: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to stat SCIP index: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	extendDeadlines(w)
+	filename := fmt.Sprintf("%d-index.scip", id)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	http.ServeContent(w, r, filename, info.ModTime(), f)
+}
+
 // HandleRegisterZoekt handles POST /api/repositories/{id}/zoekt-file
 func (h *Handlers) HandleRegisterZoekt(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
@@ -0,0 +1,249 @@
+package repo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/scip/bindings/go/scip"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCheckAdminToken(t *testing.T) {
+	for _, tc := range []struct {
+		adminToken, header string
+		want               error
+	}{
+		{"", "", nil},
+		{"secret", "Bearer secret", nil},
+		{"secret", "", ErrMissingAdminToken},
+		{"secret", "Basic secret", ErrMissingAdminToken},
+		{"secret", "Bearer secre", ErrInvalidAdminToken},
+		{"secret", "Bearer secret2", ErrInvalidAdminToken},
+	} {
+		req := httptest.NewRequest("GET", "/api/admin/repositories", nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		if err := CheckAdminToken(req, tc.adminToken); !errors.Is(err, tc.want) {
+			t.Fatalf("CheckAdminToken(%q, %q) = %v, want %v", tc.header, tc.adminToken, err, tc.want)
+		}
+	}
+}
+
+func TestHandleDownloadScip(t *testing.T) {
+	p := newTestProvider(t, 4, "alpha")
+	h := &Handlers{Provider: p}
+
+	get := func(rangeHeader string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/repositories/4/scip", nil)
+		req.SetPathValue("id", "4")
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		rec := httptest.NewRecorder()
+		h.HandleDownloadScip(rec, req)
+		return rec
+	}
+
+	if rec := get(""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without index, got %d", rec.Code)
+	}
+
+	src := filepath.Join(t.TempDir(), "index.scip")
+	if err := os.WriteFile(src, []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.RegisterScipIndex(4, src); err != nil {
+		t.Fatalf("RegisterScipIndex: %v", err)
+	}
+
+	rec := get("")
+	if rec.Code != http.StatusOK || rec.Body.String() != "0123456789" {
+		t.Fatalf("unexpected response %d %q", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/octet-stream" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); cd != `attachment; filename="4-index.scip"` {
+		t.Fatalf("unexpected content disposition %q", cd)
+	}
+
+	rec = get("bytes=2-4")
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "234" {
+		t.Fatalf("expected range response, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+// newScipUpload 构造一个包含 file 字段的 multipart 请求
+func newScipUpload(t *testing.T, content []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", "index.scip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(content)
+	mw.Close()
+
+	req := httptest.NewRequest("POST", "/api/repositories/4/scip/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.SetPathValue("id", "4")
+	return req
+}
+
+func TestHandleUploadScip(t *testing.T) {
+	p := newTestProvider(t, 4, "alpha")
+	h := &Handlers{Provider: p}
+	repoInfo, _ := p.GetRepo(4)
+
+	valid, err := proto.Marshal(&scip.Index{
+		Metadata:  &scip.Metadata{ProjectRoot: "file:///src"},
+		Documents: []*scip.Document{{RelativePath: "main.go"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	h.HandleUploadScip(rec, newScipUpload(t, valid))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	stored, err := os.ReadFile(repoInfo.ScipIndexPath())
+	if err != nil || !bytes.Equal(stored, valid) {
+		t.Fatalf("expected uploaded index to be stored, err=%v", err)
+	}
+
+	// 无效内容被拒绝，且不覆盖已有索引
+	rec = httptest.NewRecorder()
+	h.HandleUploadScip(rec, newScipUpload(t, []byte("not a scip index")))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid index, got %d", rec.Code)
+	}
+	if stored, _ := os.ReadFile(repoInfo.ScipIndexPath()); !bytes.Equal(stored, valid) {
+		t.Fatalf("invalid upload must not replace the existing index")
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(repoInfo.ScipIndexPath()), "index.scip.upload-*")); len(leftovers) != 0 {
+		t.Fatalf("temporary files left behind: %v", leftovers)
+	}
+
+	// 超过大小上限返回 413
+	h.MaxScipUploadBytes = 16
+	rec = httptest.NewRecorder()
+	h.HandleUploadScip(rec, newScipUpload(t, valid))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d", rec.Code)
+	}
+}
+
+func TestHandleReload(t *testing.T) {
+	p := newTestProvider(t, 1, "first")
+	other, err := NewProvider(p.DataDir)
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	t.Cleanup(func() { other.Close() })
+	if _, err := other.AddRepository(2, "second", t.TempDir(), false); err != nil {
+		t.Fatalf("AddRepository: %v", err)
+	}
+
+	reloads := 0
+	h := &Handlers{Provider: p, Reload: func() error {
+		reloads++
+		return p.Reload()
+	}}
+	rec := httptest.NewRecorder()
+	h.HandleReload(rec, httptest.NewRequest("POST", "/api/admin/reload", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %q", rec.Code, rec.Body.String())
+	}
+	var resp struct{ Before, Count int }
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Before != 1 || resp.Count != 2 || reloads != 1 {
+		t.Fatalf("got %+v after %d reloads, want before 1, count 2", resp, reloads)
+	}
+	if _, ok := p.GetRepo(2); !ok {
+		t.Fatal("repository 2 should be visible after reload")
+	}
+}
+
+func TestHandleReindexAll(t *testing.T) {
+	p := newTestProvider(t, 1, "plain")
+	h := &Handlers{Provider: p}
+	post := func() int {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.HandleReindexAll(rec, httptest.NewRequest("POST", "/api/repositories/reindex-all", nil))
+		return rec.Code
+	}
+
+	// 上一次全量重建尚未结束
+	p.reindexing.Store(true)
+	if code := post(); code != http.StatusConflict {
+		t.Fatalf("while running: status = %d, want 409", code)
+	}
+	p.reindexing.Store(false)
+
+	// 非 Git 仓库被跳过，重建很快结束并释放标记
+	waitIdle := func() {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for p.reindexing.Load() {
+			if time.Now().After(deadline) {
+				t.Fatal("reindex-all did not finish")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	for range 2 {
+		if code := post(); code != http.StatusAccepted {
+			t.Fatalf("status = %d, want 202", code)
+		}
+		waitIdle()
+	}
+}
+
+func TestValidateScipFile(t *testing.T) {
+	write := func(data []byte) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "index.scip")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	marshal := func(index *scip.Index) []byte {
+		t.Helper()
+		data, err := proto.Marshal(index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	doc := &scip.Document{RelativePath: "main.go", Occurrences: []*scip.Occurrence{{Range: []int32{0, 0, 4}, Symbol: "local 1"}}}
+	valid := marshal(&scip.Index{Metadata: &scip.Metadata{ProjectRoot: "file:///src"}, Documents: []*scip.Document{doc}})
+	if err := validateScipFile(write(valid)); err != nil {
+		t.Fatalf("valid index: %v", err)
+	}
+	for name, data := range map[string][]byte{
+		"no metadata": marshal(&scip.Index{Documents: []*scip.Document{doc}}),
+		"garbage":     []byte("not a scip index"),
+		"truncated":   valid[:len(valid)-3],
+	} {
+		if err := validateScipFile(write(data)); !errors.Is(err, ErrInvalidScipIndex) {
+			t.Errorf("%s: got %v, want ErrInvalidScipIndex", name, err)
+		}
+	}
+}
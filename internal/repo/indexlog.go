@@ -0,0 +1,84 @@
+package repo
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"code-browser/internal/fileperm"
+)
+
+const indexLogSubDir = "logs" // <DataPath>/logs/，存放索引输出
+
+// maxIndexLogs 每个仓库保留的索引日志数量，超出时删除最旧的
+const maxIndexLogs = 10
+
+// ErrIndexLogNotFound 仓库还没有索引日志
+var ErrIndexLogNotFound = fmt.Errorf("索引日志不存在")
+
+// createIndexLog 创建 <DataPath>/logs/index-<timestamp>.log 并清理过旧的日志
+func createIndexLog(repoInfo Repository, modes fileperm.Modes) (*os.File, error) {
+	dir := filepath.Join(repoInfo.DataPath, indexLogSubDir)
+	if err := modes.MkdirAll(dir); err != nil {
+		return nil, fmt.Errorf("创建日志目录 '%s' 失败: %w", dir, err)
+	}
+	// 时间戳精确到毫秒，按文件名排序即按时间排序
+	name := fmt.Sprintf("index-%s.log", time.Now().Format("20060102-150405.000"))
+	f, err := modes.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_WRONLY|os.O_TRUNC)
+	if err != nil {
+		return nil, fmt.Errorf("创建索引日志失败: %w", err)
+	}
+	pruneIndexLogs(dir)
+	return f, nil
+}
+
+// listIndexLogs 返回目录中的索引日志，按时间从旧到新排序
+func listIndexLogs(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var logs []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), "index-") && strings.HasSuffix(e.Name(), ".log") {
+			logs = append(logs, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Strings(logs)
+	return logs
+}
+
+// pruneIndexLogs 只保留最新的 maxIndexLogs 个日志
+func pruneIndexLogs(dir string) {
+	logs := listIndexLogs(dir)
+	if len(logs) <= maxIndexLogs {
+		return
+	}
+	for _, old := range logs[:len(logs)-maxIndexLogs] {
+		if err := os.Remove(old); err != nil {
+			log.Printf("警告: 无法删除旧索引日志 '%s': %v", old, err)
+		}
+	}
+}
+
+// LatestIndexLog 返回仓库最近一次索引日志的路径
+func (p *Provider) LatestIndexLog(id uint32) (string, error) {
+	repoInfo, ok := p.GetRepo(id)
+	if !ok {
+		return "", fmt.Errorf("仓库 ID '%d' 未找到", id)
+	}
+	logs := listIndexLogs(filepath.Join(repoInfo.DataPath, indexLogSubDir))
+	if len(logs) == 0 {
+		return "", ErrIndexLogNotFound
+	}
+	return logs[len(logs)-1], nil
+}
+
+// JobOutput 返回任务在内存中保留的输出尾部 (日志文件已被清理时使用)
+func (p *Provider) JobOutput(jobID string) ([]byte, bool) {
+	return p.jobs.Output(jobID)
+}
@@ -0,0 +1,104 @@
+package repo
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// IndexStats 从 zoekt-git-index 输出中解析出的索引统计
+// 输出格式无法识别时 Recognized 为 false，只有 DurationMs 有效
+type IndexStats struct {
+	Recognized bool     `json:"recognized"`
+	Files      int      `json:"files"`      // 写入分片的文件数 (各分片之和)
+	IndexBytes int64    `json:"indexBytes"` // 分片文件大小之和
+	Shards     []string `json:"shards"`     // 写入的分片文件名
+	DurationMs int64    `json:"durationMs"` // 索引程序的运行时间
+}
+
+// shardFinishedRe 匹配 zoekt builder 每写完一个分片输出的日志，例如:
+//
+//	2024/01/02 15:04:05 finished shard /data/zoekt-index/0000000001_repo_v16.00000.zoekt: 1234567 index bytes (overhead 2.9), 321 files processed
+//
+// 旧版本没有 "shard" 和 files processed 部分
+var shardFinishedRe = regexp.MustCompile(`finished(?: shard)? (\S+\.zoekt): (\d+) index bytes \(overhead [\d.]+\)(?:, (\d+) files processed)?`)
+
+// indexOutputParser 以 io.Writer 的形式逐行解析索引程序输出，不保留完整输出
+type indexOutputParser struct {
+	mu      sync.Mutex
+	partial []byte // 尚未遇到换行的最后一行
+	stats   IndexStats
+}
+
+func (p *indexOutputParser) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.partial = append(p.partial, b...)
+	for {
+		i := bytes.IndexAny(p.partial, "\r\n")
+		if i < 0 {
+			break
+		}
+		p.parseLine(p.partial[:i])
+		p.partial = p.partial[i+1:]
+	}
+	// 没有换行的超长输出只保留尾部，统计行不会这么长
+	if len(p.partial) > 64<<10 {
+		p.partial = append([]byte(nil), p.partial[len(p.partial)-4096:]...)
+	}
+	return len(b), nil
+}
+
+// parseLine 解析一行输出，累加分片统计
+func (p *indexOutputParser) parseLine(line []byte) {
+	m := shardFinishedRe.FindSubmatch(line)
+	if m == nil {
+		return
+	}
+	p.stats.Recognized = true
+	p.stats.Shards = append(p.stats.Shards, filepath.Base(string(m[1])))
+	if n, err := strconv.ParseInt(string(m[2]), 10, 64); err == nil {
+		p.stats.IndexBytes += n
+	}
+	if len(m[3]) > 0 {
+		if n, err := strconv.Atoi(string(m[3])); err == nil {
+			p.stats.Files += n
+		}
+	}
+}
+
+// Result 处理剩余的不完整行并返回统计结果
+func (p *indexOutputParser) Result(duration time.Duration) *IndexStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.partial) > 0 {
+		p.parseLine(p.partial)
+		p.partial = nil
+	}
+	stats := p.stats
+	if stats.Shards == nil {
+		stats.Shards = []string{}
+	}
+	stats.DurationMs = duration.Milliseconds()
+	return &stats
+}
+
+// ParseIndexOutput 解析完整的索引程序输出 (如日志文件内容)
+func ParseIndexOutput(output []byte, duration time.Duration) *IndexStats {
+	var p indexOutputParser
+	p.Write(output)
+	return p.Result(duration)
+}
+
+// String 返回适合日志和 CLI 输出的摘要，如 "索引了 12345 个文件 (3 个分片, 4.2 MiB)，耗时 34s"
+func (s *IndexStats) String() string {
+	d := (time.Duration(s.DurationMs) * time.Millisecond).Round(time.Millisecond)
+	if !s.Recognized {
+		return fmt.Sprintf("索引耗时 %s (无法识别索引程序的输出)", d)
+	}
+	return fmt.Sprintf("索引了 %d 个文件 (%d 个分片, %.1f MiB)，耗时 %s", s.Files, len(s.Shards), float64(s.IndexBytes)/(1<<20), d)
+}
@@ -0,0 +1,75 @@
+package repo
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+const sampleIndexOutput = `$ /usr/local/bin/zoekt-git-index -index /data/zoekt-index /src/repo
+2024/05/01 10:00:01 attempting to index 12000 total files
+2024/05/01 10:00:20 finished shard /data/zoekt-index/0000000001_repo_v16.00000.zoekt: 104857600 index bytes (overhead 2.9), 10000 files processed 
+2024/05/01 10:00:30 finished shard /data/zoekt-index/0000000001_repo_v16.00001.zoekt: 5242880 index bytes (overhead 3.1), 2345 files processed 
+`
+
+func TestParseIndexOutput(t *testing.T) {
+	stats := ParseIndexOutput([]byte(sampleIndexOutput), 34*time.Second)
+	if !stats.Recognized || stats.Files != 12345 || stats.IndexBytes != 110100480 || stats.DurationMs != 34000 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	want := []string{"0000000001_repo_v16.00000.zoekt", "0000000001_repo_v16.00001.zoekt"}
+	if !slices.Equal(stats.Shards, want) {
+		t.Fatalf("shards = %v, want %v", stats.Shards, want)
+	}
+	if got := stats.String(); got != "索引了 12345 个文件 (2 个分片, 105.0 MiB)，耗时 34s" {
+		t.Fatalf("summary = %q", got)
+	}
+
+	// 输出被任意切分写入时结果相同，最后一行没有换行也能解析
+	var p indexOutputParser
+	data := []byte(sampleIndexOutput[:len(sampleIndexOutput)-1])
+	for len(data) > 0 {
+		n := min(7, len(data))
+		p.Write(data[:n])
+		data = data[n:]
+	}
+	if split := p.Result(0); split.Files != 12345 || len(split.Shards) != 2 {
+		t.Fatalf("split writes: %+v", split)
+	}
+
+	// 旧版本的格式没有文件数
+	old := ParseIndexOutput([]byte("2019/01/01 00:00:00 finished /idx/repo_v16.00000.zoekt: 2048 index bytes (overhead 3.0)\n"), time.Second)
+	if !old.Recognized || old.Files != 0 || old.IndexBytes != 2048 || len(old.Shards) != 1 {
+		t.Fatalf("old format: %+v", old)
+	}
+
+	// 无法识别的输出只保留耗时
+	unknown := ParseIndexOutput([]byte("something else entirely\n"), 1500*time.Millisecond)
+	if unknown.Recognized || unknown.Files != 0 || unknown.Shards == nil || unknown.DurationMs != 1500 {
+		t.Fatalf("unknown format: %+v", unknown)
+	}
+}
+
+func TestIndexRepositoryZoekt_RecordsStats(t *testing.T) {
+	withFakeIndexer(t, "echo '2024/05/01 10:00:20 finished shard /idx/0000000004_repo_v16.00000.zoekt: 4096 index bytes (overhead 2.9), 7 files processed' >&2\n")
+
+	p := newEmptyProvider(t)
+	addGitRepo(t, p, 4, "repo")
+
+	plan, err := p.IndexRepositoryZoekt(4, false)
+	if err != nil {
+		t.Fatalf("IndexRepositoryZoekt: %v", err)
+	}
+	if plan.Stats == nil || plan.Stats.Files != 7 || plan.Stats.IndexBytes != 4096 {
+		t.Fatalf("plan stats: %+v", plan.Stats)
+	}
+
+	job, err := p.StartIndexJob(4)
+	if err != nil {
+		t.Fatalf("StartIndexJob: %v", err)
+	}
+	final, _ := p.WaitJob(job.ID)
+	if final.Status != JobSucceeded || final.Stats == nil || final.Stats.Files != 7 {
+		t.Fatalf("job stats: %+v", final)
+	}
+}
@@ -0,0 +1,24 @@
+package repo
+
+// RepoProvider 是 core、search、analysis 等服务依赖的仓库查询接口。
+// 生产环境使用 *Provider (SQLite)，测试可使用 repotest 包中的内存实现，无需落盘。
+type RepoProvider interface {
+	// GetRepo 按 ID 查找仓库 (包括已归档和已停用的仓库)
+	GetRepo(id uint32) (Repository, bool)
+	// GetAll 返回所有未归档的仓库
+	GetAll() []Repository
+	// GetRepoIDByString 解析字符串 ID，仓库不存在时返回 0
+	GetRepoIDByString(idStr string) uint32
+	// Count 返回仓库数量
+	Count() int
+	// FindRepos 按名称查找未归档的仓库 (忽略大小写和变音符号)
+	FindRepos(query string) []Repository
+	// GetIndexStatus 返回仓库的 Zoekt / SCIP 索引状态
+	GetIndexStatus(id uint32) (IndexStatus, error)
+	// OnRepoChanged 注册仓库重新索引、删除等变更时的回调
+	OnRepoChanged(fn func(id uint32))
+	// IndexGeneration 返回仓库的索引代数，每次变更 (同 OnRepoChanged) 后递增，可作为缓存键的一部分
+	IndexGeneration(id uint32) uint64
+}
+
+var _ RepoProvider = (*Provider)(nil)
@@ -0,0 +1,287 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// JobStatus 后台任务的状态
+type JobStatus string
+
+const (
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+var (
+	// ErrJobNotFound 任务不存在 (或已被清理)
+	ErrJobNotFound = errors.New("任务不存在")
+	// ErrJobNotRunning 任务已结束，无法取消
+	ErrJobNotRunning = errors.New("任务已结束")
+)
+
+// JobKindIndex Zoekt 索引任务
+const JobKindIndex = "index"
+
+// maxFinishedJobs 内存中保留的已结束任务数量上限，超出后丢弃最早结束的任务
+const maxFinishedJobs = 100
+
+// maxJobOutputBytes 每个任务在内存中保留的输出尾部字节数 (完整输出见 LogFile)
+const maxJobOutputBytes = 64 << 10
+
+// Job 记录一次后台任务 (目前只有索引) 的状态，仅保存在内存中，服务重启后丢失
+type Job struct {
+	ID         string     `json:"id"`
+	Kind       string     `json:"kind"`
+	RepoID     uint32     `json:"repoId"`
+	Status     JobStatus  `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	LogFile    string     `json:"-"` // 本次任务输出的日志文件 (服务端路径，不对外暴露)
+	// Stats 索引任务成功后的统计 (见 IndexStats)，其他任务为空
+	Stats *IndexStats `json:"stats,omitempty"`
+
+	output    []byte             // 输出尾部，最多 maxJobOutputBytes
+	written   int64              // 累计写入的输出字节数 (含已丢弃的部分)，用于增量读取
+	cancel    context.CancelFunc // 取消任务的 context
+	cancelled bool               // 已请求取消
+}
+
+// JobRecorder 供任务函数记录输出和日志文件位置，实现 io.Writer
+type JobRecorder struct {
+	m  *JobManager
+	id string
+}
+
+var _ io.Writer = (*JobRecorder)(nil)
+
+// Write 追加任务输出，只保留最后 maxJobOutputBytes 字节
+func (r *JobRecorder) Write(b []byte) (int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	if job, ok := r.m.jobs[r.id]; ok {
+		job.written += int64(len(b))
+		job.output = append(job.output, b...)
+		if over := len(job.output) - maxJobOutputBytes; over > 0 {
+			job.output = append([]byte(nil), job.output[over:]...)
+		}
+	}
+	return len(b), nil
+}
+
+// SetLogFile 记录任务输出对应的日志文件
+func (r *JobRecorder) SetLogFile(path string) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	if job, ok := r.m.jobs[r.id]; ok {
+		job.LogFile = path
+	}
+}
+
+// SetIndexStats 记录索引任务的统计结果
+func (r *JobRecorder) SetIndexStats(stats *IndexStats) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	if job, ok := r.m.jobs[r.id]; ok {
+		job.Stats = stats
+	}
+}
+
+// ErrJobInProgress 同一仓库已有同类任务在运行
+type ErrJobInProgress struct {
+	Job Job
+}
+
+func (e *ErrJobInProgress) Error() string {
+	return fmt.Sprintf("仓库 '%d' 已有 %s 任务在运行 (job %s)", e.Job.RepoID, e.Job.Kind, e.Job.ID)
+}
+
+// JobManager 跟踪后台任务，并保证同一仓库同类任务不会并发执行
+type JobManager struct {
+	mu     sync.Mutex
+	jobs   map[string]*Job
+	done   map[string]chan struct{} // 任务结束时关闭，用于 Wait
+	nextID uint64
+}
+
+// NewJobManager 创建空的任务管理器
+func NewJobManager() *JobManager {
+	return &JobManager{jobs: make(map[string]*Job), done: make(map[string]chan struct{})}
+}
+
+// Start 登记一个新任务并在后台 goroutine 中执行 fn，fn 返回的错误记录到任务中。
+// fn 应在 ctx 取消时尽快返回，并可通过 JobRecorder 记录输出。
+// 同一仓库已有同类任务在运行时返回 *ErrJobInProgress。返回值为任务快照。
+func (m *JobManager) Start(kind string, repoID uint32, fn func(ctx context.Context, rec *JobRecorder) error) (Job, error) {
+	m.mu.Lock()
+	for _, j := range m.jobs {
+		if j.Kind == kind && j.RepoID == repoID && j.Status == JobRunning {
+			running := j.snapshot()
+			m.mu.Unlock()
+			return Job{}, &ErrJobInProgress{Job: running}
+		}
+	}
+	m.nextID++
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &Job{
+		ID:        fmt.Sprintf("%s-%d-%d", kind, repoID, m.nextID),
+		Kind:      kind,
+		RepoID:    repoID,
+		Status:    JobRunning,
+		StartedAt: time.Now(),
+		cancel:    cancel,
+	}
+	m.jobs[job.ID] = job
+	m.done[job.ID] = make(chan struct{})
+	snapshot := job.snapshot()
+	m.mu.Unlock()
+
+	go func() {
+		err := fn(ctx, &JobRecorder{m: m, id: job.ID})
+		cancel()
+		m.finish(job.ID, err)
+	}()
+	return snapshot, nil
+}
+
+// finish 标记任务结束并清理过旧的记录
+func (m *JobManager) finish(id string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	job.FinishedAt = &now
+	job.Status = JobSucceeded
+	switch {
+	case job.cancelled:
+		// 取消后任务函数通常返回 "signal: killed" 之类的错误，统一记为已取消
+		job.Status = JobCancelled
+	case err != nil:
+		job.Status = JobFailed
+		job.Error = err.Error()
+	}
+	job.cancel = nil
+	close(m.done[id])
+	m.pruneLocked()
+}
+
+// pruneLocked 只保留最近结束的 maxFinishedJobs 个任务，调用方需持有 m.mu
+func (m *JobManager) pruneLocked() {
+	var finished []*Job
+	for _, j := range m.jobs {
+		if j.FinishedAt != nil {
+			finished = append(finished, j)
+		}
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+	sort.Slice(finished, func(a, b int) bool { return finished[a].FinishedAt.Before(*finished[b].FinishedAt) })
+	for _, j := range finished[:len(finished)-maxFinishedJobs] {
+		delete(m.jobs, j.ID)
+		delete(m.done, j.ID)
+	}
+}
+
+// Get 返回任务快照
+func (m *JobManager) Get(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return job.snapshot(), true
+}
+
+// Output 返回任务输出的尾部
+func (m *JobManager) Output(id string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	return append([]byte(nil), job.output...), true
+}
+
+// OutputSince 返回从累计偏移 offset 开始的输出和下一次读取的偏移，用于流式读取进度。
+// offset 之前已被丢弃的部分 (超出 maxJobOutputBytes) 会被跳过，从保留的尾部开始返回
+func (m *JobManager) OutputSince(id string, offset int64) ([]byte, int64, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, offset, false
+	}
+	start := job.written - int64(len(job.output))
+	if offset < start {
+		offset = start
+	}
+	if offset >= job.written {
+		return nil, job.written, true
+	}
+	return append([]byte(nil), job.output[offset-start:]...), job.written, true
+}
+
+// snapshot 返回不含输出缓冲的任务副本，调用方需持有 m.mu
+func (j *Job) snapshot() Job {
+	c := *j
+	c.output = nil
+	c.cancel = nil
+	return c
+}
+
+// Cancel 请求取消正在运行的任务，任务函数返回后状态变为 cancelled
+func (m *JobManager) Cancel(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+	if job.Status != JobRunning || job.cancel == nil {
+		return ErrJobNotRunning
+	}
+	job.cancelled = true
+	job.cancel()
+	return nil
+}
+
+// Wait 阻塞直到任务结束并返回最终快照，任务不存在时返回 false
+func (m *JobManager) Wait(id string) (Job, bool) {
+	m.mu.Lock()
+	done, ok := m.done[id]
+	m.mu.Unlock()
+	if !ok {
+		return Job{}, false
+	}
+	<-done
+	return m.Get(id)
+}
+
+// List 返回所有任务快照，按开始时间倒序；repoID 非 0 时只返回该仓库的任务
+func (m *JobManager) List(repoID uint32) []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := make([]Job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		if repoID != 0 && j.RepoID != repoID {
+			continue
+		}
+		jobs = append(jobs, j.snapshot())
+	}
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].StartedAt.After(jobs[b].StartedAt) })
+	return jobs
+}
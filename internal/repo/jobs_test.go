@@ -0,0 +1,95 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestJobManager_LifecycleAndGuard(t *testing.T) {
+	m := NewJobManager()
+	release := make(chan struct{})
+
+	job, err := m.Start(JobKindIndex, 1, func(context.Context, *JobRecorder) error {
+		<-release
+		return errors.New("boom")
+	})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if job.Status != JobRunning {
+		t.Fatalf("expected running, got %s", job.Status)
+	}
+
+	// 同一仓库的同类任务不能并发
+	var inProgress *ErrJobInProgress
+	if _, err := m.Start(JobKindIndex, 1, func(context.Context, *JobRecorder) error { return nil }); !errors.As(err, &inProgress) || inProgress.Job.ID != job.ID {
+		t.Fatalf("expected ErrJobInProgress for %s, got %v", job.ID, err)
+	}
+	// 其他仓库不受影响
+	other, err := m.Start(JobKindIndex, 2, func(context.Context, *JobRecorder) error { return nil })
+	if err != nil {
+		t.Fatalf("Start other: %v", err)
+	}
+
+	close(release)
+	final, ok := m.Wait(job.ID)
+	if !ok || final.Status != JobFailed || final.Error != "boom" || final.FinishedAt == nil {
+		t.Fatalf("unexpected final job: %+v", final)
+	}
+	if final, _ := m.Wait(other.ID); final.Status != JobSucceeded {
+		t.Fatalf("expected other job to succeed, got %+v", final)
+	}
+	if jobs := m.List(1); len(jobs) != 1 || jobs[0].ID != job.ID {
+		t.Fatalf("unexpected jobs for repo 1: %+v", jobs)
+	}
+
+	// 任务结束后可以再次启动
+	if _, err := m.Start(JobKindIndex, 1, func(context.Context, *JobRecorder) error { return nil }); err != nil {
+		t.Fatalf("restart after finish: %v", err)
+	}
+}
+
+func TestAddRepository_AutoIndexSkipsNonGit(t *testing.T) {
+	p, err := NewProvider(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	defer p.Close()
+
+	job, err := p.AddRepository(5, "plain", t.TempDir(), true)
+	if err != nil {
+		t.Fatalf("AddRepository: %v", err)
+	}
+	if job != nil {
+		t.Fatalf("expected no index job for non-git source, got %+v", job)
+	}
+	if _, ok := p.GetRepo(5); !ok {
+		t.Fatal("repository should still be added")
+	}
+}
+
+func TestJobManager_Cancel(t *testing.T) {
+	m := NewJobManager()
+	job, err := m.Start(JobKindIndex, 1, func(ctx context.Context, _ *JobRecorder) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	if err := m.Cancel(job.ID); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	final, _ := m.Wait(job.ID)
+	if final.Status != JobCancelled || final.Error != "" {
+		t.Fatalf("expected cancelled job, got %+v", final)
+	}
+	if err := m.Cancel(job.ID); !errors.Is(err, ErrJobNotRunning) {
+		t.Fatalf("expected ErrJobNotRunning, got %v", err)
+	}
+	if err := m.Cancel("missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Fatalf("expected ErrJobNotFound, got %v", err)
+	}
+}
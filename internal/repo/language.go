@@ -0,0 +1,168 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"code-browser/internal/codetree"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// primaryLanguageWalkTimeout 统计主要语言时遍历的时间上限，超出后按已统计的部分判断 (文件数等其余限制使用 codetree 的默认值)
+const primaryLanguageWalkTimeout = 5 * time.Second
+
+// LanguageDefaults 与主要语言对应的默认设置
+type LanguageDefaults struct {
+	Language string `json:"language"`
+	// ScipIndexer 建议用于生成 SCIP 索引的程序，没有对应的索引程序时为空
+	ScipIndexer string `json:"scipIndexer,omitempty"`
+	// LangFilter 搜索查询中 lang: 过滤条件的取值
+	LangFilter string `json:"langFilter"`
+}
+
+// languageDefaults 语言对应的 SCIP 索引程序和 lang: 过滤值 (Zoekt 的语言名)；未列出的语言没有索引程序，过滤值为语言名本身
+var languageDefaults = map[string]LanguageDefaults{
+	"go":         {ScipIndexer: "scip-go", LangFilter: "go"},
+	"python":     {ScipIndexer: "scip-python", LangFilter: "python"},
+	"javascript": {ScipIndexer: "scip-typescript", LangFilter: "javascript"},
+	"typescript": {ScipIndexer: "scip-typescript", LangFilter: "typescript"},
+	"java":       {ScipIndexer: "scip-java", LangFilter: "java"},
+	"kotlin":     {ScipIndexer: "scip-java", LangFilter: "kotlin"},
+	"scala":      {ScipIndexer: "scip-java", LangFilter: "scala"},
+	"c":          {ScipIndexer: "scip-clang", LangFilter: "c"},
+	"cpp":        {ScipIndexer: "scip-clang", LangFilter: "c++"},
+	"csharp":     {ScipIndexer: "scip-dotnet", LangFilter: "c#"},
+	"rust":       {ScipIndexer: "rust-analyzer", LangFilter: "rust"},
+	"ruby":       {ScipIndexer: "scip-ruby", LangFilter: "ruby"},
+	"bash":       {LangFilter: "shell"},
+}
+
+// DefaultsForLanguage 返回语言对应的默认设置，language 为空时返回 nil
+func DefaultsForLanguage(language string) *LanguageDefaults {
+	if language == "" {
+		return nil
+	}
+	d, ok := languageDefaults[language]
+	if !ok {
+		d.LangFilter = language
+	}
+	d.Language = language
+	return &d
+}
+
+// primaryLanguageEntry 某个 HEAD 提交对应的统计结果
+type primaryLanguageEntry struct {
+	head     plumbing.Hash
+	language string
+}
+
+// primaryLanguageCache 按仓库缓存 DetectPrimaryLanguage 的结果；HEAD 变化后自动重新统计
+type primaryLanguageCache struct {
+	mu      sync.Mutex
+	entries map[uint32]primaryLanguageEntry
+}
+
+func (c *primaryLanguageCache) get(id uint32, head plumbing.Hash) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[id]
+	if !ok || e.head != head {
+		return "", false
+	}
+	return e.language, true
+}
+
+func (c *primaryLanguageCache) set(id uint32, head plumbing.Hash, language string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[uint32]primaryLanguageEntry)
+	}
+	c.entries[id] = primaryLanguageEntry{head: head, language: language}
+}
+
+// DetectPrimaryLanguage 统计 HEAD 中各语言源码文件的字节数，返回字节数最多的语言 (名称同 codetree.DetectLanguage)。
+// 文档、配置和样式文件以及 IgnoredPaths 中的依赖和生成代码不参与统计。
+// 空仓库或没有可识别的源码文件时返回空字符串。遍历受 codetree.Walk 的限制 (时间上限为 primaryLanguageWalkTimeout)，
+// 超出后按已统计的部分判断。结果按仓库和 HEAD 缓存；因超时或 ctx 结束而截断的结果不缓存
+func (p *Provider) DetectPrimaryLanguage(ctx context.Context, id uint32) (string, error) {
+	repoInfo, ok := p.GetRepo(id)
+	if !ok {
+		return "", fmt.Errorf("仓库 ID '%d' 未找到", id)
+	}
+	r, err := git.PlainOpen(repoInfo.SourcePath)
+	if err != nil {
+		return "", fmt.Errorf("打开 Git 仓库失败: %w", err)
+	}
+	head, err := r.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("获取 HEAD 引用失败: %w", err)
+	}
+	if language, ok := p.primaryLang.get(id, head.Hash()); ok {
+		return language, nil
+	}
+
+	language, res, err := detectPrimaryLanguage(ctx, r, head.Hash(), p.IgnoredPaths)
+	if err != nil {
+		return "", fmt.Errorf("统计仓库 '%d' 的语言失败: %w", id, err)
+	}
+	if res.Reason != codetree.LimitTimeout {
+		p.primaryLang.set(id, head.Hash(), language)
+	}
+	return language, nil
+}
+
+// detectPrimaryLanguage 实现 DetectPrimaryLanguage 的统计，同时返回遍历的统计信息
+func detectPrimaryLanguage(ctx context.Context, r *git.Repository, head plumbing.Hash, ignored []string) (string, codetree.Result, error) {
+	commit, err := r.CommitObject(head)
+	if err != nil {
+		return "", codetree.Result{}, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return "", codetree.Result{}, err
+	}
+
+	bytesByLang := make(map[string]int64)
+	res, err := codetree.Walk(ctx, tree, codetree.Limits{Timeout: primaryLanguageWalkTimeout}, func(name string, entry object.TreeEntry) (int64, error) {
+		// 符号链接的内容是目标路径，不计入
+		if entry.Mode == filemode.Symlink {
+			return 0, nil
+		}
+		lang := codetree.DetectLanguage(name)
+		if !codetree.IsCodeLanguage(lang) || codetree.MatchAnyPattern(ignored, name) {
+			return 0, nil
+		}
+		size, err := r.Storer.EncodedObjectSize(entry.Hash)
+		if err != nil {
+			return 0, err
+		}
+		bytesByLang[lang] += size
+		return size, nil
+	})
+	if err != nil {
+		return "", res, err
+	}
+	return dominantLanguage(bytesByLang), res, nil
+}
+
+// dominantLanguage 返回字节数最多的语言，字节数相同时取名称较小的，保证结果稳定
+func dominantLanguage(bytesByLang map[string]int64) string {
+	best := ""
+	for lang, n := range bytesByLang {
+		if best == "" || n > bytesByLang[best] || (n == bytesByLang[best] && lang < best) {
+			best = lang
+		}
+	}
+	return best
+}
@@ -1,7 +1,10 @@
 package repo
 
 import (
+	"bufio"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -12,10 +15,15 @@ import (
 	"strconv" // Needed for converting uint32 to string for DataPath
 	"strings"
 	"sync" // Mutex for safe concurrent updates to cache
+	"sync/atomic"
 	"time"
 
-	"github.com/go-git/go-git/v5"   // ★ 新增: go-git API
+	"code-browser/internal/fileperm"
+
+	"github.com/go-git/go-git/v5" // ★ 新增: go-git API
+	gitconfig "github.com/go-git/go-git/v5/config"
 	_ "github.com/mattn/go-sqlite3" // Import the SQLite driver
+	"github.com/sourcegraph/scip/bindings/go/scip"
 )
 
 // Repository 定义了单个代码仓库的配置结构 (与数据库表对应)
@@ -27,6 +35,28 @@ type Repository struct {
 	DataPath   string    `json:"-"`    // 该仓库专属数据目录的路径
 	CreatedAt  time.Time `json:"-"`    // 创建时间
 	UpdatedAt  time.Time `json:"-"`    // 更新时间
+	Archived   bool      `json:"-"`    // 已归档: 从列表和搜索中隐藏，但保留数据
+	Disabled   bool      `json:"-"`    // 已停用 (enabled 列为 0): 仍然列出，但浏览和搜索返回 ErrRepoDisabled
+	Tags       []string  `json:"tags"` // 标签 (小写，按字母排序)，存储在 repo_tags 表
+
+	DefaultBranch string `json:"defaultBranch"` // 浏览时默认显示的分支，添加/索引时从 HEAD 检测；未知时为空
+	IndexedCommit string `json:"-"`             // 最近一次成功索引时的 HEAD 提交，从未索引时为空
+}
+
+// ErrRepoDisabled 仓库已被管理员停用，浏览、搜索和代码分析都会拒绝访问 (HTTP 403)
+var ErrRepoDisabled = errors.New("仓库已停用")
+
+// CheckEnabled 仓库已停用时返回包装了 ErrRepoDisabled 的错误；只读取内存中的字段，可在热路径上调用
+func (r Repository) CheckEnabled() error {
+	if r.Disabled {
+		return fmt.Errorf("%w: ID '%d'", ErrRepoDisabled, r.RepoID)
+	}
+	return nil
+}
+
+// ScipIndexPath 返回仓库 SCIP 索引文件的路径: <DataPath>/scip/index.scip
+func (r Repository) ScipIndexPath() string {
+	return filepath.Join(r.DataPath, "scip", "index.scip")
 }
 
 // Provider 是仓库管理服务，负责加载和提供仓库信息
@@ -36,26 +66,97 @@ type Provider struct {
 	repositories []Repository          // 按数据库顺序排列的仓库列表 (内存缓存)
 	repoMap      map[uint32]Repository // 用于通过 uint32 RepoID 快速查找仓库 (内存缓存)
 	mu           sync.RWMutex          // 用于保护内存缓存的读写锁
+	changeHooks  []func(id uint32)     // 仓库内容/索引变化时的回调 (用于缓存失效)
+	hooksMu      sync.RWMutex          // 保护 changeHooks 和 generations
+	generations  map[uint32]uint64     // 仓库的索引代数 (见 IndexGeneration)
+	jobs         *JobManager           // 后台索引任务
+	fileAges     fileAgesCache         // FileAges 结果缓存 (按 HEAD 失效)
+	primaryLang  primaryLanguageCache  // DetectPrimaryLanguage 结果缓存 (按 HEAD 失效)
+	reindexing   atomic.Bool           // StartReindexAll 启动的全量重建正在运行
+	readOnly     bool                  // 只读模式 (见 ProviderOptions.ReadOnly)
+	stopRefresh  context.CancelFunc    // 停止只读模式的定期刷新
+	refreshMu    sync.Mutex            // 串行化 refresh (定期刷新、SIGHUP 和 reload 接口可能同时触发)
+	closeOnce    sync.Once             // Close 只关闭一次数据库
+	modes        fileperm.Modes        // 新建目录和文件的权限 (见 ProviderOptions.Modes)
+	closeErr     error
+
+	// NoGitConfig 为 true 时索引不再把 zoekt.name/zoekt.repoid 写入源仓库的 .git/config，
+	// 而是通过 -name/-repoid 参数传给 zoekt-git-index (需要索引程序支持这两个参数)。
+	// 两者的值都由仓库 ID 和名称确定，数据库中的仓库记录本身就是这份映射。
+	NoGitConfig bool
+
+	// IndexerPath zoekt-git-index 的路径或命令名，为空时从 PATH 查找 zoekt-git-index
+	IndexerPath string
+	// IndexerArgs 追加到 zoekt-git-index 固定参数之后的额外参数 (如 -parallelism 4, -file_limit)
+	IndexerArgs []string
+	// IgnoredPaths 依赖和生成代码的路径模式 (服务端的 -search-ignore 列表)，主要语言检测不计入
+	IgnoredPaths []string
+}
+
+// defaultIndexer 未配置 IndexerPath 时使用的索引程序
+const defaultIndexer = "zoekt-git-index"
+
+// ResolveIndexer 校验配置的索引程序并返回其绝对路径。
+// 纯命令名按 PATH 查找，包含路径分隔符时检查该文件存在且可执行。
+func (p *Provider) ResolveIndexer() (string, error) {
+	name := p.IndexerPath
+	if name == "" {
+		name = defaultIndexer
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		if p.IndexerPath == "" {
+			return "", fmt.Errorf("错误: 'zoekt-git-index' 命令未找到。请确保已安装并配置在系统 PATH 中。参考 README.md")
+		}
+		return "", fmt.Errorf("配置的索引程序 '%s' 不可用: %w", p.IndexerPath, err)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return path, nil
 }
 
 const dbFileName = "app.db"
+
+// dbOptions 数据库连接参数 (go-sqlite3 DSN)。服务端、CLI 和反馈服务共用同一个 *sql.DB，
+// 但 CLI 进程和连接池中的多个连接仍会并发写:
+//   - WAL: 读写互不阻塞
+//   - busy_timeout: 写锁被占用时最多等待 5 秒而不是立即返回 SQLITE_BUSY
+//   - txlock=immediate: 事务开始时即获取写锁，避免先读后写的事务在升级写锁时直接失败 (此时不会等待 busy_timeout)
+//   - synchronous=NORMAL: WAL 模式下仍保证一致性，只在断电时可能丢失最近提交的事务
+const dbOptions = "_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate&_synchronous=NORMAL"
 const reposSubDir = "repos"            // 子目录，存放各仓库数据
 const zoektIndexSubDir = "zoekt-index" // 子目录，存放 Zoekt 索引
 
 // NewProvider 创建一个新的仓库服务实例
 // 它会在全局数据目录 (dataDir) 下初始化 SQLite 数据库。
 func NewProvider(dataDir string) (*Provider, error) {
+	return NewProviderWithOptions(dataDir, ProviderOptions{})
+}
+
+// NewProviderWithOptions 与 NewProvider 相同，但可以以只读模式打开 (见 ProviderOptions)
+func NewProviderWithOptions(dataDir string, opts ProviderOptions) (*Provider, error) {
 	absDataDir, err := filepath.Abs(dataDir)
 	if err != nil {
 		return nil, fmt.Errorf("无法获取全局数据目录 '%s' 的绝对路径: %w", dataDir, err)
 	}
+	if opts.ReadOnly {
+		return newReadOnlyProvider(absDataDir, opts.RefreshInterval)
+	}
 	// 确保基础数据目录和仓库子目录存在
 	reposPath := filepath.Join(absDataDir, reposSubDir)
-	if err := os.MkdirAll(reposPath, 0755); err != nil {
+	if err := opts.Modes.MkdirAll(reposPath); err != nil {
 		return nil, fmt.Errorf("创建仓库数据子目录 '%s' 失败: %w", reposPath, err)
 	}
 
-	dbPath := filepath.Join(absDataDir, dbFileName) + "?_foreign_keys=on&_journal_mode=WAL" // Use WAL mode for better concurrency
+	// 预先创建数据库文件，使其使用配置的权限 (SQLite 创建的 -wal/-shm 文件沿用数据库文件的权限)
+	dbFile, err := opts.Modes.OpenFile(filepath.Join(absDataDir, dbFileName), os.O_RDWR|os.O_CREATE)
+	if err != nil {
+		return nil, fmt.Errorf("创建数据库文件失败: %w", err)
+	}
+	dbFile.Close()
+
+	dbPath := filepath.Join(absDataDir, dbFileName) + "?" + dbOptions
 	log.Printf("初始化数据库: %s", filepath.Join(absDataDir, dbFileName))
 	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
@@ -71,6 +172,8 @@ func NewProvider(dataDir string) (*Provider, error) {
 		DataDir:      absDataDir,
 		repositories: make([]Repository, 0),
 		repoMap:      make(map[uint32]Repository),
+		jobs:         NewJobManager(),
+		modes:        opts.Modes,
 	}
 
 	if err := p.initSchema(); err != nil {
@@ -103,14 +206,73 @@ func (p *Provider) initSchema() error {
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
 
+	-- 仓库标签；删除仓库时级联删除，relocate 修改 repo_id 时级联更新
+	CREATE TABLE IF NOT EXISTS repo_tags (
+		repo_id INTEGER NOT NULL REFERENCES repositories(repo_id) ON DELETE CASCADE ON UPDATE CASCADE,
+		tag TEXT NOT NULL,
+		PRIMARY KEY (repo_id, tag)
+	);
+
 	CREATE TRIGGER IF NOT EXISTS update_repo_updated_at
 	AFTER UPDATE ON repositories FOR EACH ROW
 	BEGIN
 		UPDATE repositories SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
 	END;
 	`
-	_, err := p.db.Exec(query)
-	return err
+	if _, err := p.db.Exec(query); err != nil {
+		return err
+	}
+	return p.migrateSchema()
+}
+
+// migrateSchema 为旧版本数据库补齐后续新增的列 (幂等)
+func (p *Provider) migrateSchema() error {
+	if err := p.addColumnIfNotExists("repositories", "archived", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := p.addColumnIfNotExists("repositories", "default_branch", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := p.addColumnIfNotExists("repositories", "enabled", "INTEGER NOT NULL DEFAULT 1"); err != nil {
+		return err
+	}
+	return p.addColumnIfNotExists("repositories", "indexed_commit", "TEXT NOT NULL DEFAULT ''")
+}
+
+// addColumnIfNotExists 当表中不存在指定列时执行 ALTER TABLE ADD COLUMN
+func (p *Provider) addColumnIfNotExists(table, column, definition string) error {
+	rows, err := p.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("读取表 '%s' 结构失败: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			colType    string
+			notNull    int
+			defaultVal sql.NullString
+			pk         int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultVal, &pk); err != nil {
+			return fmt.Errorf("扫描表 '%s' 结构失败: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("读取表 '%s' 结构失败: %w", table, err)
+	}
+	rows.Close()
+
+	log.Printf("数据库迁移: 为表 %s 添加列 %s", table, column)
+	if _, err := p.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("为表 '%s' 添加列 '%s' 失败: %w", table, column, err)
+	}
+	return nil
 }
 
 // loadReposFromDB 从数据库加载所有仓库信息到内存缓存
@@ -118,7 +280,12 @@ func (p *Provider) loadReposFromDB() error {
 	p.mu.Lock() // Acquire write lock to modify cache
 	defer p.mu.Unlock()
 
-	rows, err := p.db.Query("SELECT id, repo_id, name, source_path, data_path, created_at, updated_at FROM repositories ORDER BY name")
+	tags, err := p.loadTagsLocked()
+	if err != nil {
+		return err
+	}
+
+	rows, err := p.db.Query("SELECT id, repo_id, name, source_path, data_path, created_at, updated_at, archived, default_branch, enabled = 0, indexed_commit FROM repositories ORDER BY name")
 	if err != nil {
 		return fmt.Errorf("查询数据库仓库失败: %w", err)
 	}
@@ -132,7 +299,7 @@ func (p *Provider) loadReposFromDB() error {
 		var repo Repository
 		var createdAt sql.NullTime
 		var updatedAt sql.NullTime
-		err := rows.Scan(&repo.DBID, &repo.RepoID, &repo.Name, &repo.SourcePath, &repo.DataPath, &createdAt, &updatedAt)
+		err := rows.Scan(&repo.DBID, &repo.RepoID, &repo.Name, &repo.SourcePath, &repo.DataPath, &createdAt, &updatedAt, &repo.Archived, &repo.DefaultBranch, &repo.Disabled, &repo.IndexedCommit)
 		if err != nil {
 			// Log individual scan errors but continue if possible
 			log.Printf("警告: 扫描数据库行失败: %v", err)
@@ -145,6 +312,7 @@ func (p *Provider) loadReposFromDB() error {
 		if updatedAt.Valid {
 			repo.UpdatedAt = updatedAt.Time
 		}
+		repo.Tags = tags[repo.RepoID]
 
 		p.repositories = append(p.repositories, repo)
 		p.repoMap[repo.RepoID] = repo
@@ -156,6 +324,13 @@ func (p *Provider) loadReposFromDB() error {
 	return nil
 }
 
+// Reload 从数据库重新加载仓库列表，用于数据库被其他进程修改之后 (如 SIGHUP 或 /api/admin/reload)，
+// 新增、删除或记录发生变化的仓库会触发 OnRepoChanged 回调，使相关缓存失效。
+// 可以并发调用: 多次 Reload 依次执行；加载期间持有写锁，读取方看到的总是加载前或加载后的完整列表
+func (p *Provider) Reload() error {
+	return p.refresh()
+}
+
 func (p *Provider) GetRepoIDByString(idStr string) uint32 {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
@@ -173,32 +348,65 @@ func (p *Provider) GetRepoIDByString(idStr string) uint32 {
 }
 
 // AddRepository 添加一个新的仓库到数据库并更新缓存
-func (p *Provider) AddRepository(id uint32, name string, sourcePath string) error {
+// autoIndex 为 true 且源路径是 Git 仓库时，添加成功后在后台启动 Zoekt 索引任务并返回该任务；
+// 否则返回的 *Job 为 nil。索引任务启动失败不影响添加结果，只记录日志。
+func (p *Provider) AddRepository(id uint32, name string, sourcePath string, autoIndex bool) (*Job, error) {
+	absSourcePath, err := p.insertRepository(id, name, sourcePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// 刷新内存缓存
+	if err := p.loadReposFromDB(); err != nil {
+		return nil, err
+	}
+
+	if !autoIndex {
+		return nil, nil
+	}
+	if _, err := git.PlainOpen(absSourcePath); err != nil {
+		log.Printf("提示: 仓库 '%d' 的源路径 '%s' 不是 Git 仓库，跳过自动索引", id, absSourcePath)
+		return nil, nil
+	}
+	job, err := p.StartIndexJob(id)
+	if err != nil {
+		log.Printf("警告: 为仓库 '%d' 启动自动索引失败: %v", id, err)
+		return nil, nil
+	}
+	return &job, nil
+}
+
+// insertRepository 校验参数、创建数据目录并写入数据库，返回源路径的绝对路径
+// 不刷新内存缓存，调用方需在之后调用 loadReposFromDB
+func (p *Provider) insertRepository(id uint32, name string, sourcePath string) (string, error) {
+	if err := p.checkWritable(); err != nil {
+		return "", err
+	}
 	if id == 0 {
-		return fmt.Errorf("仓库 ID 不能为 0")
+		return "", fmt.Errorf("仓库 ID 不能为 0")
 	}
 	if name == "" {
-		return fmt.Errorf("仓库名称不能为空")
+		return "", fmt.Errorf("仓库名称不能为空")
 	}
 	if sourcePath == "" {
-		return fmt.Errorf("仓库源路径不能为空")
+		return "", fmt.Errorf("仓库源路径不能为空")
 	}
 
 	absSourcePath, err := filepath.Abs(sourcePath)
 	if err != nil {
-		return fmt.Errorf("无法获取仓库 '%d' 源路径 '%s' 的绝对路径: %w", id, sourcePath, err)
+		return "", fmt.Errorf("无法获取仓库 '%d' 源路径 '%s' 的绝对路径: %w", id, sourcePath, err)
 	}
 
 	// 确保源路径存在且是目录
 	info, err := os.Stat(absSourcePath)
 	if os.IsNotExist(err) {
-		return fmt.Errorf("仓库 '%d' 的源路径 '%s' 不存在", id, absSourcePath)
+		return "", fmt.Errorf("仓库 '%d' 的源路径 '%s' 不存在", id, absSourcePath)
 	}
 	if err != nil {
-		return fmt.Errorf("检查仓库 '%d' 的源路径 '%s' 时出错: %w", id, absSourcePath, err)
+		return "", fmt.Errorf("检查仓库 '%d' 的源路径 '%s' 时出错: %w", id, absSourcePath, err)
 	}
 	if !info.IsDir() {
-		return fmt.Errorf("仓库 '%d' 的源路径 '%s' 不是一个目录", id, absSourcePath)
+		return "", fmt.Errorf("仓库 '%d' 的源路径 '%s' 不是一个目录", id, absSourcePath)
 	}
 
 	// ★ 新的数据目录结构 ★
@@ -206,29 +414,30 @@ func (p *Provider) AddRepository(id uint32, name string, sourcePath string) erro
 	repoDataPath := filepath.Join(p.DataDir, reposSubDir, repoDataDirName) // <dataDir>/repos/<id>/
 
 	// 创建仓库专属数据目录
-	if err := os.MkdirAll(repoDataPath, 0755); err != nil {
-		return fmt.Errorf("为仓库 '%d' 创建数据目录 '%s' 失败: %w", id, repoDataPath, err)
+	if err := p.modes.MkdirAll(repoDataPath); err != nil {
+		return "", fmt.Errorf("为仓库 '%d' 创建数据目录 '%s' 失败: %w", id, repoDataPath, err)
 	}
 
 	// 插入数据库
-	query := "INSERT INTO repositories (repo_id, name, source_path, data_path) VALUES (?, ?, ?, ?)"
-	_, err = p.db.Exec(query, id, name, absSourcePath, repoDataPath)
+	query := "INSERT INTO repositories (repo_id, name, source_path, data_path, default_branch) VALUES (?, ?, ?, ?, ?)"
+	_, err = p.db.Exec(query, id, name, absSourcePath, repoDataPath, detectDefaultBranch(absSourcePath))
 	if err != nil {
 		// Specific check for UNIQUE constraint violation
 		if strings.Contains(err.Error(), "UNIQUE constraint failed: repositories.repo_id") {
-			return fmt.Errorf("仓库 ID '%d' 已存在", id)
+			return "", fmt.Errorf("仓库 ID '%d' 已存在", id)
 		}
-		return fmt.Errorf("插入仓库 '%d' 到数据库失败: %w", id, err)
+		return "", fmt.Errorf("插入仓库 '%d' 到数据库失败: %w", id, err)
 	}
 
 	log.Printf("成功添加仓库到数据库: ID=%d, Name=%s", id, name)
-
-	// 刷新内存缓存
-	return p.loadReposFromDB()
+	return absSourcePath, nil
 }
 
 // DeleteRepository 从数据库删除一个仓库并更新缓存
 func (p *Provider) DeleteRepository(id uint32) error {
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
 	// 先从缓存中获取 DataPath，以便后续删除目录
 	repo, ok := p.GetRepo(id) // Use GetRepo which uses RLock
 	if !ok {
@@ -254,6 +463,7 @@ func (p *Provider) DeleteRepository(id uint32) error {
 	}
 
 	log.Printf("成功从数据库删除仓库: ID=%d", id)
+	p.notifyRepoChanged(id)
 
 	// 删除仓库专属数据目录
 	if repoDataPath != "" {
@@ -270,98 +480,466 @@ func (p *Provider) DeleteRepository(id uint32) error {
 	return p.loadReposFromDB()
 }
 
+// ArchiveRepository 归档仓库 (软删除): 从 GetAll 和搜索中隐藏，但不删除任何数据
+func (p *Provider) ArchiveRepository(id uint32) error {
+	return p.setArchived(id, true)
+}
+
+// UnarchiveRepository 取消归档，仓库重新出现在列表和搜索中
+func (p *Provider) UnarchiveRepository(id uint32) error {
+	return p.setArchived(id, false)
+}
+
+// setArchived 更新仓库的归档标记并刷新缓存
+func (p *Provider) setArchived(id uint32, archived bool) error {
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
+	if _, ok := p.GetRepo(id); !ok {
+		return fmt.Errorf("仓库 ID '%d' 未找到", id)
+	}
+
+	if _, err := p.db.Exec("UPDATE repositories SET archived = ? WHERE repo_id = ?", archived, id); err != nil {
+		return fmt.Errorf("更新仓库 '%d' 的归档状态失败: %w", id, err)
+	}
+	log.Printf("仓库 %d 归档状态已更新: archived=%t", id, archived)
+
+	// 刷新内存缓存
+	return p.loadReposFromDB()
+}
+
+// SetEnabled 启用或停用仓库。停用的仓库仍出现在列表中 (带状态)，但浏览、搜索和代码分析返回 ErrRepoDisabled；
+// 与归档不同，它不会从列表中隐藏仓库，适合作为临时的开关
+func (p *Provider) SetEnabled(id uint32, enabled bool) error {
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
+	if _, ok := p.GetRepo(id); !ok {
+		return fmt.Errorf("仓库 ID '%d' 未找到", id)
+	}
+
+	if _, err := p.db.Exec("UPDATE repositories SET enabled = ? WHERE repo_id = ?", enabled, id); err != nil {
+		return fmt.Errorf("更新仓库 '%d' 的启用状态失败: %w", id, err)
+	}
+	log.Printf("仓库 %d 启用状态已更新: enabled=%t", id, enabled)
+
+	// 刷新内存缓存
+	return p.loadReposFromDB()
+}
+
+// RelocateRepository 将仓库从 oldID 重新映射到 newID，并把数据目录移动到 <dataDir>/repos/<newID>/
+// 数据库更新在事务中进行，目录移动失败时回滚事务；提交失败时把目录移回原位。
+// Zoekt 分片内记录的是旧 ID，无法原地改写: 这里删除旧分片并更新 .git/config 中的 zoekt.name / zoekt.repoid，
+// 仓库需要重新索引后才能再次使用 Zoekt 搜索。
+func (p *Provider) RelocateRepository(oldID, newID uint32) error {
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
+	if newID == 0 {
+		return fmt.Errorf("仓库 ID 不能为 0")
+	}
+	if oldID == newID {
+		return fmt.Errorf("新旧仓库 ID 相同: %d", oldID)
+	}
+	repoInfo, ok := p.GetRepo(oldID)
+	if !ok {
+		return fmt.Errorf("仓库 ID '%d' 未找到", oldID)
+	}
+	if _, exists := p.GetRepo(newID); exists {
+		return fmt.Errorf("仓库 ID '%d' 已存在", newID)
+	}
+
+	oldDataPath := repoInfo.DataPath
+	newDataPath := filepath.Join(p.DataDir, reposSubDir, strconv.FormatUint(uint64(newID), 10))
+	if _, err := os.Stat(newDataPath); err == nil {
+		return fmt.Errorf("目标数据目录 '%s' 已存在", newDataPath)
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("检查目标数据目录 '%s' 失败: %w", newDataPath, err)
+	}
+
+	// 1. 在事务中更新 repo_id 和 data_path (UNIQUE 约束兜底防止并发冲突)
+	tx, err := p.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	_, err = tx.Exec("UPDATE repositories SET repo_id = ?, data_path = ? WHERE repo_id = ?", newID, newDataPath, oldID)
+	if err != nil {
+		tx.Rollback()
+		if strings.Contains(err.Error(), "UNIQUE constraint failed: repositories.repo_id") {
+			return fmt.Errorf("仓库 ID '%d' 已存在", newID)
+		}
+		return fmt.Errorf("更新仓库 '%d' 的 ID 失败: %w", oldID, err)
+	}
+
+	// 2. 移动数据目录，失败则回滚数据库
+	if err := os.Rename(oldDataPath, newDataPath); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("移动数据目录 '%s' -> '%s' 失败: %w", oldDataPath, newDataPath, err)
+	}
+
+	// 3. 提交事务，失败则把目录移回去
+	if err := tx.Commit(); err != nil {
+		if rbErr := os.Rename(newDataPath, oldDataPath); rbErr != nil {
+			log.Printf("严重: 提交失败后无法恢复数据目录 '%s' -> '%s': %v", newDataPath, oldDataPath, rbErr)
+		}
+		return fmt.Errorf("提交仓库 '%d' 的 ID 变更失败: %w", oldID, err)
+	}
+	log.Printf("仓库已重新映射: %d -> %d, 数据目录: %s", oldID, newID, newDataPath)
+
+	// 4. 更新 Zoekt 索引名称: 删除旧分片，写入新的 git 配置，等待重新索引
+	if err := p.removeZoektShards(fmt.Sprintf("%010d_", oldID)); err != nil {
+		log.Printf("警告: 清理仓库 %d 的旧 Zoekt 分片失败: %v", oldID, err)
+	}
+	newInfo := repoInfo
+	newInfo.RepoID = newID
+	if gitRepo, err := git.PlainOpen(repoInfo.SourcePath); err == nil && !p.NoGitConfig {
+		if cfg, err := gitRepo.Config(); err == nil {
+			applyZoektGitConfig(cfg, newInfo)
+			if err := gitRepo.SetConfig(cfg); err != nil {
+				log.Printf("警告: 无法更新仓库 %d 的 .git/config: %v", newID, err)
+			}
+		}
+	}
+	log.Printf("提示: 仓库 %d 需要重新索引后才能使用 Zoekt 搜索", newID)
+
+	if err := p.loadReposFromDB(); err != nil {
+		return err
+	}
+	p.notifyRepoChanged(oldID)
+	p.notifyRepoChanged(newID)
+	return nil
+}
+
+// StartIndexJob 在后台执行 IndexRepositoryZoekt 并返回任务快照，可通过 GetJob 查询进度。
+// 同一仓库已有索引任务在运行时返回 *ErrJobInProgress。
+func (p *Provider) StartIndexJob(id uint32) (Job, error) {
+	if err := p.checkWritable(); err != nil {
+		return Job{}, err
+	}
+	if _, ok := p.GetRepo(id); !ok {
+		return Job{}, fmt.Errorf("仓库 ID '%d' 未找到", id)
+	}
+	return p.jobs.Start(JobKindIndex, id, func(ctx context.Context, rec *JobRecorder) error {
+		_, err := p.indexRepositoryZoekt(ctx, id, false, rec)
+		if err != nil {
+			log.Printf("后台索引仓库 '%d' 失败: %v", id, err)
+		}
+		return err
+	})
+}
+
+// CancelJob 取消正在运行的任务 (终止索引进程)
+func (p *Provider) CancelJob(jobID string) error {
+	return p.jobs.Cancel(jobID)
+}
+
+// GetJob 返回指定任务的快照
+func (p *Provider) GetJob(jobID string) (Job, bool) {
+	return p.jobs.Get(jobID)
+}
+
+// WaitJob 阻塞直到任务结束并返回最终快照 (用于 CLI 等无法在后台等待的场景)
+func (p *Provider) WaitJob(jobID string) (Job, bool) {
+	return p.jobs.Wait(jobID)
+}
+
+// ListJobs 返回后台任务列表 (按开始时间倒序)，repoID 为 0 时返回全部
+func (p *Provider) ListJobs(repoID uint32) []Job {
+	return p.jobs.List(repoID)
+}
+
+// IndexPlan 描述一次 Zoekt 索引将要执行的操作 (dry-run 的输出)
+type IndexPlan struct {
+	RepoID         uint32            `json:"repoId"`
+	Name           string            `json:"name"`
+	SourcePath     string            `json:"sourcePath"`
+	IndexDir       string            `json:"indexDir"`
+	ZoektName      string            `json:"zoektName"`      // 分片文件名前缀
+	GitConfig      map[string]string `json:"gitConfig"`      // 将写入 .git/config 的选项
+	Command        string            `json:"command"`        // zoekt-git-index 的绝对路径
+	Args           []string          `json:"args"`           // 传给 zoekt-git-index 的参数
+	HeadCommit     string            `json:"headCommit"`     // 当前 HEAD 提交
+	ExistingShards []string          `json:"existingShards"` // 已存在的分片文件名
+	// UpToDate 已有分片晚于 HEAD 的提交时间，zoekt-git-index 大概率会跳过 (按文件时间估计)
+	UpToDate bool `json:"upToDate"`
+	// LogFile 实际执行时索引程序输出所在的日志文件 (dry-run 时为空)
+	LogFile string `json:"logFile,omitempty"`
+	// Stats 索引完成后从索引程序输出中解析的统计 (dry-run 时为空)
+	Stats *IndexStats `json:"stats,omitempty"`
+}
+
 // IndexRepositoryZoekt 为指定的 Git 仓库生成或更新 Zoekt 索引
-func (p *Provider) IndexRepositoryZoekt(id uint32) error {
+// dryRun 为 true 时只做校验并返回执行计划，不写 .git/config、不创建目录、不执行索引程序。
+// 索引程序的输出写入 <DataPath>/logs/index-<timestamp>.log，同时打印到控制台。
+func (p *Provider) IndexRepositoryZoekt(id uint32, dryRun bool) (*IndexPlan, error) {
+	if !dryRun {
+		if err := p.checkWritable(); err != nil {
+			return nil, err
+		}
+	}
+	return p.indexRepositoryZoekt(context.Background(), id, dryRun, nil)
+}
+
+// indexRepositoryZoekt 实现 IndexRepositoryZoekt；rec 非 nil 时 (后台任务) 输出写入日志文件和任务记录，不再打印到控制台。
+// ctx 取消时终止索引进程并清理未完成的分片临时文件。
+func (p *Provider) indexRepositoryZoekt(ctx context.Context, id uint32, dryRun bool, rec *JobRecorder) (*IndexPlan, error) {
 	repoInfo, ok := p.GetRepo(id) // Read lock
 	if !ok {
-		return fmt.Errorf("仓库 ID '%d' 未找到", id)
+		return nil, fmt.Errorf("仓库 ID '%d' 未找到", id)
 	}
 
 	// ★ 1. 检查是否为 Git 仓库 (使用 go-git) ★
 	repo, err := git.PlainOpen(repoInfo.SourcePath)
 	if err != nil {
-		return fmt.Errorf("仓库 '%s' (%d) 在路径 '%s' 下不是一个有效的 Git 仓库 (go-git open 失败): %w", repoInfo.Name, id, repoInfo.SourcePath, err)
+		return nil, fmt.Errorf("仓库 '%s' (%d) 在路径 '%s' 下不是一个有效的 Git 仓库 (go-git open 失败): %w", repoInfo.Name, id, repoInfo.SourcePath, err)
 	}
 
-	// 2. 确保全局 Zoekt 索引目录存在
+	// 2. 全局 Zoekt 索引目录
 	zoektIndexPath := filepath.Join(p.DataDir, zoektIndexSubDir) // <dataDir>/zoekt-index/
-	if err := os.MkdirAll(zoektIndexPath, 0755); err != nil {
-		return fmt.Errorf("创建全局 Zoekt 索引目录 '%s' 失败: %w", zoektIndexPath, err)
-	}
 
-	// 3. 检查 zoekt-git-index 命令是否存在
-	zoektCmdPath, err := exec.LookPath("zoekt-git-index")
+	// 3. 检查 zoekt-git-index 命令是否存在 (可通过 IndexerPath 配置)
+	zoektCmdPath, err := p.ResolveIndexer()
 	if err != nil {
-		return fmt.Errorf("错误: 'zoekt-git-index' 命令未找到。请确保已安装并配置在系统 PATH 中。参考 README.md")
+		return nil, err
 	}
 
-	// ★ 4. 更新仓库本地 Git 配置以包含 zoekt.repoid, zoekt.name (使用 go-git) ★
-	log.Printf("正在更新仓库 '%s' (%d) 的 .git/config...", repoInfo.Name, id)
-	cfg, err := repo.Config() // 读取 .git/config
-	if err != nil {
-		return fmt.Errorf("无法读取仓库 '%s' (%d) 的 .git/config 文件: %w", repoInfo.Name, id, err)
+	// ★ 4. 计算 zoekt.repoid, zoekt.name ★
+	// ★ 新的 Zoekt 索引名称格式: "id(10位补0)_reponame" ★
+	zoektName := zoektShardPrefix(repoInfo)
+	gitConfig := map[string]string{}
+	var cfg *gitconfig.Config
+	// zoekt-git-index [-index indexDir] [-name repoName -repoid id] [extra args...] repoDir
+	args := []string{"-index", zoektIndexPath}
+	if p.NoGitConfig {
+		args = append(args, "-name", zoektName, "-repoid", strconv.FormatUint(uint64(id), 10))
+	} else {
+		// 通过 go-git 写入 .git/config，zoekt-git-index 从中读取
+		cfg, err = repo.Config() // 读取 .git/config
+		if err != nil {
+			return nil, fmt.Errorf("无法读取仓库 '%s' (%d) 的 .git/config 文件: %w", repoInfo.Name, id, err)
+		}
+		applyZoektGitConfig(cfg, repoInfo)
+		gitConfig["zoekt.name"] = cfg.Raw.Section("zoekt").Option("name")
+		gitConfig["zoekt.repoid"] = cfg.Raw.Section("zoekt").Option("repoid")
+	}
+	args = append(args, p.IndexerArgs...)
+	args = append(args, repoInfo.SourcePath)
+
+	plan := &IndexPlan{
+		RepoID:     id,
+		Name:       repoInfo.Name,
+		SourcePath: repoInfo.SourcePath,
+		IndexDir:   zoektIndexPath,
+		ZoektName:  zoektName,
+		GitConfig:  gitConfig,
+		Command:    zoektCmdPath,
+		Args:       args,
+	}
+	if dryRun {
+		p.fillIndexPlanState(plan, repo)
+		return plan, nil
 	}
 
-	// ★ 新的 Zoekt 索引名称格式: "id(10位补0)_reponame" ★
-	// 确保仓库名对于文件名是安全的 (替换所有非字母数字字符为下划线)
-	reg := regexp.MustCompile("[^a-zA-Z0-9]+")
-	sanitizedName := reg.ReplaceAllString(repoInfo.Name, "_")
-	// 格式化 ID 为 10 位，用 0 填充
-	zoektName := fmt.Sprintf("%010d_%s", id, sanitizedName)
-	cfg.Raw.SetOption("zoekt", "", "name", zoektName)
+	if err := p.modes.MkdirAll(zoektIndexPath); err != nil {
+		return nil, fmt.Errorf("创建全局 Zoekt 索引目录 '%s' 失败: %w", zoektIndexPath, err)
+	}
 
-	repoIDStr := strconv.FormatUint(uint64(id), 10)
-	// section="zoekt", subsection="", key="repoid", value=repoIDStr
-	cfg.Raw.SetOption("zoekt", "", "repoid", repoIDStr)
+	if cfg != nil {
+		log.Printf("正在更新仓库 '%s' (%d) 的 .git/config...", repoInfo.Name, id)
+		if err := repo.SetConfig(cfg); err != nil { // 写回 .git/config
+			// 记录警告，但不一定是致命错误
+			log.Printf("警告: 无法将 zoekt.repoid 写入仓库 '%s' (%d) 的 .git/config: %v", repoInfo.Name, id, err)
+		} else {
+			log.Printf("成功更新仓库 '%s' (%d) 的 Git 配置 zoekt.repoid", repoInfo.Name, id)
+		}
+	}
 
-	if err := repo.SetConfig(cfg); err != nil { // 写回 .git/config
-		// 记录警告，但不一定是致命错误
-		log.Printf("警告: 无法将 zoekt.repoid 写入仓库 '%s' (%d) 的 .git/config: %v", repoInfo.Name, id, err)
-	} else {
-		log.Printf("成功更新仓库 '%s' (%d) 的 Git 配置 zoekt.repoid", repoInfo.Name, id)
+	// ★ 5. 执行 zoekt-git-index 命令，输出写入本次索引的日志文件 ★
+	logFile, err := createIndexLog(repoInfo, p.modes)
+	if err != nil {
+		return nil, err
 	}
+	defer logFile.Close()
+	plan.LogFile = logFile.Name()
 
-	// ★ 5. 执行 zoekt-git-index 命令 ★
-	// zoekt-git-index [-index indexDir] [-name repoName] repoDir
-	args := []string{
-		"-index", zoektIndexPath,
-		repoInfo.SourcePath,
+	var out io.Writer = io.MultiWriter(logFile, os.Stdout)
+	if rec != nil {
+		rec.SetLogFile(logFile.Name())
+		out = io.MultiWriter(logFile, rec)
 	}
-	zoektCmd := exec.Command(zoektCmdPath, args...)
-	zoektCmd.Stdout = os.Stdout // 将输出直接打印到控制台
-	zoektCmd.Stderr = os.Stderr
+	fmt.Fprintf(out, "$ %s %s\n", zoektCmdPath, strings.Join(args, " "))
+
+	// 同时解析输出中的分片统计 (zoekt 的日志写在 stderr)
+	parser := &indexOutputParser{}
+	zoektCmd := exec.CommandContext(ctx, zoektCmdPath, args...)
+	zoektCmd.Stdout = io.MultiWriter(out, parser)
+	zoektCmd.Stderr = zoektCmd.Stdout
+	// 取消后子进程 (如 ctags) 可能仍占用输出管道，最多再等待 5 秒
+	zoektCmd.WaitDelay = 5 * time.Second
 	log.Printf("正在为仓库 '%s' (%d) 生成 Zoekt 索引...", repoInfo.Name, id)
-	log.Printf("执行命令: %s %s", zoektCmdPath, strings.Join(args, " "))
+	log.Printf("执行命令: %s %s (输出: %s)", zoektCmdPath, strings.Join(args, " "), logFile.Name())
 
+	// 索引开始前记录 HEAD，索引期间的新提交留给下一次
+	indexedCommit := headCommit(repoInfo.SourcePath)
 	startTime := time.Now()
 	if err := zoektCmd.Run(); err != nil {
-		return fmt.Errorf("执行 zoekt-git-index 为仓库 '%s' (%d) 创建索引失败: %w", repoInfo.Name, id, err)
+		if ctx.Err() != nil {
+			fmt.Fprintf(out, "索引已取消\n")
+			p.removePartialShards(zoektName)
+			return nil, fmt.Errorf("仓库 '%s' (%d) 的索引已取消: %w", repoInfo.Name, id, ctx.Err())
+		}
+		fmt.Fprintf(out, "索引失败: %v\n", err)
+		return nil, fmt.Errorf("执行 zoekt-git-index 为仓库 '%s' (%d) 创建索引失败: %w", repoInfo.Name, id, err)
 	}
 
-	log.Printf("成功为仓库 '%s' (%d) 生成 Zoekt 索引 (名称: %s)，耗时: %v", repoInfo.Name, id, zoektName, time.Since(startTime))
-	return nil
+	plan.Stats = parser.Result(time.Since(startTime))
+	fmt.Fprintf(out, "%s\n", plan.Stats)
+	if rec != nil {
+		rec.SetIndexStats(plan.Stats)
+	}
+	log.Printf("成功为仓库 '%s' (%d) 生成 Zoekt 索引 (名称: %s)，%s", repoInfo.Name, id, zoektName, plan.Stats)
+	p.recordIndexedCommit(id, indexedCommit)
+	p.ensureDefaultBranch(repoInfo)
+	p.notifyRepoChanged(id)
+	return plan, nil
+}
+
+// fillIndexPlanState 补充计划中的 HEAD 提交、已有分片以及是否可能已是最新
+// 这些信息只用于展示，读取失败时留空
+func (p *Provider) fillIndexPlanState(plan *IndexPlan, repo *git.Repository) {
+	shards, _ := filepath.Glob(filepath.Join(plan.IndexDir, plan.ZoektName+"_*.zoekt"))
+	var newestShard time.Time
+	plan.ExistingShards = []string{}
+	for _, shard := range shards {
+		plan.ExistingShards = append(plan.ExistingShards, filepath.Base(shard))
+		if info, err := os.Stat(shard); err == nil && info.ModTime().After(newestShard) {
+			newestShard = info.ModTime()
+		}
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return
+	}
+	plan.HeadCommit = head.Hash().String()
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return
+	}
+	plan.UpToDate = len(shards) > 0 && !newestShard.Before(commit.Committer.When)
 }
 
 // RegisterScipIndex 注册 SCIP 索引文件 (复制到仓库数据目录)
 func (p *Provider) RegisterScipIndex(id uint32, scipPath string) error {
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
 	repoInfo, ok := p.GetRepo(id)
 	if !ok {
 		return fmt.Errorf("仓库 ID '%d' 未找到", id)
 	}
 
-	targetDir := filepath.Join(repoInfo.DataPath, "scip")
-	if err := os.MkdirAll(targetDir, 0755); err != nil {
+	targetFile := repoInfo.ScipIndexPath()
+	if err := p.modes.MkdirAll(filepath.Dir(targetFile)); err != nil {
 		return fmt.Errorf("创建 SCIP 目录失败: %w", err)
 	}
-	targetFile := filepath.Join(targetDir, "index.scip")
 
 	log.Printf("正在注册 SCIP 索引: %s -> %s", scipPath, targetFile)
-	return copyFile(scipPath, targetFile)
+	if err := copyFile(scipPath, targetFile, p.modes); err != nil {
+		return err
+	}
+	p.notifyRepoChanged(id)
+	return nil
+}
+
+// StoreScipIndex 从 r 读取 SCIP 索引并原子地写入仓库数据目录
+// 先写入同目录下的临时文件并校验能否解析为 scip.Index，成功后再 rename 覆盖旧索引，
+// 因此上传中断或内容无效时不会破坏已有索引。
+func (p *Provider) StoreScipIndex(id uint32, r io.Reader) error {
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
+	repoInfo, ok := p.GetRepo(id)
+	if !ok {
+		return fmt.Errorf("仓库 ID '%d' 未找到", id)
+	}
+
+	targetFile := repoInfo.ScipIndexPath()
+	if err := p.modes.MkdirAll(filepath.Dir(targetFile)); err != nil {
+		return fmt.Errorf("创建 SCIP 目录失败: %w", err)
+	}
+
+	tmp, err := p.modes.CreateTemp(filepath.Dir(targetFile), "index.scip.upload-*")
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	tmpPath := tmp.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入 SCIP 索引失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入 SCIP 索引失败: %w", err)
+	}
+
+	if err := validateScipFile(tmpPath); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, targetFile); err != nil {
+		return fmt.Errorf("替换 SCIP 索引失败: %w", err)
+	}
+	committed = true
+	log.Printf("已上传仓库 %d 的 SCIP 索引: %s", id, targetFile)
+
+	p.notifyRepoChanged(id)
+	return nil
+}
+
+// ErrInvalidScipIndex 表示上传的内容不是有效的 SCIP 索引
+var ErrInvalidScipIndex = errors.New("无效的 SCIP 索引")
+
+// validateScipFile 校验文件是否为有效的 SCIP 索引 (可解析且包含 Metadata)。
+// 按文档流式解析 (scip.IndexVisitor)，解析后即丢弃，内存占用只与单个文档的大小有关，而不是整个索引
+func validateScipFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("读取 SCIP 索引失败: %w", err)
+	}
+	defer f.Close()
+
+	hasMetadata := false
+	visitor := scip.IndexVisitor{
+		VisitMetadata: func(context.Context, *scip.Metadata) error {
+			hasMetadata = true
+			return nil
+		},
+		// 解析每个文档以校验其内容，不保留
+		VisitDocument:       func(context.Context, *scip.Document) error { return nil },
+		VisitExternalSymbol: func(context.Context, *scip.SymbolInformation) error { return nil },
+	}
+	if err := visitor.ParseStreaming(context.Background(), bufio.NewReader(f)); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidScipIndex, err)
+	}
+	if !hasMetadata {
+		return fmt.Errorf("%w: 缺少 Metadata", ErrInvalidScipIndex)
+	}
+	return nil
 }
 
 // RegisterZoektIndex 手动注册 Zoekt 索引文件 (复制到全局索引目录)
 // 支持注册多个文件，文件名必须符合 {ShardPrefix}.{ShardID}.zoekt 格式
 func (p *Provider) RegisterZoektIndex(id uint32, zoektPaths []string) error {
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
 	repoInfo, ok := p.GetRepo(id)
 	if !ok {
 		return fmt.Errorf("仓库 ID '%d' 未找到", id)
@@ -372,29 +950,16 @@ func (p *Provider) RegisterZoektIndex(id uint32, zoektPaths []string) error {
 	}
 
 	zoektIndexPath := filepath.Join(p.DataDir, zoektIndexSubDir)
-	if err := os.MkdirAll(zoektIndexPath, 0755); err != nil {
+	if err := p.modes.MkdirAll(zoektIndexPath); err != nil {
 		return fmt.Errorf("创建 Zoekt 索引目录失败: %w", err)
 	}
 
 	// 生成标准化的文件名前缀: id(10位)_name
-	reg := regexp.MustCompile("[^a-zA-Z0-9]+")
-	sanitizedName := reg.ReplaceAllString(repoInfo.Name, "_")
-	targetPrefix := fmt.Sprintf("%010d_%s", id, sanitizedName)
+	targetPrefix := zoektShardPrefix(repoInfo)
 
 	// 1. 删除旧的索引文件 (以 targetPrefix 开头的所有 .zoekt 文件)
-	entries, err := os.ReadDir(zoektIndexPath)
-	if err != nil {
-		return fmt.Errorf("读取索引目录失败: %w", err)
-	}
-	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasPrefix(entry.Name(), targetPrefix) && strings.HasSuffix(entry.Name(), ".zoekt") {
-			oldPath := filepath.Join(zoektIndexPath, entry.Name())
-			if err := os.Remove(oldPath); err != nil {
-				log.Printf("警告: 无法删除旧索引文件 '%s': %v", oldPath, err)
-			} else {
-				log.Printf("已删除旧索引文件: %s", entry.Name())
-			}
-		}
+	if err := p.removeZoektShards(targetPrefix); err != nil {
+		return err
 	}
 
 	// 2. 复制新文件
@@ -421,23 +986,105 @@ func (p *Provider) RegisterZoektIndex(id uint32, zoektPaths []string) error {
 		targetFile := filepath.Join(zoektIndexPath, targetName)
 
 		log.Printf("正在手动注册 Zoekt 索引: %s -> %s", srcPath, targetFile)
-		if err := copyFile(srcPath, targetFile); err != nil {
+		if err := copyFile(srcPath, targetFile, p.modes); err != nil {
 			return fmt.Errorf("复制文件 '%s' 失败: %w", srcPath, err)
 		}
 	}
 
+	p.notifyRepoChanged(id)
+	return nil
+}
+
+// zoektShardPrefix 返回仓库 Zoekt 索引的名称 (也是分片文件名前缀): "id(10位补0)_reponame"
+// 仓库名中所有非字母数字字符替换为下划线，确保对文件名安全
+func zoektShardPrefix(repoInfo Repository) string {
+	sanitizedName := unsafeNameChars.ReplaceAllString(repoInfo.Name, "_")
+	return fmt.Sprintf("%010d_%s", repoInfo.RepoID, sanitizedName)
+}
+
+var unsafeNameChars = regexp.MustCompile("[^a-zA-Z0-9]+")
+
+// applyZoektGitConfig 在 git 配置中设置 zoekt.name 和 zoekt.repoid，返回索引名称
+// zoekt-git-index 根据这两个选项决定分片文件名和分片内记录的仓库 ID
+func applyZoektGitConfig(cfg *gitconfig.Config, repoInfo Repository) string {
+	zoektName := zoektShardPrefix(repoInfo)
+	cfg.Raw.SetOption("zoekt", "", "name", zoektName)
+	// section="zoekt", subsection="", key="repoid"
+	cfg.Raw.SetOption("zoekt", "", "repoid", strconv.FormatUint(uint64(repoInfo.RepoID), 10))
+	return zoektName
+}
+
+// removeZoektShards 删除全局索引目录中以 prefix 开头的所有 .zoekt 分片文件
+func (p *Provider) removeZoektShards(prefix string) error {
+	zoektIndexPath := filepath.Join(p.DataDir, zoektIndexSubDir)
+	entries, err := os.ReadDir(zoektIndexPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取索引目录失败: %w", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), prefix) && strings.HasSuffix(entry.Name(), ".zoekt") {
+			oldPath := filepath.Join(zoektIndexPath, entry.Name())
+			if err := os.Remove(oldPath); err != nil {
+				log.Printf("警告: 无法删除旧索引文件 '%s': %v", oldPath, err)
+			} else {
+				log.Printf("已删除旧索引文件: %s", entry.Name())
+			}
+		}
+	}
 	return nil
 }
 
-// copyFile 辅助函数：复制文件
-func copyFile(src, dst string) error {
+// removePartialShards 删除被中断的索引留下的分片临时文件 (<prefix>_*.zoekt.*.tmp)
+// 已完成的分片保持不变，旧索引仍然可用
+func (p *Provider) removePartialShards(prefix string) {
+	pattern := filepath.Join(p.DataDir, zoektIndexSubDir, prefix+"_*.tmp")
+	tmps, _ := filepath.Glob(pattern)
+	for _, tmp := range tmps {
+		if err := os.Remove(tmp); err != nil {
+			log.Printf("警告: 无法删除未完成的索引文件 '%s': %v", tmp, err)
+		} else {
+			log.Printf("已删除未完成的索引文件: %s", filepath.Base(tmp))
+		}
+	}
+}
+
+// IndexStatus 描述仓库的索引情况
+type IndexStatus struct {
+	Zoekt bool // 全局索引目录中存在该仓库的 Zoekt 分片
+	Scip  bool // 仓库数据目录中存在 scip/index.scip
+}
+
+// GetIndexStatus 检查仓库的 Zoekt 分片和 SCIP 索引是否存在 (直接访问文件系统，调用方应自行缓存)
+// Zoekt 分片按 ID 前缀匹配，仓库改名后旧分片仍然算作已索引
+func (p *Provider) GetIndexStatus(id uint32) (IndexStatus, error) {
+	repoInfo, ok := p.GetRepo(id)
+	if !ok {
+		return IndexStatus{}, fmt.Errorf("仓库 ID '%d' 未找到", id)
+	}
+
+	var status IndexStatus
+	shardPattern := filepath.Join(p.DataDir, zoektIndexSubDir, fmt.Sprintf("%010d_*.zoekt", id))
+	if matches, err := filepath.Glob(shardPattern); err == nil && len(matches) > 0 {
+		status.Zoekt = true
+	}
+	if info, err := os.Stat(repoInfo.ScipIndexPath()); err == nil && !info.IsDir() {
+		status.Scip = true
+	}
+	return status, nil
+}
+
+// copyFile 辅助函数：复制文件，新建的目标文件使用 modes 中的权限
+func copyFile(src, dst string, modes fileperm.Modes) error {
 	sourceFile, err := os.Open(src)
 	if err != nil {
 		return fmt.Errorf("打开源文件失败: %w", err)
 	}
 	defer sourceFile.Close()
 
-	destFile, err := os.Create(dst)
+	destFile, err := modes.Create(dst)
 	if err != nil {
 		return fmt.Errorf("创建目标文件失败: %w", err)
 	}
@@ -449,7 +1096,40 @@ func copyFile(src, dst string) error {
 	return nil
 }
 
+// OnRepoChanged 注册一个回调，在仓库被重新索引、注册 SCIP 或删除后调用
+// 上层服务 (如 core) 通过它清理与该仓库相关的缓存
+func (p *Provider) OnRepoChanged(fn func(id uint32)) {
+	p.hooksMu.Lock()
+	defer p.hooksMu.Unlock()
+	p.changeHooks = append(p.changeHooks, fn)
+}
+
+// IndexGeneration 返回仓库的索引代数: 重新索引、注册 Zoekt/SCIP 索引、删除等变更后递增。
+// 只保存在内存中 (从 0 开始)，与同样在内存中的搜索缓存生命周期一致
+func (p *Provider) IndexGeneration(id uint32) uint64 {
+	p.hooksMu.RLock()
+	defer p.hooksMu.RUnlock()
+	return p.generations[id]
+}
+
+// notifyRepoChanged 递增仓库的索引代数，然后依次调用所有已注册的变更回调
+func (p *Provider) notifyRepoChanged(id uint32) {
+	p.hooksMu.Lock()
+	if p.generations == nil {
+		p.generations = make(map[uint32]uint64)
+	}
+	p.generations[id]++
+	hooks := make([]func(uint32), len(p.changeHooks))
+	copy(hooks, p.changeHooks)
+	p.hooksMu.Unlock()
+
+	for _, fn := range hooks {
+		fn(id)
+	}
+}
+
 // GetRepo 根据 uint32 ID 查找并返回一个仓库配置 (线程安全)
+// 已归档和已停用的仓库同样可以查到，调用方需要时自行检查 Archived / CheckEnabled
 func (p *Provider) GetRepo(id uint32) (Repository, bool) {
 	p.mu.RLock() // Acquire read lock
 	defer p.mu.RUnlock()
@@ -457,11 +1137,24 @@ func (p *Provider) GetRepo(id uint32) (Repository, bool) {
 	return repo, ok
 }
 
-// GetAll 返回所有已配置的仓库列表 (按名称排序, 线程安全)
+// GetAll 返回所有未归档的仓库列表 (按名称排序, 线程安全)
 func (p *Provider) GetAll() []Repository {
 	p.mu.RLock() // Acquire read lock
 	defer p.mu.RUnlock()
 	// 返回副本以防止外部修改
+	reposCopy := make([]Repository, 0, len(p.repositories))
+	for _, repo := range p.repositories {
+		if !repo.Archived {
+			reposCopy = append(reposCopy, repo)
+		}
+	}
+	return reposCopy
+}
+
+// GetAllIncludingArchived 返回包括已归档仓库在内的完整列表 (管理端使用)
+func (p *Provider) GetAllIncludingArchived() []Repository {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
 	reposCopy := make([]Repository, len(p.repositories))
 	copy(reposCopy, p.repositories)
 	return reposCopy
@@ -474,16 +1167,23 @@ func (p *Provider) Count() int {
 	return len(p.repositories)
 }
 
-// GetDB 返回底层的数据库连接
+// GetDB 返回底层的数据库连接池，供同一进程中的其他服务 (如 feedback.NewService) 共用，
+// 使所有读写都经过同一个连接池和 WAL 文件，而不是对同一个 SQLite 文件打开多个连接池。
+// 连接池归 Provider 所有: 调用方不要关闭它，只在退出时调用一次 Provider.Close。
 func (p *Provider) GetDB() *sql.DB {
 	return p.db
 }
 
-// Close 关闭数据库连接 (应用退出时调用)
+// Close 停止后台刷新并关闭数据库连接池 (应用退出时调用)。重复调用是安全的，数据库只关闭一次。
 func (p *Provider) Close() error {
-	if p.db != nil {
-		log.Println("关闭数据库连接...")
-		return p.db.Close()
-	}
-	return nil
+	p.closeOnce.Do(func() {
+		if p.stopRefresh != nil {
+			p.stopRefresh()
+		}
+		if p.db != nil {
+			log.Println("关闭数据库连接...")
+			p.closeErr = p.db.Close()
+		}
+	})
+	return p.closeErr
 }
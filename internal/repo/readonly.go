@@ -0,0 +1,130 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"code-browser/internal/fileperm"
+)
+
+// ErrReadOnly 仓库服务以只读模式运行，拒绝所有修改 (添加、删除、索引、注册索引等)
+var ErrReadOnly = errors.New("仓库服务为只读模式，请在管理实例上修改")
+
+// DefaultRefreshInterval 只读模式下从数据库重新加载仓库列表的默认间隔
+const DefaultRefreshInterval = 30 * time.Second
+
+// ProviderOptions NewProviderWithOptions 的选项
+type ProviderOptions struct {
+	// ReadOnly 为 true 时以只读方式打开已有的数据库 (SQLite mode=ro)，不创建目录、不执行 schema 迁移，
+	// 所有修改操作返回 ErrReadOnly。用于水平扩展的只读浏览实例，数据库和索引由另一个管理实例维护。
+	ReadOnly bool
+	// RefreshInterval 只读模式下定期重新加载仓库列表的间隔，<= 0 时使用 DefaultRefreshInterval
+	RefreshInterval time.Duration
+	// Modes 在数据目录中新建目录和文件 (仓库数据目录、索引、SCIP、日志) 使用的权限，零值为 0755/0644
+	Modes fileperm.Modes
+}
+
+// newReadOnlyProvider 以只读方式打开 absDataDir 下已有的数据库，并启动定期刷新
+func newReadOnlyProvider(absDataDir string, interval time.Duration) (*Provider, error) {
+	dbFile := filepath.Join(absDataDir, dbFileName)
+	dsn := (&url.URL{Scheme: "file", Path: dbFile, RawQuery: "mode=ro&_foreign_keys=on&_busy_timeout=5000"}).String()
+	log.Printf("以只读模式打开数据库: %s", dbFile)
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("打开数据库 '%s' 失败: %w", dbFile, err)
+	}
+	db.SetMaxOpenConns(10)
+	db.SetMaxIdleConns(5)
+	db.SetConnMaxLifetime(5 * time.Minute)
+
+	p := &Provider{
+		db:           db,
+		DataDir:      absDataDir,
+		repositories: make([]Repository, 0),
+		repoMap:      make(map[uint32]Repository),
+		jobs:         NewJobManager(),
+		readOnly:     true,
+	}
+	if err := p.loadReposFromDB(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("从只读数据库加载仓库失败 (数据库需已由管理实例创建并迁移到当前版本): %w", err)
+	}
+	log.Printf("从数据库加载 %d 个仓库...", len(p.repositories))
+
+	if interval <= 0 {
+		interval = DefaultRefreshInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p.stopRefresh = cancel
+	go p.refreshLoop(ctx, interval)
+	return p, nil
+}
+
+// ReadOnly 报告仓库服务是否以只读模式运行
+func (p *Provider) ReadOnly() bool {
+	return p.readOnly
+}
+
+// checkWritable 只读模式下返回 ErrReadOnly，每个修改操作在开始时调用
+func (p *Provider) checkWritable() error {
+	if p.readOnly {
+		return ErrReadOnly
+	}
+	return nil
+}
+
+// refreshLoop 定期从数据库重新加载仓库列表，直到 ctx 取消
+func (p *Provider) refreshLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.refresh(); err != nil {
+				log.Printf("警告: 刷新仓库列表失败: %v", err)
+			}
+		}
+	}
+}
+
+// refresh 重新加载仓库列表，并对新增、删除或记录发生变化 (如管理实例重新索引后 indexed_commit 改变) 的仓库
+// 调用 notifyRepoChanged，使本实例的缓存失效
+func (p *Provider) refresh() error {
+	p.refreshMu.Lock()
+	defer p.refreshMu.Unlock()
+	before := make(map[uint32]Repository)
+	for _, r := range p.GetAllIncludingArchived() {
+		before[r.RepoID] = r
+	}
+	if err := p.loadReposFromDB(); err != nil {
+		return err
+	}
+
+	var changed []uint32
+	for _, r := range p.GetAllIncludingArchived() {
+		old, ok := before[r.RepoID]
+		delete(before, r.RepoID)
+		if !ok || !reflect.DeepEqual(old, r) {
+			changed = append(changed, r.RepoID)
+		}
+	}
+	for id := range before {
+		changed = append(changed, id)
+	}
+	for _, id := range changed {
+		p.notifyRepoChanged(id)
+	}
+	if len(changed) > 0 {
+		log.Printf("刷新仓库列表: %d 个仓库有变化", len(changed))
+	}
+	return nil
+}
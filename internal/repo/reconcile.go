@@ -0,0 +1,135 @@
+package repo
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MissingDir 数据库中存在、但数据目录已丢失的仓库
+type MissingDir struct {
+	RepoID uint32 `json:"repoId"`
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+}
+
+// ReconcileReport 数据库与数据目录之间的差异，以及修复情况
+type ReconcileReport struct {
+	DryRun bool `json:"dryRun"`
+	// OrphanedDirs <dataDir>/repos/ 下没有对应仓库记录的目录
+	OrphanedDirs []string `json:"orphanedDirs"`
+	// MissingDirs 仓库记录存在但数据目录不存在
+	MissingDirs []MissingDir `json:"missingDirs"`
+	// OrphanedShards zoekt-index/ 下不属于任何仓库 (或仓库名已不匹配) 的分片文件名
+	OrphanedShards []string `json:"orphanedShards"`
+	// Fixed 实际修复的问题数 (dryRun 时为 0)
+	Fixed int `json:"fixed"`
+	// Errors 修复过程中遇到的错误
+	Errors []string `json:"errors"`
+}
+
+// Reconcile 对比仓库表与 <dataDir>/repos/* 以及 Zoekt 分片，报告不一致之处。
+// dryRun 为 false 时同时修复: 删除孤立目录和孤立分片，为缺失数据目录的仓库重新创建空目录
+// (目录中的 SCIP 索引无法恢复，需要重新注册)。归档的仓库也视为有效仓库。
+func (p *Provider) Reconcile(dryRun bool) (*ReconcileReport, error) {
+	if !dryRun {
+		if err := p.checkWritable(); err != nil {
+			return nil, err
+		}
+	}
+	report := &ReconcileReport{
+		DryRun:         dryRun,
+		OrphanedDirs:   []string{},
+		MissingDirs:    []MissingDir{},
+		OrphanedShards: []string{},
+		Errors:         []string{},
+	}
+	repos := p.GetAllIncludingArchived()
+
+	// 1. 仓库数据目录
+	knownDirs := make(map[string]bool, len(repos))
+	for _, r := range repos {
+		knownDirs[filepath.Clean(r.DataPath)] = true
+		if info, err := os.Stat(r.DataPath); err != nil || !info.IsDir() {
+			report.MissingDirs = append(report.MissingDirs, MissingDir{RepoID: r.RepoID, Name: r.Name, Path: r.DataPath})
+		}
+	}
+	reposPath := filepath.Join(p.DataDir, reposSubDir)
+	entries, err := os.ReadDir(reposPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("读取仓库数据目录 '%s' 失败: %w", reposPath, err)
+	}
+	for _, e := range entries {
+		path := filepath.Join(reposPath, e.Name())
+		if e.IsDir() && !knownDirs[path] {
+			report.OrphanedDirs = append(report.OrphanedDirs, path)
+		}
+	}
+
+	// 2. Zoekt 分片: 文件名必须以某个仓库当前的 "id_name" 前缀开头
+	prefixes := make(map[string]string, len(repos)) // 10 位 id -> 完整前缀
+	for _, r := range repos {
+		prefix := zoektShardPrefix(r)
+		prefixes[prefix[:10]] = prefix
+	}
+	zoektIndexPath := filepath.Join(p.DataDir, zoektIndexSubDir)
+	shards, err := os.ReadDir(zoektIndexPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("读取索引目录 '%s' 失败: %w", zoektIndexPath, err)
+	}
+	for _, e := range shards {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".zoekt") {
+			continue
+		}
+		if !shardOwned(e.Name(), prefixes) {
+			report.OrphanedShards = append(report.OrphanedShards, e.Name())
+		}
+	}
+
+	if dryRun {
+		return report, nil
+	}
+
+	// 3. 修复
+	for _, dir := range report.OrphanedDirs {
+		if err := os.RemoveAll(dir); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("删除孤立目录 '%s' 失败: %v", dir, err))
+			continue
+		}
+		log.Printf("已删除孤立的仓库数据目录: %s", dir)
+		report.Fixed++
+	}
+	for _, missing := range report.MissingDirs {
+		if err := p.modes.MkdirAll(missing.Path); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("重建仓库 %d 的数据目录失败: %v", missing.RepoID, err))
+			continue
+		}
+		log.Printf("已重建仓库 %d 的数据目录: %s", missing.RepoID, missing.Path)
+		report.Fixed++
+		p.notifyRepoChanged(missing.RepoID)
+	}
+	for _, shard := range report.OrphanedShards {
+		if err := os.Remove(filepath.Join(zoektIndexPath, shard)); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("删除孤立分片 '%s' 失败: %v", shard, err))
+			continue
+		}
+		log.Printf("已删除孤立的索引分片: %s", shard)
+		report.Fixed++
+	}
+	return report, nil
+}
+
+// shardOwned 判断分片文件是否属于某个仓库: 以该仓库的完整前缀开头，且前缀后紧跟 '_' (zoekt-git-index 生成) 或 '.' (手动注册)
+func shardOwned(name string, prefixes map[string]string) bool {
+	if len(name) < 10 {
+		return false
+	}
+	prefix, ok := prefixes[name[:10]]
+	if !ok || !strings.HasPrefix(name, prefix) || len(name) == len(prefix) {
+		return false
+	}
+	next := name[len(prefix)]
+	return next == '_' || next == '.'
+}
@@ -0,0 +1,138 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// DefaultReindexConcurrency ReindexAll 默认同时运行的索引数
+const DefaultReindexConcurrency = 2
+
+// ErrReindexInProgress 已有 StartReindexAll 启动的全量重建在运行
+var ErrReindexInProgress = errors.New("已有全量重建索引在运行")
+
+// ReindexSkipped ReindexResult.Status 的补充取值: 仓库未被索引 (非 Git 仓库或已有索引任务在运行)
+const ReindexSkipped JobStatus = "skipped"
+
+// ReindexResult 单个仓库的重建索引结果
+type ReindexResult struct {
+	RepoID uint32    `json:"repoId"`
+	Name   string    `json:"name"`
+	JobID  string    `json:"jobId,omitempty"`
+	Status JobStatus `json:"status"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// ReindexAll 为所有未归档的 Git 仓库重建 Zoekt 索引，阻塞直到全部完成。
+// 最多同时运行 concurrency 个索引 (<= 0 时使用 DefaultReindexConcurrency)。
+// 每个仓库都作为一个索引任务运行，因此已有索引任务在运行的仓库会被跳过，进度也可通过任务列表查看。
+// 返回结果与 GetAll 的顺序一致。
+func (p *Provider) ReindexAll(concurrency int) []ReindexResult {
+	return p.reindexRepos(context.Background(), p.GetAll(), concurrency)
+}
+
+// StartReindexAll 在后台运行 ReindexAll 并立即返回。
+// 同一时间只运行一次全量重建，上一次尚未结束时返回 ErrReindexInProgress
+func (p *Provider) StartReindexAll(concurrency int) error {
+	if !p.reindexing.CompareAndSwap(false, true) {
+		return ErrReindexInProgress
+	}
+	go func() {
+		defer p.reindexing.Store(false)
+		p.ReindexAll(concurrency)
+	}()
+	return nil
+}
+
+// reindexRepos 以最多 concurrency 个并发为 repos 建立索引，阻塞直到全部完成。
+// ctx 取消后不再启动新的索引，正在运行的索引任务会被取消。
+func (p *Provider) reindexRepos(ctx context.Context, repos []Repository, concurrency int) []ReindexResult {
+	if concurrency <= 0 {
+		concurrency = DefaultReindexConcurrency
+	}
+	results := make([]ReindexResult, len(repos))
+	if len(repos) == 0 {
+		return results
+	}
+	log.Printf("开始重建 %d 个仓库的索引 (并发数 %d)", len(repos), concurrency)
+
+	work := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		finished int
+	)
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				result := p.reindexOne(ctx, repos[i])
+				results[i] = result
+
+				mu.Lock()
+				finished++
+				log.Printf("重建索引进度 [%d/%d]: 仓库 '%s' (%d) %s %s", finished, len(repos), result.Name, result.RepoID, result.Status, result.Error)
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range repos {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	log.Printf("重建索引完成: 共 %d 个仓库", len(repos))
+	return results
+}
+
+// reindexOne 通过任务管理器为单个仓库建立索引并等待结束；ctx 取消时取消该索引任务
+func (p *Provider) reindexOne(ctx context.Context, repoInfo Repository) ReindexResult {
+	result := ReindexResult{RepoID: repoInfo.RepoID, Name: repoInfo.Name}
+	if ctx.Err() != nil {
+		result.Status = ReindexSkipped
+		result.Error = "已停止"
+		return result
+	}
+	if _, err := git.PlainOpen(repoInfo.SourcePath); err != nil {
+		result.Status = ReindexSkipped
+		result.Error = "不是 Git 仓库"
+		return result
+	}
+
+	job, err := p.StartIndexJob(repoInfo.RepoID)
+	if err != nil {
+		var inProgress *ErrJobInProgress
+		if errors.As(err, &inProgress) {
+			result.Status = ReindexSkipped
+			result.JobID = inProgress.Job.ID
+			result.Error = "已有索引任务在运行"
+			return result
+		}
+		result.Status = JobFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	finished := make(chan Job, 1)
+	go func() {
+		final, _ := p.WaitJob(job.ID)
+		finished <- final
+	}()
+	var final Job
+	select {
+	case final = <-finished:
+	case <-ctx.Done():
+		p.CancelJob(job.ID)
+		final = <-finished
+	}
+	result.JobID = job.ID
+	result.Status = final.Status
+	result.Error = final.Error
+	return result
+}
@@ -0,0 +1,139 @@
+// Package repotest 提供 repo.RepoProvider 的内存实现，用于不依赖 SQLite 和磁盘的单元测试
+package repotest
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"code-browser/internal/repo"
+)
+
+// Provider 是线程安全的内存仓库列表，实现 repo.RepoProvider
+type Provider struct {
+	mu     sync.RWMutex
+	repos  map[uint32]repo.Repository
+	status map[uint32]repo.IndexStatus
+	hooks  []func(id uint32)
+	gens   map[uint32]uint64
+}
+
+var _ repo.RepoProvider = (*Provider)(nil)
+
+// New 创建包含给定仓库的内存 Provider
+func New(repos ...repo.Repository) *Provider {
+	p := &Provider{
+		repos:  make(map[uint32]repo.Repository),
+		status: make(map[uint32]repo.IndexStatus),
+		gens:   make(map[uint32]uint64),
+	}
+	for _, r := range repos {
+		p.repos[r.RepoID] = r
+	}
+	return p
+}
+
+// Add 添加或替换一个仓库
+func (p *Provider) Add(r repo.Repository) {
+	p.mu.Lock()
+	p.repos[r.RepoID] = r
+	p.mu.Unlock()
+}
+
+// Remove 删除仓库并触发变更回调，模拟 Provider.DeleteRepository
+func (p *Provider) Remove(id uint32) {
+	p.mu.Lock()
+	delete(p.repos, id)
+	delete(p.status, id)
+	p.mu.Unlock()
+	p.NotifyChanged(id)
+}
+
+// SetIndexStatus 设置 GetIndexStatus 返回的索引状态 (默认均为未索引)
+func (p *Provider) SetIndexStatus(id uint32, status repo.IndexStatus) {
+	p.mu.Lock()
+	p.status[id] = status
+	p.mu.Unlock()
+}
+
+// NotifyChanged 递增索引代数并调用所有已注册的变更回调，模拟重新索引完成
+func (p *Provider) NotifyChanged(id uint32) {
+	p.mu.Lock()
+	p.gens[id]++
+	hooks := append([]func(uint32){}, p.hooks...)
+	p.mu.Unlock()
+	for _, fn := range hooks {
+		fn(id)
+	}
+}
+
+// GetRepo 实现 repo.RepoProvider
+func (p *Provider) GetRepo(id uint32) (repo.Repository, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	r, ok := p.repos[id]
+	return r, ok
+}
+
+// GetAll 实现 repo.RepoProvider，按 ID 排序，不含已归档仓库
+func (p *Provider) GetAll() []repo.Repository {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	repos := make([]repo.Repository, 0, len(p.repos))
+	for _, r := range p.repos {
+		if !r.Archived {
+			repos = append(repos, r)
+		}
+	}
+	sort.Slice(repos, func(i, j int) bool { return repos[i].RepoID < repos[j].RepoID })
+	return repos
+}
+
+// GetRepoIDByString 实现 repo.RepoProvider
+func (p *Provider) GetRepoIDByString(idStr string) uint32 {
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		return 0
+	}
+	if _, ok := p.GetRepo(uint32(id)); !ok {
+		return 0
+	}
+	return uint32(id)
+}
+
+// Count 实现 repo.RepoProvider
+func (p *Provider) Count() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.repos)
+}
+
+// FindRepos 实现 repo.RepoProvider，匹配规则与 *repo.Provider 相同
+func (p *Provider) FindRepos(query string) []repo.Repository {
+	return repo.FilterRepos(p.GetAll(), query, false)
+}
+
+// GetIndexStatus 实现 repo.RepoProvider
+func (p *Provider) GetIndexStatus(id uint32) (repo.IndexStatus, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if _, ok := p.repos[id]; !ok {
+		return repo.IndexStatus{}, fmt.Errorf("仓库 ID '%d' 未找到", id)
+	}
+	return p.status[id], nil
+}
+
+// OnRepoChanged 实现 repo.RepoProvider
+func (p *Provider) OnRepoChanged(fn func(id uint32)) {
+	p.mu.Lock()
+	p.hooks = append(p.hooks, fn)
+	p.mu.Unlock()
+}
+
+// IndexGeneration 实现 repo.RepoProvider，每次 NotifyChanged 后递增
+func (p *Provider) IndexGeneration(id uint32) uint64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.gens[id]
+}
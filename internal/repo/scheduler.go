@@ -0,0 +1,132 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// headCommit 返回源仓库 HEAD 指向的提交哈希；非 Git 仓库或空仓库返回空字符串
+func headCommit(sourcePath string) string {
+	r, err := git.PlainOpen(sourcePath)
+	if err != nil {
+		return ""
+	}
+	head, err := r.Head()
+	if err != nil {
+		return ""
+	}
+	return head.Hash().String()
+}
+
+// recordIndexedCommit 记录最近一次成功索引时的 HEAD 提交并刷新缓存，供定时重建索引判断仓库是否有变化
+func (p *Provider) recordIndexedCommit(id uint32, commit string) {
+	if commit == "" {
+		return
+	}
+	if _, err := p.db.Exec("UPDATE repositories SET indexed_commit = ? WHERE repo_id = ?", commit, id); err != nil {
+		log.Printf("警告: 记录仓库 '%d' 的索引提交失败: %v", id, err)
+		return
+	}
+	if err := p.loadReposFromDB(); err != nil {
+		log.Printf("警告: 刷新仓库缓存失败: %v", err)
+	}
+}
+
+// ChangedRepos 返回 HEAD 与上次成功索引时记录的提交不同的仓库 (包括从未索引过的仓库)。
+// 已归档、已停用的仓库以及非 Git 仓库不在其中。
+func (p *Provider) ChangedRepos() []Repository {
+	var changed []Repository
+	for _, repoInfo := range p.GetAll() {
+		if repoInfo.Disabled {
+			continue
+		}
+		head := headCommit(repoInfo.SourcePath)
+		if head != "" && head != repoInfo.IndexedCommit {
+			changed = append(changed, repoInfo)
+		}
+	}
+	return changed
+}
+
+// ReindexScheduler 按固定间隔为 HEAD 有变化的仓库重建 Zoekt 索引 (见 ChangedRepos)。
+// 每个仓库作为普通索引任务运行，因此已有索引任务在运行的仓库会被跳过；同时运行的索引数受 concurrency 限制。
+type ReindexScheduler struct {
+	p           *Provider
+	interval    time.Duration
+	concurrency int
+	cancel      context.CancelFunc
+	done        chan struct{}
+	stopOnce    sync.Once
+}
+
+// StartReindexScheduler 在后台启动定时重建索引，第一轮在 interval 之后执行。
+// concurrency <= 0 时使用 DefaultReindexConcurrency。调用方负责在退出前调用 Stop。
+func (p *Provider) StartReindexScheduler(interval time.Duration, concurrency int) (*ReindexScheduler, error) {
+	if err := p.checkWritable(); err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("定时重建索引的间隔必须大于 0: %v", interval)
+	}
+	if concurrency <= 0 {
+		concurrency = DefaultReindexConcurrency
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &ReindexScheduler{
+		p:           p,
+		interval:    interval,
+		concurrency: concurrency,
+		cancel:      cancel,
+		done:        make(chan struct{}),
+	}
+	go s.run(ctx)
+	log.Printf("定时重建索引已启动: 间隔 %v，并发数 %d", interval, concurrency)
+	return s, nil
+}
+
+// Stop 停止调度并取消本轮中正在运行的索引任务，阻塞直到后台 goroutine 退出。可重复调用。
+func (s *ReindexScheduler) Stop() {
+	s.stopOnce.Do(func() {
+		s.cancel()
+		<-s.done
+		log.Printf("定时重建索引已停止")
+	})
+}
+
+func (s *ReindexScheduler) run(ctx context.Context) {
+	defer close(s.done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.RunOnce(ctx)
+		}
+	}
+}
+
+// RunOnce 执行一轮检查和重建，阻塞直到本轮结束或 ctx 取消，并在日志中输出本轮汇总
+func (s *ReindexScheduler) RunOnce(ctx context.Context) []ReindexResult {
+	start := time.Now()
+	changed := s.p.ChangedRepos()
+	if len(changed) == 0 {
+		log.Printf("定时重建索引: 没有仓库需要重建")
+		return nil
+	}
+	results := s.p.reindexRepos(ctx, changed, s.concurrency)
+
+	counts := make(map[JobStatus]int)
+	for _, r := range results {
+		counts[r.Status]++
+	}
+	log.Printf("定时重建索引: %d 个仓库有新提交，成功 %d，失败 %d，取消 %d，跳过 %d，耗时 %v",
+		len(changed), counts[JobSucceeded], counts[JobFailed], counts[JobCancelled], counts[ReindexSkipped], time.Since(start).Round(time.Millisecond))
+	return results
+}
@@ -0,0 +1,119 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// maxTagLength 标签的最大字符数
+const maxTagLength = 64
+
+// ErrInvalidTag 标签为空、过长或包含空白/逗号
+var ErrInvalidTag = errors.New("无效的标签")
+
+// NormalizeTag 去掉首尾空白并转为小写；标签不能为空、不能超过 maxTagLength 个字符，且不能包含空白或逗号
+func NormalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" || len([]rune(tag)) > maxTagLength || strings.ContainsAny(tag, " \t\r\n,") {
+		return "", fmt.Errorf("%w: '%s' (1-%d 个字符，不能包含空白或逗号)", ErrInvalidTag, tag, maxTagLength)
+	}
+	return tag, nil
+}
+
+// HasTag 判断仓库是否带有指定标签 (tag 按 NormalizeTag 规则比较)
+func (r Repository) HasTag(tag string) bool {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	for _, t := range r.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterReposByTag 返回带有 tag 的仓库，tag 为空时返回全部
+func FilterReposByTag(repos []Repository, tag string) []Repository {
+	if strings.TrimSpace(tag) == "" {
+		return repos
+	}
+	matched := make([]Repository, 0)
+	for _, r := range repos {
+		if r.HasTag(tag) {
+			matched = append(matched, r)
+		}
+	}
+	return matched
+}
+
+// AddTag 为仓库添加标签 (已存在时忽略) 并刷新缓存
+func (p *Provider) AddTag(id uint32, tag string) error {
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
+	tag, err := NormalizeTag(tag)
+	if err != nil {
+		return err
+	}
+	if _, ok := p.GetRepo(id); !ok {
+		return fmt.Errorf("仓库 ID '%d' 未找到", id)
+	}
+	if _, err := p.db.Exec("INSERT OR IGNORE INTO repo_tags (repo_id, tag) VALUES (?, ?)", id, tag); err != nil {
+		return fmt.Errorf("为仓库 '%d' 添加标签 '%s' 失败: %w", id, tag, err)
+	}
+	log.Printf("仓库 %d 添加标签: %s", id, tag)
+	return p.loadReposFromDB()
+}
+
+// RemoveTag 删除仓库的标签 (不存在时忽略) 并刷新缓存
+func (p *Provider) RemoveTag(id uint32, tag string) error {
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if _, ok := p.GetRepo(id); !ok {
+		return fmt.Errorf("仓库 ID '%d' 未找到", id)
+	}
+	if _, err := p.db.Exec("DELETE FROM repo_tags WHERE repo_id = ? AND tag = ?", id, tag); err != nil {
+		return fmt.Errorf("删除仓库 '%d' 的标签 '%s' 失败: %w", id, tag, err)
+	}
+	log.Printf("仓库 %d 删除标签: %s", id, tag)
+	return p.loadReposFromDB()
+}
+
+// GetTags 返回仓库的标签 (按字母排序)
+func (p *Provider) GetTags(id uint32) ([]string, error) {
+	repo, ok := p.GetRepo(id)
+	if !ok {
+		return nil, fmt.Errorf("仓库 ID '%d' 未找到", id)
+	}
+	return append([]string{}, repo.Tags...), nil
+}
+
+// loadTagsLocked 读取所有仓库的标签，调用方需持有 p.mu 写锁
+func (p *Provider) loadTagsLocked() (map[uint32][]string, error) {
+	rows, err := p.db.Query("SELECT repo_id, tag FROM repo_tags")
+	if err != nil {
+		return nil, fmt.Errorf("查询仓库标签失败: %w", err)
+	}
+	defer rows.Close()
+
+	tags := make(map[uint32][]string)
+	for rows.Next() {
+		var id uint32
+		var tag string
+		if err := rows.Scan(&id, &tag); err != nil {
+			return nil, fmt.Errorf("扫描仓库标签失败: %w", err)
+		}
+		tags[id] = append(tags[id], tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("读取仓库标签失败: %w", err)
+	}
+	for _, t := range tags {
+		sort.Strings(t)
+	}
+	return tags, nil
+}
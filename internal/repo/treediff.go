@@ -0,0 +1,166 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+)
+
+// ErrRevisionNotFound 无法解析的版本 (分支、标签、提交哈希或 HEAD~1 这类表达式)
+var ErrRevisionNotFound = errors.New("版本不存在")
+
+// treeDiffRenameLimit 重命名检测时比较的新增/删除文件数上限，超出后只识别内容完全相同的重命名
+// (与 git 的 diff.renameLimit 默认值一致)
+const treeDiffRenameLimit = 1000
+
+// TreeChange 两个版本之间一个路径的变化
+type TreeChange struct {
+	Action  string `json:"action"` // "added" | "modified" | "deleted" | "renamed"
+	Path    string `json:"path"`   // 变化后的路径；deleted 时为删除前的路径
+	OldPath string `json:"oldPath,omitempty"`
+}
+
+// TreeDiff 比较 revA 和 revB 两个提交的目录树，返回按路径排序的文件变化
+// revB 为空时使用 HEAD；revA 为空时使用 revB 的第一个父提交，
+// revB 是首个提交 (没有父提交) 时与空树比较，所有文件都是 added
+func (p *Provider) TreeDiff(id uint32, revA, revB string) ([]TreeChange, error) {
+	repoInfo, ok := p.GetRepo(id)
+	if !ok {
+		return nil, fmt.Errorf("仓库 ID '%d' 未找到", id)
+	}
+	return diffTrees(repoInfo.SourcePath, revA, revB)
+}
+
+// ChangedFilesSince 返回 rev 与 HEAD 之间新增、修改或重命名后的文件路径 (按路径排序)，
+// 即 HEAD 中仍然存在、内容与 rev 不同的文件；删除的文件不包括在内
+func ChangedFilesSince(r Repository, rev string) ([]string, error) {
+	return ChangedFilesBetween(r, rev, "HEAD")
+}
+
+// ChangedFilesBetween 同 ChangedFilesSince，但比较 base 与 head 两个版本 (通常是 ResolveChangedSince 返回的提交哈希)
+func ChangedFilesBetween(r Repository, base, head string) ([]string, error) {
+	if strings.TrimSpace(base) == "" {
+		return nil, fmt.Errorf("%w: 版本为空", ErrRevisionNotFound)
+	}
+	changes, err := diffTrees(r.SourcePath, base, head)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(changes))
+	for _, c := range changes {
+		if c.Action != "deleted" {
+			files = append(files, c.Path)
+		}
+	}
+	return files, nil
+}
+
+// ResolveChangedSince 把 rev 和当前 HEAD 解析为提交哈希。分支名或 HEAD~3 这类表达式指向的提交会变化，
+// 缓存等需要稳定标识的场景应使用解析后的哈希
+func ResolveChangedSince(r Repository, rev string) (base, head string, err error) {
+	if strings.TrimSpace(rev) == "" {
+		return "", "", fmt.Errorf("%w: 版本为空", ErrRevisionNotFound)
+	}
+	gitRepo, err := git.PlainOpen(r.SourcePath)
+	if err != nil {
+		return "", "", fmt.Errorf("打开 Git 仓库失败: %w", err)
+	}
+	baseCommit, err := resolveCommit(gitRepo, rev)
+	if err != nil {
+		return "", "", err
+	}
+	headCommit, err := resolveCommit(gitRepo, "HEAD")
+	if err != nil {
+		return "", "", err
+	}
+	return baseCommit.Hash.String(), headCommit.Hash.String(), nil
+}
+
+// diffTrees 实现 TreeDiff，sourcePath 为仓库的工作目录
+func diffTrees(sourcePath, revA, revB string) ([]TreeChange, error) {
+	r, err := git.PlainOpen(sourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("打开 Git 仓库失败: %w", err)
+	}
+	if revB == "" {
+		revB = "HEAD"
+	}
+	commitB, err := resolveCommit(r, revB)
+	if err != nil {
+		return nil, err
+	}
+	treeB, err := commitB.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("读取版本 '%s' 的目录树失败: %w", revB, err)
+	}
+
+	var treeA *object.Tree // nil 表示空树
+	if revA != "" {
+		commitA, err := resolveCommit(r, revA)
+		if err != nil {
+			return nil, err
+		}
+		if treeA, err = commitA.Tree(); err != nil {
+			return nil, fmt.Errorf("读取版本 '%s' 的目录树失败: %w", revA, err)
+		}
+	} else if commitB.NumParents() > 0 {
+		parent, err := commitB.Parent(0)
+		if err != nil {
+			return nil, fmt.Errorf("读取版本 '%s' 的父提交失败: %w", revB, err)
+		}
+		if treeA, err = parent.Tree(); err != nil {
+			return nil, fmt.Errorf("读取版本 '%s' 的父提交目录树失败: %w", revB, err)
+		}
+	}
+
+	opts := *object.DefaultDiffTreeOptions
+	opts.RenameLimit = treeDiffRenameLimit
+	changes, err := object.DiffTreeWithOptions(context.Background(), treeA, treeB, &opts)
+	if err != nil {
+		return nil, fmt.Errorf("比较目录树失败: %w", err)
+	}
+	return treeChanges(changes)
+}
+
+// resolveCommit 把版本表达式解析为提交
+func resolveCommit(r *git.Repository, rev string) (*object.Commit, error) {
+	hash, err := r.ResolveRevision(plumbing.Revision(strings.TrimSpace(rev)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: '%s'", ErrRevisionNotFound, rev)
+	}
+	commit, err := r.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("%w: '%s' 不是提交", ErrRevisionNotFound, rev)
+	}
+	return commit, nil
+}
+
+// treeChanges 把 go-git 的变化列表转换为 TreeChange，From 和 To 路径不同的修改视为重命名
+func treeChanges(changes object.Changes) ([]TreeChange, error) {
+	result := make([]TreeChange, 0, len(changes))
+	for _, c := range changes {
+		action, err := c.Action()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case action == merkletrie.Insert:
+			result = append(result, TreeChange{Action: "added", Path: c.To.Name})
+		case action == merkletrie.Delete:
+			result = append(result, TreeChange{Action: "deleted", Path: c.From.Name})
+		case c.From.Name != c.To.Name:
+			result = append(result, TreeChange{Action: "renamed", Path: c.To.Name, OldPath: c.From.Name})
+		default:
+			result = append(result, TreeChange{Action: "modified", Path: c.To.Name})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Path < result[j].Path })
+	return result, nil
+}
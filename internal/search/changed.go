@@ -0,0 +1,94 @@
+package search
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"code-browser/internal/codetree"
+)
+
+// MaxChangedFiles changedSince 交给引擎的最多文件数。超出时 Zoekt 的正则和 rg 的命令行参数都会过长
+// (rg 可能因 E2BIG 无法启动)，改为搜索整个仓库，只在结果中按变化文件过滤
+const MaxChangedFiles = 1000
+
+// applyChangedFiles 把搜索限制在 files 中 (changedSince 得到的变化文件):
+// Zoekt 在查询后追加 f:"^(?:a|b)$" 条件，ripgrep 把文件作为命令行参数传入 (SearchOptions.Paths)；
+// 返回 false 表示没有需要搜索的文件，调用方直接返回空结果。其他引擎以及超过 MaxChangedFiles 个文件时
+// 只能在结果中过滤 (见 notIn)
+func applyChangedFiles(engineName, nativeQuery string, opts SearchOptions, files []string) (string, SearchOptions, bool) {
+	if len(files) == 0 {
+		return nativeQuery, opts, false
+	}
+	if len(files) > MaxChangedFiles {
+		return nativeQuery, opts, true
+	}
+	switch engineName {
+	case EngineZoekt:
+		quoted := make([]string, len(files))
+		for i, f := range files {
+			quoted[i] = regexp.QuoteMeta(f)
+		}
+		nativeQuery += fmt.Sprintf(" f:%q", "^(?:"+strings.Join(quoted, "|")+")$")
+	case EngineRipgrep:
+		opts.Paths = ripgrepExplicitPaths(files, opts)
+		if len(opts.Paths) == 0 {
+			return nativeQuery, opts, false
+		}
+	}
+	return nativeQuery, opts, true
+}
+
+// ripgrepExplicitPaths 按 -g 和排除列表筛选要传给 rg 的文件:
+// rg 不对命令行上显式给出的文件应用这些过滤条件，这里按相同的规则预先筛选
+func ripgrepExplicitPaths(files []string, opts SearchOptions) []string {
+	globs := opts.Globs
+	if len(globs) == 0 {
+		for _, ext := range opts.Extensions {
+			globs = append(globs, "*."+ext)
+		}
+	}
+	var matchers []*regexp.Regexp
+	for _, g := range globs {
+		if re, err := regexp.Compile(globToRegex(g)); err == nil {
+			matchers = append(matchers, re)
+		}
+	}
+	paths := []string{}
+	for _, f := range files {
+		if codetree.MatchAnyPattern(opts.Ignore, f) {
+			continue
+		}
+		if len(matchers) > 0 && !matchesAny(matchers, f) {
+			continue
+		}
+		paths = append(paths, f)
+	}
+	return paths
+}
+
+func matchesAny(matchers []*regexp.Regexp, p string) bool {
+	for _, re := range matchers {
+		if re.MatchString(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// notIn 返回判断路径是否不在 files 中的函数，用于 excludedBy
+func notIn(files []string) func(string) bool {
+	set := make(map[string]bool, len(files))
+	for _, f := range files {
+		set[f] = true
+	}
+	return func(p string) bool { return !set[p] }
+}
+
+// ripgrepPathArgs 返回 rg 的搜索路径参数: 指定了 Paths 时为这些文件，否则为整个仓库
+func ripgrepPathArgs(opts SearchOptions) []string {
+	if len(opts.Paths) > 0 {
+		return append([]string{"--"}, opts.Paths...)
+	}
+	return []string{"."}
+}
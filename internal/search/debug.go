@@ -0,0 +1,52 @@
+package search
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"code-browser/internal/repo"
+)
+
+// SearchDebug 内容搜索带 debug=true 时随结果返回的调试信息，用于排查查询翻译和引擎的行为
+type SearchDebug struct {
+	Engine      string `json:"engine"`
+	Query       string `json:"query"`       // 请求中的原始查询 (q)
+	NativeQuery string `json:"nativeQuery"` // 翻译统一过滤条件、加上排除条件后交给引擎的查询
+
+	// ZoektRequest 发给 Zoekt /api/search 的请求体；ZoektStats 为 Zoekt 响应中 Result 的统计字段
+	ZoektRequest json.RawMessage            `json:"zoektRequest,omitempty"`
+	ZoektStats   map[string]json.RawMessage `json:"zoektStats,omitempty"`
+
+	// RipgrepArgs rg 的命令行参数
+	RipgrepArgs []string `json:"ripgrepArgs,omitempty"`
+}
+
+// DebugSearchResponse debug=true 时的响应: results 为不带 debug 时的完整响应 (数组、计数或 {results, index})
+type DebugSearchResponse struct {
+	Results any          `json:"results"`
+	Debug   *SearchDebug `json:"debug"`
+}
+
+// zoektResultLists Zoekt 响应 Result 中的结果列表字段，不属于统计信息
+var zoektResultLists = map[string]bool{"Files": true, "RepoURLs": true, "LineFragments": true}
+
+// zoektStats 从 Zoekt 响应体中取出 Result 的顶层统计字段 (Stats 嵌入在 Result 中，如 FileCount、MatchCount、Duration)
+func zoektStats(body []byte) map[string]json.RawMessage {
+	var resp struct {
+		Result map[string]json.RawMessage `json:"Result"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil || resp.Result == nil {
+		return nil
+	}
+	for key := range resp.Result {
+		if zoektResultLists[key] {
+			delete(resp.Result, key)
+		}
+	}
+	return resp.Result
+}
+
+// isAdmin 判断请求是否带有管理 Token；与管理 API 使用同一检查 (repo.CheckAdminToken)，未设置 AdminToken 时不鉴权
+func (h *Handlers) isAdmin(r *http.Request) bool {
+	return repo.CheckAdminToken(r, h.AdminToken) == nil
+}
@@ -3,16 +3,21 @@ package search
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url" // 引入 net/url
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode"
 
 	"code-browser/internal/repo"
 )
@@ -25,16 +30,81 @@ type SearchFragment struct {
 
 // SearchResult 定义了返回给前端的单条搜索结果的结构 (已更新)
 type SearchResult struct {
-	Path      string           `json:"path"`
-	LineNum   int              `json:"lineNum"`
-	LineText  string           `json:"lineText"`  // 完整的、base64 解码后的行文本
-	Fragments []SearchFragment `json:"fragments"` // 行内的匹配片段列表
+	Path       string           `json:"path"`
+	LineNum    int              `json:"lineNum"`
+	LineText   string           `json:"lineText"`             // 完整的、base64 解码后的行文本
+	Fragments  []SearchFragment `json:"fragments"`            // 行内的匹配片段列表
+	EndLineNum int              `json:"endLineNum,omitempty"` // 多行匹配时的结束行号
+	MatchText  string           `json:"matchText,omitempty"`  // 多行匹配时的完整匹配文本
+	// TruncatedLine 为 true 时 LineText 只是原行中首个匹配附近的窗口 (见 TrimLongLines)
+	TruncatedLine bool `json:"truncatedLine,omitempty"`
+	// Score 引擎给出的相关度 (Zoekt: 所在文件的分数)，越大越相关；引擎不提供时为 0 (见 SortResults)
+	Score float64 `json:"score,omitempty"`
 }
 
-// Engine 定义了所有搜索引擎都必须实现的接口 (保持不变)
+// SearchOptions 内容搜索的可选参数，不支持的引擎会忽略对应选项
+type SearchOptions struct {
+	Multiline bool // 允许正则跨行匹配 (仅 ripgrep: -U --multiline-dotall)
+
+	// 以下由 TranslateQuery 根据统一查询语法生成 (仅 ripgrep；Zoekt 的过滤条件直接写在查询中)
+	Case       string   // yes / no / auto，空表示忽略大小写
+	Globs      []string // path: 的 glob，作为 -g 传给 rg
+	Extensions []string // lang: 对应的扩展名
+
+	// Text 把二进制文件也当作文本搜索 (仅 ripgrep: --text)；textOnly=false 时设置
+	Text bool
+
+	// Ignore 排除的路径模式，作为 -g !<glob> 传给 rg (仅 ripgrep；Zoekt 的排除条件直接写在查询中)
+	Ignore []string
+
+	// Paths 只搜索这些文件 (仅 ripgrep，作为命令行参数传入；Zoekt 的限制条件直接写在查询中)，空表示整个仓库
+	Paths []string
+
+	// Debug 不为 nil 时，引擎在其中记录发给后端的请求 (Zoekt 请求体、rg 参数) 和后端的统计信息 (debug=true)
+	Debug *SearchDebug
+
+	// Truncated 不为 nil 时，引擎因自身的结果数上限丢弃了部分结果后将其设为 true (目前仅 scip 引擎)
+	Truncated *bool
+}
+
+// FileCount 单个文件的匹配计数
+type FileCount struct {
+	Path  string `json:"path"`
+	Count int    `json:"count"`
+}
+
+// CountResult 仅计数模式的搜索结果: 每个文件的匹配数以及总数
+type CountResult struct {
+	Files []FileCount `json:"files"`
+	Total int         `json:"total"`
+	// Truncated 引擎达到计数上限，Total 只是下限 (同时设置 TruncatedHeader)
+	Truncated bool `json:"truncated"`
+}
+
+// 搜索结果数量上限
+const (
+	ZoektMaxMatchCount       = 500    // Zoekt 每个分片的最大匹配数 / 最大展示数
+	ZoektCountMaxMatchCount  = 100000 // 仅计数时 Zoekt 每个分片的最大匹配数，不解码行文本所以可以放得更宽
+	RipgrepMaxMatchesPerFile = 100    // ripgrep 每个文件的最大匹配行数 (-m)
+)
+
+// 内置引擎的名称，与 API 的 engine 参数一致
+const (
+	EngineZoekt   = "zoekt"
+	EngineRipgrep = "ripgrep"
+	EngineScip    = "scip" // 由 analysis.ScipEngine 实现 (按符号名搜索 SCIP 索引)
+)
+
+// Engine 定义了所有搜索引擎都必须实现的接口
 type Engine interface {
-	SearchContent(repo repo.Repository, query string) ([]SearchResult, error)
-	SearchFiles(repo repo.Repository, query string) ([]string, error)
+	// Name 返回引擎名称 (如 EngineZoekt)，调用方据此选择查询语法，而不是断言具体类型
+	Name() string
+	// ctx 为请求的上下文，取消后引擎应尽快停止 (终止子进程、中断 HTTP 请求)
+	SearchContent(ctx context.Context, repo repo.Repository, query string, opts SearchOptions) ([]SearchResult, error)
+	CountContent(ctx context.Context, repo repo.Repository, query string, opts SearchOptions) (*CountResult, error)
+	SearchFiles(ctx context.Context, repo repo.Repository, query string, opts FileSearchOptions) (*FileSearchResult, error)
+	// SyntaxHelp 描述引擎支持的查询语法，由 GET /api/engines/{name}/syntax 返回
+	SyntaxHelp() []SyntaxItem
 }
 
 // =================================================================================
@@ -45,6 +115,9 @@ type ZoektEngine struct {
 	ApiUrl string // 应该是 http://localhost:6070
 }
 
+// Name 实现 Engine 接口；Zoekt 支持 sym: 等查询语法
+func (e *ZoektEngine) Name() string { return EngineZoekt }
+
 // --- Zoekt JSON API 响应结构 (根据您的示例定义) ---
 // (这些结构与 GET /search?format=json 的响应结构一致)
 type ZoektApiSearchResult struct {
@@ -59,6 +132,7 @@ type ZoektFileMatch struct {
 	FileName string       `json:"FileName"`
 	Repo     string       `json:"Repository"`
 	Matches  []ZoektMatch `json:"LineMatches,omitempty"`
+	Score    float64      `json:"Score"` // Zoekt 按此分数降序返回文件
 }
 
 type ZoektMatch struct {
@@ -78,6 +152,7 @@ type ZoektFragment struct {
 type ZoektSearchOptions struct {
 	ShardMaxMatchCount int `json:"ShardMaxMatchCount,omitempty"`
 	MaxMatchDisplayCount int `json:"MaxMatchDisplayCount,omitempty"`
+	MaxDocDisplayCount   int `json:"MaxDocDisplayCount,omitempty"`
 }
 
 type zoektSearchRequest struct {
@@ -88,7 +163,8 @@ type zoektSearchRequest struct {
 
 // --- ZoektEngine 方法实现 (已更新) ---
 
-func (z *ZoektEngine) doZoektRequest(payload any) (*ZoektApiSearchResult, error) {
+// doZoektRequest 发送搜索请求并解析响应；debug 不为 nil 时记录请求体和响应中的统计信息
+func (z *ZoektEngine) doZoektRequest(ctx context.Context, payload any, debug *SearchDebug) (*ZoektApiSearchResult, error) {
 	// 1. 构建 URL
 	searchURL, err := url.Parse(z.ApiUrl)
 	if err != nil {
@@ -103,12 +179,13 @@ func (z *ZoektEngine) doZoektRequest(payload any) (*ZoektApiSearchResult, error)
 	}
 
 	// 3. 添加调试日志
-	log.Printf("DEBUG: 正在向 Zoekt 发送 POST 请求...")
-	log.Printf("DEBUG: URL: %s", searchURL.String())
-	log.Printf("DEBUG: Body: %s", string(body))
+	slog.Debug("正在向 Zoekt 发送 POST 请求", "url", searchURL.String(), "body", string(body))
+	if debug != nil {
+		debug.ZoektRequest = body
+	}
 
 	// 4. 发送 POST 请求
-	req, err := http.NewRequest("POST", searchURL.String(), bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", searchURL.String(), bytes.NewBuffer(body))
 	if err != nil {
 		return nil, fmt.Errorf("创建 Zoekt POST 请求失败: %w", err)
 	}
@@ -116,14 +193,14 @@ func (z *ZoektEngine) doZoektRequest(payload any) (*ZoektApiSearchResult, error)
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		log.Printf("ERROR: 请求 Zoekt API 失败: %v", err)
+		slog.Error("请求 Zoekt API 失败", "err", err)
 		return nil, fmt.Errorf("无法连接到 Zoekt 服务 (%s): %w", searchURL.String(), err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		log.Printf("DEBUG: Zoekt 返回的错误 Body: %s", string(bodyBytes))
+		slog.Debug("Zoekt 返回的错误 Body", "status", resp.StatusCode, "body", string(bodyBytes))
 		return nil, fmt.Errorf("Zoekt 服务返回错误, 状态码: %d", resp.StatusCode)
 	}
 
@@ -133,8 +210,11 @@ func (z *ZoektEngine) doZoektRequest(payload any) (*ZoektApiSearchResult, error)
 	if err != nil {
 		return nil, fmt.Errorf("读取 Zoekt 响应体失败: %w", err)
 	}
+	if debug != nil {
+		debug.ZoektStats = zoektStats(bodyBytes)
+	}
 	if err := json.Unmarshal(bodyBytes, &zoektResp); err != nil {
-		log.Printf("DEBUG: 无法解析的 Zoekt JSON 响应: %s", string(bodyBytes))
+		slog.Debug("无法解析的 Zoekt JSON 响应", "body", string(bodyBytes))
 		return nil, fmt.Errorf("解析 Zoekt JSON 失败: %w", err)
 	}
 
@@ -142,7 +222,7 @@ func (z *ZoektEngine) doZoektRequest(payload any) (*ZoektApiSearchResult, error)
 		// 注意: Zoekt 在没有结果时，可能会返回一个空的 `{"Result": {}}`，这不一定是错误
 		// 但如果连 Result 字段都没有，那一定是格式错了
 		if !strings.Contains(string(bodyBytes), `"Result"`) {
-			log.Printf("DEBUG: Zoekt 响应体中缺少 'Result' 字段: %s", string(bodyBytes))
+			slog.Debug("Zoekt 响应体中缺少 'Result' 字段", "body", string(bodyBytes))
 			return nil, fmt.Errorf("Zoekt 响应格式无效 (缺少 'Result')")
 		}
 		// 否则，只是没有匹配，返回一个空结果
@@ -152,15 +232,15 @@ func (z *ZoektEngine) doZoektRequest(payload any) (*ZoektApiSearchResult, error)
 	return &zoektResp, nil
 }
 
-func (z *ZoektEngine) SearchContent(repo repo.Repository, query string) ([]SearchResult, error) {
+func (z *ZoektEngine) SearchContent(ctx context.Context, repo repo.Repository, query string, opts SearchOptions) ([]SearchResult, error) {
 	// ★★★ 核心改动: 添加 Opts 字段 ★★★
 	payload := zoektSearchRequest{
 		Q:       query,
 		RepoIDs: []uint32{repo.RepoID},
-		Opts:    &ZoektSearchOptions{ShardMaxMatchCount: 500, MaxMatchDisplayCount: 500},
+		Opts:    &ZoektSearchOptions{ShardMaxMatchCount: ZoektMaxMatchCount, MaxMatchDisplayCount: ZoektMaxMatchCount},
 	}
 
-	zoektResp, err := z.doZoektRequest(payload)
+	zoektResp, err := z.doZoektRequest(ctx, payload, opts.Debug)
 	if err != nil {
 		return nil, err
 	}
@@ -179,7 +259,7 @@ func (z *ZoektEngine) SearchContent(repo repo.Repository, query string) ([]Searc
 			// 1. 解码 base64 行文本
 			lineTextBytes, err := base64.StdEncoding.DecodeString(match.Line)
 			if err != nil {
-				log.Printf("WARN: 解码 Zoekt base64 内容失败 (%s): %v", match.Line, err)
+				slog.Warn("解码 Zoekt base64 内容失败", "line", match.Line, "err", err)
 				continue
 			}
 			lineText := string(lineTextBytes)
@@ -199,35 +279,89 @@ func (z *ZoektEngine) SearchContent(repo repo.Repository, query string) ([]Searc
 				LineNum:   match.LineNumber,
 				LineText:  lineText,
 				Fragments: apiFragments,
+				Score:     fileMatch.Score,
 			})
 		}
 	}
 	return results, nil
 }
 
-func (z *ZoektEngine) SearchFiles(repo repo.Repository, query string) ([]string, error) {
-	fileQuery := fmt.Sprintf("f:%s", query)
-	// ★★★ 核心改动: 添加 Opts 字段 ★★★
+// CountContent 统计每个文件的匹配数，只累加片段数量，不解码行文本。
+// 使用 ZoektCountMaxMatchCount 作为上限，总数达到上限时 Truncated 为 true
+func (z *ZoektEngine) CountContent(ctx context.Context, repo repo.Repository, query string, opts SearchOptions) (*CountResult, error) {
 	payload := zoektSearchRequest{
-		Q:       fileQuery,
+		Q:       query,
 		RepoIDs: []uint32{repo.RepoID},
-		Opts:    &ZoektSearchOptions{ShardMaxMatchCount: 500, MaxMatchDisplayCount: 500},
+		Opts:    &ZoektSearchOptions{ShardMaxMatchCount: ZoektCountMaxMatchCount, MaxMatchDisplayCount: ZoektCountMaxMatchCount},
 	}
 
-	zoektResp, err := z.doZoektRequest(payload)
+	zoektResp, err := z.doZoektRequest(ctx, payload, opts.Debug)
 	if err != nil {
 		return nil, err
 	}
+	counts := countZoektMatches(zoektResp.Result.FileMatches)
+	// Zoekt 不报告是否因上限停止，达到上限时按截断处理
+	counts.Truncated = counts.Total >= ZoektCountMaxMatchCount
+	return counts, nil
+}
 
-	var results []string
-	if zoektResp.Result.FileMatches == nil {
-		return results, nil // 没有匹配，返回空列表
+// countZoektMatches 汇总 Zoekt 响应中每个文件的匹配数
+// 一行内可能有多个片段，每个片段计为一次匹配；没有片段信息的行计为一次。
+func countZoektMatches(fileMatches []*ZoektFileMatch) *CountResult {
+	result := &CountResult{Files: []FileCount{}}
+	for _, fileMatch := range fileMatches {
+		count := 0
+		for _, match := range fileMatch.Matches {
+			if n := len(match.LineFragments); n > 0 {
+				count += n
+			} else {
+				count++
+			}
+		}
+		if count == 0 {
+			// 文件名匹配等情况下没有行匹配，仍计为一次
+			count = 1
+		}
+		result.Files = append(result.Files, FileCount{Path: fileMatch.FileName, Count: count})
+		result.Total += count
 	}
+	return result
+}
 
+func (z *ZoektEngine) SearchFiles(ctx context.Context, repo repo.Repository, query string, opts FileSearchOptions) (*FileSearchResult, error) {
+	if query == "" {
+		return NewFileSearchResult(nil, opts.Limit, false), nil
+	}
+	fileQuery, err := zoektFileQuery(query, opts.Mode)
+	if err != nil {
+		return nil, err
+	}
+	fileQuery += zoektIgnoreAtoms(opts.Ignore)
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultMaxFileResults
+	}
+	// 多请求一个文件，用于判断结果是否被截断
+	payload := zoektSearchRequest{
+		Q:       fileQuery,
+		RepoIDs: []uint32{repo.RepoID},
+		Opts: &ZoektSearchOptions{
+			ShardMaxMatchCount:   limit + 1,
+			MaxMatchDisplayCount: limit + 1,
+			MaxDocDisplayCount:   limit + 1,
+		},
+	}
+
+	zoektResp, err := z.doZoektRequest(ctx, payload, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []string
 	for _, f := range zoektResp.Result.FileMatches {
 		results = append(results, f.FileName)
 	}
-	return results, nil
+	return NewFileSearchResult(results, limit, false), nil
 }
 
 // =================================================================================
@@ -236,8 +370,38 @@ func (z *ZoektEngine) SearchFiles(repo repo.Repository, query string) ([]string,
 
 type RipgrepEngine struct{}
 
-func (rg *RipgrepEngine) SearchContent(repo repo.Repository, query string) ([]SearchResult, error) {
-	cmd := exec.Command("rg", "--json", "-i", "-m", "100", query, ".")
+// Name 实现 Engine 接口；查询按正则处理
+func (e *RipgrepEngine) Name() string { return EngineRipgrep }
+
+// rgMessage 对应 `rg --json` 输出的一行消息 (只解析我们关心的字段)
+type rgMessage struct {
+	Type string      `json:"type"`
+	Data rgMatchData `json:"data"`
+}
+
+type rgMatchData struct {
+	Path       struct{ Text string `json:"text"` } `json:"path"`
+	LineNumber uint64                              `json:"line_number"`
+	Lines      struct{ Text string `json:"text"` } `json:"lines"`
+	// ★★★ 核心改动: 捕获 Submatches ★★★
+	Submatches []struct {
+		Match struct{ Text string `json:"text"` } `json:"match"`
+		Start int                                 `json:"start"`
+		End   int                                 `json:"end"`
+	} `json:"submatches"`
+}
+
+func (rg *RipgrepEngine) SearchContent(ctx context.Context, repo repo.Repository, query string, opts SearchOptions) ([]SearchResult, error) {
+	args := ripgrepSearchArgs(query, opts)
+	if opts.Debug != nil {
+		opts.Debug.RipgrepArgs = args
+	}
+	release, err := acquireRipgrep(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	cmd := exec.CommandContext(ctx, "rg", args...)
 	cmd.Dir = repo.SourcePath // 使用正确的字段名
 
 	stdout, err := cmd.StdoutPipe()
@@ -251,47 +415,23 @@ func (rg *RipgrepEngine) SearchContent(repo repo.Repository, query string) ([]Se
 
 	var results []SearchResult
 	scanner := bufio.NewScanner(stdout)
+	// 多行匹配时单条消息可能很长，放宽默认 64KB 的行长度限制
+	scanner.Buffer(make([]byte, 0, 64*1024), 8*1024*1024)
 	for scanner.Scan() {
 		line := scanner.Text()
-		var rgResult struct {
-			Type string `json:"type"`
-			Data struct {
-				Path       struct{ Text string `json:"text"` } `json:"path"`
-				LineNumber uint64                              `json:"line_number"`
-				Lines      struct{ Text string `json:"text"` } `json:"lines"`
-				// ★★★ 核心改动: 捕获 Submatches ★★★
-				Submatches []struct {
-					Start int `json:"start"`
-					End   int `json:"end"`
-				} `json:"submatches"`
-			} `json:"data"`
-		}
+		var rgResult rgMessage
 		if err := json.Unmarshal([]byte(line), &rgResult); err != nil {
-			log.Printf("解析 rg JSON 行失败: %v, 行内容: %s", err, line)
+			slog.Warn("解析 rg JSON 行失败", "err", err, "line", line)
 			continue
 		}
-		if rgResult.Type == "match" {
-			// ★★★ 核心改动: 转换 Submatches ★★★
-			var apiFragments []SearchFragment
-			lineText := strings.TrimSpace(rgResult.Data.Lines.Text)
-			
-			for _, submatch := range rgResult.Data.Submatches {
-				// rg 的 offset 是基于原始行（包含换行符）的，
-				// 而我们 TrimSpace 了。为简单起见，我们假设匹配不在前导/后导空格中。
-				// 更健壮的方法是计算前导空格的长度。
-				// 但对于大多数代码文件，TrimSpace 影响不大。
-				apiFragments = append(apiFragments, SearchFragment{
-					Offset: submatch.Start,
-					Length: submatch.End - submatch.Start,
-				})
-			}
-
-			results = append(results, SearchResult{
-				Path:      filepath.ToSlash(rgResult.Data.Path.Text),
-				LineNum:   int(rgResult.Data.LineNumber),
-				LineText:  lineText,
-				Fragments: apiFragments, // 填充 Fragments
-			})
+		// 只处理 match 消息；begin/end/context/summary 等消息忽略
+		if rgResult.Type != "match" || !matchesExtensions(rgResult.Data.Path.Text, opts) {
+			continue
+		}
+		if opts.Multiline {
+			results = append(results, parseRgMultilineMatch(rgResult.Data)...)
+		} else {
+			results = append(results, parseRgMatch(rgResult.Data))
 		}
 	}
 
@@ -304,44 +444,184 @@ func (rg *RipgrepEngine) SearchContent(repo repo.Repository, query string) ([]Se
 	return results, nil
 }
 
-func (rg *RipgrepEngine) SearchFiles(repo repo.Repository, query string) ([]string, error) {
+// parseRgMatch 将单行模式下的一条 rg match 消息转换为 SearchResult
+func parseRgMatch(data rgMatchData) SearchResult {
+	// ★★★ 核心改动: 转换 Submatches ★★★
+	var apiFragments []SearchFragment
+	// rg 的 offset 基于原始行 (包含前导空白和换行符)，而 LineText 去掉了首尾空白，
+	// 偏移需要减去去掉的前导字节数，并裁剪到去掉空白后的行内
+	raw := data.Lines.Text
+	trimmedLeft := strings.TrimLeftFunc(raw, unicode.IsSpace)
+	lead := len(raw) - len(trimmedLeft)
+	lineText := strings.TrimRightFunc(trimmedLeft, unicode.IsSpace)
+
+	for _, submatch := range data.Submatches {
+		start := min(max(submatch.Start-lead, 0), len(lineText))
+		end := min(max(submatch.End-lead, 0), len(lineText))
+		if start > end || (start == end && submatch.End > submatch.Start) {
+			// 匹配完全落在被去掉的空白中
+			continue
+		}
+		apiFragments = append(apiFragments, SearchFragment{
+			Offset: start,
+			Length: end - start,
+		})
+	}
+
+	return SearchResult{
+		Path:      filepath.ToSlash(data.Path.Text),
+		LineNum:   int(data.LineNumber),
+		LineText:  lineText,
+		Fragments: apiFragments, // 填充 Fragments
+	}
+}
+
+// parseRgMultilineMatch 将多行模式下的一条 rg match 消息拆分为每个匹配一条 SearchResult
+// 多行模式下 lines.text 包含匹配涉及的所有行，line_number 为第一行的行号，
+// submatch 的 start/end 是相对于 lines.text 的字节偏移，可能跨越多行。
+func parseRgMultilineMatch(data rgMatchData) []SearchResult {
+	text := data.Lines.Text
+	path := filepath.ToSlash(data.Path.Text)
+
+	var results []SearchResult
+	for _, submatch := range data.Submatches {
+		start, end := submatch.Start, submatch.End
+		if start < 0 || end > len(text) || start > end {
+			continue
+		}
+
+		// 匹配起始行在 lines.text 中的位置
+		lineStart := strings.LastIndex(text[:start], "\n") + 1
+		// 匹配结束行的行尾 (不包含换行符)
+		lineEnd := len(text)
+		searchFrom := end
+		if end > start && text[end-1] == '\n' {
+			// 匹配以换行结尾时，结束行就是换行所在行
+			searchFrom = end - 1
+		}
+		if idx := strings.Index(text[searchFrom:], "\n"); idx >= 0 {
+			lineEnd = searchFrom + idx
+		}
+
+		startLine := int(data.LineNumber) + strings.Count(text[:start], "\n")
+		endLine := startLine + strings.Count(text[start:searchFrom], "\n")
+
+		matchText := submatch.Match.Text
+		if matchText == "" {
+			matchText = text[start:end]
+		}
+
+		results = append(results, SearchResult{
+			Path:       path,
+			LineNum:    startLine,
+			LineText:   text[lineStart:lineEnd],
+			Fragments:  []SearchFragment{{Offset: start - lineStart, Length: end - start}},
+			EndLineNum: endLine,
+			MatchText:  matchText,
+		})
+	}
+	return results
+}
+
+// CountContent 使用 `rg --count-matches` 统计每个文件的匹配数
+// 注意 rg 不允许 --json 与 --count-matches 同时使用，这里改用 --null 分隔的纯文本输出 (路径\0数量)。
+func (rg *RipgrepEngine) CountContent(ctx context.Context, repo repo.Repository, query string, opts SearchOptions) (*CountResult, error) {
+	args := append([]string{"--count-matches", "--with-filename", "--null"}, ripgrepFilterArgs(opts)...)
+	args = append(append(args, "-e", query), ripgrepPathArgs(opts)...)
+	if opts.Debug != nil {
+		opts.Debug.RipgrepArgs = args
+	}
+	release, err := acquireRipgrep(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	cmd := exec.CommandContext(ctx, "rg", args...)
+	cmd.Dir = repo.SourcePath
+
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+			return &CountResult{Files: []FileCount{}}, nil
+		}
+		return nil, fmt.Errorf("rg --count-matches 执行失败: %w", err)
+	}
+	return filterCountExtensions(parseRgCounts(output), opts), nil
+}
+
+// parseRgCounts 解析 `rg --count-matches --with-filename --null` 的输出
+// 每行格式为 "<path>\x00<count>"
+func parseRgCounts(output []byte) *CountResult {
+	result := &CountResult{Files: []FileCount{}}
+	for _, line := range strings.Split(string(output), "\n") {
+		if line == "" {
+			continue
+		}
+		sep := strings.LastIndexByte(line, 0)
+		if sep < 0 {
+			slog.Warn("解析 rg 计数行失败", "line", line)
+			continue
+		}
+		count, err := strconv.Atoi(strings.TrimSpace(line[sep+1:]))
+		if err != nil {
+			slog.Warn("解析 rg 计数失败", "err", err, "line", line)
+			continue
+		}
+		path := strings.TrimPrefix(filepath.ToSlash(line[:sep]), "./")
+		result.Files = append(result.Files, FileCount{Path: path, Count: count})
+		result.Total += count
+	}
+	sort.Slice(result.Files, func(i, j int) bool { return result.Files[i].Path < result.Files[j].Path })
+	return result
+}
+
+func (rg *RipgrepEngine) SearchFiles(ctx context.Context, repo repo.Repository, query string, opts FileSearchOptions) (*FileSearchResult, error) {
 	if query == "" {
-		return []string{}, nil
+		return NewFileSearchResult(nil, opts.Limit, false), nil
+	}
+	// 与 Zoekt 使用同一个路径正则，在进程内过滤 rg --files 的输出，保证两个引擎语义一致
+	matcher, err := compileFileMatcher(query, opts.Mode)
+	if err != nil {
+		return nil, err
 	}
-	escapedQuery := escapeGlob(query)
-	globPattern := fmt.Sprintf("*%s*", escapedQuery)
 
-	cmd := exec.Command("rg", "--files", "--iglob", globPattern)
+	release, err := acquireRipgrep(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	cmd := exec.CommandContext(ctx, "rg", append([]string{"--files"}, ripgrepIgnoreArgs(opts.Ignore)...)...)
 	cmd.Dir = repo.SourcePath // 使用正确的字段名
 
 	output, err := cmd.Output()
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
-			return []string{}, nil
+			return NewFileSearchResult(nil, opts.Limit, false), nil
 		}
 		return nil, fmt.Errorf("rg --files 执行失败: %w", err)
 	}
 
-	files := strings.Split(strings.TrimSpace(string(output)), "\n")
-	if len(files) == 1 && files[0] == "" {
-		return []string{}, nil
-	}
+	// rg 没有结果数量参数，收集后再截断
+	return NewFileSearchResult(filterFiles(strings.Split(string(output), "\n"), matcher), opts.Limit, false), nil
+}
 
-	results := make([]string, len(files))
-	for i, f := range files {
-		results[i] = filepath.ToSlash(f)
+// filterFiles 规范化 rg --files 输出的路径并保留匹配的文件
+func filterFiles(files []string, matcher *regexp.Regexp) []string {
+	results := []string{}
+	for _, f := range files {
+		f = strings.TrimPrefix(filepath.ToSlash(strings.TrimSpace(f)), "./")
+		if f != "" && matcher.MatchString(f) {
+			results = append(results, f)
+		}
 	}
-	return results, nil
+	return results
 }
 
-// escapeGlob 转义 glob 模式中的特殊字符 (*, ?, [)
-func escapeGlob(pattern string) string {
-	var sb strings.Builder
-	for _, r := range pattern {
-		if r == '*' || r == '?' || r == '[' {
-			sb.WriteRune('\\')
-		}
-		sb.WriteRune(r)
+// zoektFileQuery 构造 Zoekt 的文件名查询: 大小写不敏感的 f: 正则 (加引号以支持空格)
+func zoektFileQuery(query string, mode FileMatchMode) (string, error) {
+	pattern, err := fileQueryRegex(query, mode)
+	if err != nil {
+		return "", err
 	}
-	return sb.String()
+	return fmt.Sprintf("case:no f:%q", pattern), nil
 }
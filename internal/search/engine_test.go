@@ -0,0 +1,94 @@
+package search
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseRgMultilineMatch_SpansLines(t *testing.T) {
+	// rg -U --json 对跨行匹配输出的 match 消息 (lines.text 包含所有涉及的行)
+	line := `{"type":"match","data":{"path":{"text":"./pkg/a.go"},"lines":{"text":"func main() {\n\tfmt.Println(\"hi\")\n}\n"},"line_number":10,"absolute_offset":120,"submatches":[{"match":{"text":"main() {\n\tfmt"},"start":5,"end":18}]}}`
+
+	var msg rgMessage
+	if err := json.Unmarshal([]byte(line), &msg); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	results := parseRgMultilineMatch(msg.Data)
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+	r := results[0]
+	if r.Path != "pkg/a.go" && r.Path != "./pkg/a.go" {
+		t.Fatalf("unexpected path %q", r.Path)
+	}
+	if r.LineNum != 10 || r.EndLineNum != 11 {
+		t.Fatalf("expected lines 10..11, got %d..%d", r.LineNum, r.EndLineNum)
+	}
+	if r.MatchText != "main() {\n\tfmt" {
+		t.Fatalf("unexpected match text %q", r.MatchText)
+	}
+	if r.LineText != "func main() {\n\tfmt.Println(\"hi\")" {
+		t.Fatalf("unexpected line text %q", r.LineText)
+	}
+	frag := r.Fragments[0]
+	if got := r.LineText[frag.Offset : frag.Offset+frag.Length]; got != r.MatchText {
+		t.Fatalf("fragment does not point at match: %q", got)
+	}
+}
+
+func TestParseRgMultilineMatch_MultipleSubmatches(t *testing.T) {
+	line := `{"type":"match","data":{"path":{"text":"b.txt"},"lines":{"text":"foo\nbar\nfoo\nbar\n"},"line_number":3,"submatches":[{"match":{"text":"foo\nbar"},"start":0,"end":7},{"match":{"text":"foo\nbar"},"start":8,"end":15}]}}`
+
+	var msg rgMessage
+	if err := json.Unmarshal([]byte(line), &msg); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	results := parseRgMultilineMatch(msg.Data)
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].LineNum != 3 || results[0].EndLineNum != 4 {
+		t.Fatalf("first match lines: %d..%d", results[0].LineNum, results[0].EndLineNum)
+	}
+	if results[1].LineNum != 5 || results[1].EndLineNum != 6 {
+		t.Fatalf("second match lines: %d..%d", results[1].LineNum, results[1].EndLineNum)
+	}
+	if results[1].Fragments[0].Offset != 0 || results[1].LineText != "foo\nbar" {
+		t.Fatalf("unexpected second match window: %+v", results[1])
+	}
+}
+
+func TestParseRgCounts(t *testing.T) {
+	output := []byte("./b/c.go\x003\n./a.go\x002\n\nbroken line\n")
+
+	result := parseRgCounts(output)
+	if result.Total != 5 {
+		t.Fatalf("expected total 5, got %d", result.Total)
+	}
+	if len(result.Files) != 2 {
+		t.Fatalf("expected 2 files, got %+v", result.Files)
+	}
+	if result.Files[0] != (FileCount{Path: "a.go", Count: 2}) || result.Files[1] != (FileCount{Path: "b/c.go", Count: 3}) {
+		t.Fatalf("unexpected counts: %+v", result.Files)
+	}
+}
+
+func TestCountZoektMatches(t *testing.T) {
+	files := []*ZoektFileMatch{
+		{FileName: "a.go", Matches: []ZoektMatch{
+			{LineNumber: 1, LineFragments: []ZoektFragment{{LineOffset: 0, MatchLength: 3}, {LineOffset: 6, MatchLength: 3}}},
+			{LineNumber: 4, LineFragments: []ZoektFragment{{LineOffset: 2, MatchLength: 3}}},
+		}},
+		{FileName: "b.go", Matches: []ZoektMatch{{LineNumber: 9}}},
+	}
+
+	result := countZoektMatches(files)
+	if result.Total != 4 {
+		t.Fatalf("expected total 4, got %d", result.Total)
+	}
+	if result.Files[0].Count != 3 || result.Files[1].Count != 1 {
+		t.Fatalf("unexpected counts: %+v", result.Files)
+	}
+}
@@ -0,0 +1,182 @@
+package search
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultMaxFileResults 文件名搜索默认返回的最大路径数
+const DefaultMaxFileResults = 1000
+
+// FileMatchMode 文件名搜索的匹配方式，所有引擎语义一致 (均对完整相对路径做大小写不敏感匹配)
+type FileMatchMode string
+
+const (
+	FileModeSubstring FileMatchMode = "substring" // 路径包含查询字符串 (默认)
+	FileModeGlob      FileMatchMode = "glob"      // glob 模式: 不含 / 时匹配任意目录下的文件名，含 / 时匹配整个路径；* 和 ? 不跨目录，** 可跨目录
+	FileModeRegex     FileMatchMode = "regex"     // 正则，在路径任意位置匹配
+)
+
+// FileSearchOptions 文件名搜索选项
+type FileSearchOptions struct {
+	Mode   FileMatchMode
+	Limit  int      // 最多返回的路径数，<= 0 时使用 DefaultMaxFileResults
+	Ignore []string // 排除的路径模式 (Zoekt: -f:，ripgrep: -g !<glob>)
+}
+
+// FileSearchResult 文件名搜索结果: 按字典序排列、去重后的路径，Truncated 表示超出上限被截断
+type FileSearchResult struct {
+	Files     []string `json:"files"`
+	Truncated bool     `json:"truncated"`
+}
+
+// NewFileSearchResult 对引擎返回的路径排序、去重并截断到 limit (供各 Engine 实现构造结果)
+// truncated 为 true 表示引擎自身已经截断了结果 (例如 Zoekt 达到匹配上限)
+func NewFileSearchResult(files []string, limit int, truncated bool) *FileSearchResult {
+	if limit <= 0 {
+		limit = DefaultMaxFileResults
+	}
+	sort.Strings(files)
+	unique := make([]string, 0, len(files))
+	for _, f := range files {
+		if len(unique) == 0 || f != unique[len(unique)-1] {
+			unique = append(unique, f)
+		}
+	}
+	if len(unique) > limit {
+		unique = unique[:limit]
+		truncated = true
+	}
+	return &FileSearchResult{Files: unique, Truncated: truncated}
+}
+
+// FileMatch 文件名搜索的单条结果: 路径以及查询在路径中匹配的位置 (字节偏移)，供前端高亮
+type FileMatch struct {
+	Path        string           `json:"path"`
+	MatchRanges []SearchFragment `json:"matchRanges"`
+}
+
+// FileMatchResult search-files 的默认响应格式 (plain=true 时仍返回 FileSearchResult)
+type FileMatchResult struct {
+	Files     []FileMatch `json:"files"`
+	Truncated bool        `json:"truncated"`
+}
+
+// withMatchRanges 为结果中的每个路径计算 matcher 的匹配位置。
+// 两个引擎使用同一个路径正则 (见 fileQueryRegex)，所以位置与引擎的匹配一致
+func withMatchRanges(result *FileSearchResult, matcher *regexp.Regexp, mode FileMatchMode) *FileMatchResult {
+	files := make([]FileMatch, 0, len(result.Files))
+	for _, path := range result.Files {
+		files = append(files, FileMatch{Path: path, MatchRanges: fileMatchRanges(matcher, path, mode)})
+	}
+	return &FileMatchResult{Files: files, Truncated: result.Truncated}
+}
+
+// fileMatchRanges 返回 matcher 在 path 中的所有非空匹配。glob 模式开头用于锚定目录的 "/" 不计入范围，
+// 使范围正好覆盖匹配的路径片段。没有匹配时 (如 scip 引擎按符号名返回的文件) 返回空数组
+func fileMatchRanges(matcher *regexp.Regexp, path string, mode FileMatchMode) []SearchFragment {
+	ranges := []SearchFragment{}
+	for _, loc := range matcher.FindAllStringIndex(path, -1) {
+		start, end := loc[0], loc[1]
+		if start < end && mode == FileModeGlob && path[start] == '/' {
+			start++
+		}
+		if start < end {
+			ranges = append(ranges, SearchFragment{Offset: start, Length: end - start})
+		}
+	}
+	return ranges
+}
+
+// ParseFileMatchMode 解析 mode 参数，空字符串表示 substring
+func ParseFileMatchMode(s string) (FileMatchMode, error) {
+	switch FileMatchMode(s) {
+	case "", FileModeSubstring:
+		return FileModeSubstring, nil
+	case FileModeGlob, FileModeRegex:
+		return FileMatchMode(s), nil
+	}
+	return "", fmt.Errorf("无效的文件匹配模式: '%s' (可选: substring, glob, regex)", s)
+}
+
+// fileQueryRegex 将查询按 mode 转换为匹配路径的正则 (不含大小写标志)
+// Zoekt 直接把它作为 f: 查询，ripgrep 用它过滤 rg --files 的输出，从而保证两者结果一致
+func fileQueryRegex(query string, mode FileMatchMode) (string, error) {
+	switch mode {
+	case FileModeGlob:
+		return globToRegex(query), nil
+	case FileModeRegex:
+		if _, err := regexp.Compile(query); err != nil {
+			return "", fmt.Errorf("无效的正则表达式: %w", err)
+		}
+		return query, nil
+	default:
+		return regexp.QuoteMeta(query), nil
+	}
+}
+
+// compileFileMatcher 编译大小写不敏感的路径匹配正则
+func compileFileMatcher(query string, mode FileMatchMode) (*regexp.Regexp, error) {
+	pattern, err := fileQueryRegex(query, mode)
+	if err != nil {
+		return nil, err
+	}
+	return regexp.Compile("(?i)" + pattern)
+}
+
+// globToRegex 把 glob 转换为锚定的正则，规则与 .gitignore / rg --glob 一致:
+// 不含 / 的模式匹配任意目录下的文件名；含 / 的模式从仓库根开始匹配 (开头的 / 会被忽略)
+func globToRegex(glob string) string {
+	anchored := strings.Contains(glob, "/")
+	glob = strings.TrimPrefix(glob, "/")
+
+	var sb strings.Builder
+	if anchored {
+		sb.WriteString("^")
+	} else {
+		sb.WriteString("(?:^|/)")
+	}
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				// "**/" 匹配零或多级目录
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					i++
+					sb.WriteString("(?:.*/)?")
+				} else {
+					sb.WriteString(".*")
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				sb.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String()
+}
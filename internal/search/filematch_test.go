@@ -0,0 +1,113 @@
+package search
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var testRepoFiles = []string{
+	"README.md",
+	"cmd/server/main.go",
+	"cmd/cli/main.go",
+	"internal/search/engine.go",
+	"internal/search/engine_test.go",
+	"internal/repo/Provider.go",
+	"web/index.html",
+	"docs/my notes.txt",
+}
+
+// zoektFileMatches 模拟 Zoekt 对 "case:no f:<quoted regex>" 查询的文件名匹配
+func zoektFileMatches(t *testing.T, query string, mode FileMatchMode, files []string) []string {
+	t.Helper()
+	q, err := zoektFileQuery(query, mode)
+	if err != nil {
+		t.Fatalf("zoektFileQuery(%q, %s): %v", query, mode, err)
+	}
+	quoted, ok := strings.CutPrefix(q, "case:no f:")
+	if !ok {
+		t.Fatalf("unexpected zoekt query %q", q)
+	}
+	pattern, err := strconv.Unquote(quoted)
+	if err != nil {
+		t.Fatalf("unquote %q: %v", quoted, err)
+	}
+	re := regexp.MustCompile("(?i)" + pattern)
+	matches := []string{}
+	for _, f := range files {
+		if re.MatchString(f) {
+			matches = append(matches, f)
+		}
+	}
+	return matches
+}
+
+func TestFileSearch_SameResultsAcrossEngines(t *testing.T) {
+	cases := []struct {
+		query string
+		mode  FileMatchMode
+		want  []string
+	}{
+		{"main.go", FileModeSubstring, []string{"cmd/server/main.go", "cmd/cli/main.go"}},
+		{"SERVER/main", FileModeSubstring, []string{"cmd/server/main.go"}},
+		{"my notes", FileModeSubstring, []string{"docs/my notes.txt"}},
+		{"*.go", FileModeGlob, []string{"cmd/server/main.go", "cmd/cli/main.go", "internal/search/engine.go", "internal/search/engine_test.go", "internal/repo/Provider.go"}},
+		{"cmd/*/main.go", FileModeGlob, []string{"cmd/server/main.go", "cmd/cli/main.go"}},
+		{"internal/**/*_test.go", FileModeGlob, []string{"internal/search/engine_test.go"}},
+		{"provider.go", FileModeGlob, []string{"internal/repo/Provider.go"}},
+		{"main", FileModeGlob, []string{}},
+		{`engine(_test)?\.go$`, FileModeRegex, []string{"internal/search/engine.go", "internal/search/engine_test.go"}},
+		{"^web/", FileModeRegex, []string{"web/index.html"}},
+	}
+	for _, c := range cases {
+		matcher, err := compileFileMatcher(c.query, c.mode)
+		if err != nil {
+			t.Fatalf("compileFileMatcher(%q, %s): %v", c.query, c.mode, err)
+		}
+		// ripgrep 输出的路径带 ./ 前缀
+		rgOutput := make([]string, len(testRepoFiles))
+		for i, f := range testRepoFiles {
+			rgOutput[i] = "./" + f
+		}
+		rgResult := filterFiles(rgOutput, matcher)
+		zoektResult := zoektFileMatches(t, c.query, c.mode, testRepoFiles)
+
+		if !reflect.DeepEqual(rgResult, zoektResult) {
+			t.Fatalf("%s %q: engines disagree: ripgrep=%v zoekt=%v", c.mode, c.query, rgResult, zoektResult)
+		}
+		if !reflect.DeepEqual(rgResult, c.want) {
+			t.Fatalf("%s %q: expected %v, got %v", c.mode, c.query, c.want, rgResult)
+		}
+	}
+}
+
+func TestParseFileMatchMode(t *testing.T) {
+	if mode, err := ParseFileMatchMode(""); err != nil || mode != FileModeSubstring {
+		t.Fatalf("empty mode should default to substring, got %q %v", mode, err)
+	}
+	if _, err := ParseFileMatchMode("fuzzy"); err == nil {
+		t.Fatal("expected error for unknown mode")
+	}
+	if _, err := compileFileMatcher("(", FileModeRegex); err == nil {
+		t.Fatal("expected error for invalid regex")
+	}
+}
+
+func TestNewFileSearchResult_SortDedupeTruncate(t *testing.T) {
+	result := NewFileSearchResult([]string{"b.go", "a.go", "c.go", "a.go"}, 2, false)
+	if !reflect.DeepEqual(result.Files, []string{"a.go", "b.go"}) || !result.Truncated {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	result = NewFileSearchResult([]string{"b.go", "a.go", "b.go"}, 2, false)
+	if !reflect.DeepEqual(result.Files, []string{"a.go", "b.go"}) || result.Truncated {
+		t.Fatalf("duplicates should not count towards the limit: %+v", result)
+	}
+
+	result = NewFileSearchResult(nil, 0, false)
+	if result.Files == nil || len(result.Files) != 0 || result.Truncated {
+		t.Fatalf("empty result should have an empty (non-nil) list: %+v", result)
+	}
+}
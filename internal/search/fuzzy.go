@@ -0,0 +1,194 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// fuzzyMaxResults 模糊文件搜索返回结果的上限
+const fuzzyMaxResults = 100
+
+// FuzzyMatch 表示一个模糊匹配的候选路径及其得分
+type FuzzyMatch struct {
+	Path  string `json:"path"`
+	Score int    `json:"score"`
+	// MatchRanges 查询字符在路径中匹配的位置 (字节偏移，相邻字符合并为一段)，格式同 FileMatch.MatchRanges
+	MatchRanges []SearchFragment `json:"matchRanges"`
+
+	positions []int // fuzzyMatch 返回的 rune 下标，只为最终返回的结果转换为 MatchRanges
+}
+
+// 打分参数: 连续匹配和路径片段开头的匹配会获得额外加分，间隔会被扣分
+const (
+	fuzzyScoreMatch       = 16
+	fuzzyBonusConsecutive = 24
+	fuzzyBonusBoundary    = 20
+	fuzzyBonusFirstChar   = 12
+	fuzzyBonusBasename    = 8
+	fuzzyPenaltyGap       = 2
+	fuzzyPenaltyGapMax    = 12
+)
+
+// FuzzyScore 以子序列方式匹配 query 与 candidate (大小写不敏感)。
+// 不是子序列时返回 false；否则返回得分，得分越高越相关。
+func FuzzyScore(query, candidate string) (int, bool) {
+	score, _, ok := fuzzyMatch(query, candidate)
+	return score, ok
+}
+
+// fuzzyMatch 同 FuzzyScore，另外返回计分时匹配的字符位置 (rune 下标，升序)。
+// 采用贪心 + 回溯的方式: 先找到最靠后的起点使匹配最紧凑，再从左向右计分。
+// 逐个字符转换小写，使 lc 与 c 的下标一一对应
+func fuzzyMatch(query, candidate string) (int, []int, bool) {
+	q := []rune(query)
+	for i, r := range q {
+		q[i] = unicode.ToLower(r)
+	}
+	c := []rune(candidate)
+	lc := make([]rune, len(c))
+	for i, r := range c {
+		lc[i] = unicode.ToLower(r)
+	}
+	if len(q) == 0 {
+		return 0, nil, true
+	}
+	if len(q) > len(lc) {
+		return 0, nil, false
+	}
+
+	// 1. 正向扫描确认是子序列，并记录最后一个字符的匹配位置
+	qi := 0
+	end := -1
+	for ci := 0; ci < len(lc) && qi < len(q); ci++ {
+		if lc[ci] == q[qi] {
+			qi++
+			if qi == len(q) {
+				end = ci
+			}
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+
+	// 2. 从匹配结尾反向扫描，找到最靠右的起点 (得到更紧凑的匹配窗口)
+	qi = len(q) - 1
+	start := end
+	for ci := end; ci >= 0 && qi >= 0; ci-- {
+		if lc[ci] == q[qi] {
+			qi--
+			start = ci
+		}
+	}
+
+	// 3. 在 [start, end] 窗口内正向计分
+	baseStart := strings.LastIndex(candidate, "/") + 1
+	baseStart = len([]rune(candidate[:baseStart]))
+
+	score := 0
+	qi = 0
+	lastMatch := -1
+	positions := make([]int, 0, len(q))
+	for ci := start; ci <= end && qi < len(q); ci++ {
+		if lc[ci] != q[qi] {
+			continue
+		}
+		score += fuzzyScoreMatch
+		if lastMatch >= 0 && ci == lastMatch+1 {
+			score += fuzzyBonusConsecutive
+		} else if lastMatch >= 0 {
+			gap := (ci - lastMatch - 1) * fuzzyPenaltyGap
+			if gap > fuzzyPenaltyGapMax {
+				gap = fuzzyPenaltyGapMax
+			}
+			score -= gap
+		}
+		if isBoundary(c, ci) {
+			score += fuzzyBonusBoundary
+			if qi == 0 {
+				score += fuzzyBonusFirstChar
+			}
+		}
+		if ci >= baseStart {
+			score += fuzzyBonusBasename
+		}
+		lastMatch = ci
+		positions = append(positions, ci)
+		qi++
+	}
+
+	// 路径越短越优先 (同分时更贴近用户意图)
+	score -= len(c) / 8
+	return score, positions, true
+}
+
+// fuzzyRanges 把 fuzzyMatch 返回的 rune 下标转换为 candidate 中的字节范围，相邻字符合并为一段
+func fuzzyRanges(candidate string, positions []int) []SearchFragment {
+	ranges := []SearchFragment{}
+	next, runeIdx := 0, 0
+	for offset := range candidate {
+		if next == len(positions) {
+			break
+		}
+		if runeIdx == positions[next] {
+			_, size := utf8.DecodeRuneInString(candidate[offset:])
+			if n := len(ranges); n > 0 && ranges[n-1].Offset+ranges[n-1].Length == offset {
+				ranges[n-1].Length += size
+			} else {
+				ranges = append(ranges, SearchFragment{Offset: offset, Length: size})
+			}
+			next++
+		}
+		runeIdx++
+	}
+	return ranges
+}
+
+// isBoundary 判断位置 i 是否为路径片段/单词的开头
+func isBoundary(c []rune, i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev, cur := c[i-1], c[i]
+	switch prev {
+	case '/', '_', '-', '.', ' ':
+		return true
+	}
+	// camelCase 边界
+	return unicode.IsLower(prev) && unicode.IsUpper(cur)
+}
+
+// FuzzyFind 对候选路径进行模糊匹配并按得分降序返回，最多返回 limit 条
+func FuzzyFind(query string, candidates []string, limit int) []FuzzyMatch {
+	query = strings.ReplaceAll(strings.TrimSpace(query), " ", "")
+	if query == "" {
+		return []FuzzyMatch{}
+	}
+
+	matches := make([]FuzzyMatch, 0)
+	for _, p := range candidates {
+		if score, positions, ok := fuzzyMatch(query, p); ok {
+			matches = append(matches, FuzzyMatch{Path: p, Score: score, positions: positions})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		if len(matches[i].Path) != len(matches[j].Path) {
+			return len(matches[i].Path) < len(matches[j].Path)
+		}
+		return matches[i].Path < matches[j].Path
+	})
+
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	for i := range matches {
+		matches[i].MatchRanges = fuzzyRanges(matches[i].Path, matches[i].positions)
+	}
+	return matches
+}
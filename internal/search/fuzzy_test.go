@@ -0,0 +1,80 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFuzzyFind_TypoQueryMatchesDeepPath(t *testing.T) {
+	files := []string{
+		"README.md",
+		"internal/search/engine.go",
+		"internal/search/handler.go",
+		"internal/repo/handler.go",
+		"web/admin-repo-detail.html",
+		"vendor/github.com/some/pkg/search/internal/handlers/http_handler_test.go",
+	}
+
+	// 漏掉元音的 "srch hndlr" 仍应命中 internal/search/handler.go 并排在第一位
+	matches := FuzzyFind("srchhndlr", files, 10)
+	if len(matches) == 0 {
+		t.Fatalf("expected matches, got none")
+	}
+	if matches[0].Path != "internal/search/handler.go" {
+		t.Fatalf("expected internal/search/handler.go first, got %v", matches)
+	}
+	for _, m := range matches {
+		if m.Path == "README.md" {
+			t.Fatalf("README.md should not match query 'srchhndlr'")
+		}
+	}
+}
+
+func TestFuzzyFind_RanksBasenameAndCapsResults(t *testing.T) {
+	files := []string{
+		"cmd/server/main.go",
+		"docs/maintenance/notes.md",
+		"internal/analysis/types.go",
+	}
+
+	matches := FuzzyFind("main", files, 1)
+	if len(matches) != 1 {
+		t.Fatalf("expected results capped to 1, got %d", len(matches))
+	}
+	if matches[0].Path != "cmd/server/main.go" {
+		t.Fatalf("expected basename match first, got %s", matches[0].Path)
+	}
+}
+
+func TestFuzzyScore_NotSubsequence(t *testing.T) {
+	if _, ok := FuzzyScore("xyz", "internal/search/handler.go"); ok {
+		t.Fatalf("expected no match for non-subsequence query")
+	}
+	if _, ok := FuzzyScore("", "a.go"); !ok {
+		t.Fatalf("empty query should match everything")
+	}
+}
+
+func TestFuzzyFind_MatchRanges(t *testing.T) {
+	// 相邻字符合并为一段，范围是字节偏移 (多字节字符也按字节计算)
+	matches := FuzzyFind("srchhndlr", []string{"internal/search/handler.go"}, 10)
+	if len(matches) != 1 {
+		t.Fatalf("expected one match, got %v", matches)
+	}
+	var texts []string
+	for _, r := range matches[0].MatchRanges {
+		texts = append(texts, matches[0].Path[r.Offset:r.Offset+r.Length])
+	}
+	if got := strings.Join(texts, ","); got != "s,rch,h,ndl,r" {
+		t.Fatalf("matched %q", got)
+	}
+
+	matches = FuzzyFind("文档md", []string{"docs/中文文档.md"}, 10)
+	if len(matches) != 1 {
+		t.Fatalf("expected one match, got %v", matches)
+	}
+	path, r := matches[0].Path, matches[0].MatchRanges
+	if len(r) != 2 || path[r[0].Offset:r[0].Offset+r[0].Length] != "文档" || path[r[1].Offset:r[1].Offset+r[1].Length] != "md" {
+		t.Fatalf("multibyte ranges = %+v", r)
+	}
+}
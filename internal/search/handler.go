@@ -2,11 +2,14 @@ package search
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
+	"sort"
 	"strconv" // Needed for parsing uint32 repoID
 
+	"code-browser/internal/core"
+	"code-browser/internal/logging"
 	"code-browser/internal/repo"
 	"github.com/patrickmn/go-cache"
 )
@@ -14,8 +17,31 @@ import (
 // Handlers 封装了所有与搜索相关的 HTTP 处理器
 type Handlers struct {
 	Engines      map[string]Engine // 搜索引擎实例映射
-	RepoProvider *repo.Provider    // 仓库服务实例，用于获取仓库信息
+	RepoProvider repo.RepoProvider // 仓库服务实例，用于获取仓库信息
+	CoreService  *core.Service     // 核心服务，提供仓库完整文件列表 (模糊搜索使用)
 	Cache        *cache.Cache      // 缓存实例
+	// MaxQueryLength 查询允许的最大字符数，超出返回 400 (0 表示使用 DefaultMaxQueryLength)
+	MaxQueryLength int
+	// MaxFileResults 文件名搜索最多返回的路径数 (0 表示使用 DefaultMaxFileResults)
+	MaxFileResults int
+	// SnippetContext 超长行截断时首个匹配两侧各保留的字节数 (0 表示使用 DefaultSnippetContext，负数表示不截断)
+	SnippetContext int
+	// DefaultEngine 请求未指定 engine 时使用的引擎 (为空时使用 EngineZoekt)
+	DefaultEngine string
+	// SearchIgnore 内容和文件名搜索默认排除的路径模式 (见 DefaultSearchIgnore)，仓库的 searchIgnore 设置可以替换它；
+	// 请求带 includeIgnored=true 时不排除
+	SearchIgnore []string
+	// MaxResponseBytes 内容搜索结果的估算大小上限，超出时截断并设置 TruncatedHeader
+	// (0 表示使用 DefaultMaxResponseBytes，负数表示不限制)
+	MaxResponseBytes int
+	// AdminToken 管理 Token，内容搜索的 debug=true 需要携带 (为空时不鉴权，与管理 API 一致)
+	AdminToken string
+}
+
+// contentCacheEntry 内容搜索的缓存值，记录结果是否因大小上限被截断，缓存命中时同样设置响应头
+type contentCacheEntry struct {
+	results   any
+	truncated bool
 }
 
 // parseRepoIDHelper 从请求路径中解析 uint32 仓库 ID (辅助函数)
@@ -36,50 +62,199 @@ func (h *Handlers) SearchContent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	query := r.URL.Query().Get("q")
-	engineName := r.URL.Query().Get("engine")
+	engineName, engine, err := h.resolveEngine(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// debug=true 返回发给引擎的查询和引擎的统计信息，只对管理员开放
+	debug := r.URL.Query().Get("debug") == "true"
+	if debug && !h.isAdmin(r) {
+		http.Error(w, "Unauthorized: debug requires the admin token", http.StatusUnauthorized)
+		return
+	}
 
 	if query == "" {
 		http.Error(w, "Query parameter 'q' is required", http.StatusBadRequest)
 		return
 	}
-
-	// 为 SearchContent 添加缓存
-	cacheKey := fmt.Sprintf("search:content:%s:%d:%s", engineName, repoID, query)
-	if data, found := h.Cache.Get(cacheKey); found {
-		log.Printf("DEBUG: 缓存命中 (search-content): %s", cacheKey)
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(data)
+	if err := ValidateQuery(query, h.MaxQueryLength); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 把统一查询语法 (path:、lang:、case:、word:) 转换为引擎的原生查询
+	parsed, err := ParseQuery(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	nativeQuery, opts := TranslateQuery(parsed, engineName)
+	opts.Multiline = r.URL.Query().Get("multiline") == "true"
+	// textOnly 默认为 true: 跳过二进制文件 (rg 默认行为 + 按扩展名过滤)；为 false 时 rg 使用 --text 搜索所有文件
+	textOnly := r.URL.Query().Get("textOnly") != "false"
+	opts.Text = !textOnly
+	sortOrder, err := ParseSortOrder(r.URL.Query().Get("sort"), engineName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if nativeQuery == "" {
+		http.Error(w, "Query has no search terms", http.StatusBadRequest)
 		return
 	}
+	// ripgrep 直接把查询作为正则执行，启动子进程前拒绝危险的正则
+	if engineName == EngineRipgrep {
+		if err := ValidateRegex(nativeQuery); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
 
-	engine, ok := h.Engines[engineName]
+	repoInfo, ok := h.searchableRepo(w, r, repoID)
 	if !ok {
-		http.Error(w, fmt.Sprintf("Invalid search engine: %s. Available: %v", engineName, getMapKeys(h.Engines)), http.StatusBadRequest)
 		return
 	}
+	settings := h.repoSettings(repoID)
+	ignore, includeIgnored, err := h.searchIgnore(r, settings)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	nativeQuery, opts = applySearchIgnore(engineName, nativeQuery, opts, ignore)
 
-	repoInfo, ok := h.RepoProvider.GetRepo(repoID)
-	if !ok {
-		http.Error(w, fmt.Sprintf("仓库 ID '%d' 未找到", repoID), http.StatusNotFound)
+	// countOnly=true 时只返回每个文件的匹配数，不返回行内容
+	countOnly := r.URL.Query().Get("countOnly") == "true"
+	// includeIndex=true 时返回 {results, index}，附带扁平的匹配导航索引
+	includeIndex := !countOnly && r.URL.Query().Get("includeIndex") == "true"
+	mode := "lines"
+	if countOnly {
+		mode = "count"
+	} else if includeIndex {
+		mode = "indexed"
+	}
+
+	// 为 SearchContent 添加缓存 (key 中包含返回模式，避免两种结构互相覆盖；
+	// 包含索引代数，重新索引后旧的缓存条目不再命中，等待过期即可)
+	// changedSince=<rev> 只搜索 rev 与 HEAD 之间变化的文件 (代码评审场景)
+	// 缓存键使用解析后的提交哈希: 分支或 HEAD~3 这类表达式移动后不再命中旧结果
+	changedSince := r.URL.Query().Get("changedSince")
+	var changedBase, changedHead, changedKey string
+	if changedSince != "" {
+		changedBase, changedHead, err = repo.ResolveChangedSince(repoInfo, changedSince)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, repo.ErrRevisionNotFound) {
+				status = http.StatusBadRequest
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		changedKey = changedBase + ".." + changedHead
+	}
+	cacheKey := fmt.Sprintf("search:content:%s:%s:%d:g%d:%t:%t:%t:%s:%s:%s", mode, engineName, repoID, h.RepoProvider.IndexGeneration(repoID), opts.Multiline, textOnly, includeIgnored, sortOrder, changedKey, query)
+	// debug 需要实际请求引擎，不读缓存
+	if data, found := h.Cache.Get(cacheKey); found && !debug {
+		logging.FromContext(r.Context()).Debug("缓存命中 (search-content)", "key", cacheKey)
+		entry := data.(contentCacheEntry)
+		if entry.truncated {
+			w.Header().Set(TruncatedHeader, "true")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entry.results)
 		return
 	}
 
-	results, err := engine.SearchContent(repoInfo, query)
+	// 隐藏路径始终去掉；textOnly 时所有引擎都按扩展名去掉二进制文件 (Zoekt 没有 rg 的二进制检测)；
+	// 排除列表已交给引擎处理，这里再过滤一次，覆盖不支持排除条件的引擎
+	excluded := excludedBy(settings.IsHidden, ignoredBy(ignore))
+	if textOnly {
+		excluded = excludedBy(settings.IsHidden, ignoredBy(ignore), core.IsBinaryPath)
+	}
+	hasFiles := true
+	if changedSince != "" {
+		changed, err := repo.ChangedFilesBetween(repoInfo, changedBase, changedHead)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		nativeQuery, opts, hasFiles = applyChangedFiles(engineName, nativeQuery, opts, changed)
+		excluded = excludedBy(excluded, notIn(changed))
+	}
+	if debug {
+		opts.Debug = &SearchDebug{Engine: engineName, Query: query, NativeQuery: nativeQuery}
+	}
+	var results any
+	truncated := false
+	if countOnly {
+		counts := &CountResult{Files: []FileCount{}}
+		if hasFiles {
+			counts, err = engine.CountContent(r.Context(), repoInfo, nativeQuery, opts)
+		}
+		if counts != nil {
+			counts = filterCounts(counts, excluded)
+			truncated = counts.Truncated
+		}
+		results = counts
+	} else {
+		lines := []SearchResult{}
+		engineTruncated := false
+		opts.Truncated = &engineTruncated
+		if hasFiles {
+			lines, err = engine.SearchContent(r.Context(), repoInfo, nativeQuery, opts)
+		}
+		lines = filterResults(lines, excluded)
+		SortResults(lines, sortOrder)
+		TrimLongLines(lines, h.snippetContext())
+		lines, truncated = LimitResponseSize(lines, h.maxResponseBytes())
+		truncated = truncated || engineTruncated
+		results = lines
+		if includeIndex {
+			if lines == nil {
+				lines = []SearchResult{}
+			}
+			results = IndexedSearchResponse{Results: lines, Index: BuildMatchIndex(lines), Truncated: truncated}
+		}
+	}
 	if err != nil {
-		log.Printf("内容搜索失败 (engine: %s, repo: %d): %v", engineName, repoID, err)
+		if writeBusy(w, err) {
+			return
+		}
+		logging.FromContext(r.Context()).Error("内容搜索失败", "engine", engineName, "repo", repoID, "err", err)
 		http.Error(w, fmt.Sprintf("Search failed: %v", err), http.StatusInternalServerError)
 		return
 	}
 
 	// 缓存结果
-	h.Cache.Set(cacheKey, results, cache.DefaultExpiration)
+	h.Cache.Set(cacheKey, contentCacheEntry{results: results, truncated: truncated}, cache.DefaultExpiration)
 
+	if truncated {
+		logging.FromContext(r.Context()).Warn("搜索结果超出响应大小或引擎的结果数上限，已截断", "engine", engineName, "repo", repoID, "query", query)
+		w.Header().Set(TruncatedHeader, "true")
+	}
+	if debug {
+		results = DebugSearchResponse{Results: results, Debug: opts.Debug}
+	}
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(results); err != nil {
-		log.Printf("序列化搜索结果失败: %v", err)
+		logging.FromContext(r.Context()).Error("序列化搜索结果失败", "err", err)
 	}
 }
 
+// snippetContext 返回超长行截断的窗口大小，<= 0 表示不截断
+func (h *Handlers) snippetContext() int {
+	if h.SnippetContext == 0 {
+		return DefaultSnippetContext
+	}
+	return h.SnippetContext
+}
+
+func (h *Handlers) maxResponseBytes() int {
+	if h.MaxResponseBytes == 0 {
+		return DefaultMaxResponseBytes
+	}
+	return h.MaxResponseBytes
+}
+
 // SearchFiles 处理文件名搜索请求
 func (h *Handlers) SearchFiles(w http.ResponseWriter, r *http.Request) {
 	repoID, err := parseRepoIDHelper(r)
@@ -88,55 +263,239 @@ func (h *Handlers) SearchFiles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	query := r.URL.Query().Get("q")
-	engineName := r.URL.Query().Get("engine")
-
-	if engineName == "" {
-		engineName = "zoekt" // Default to zoekt if no engine specified
+	engineName, engine, err := h.resolveEngine(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-
-	// 为 SearchFiles 添加缓存
-	cacheKey := fmt.Sprintf("search:files:%s:%d:%s", engineName, repoID, query)
-	if data, found := h.Cache.Get(cacheKey); found {
-		log.Printf("DEBUG: 缓存命中 (search-files): %s", cacheKey)
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(data)
+	if err := ValidateQuery(query, h.MaxQueryLength); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	engine, ok := h.Engines[engineName]
+	repoInfo, ok := h.searchableRepo(w, r, repoID)
 	if !ok {
-		http.Error(w, fmt.Sprintf("Invalid search engine: %s. Available: %v", engineName, getMapKeys(h.Engines)), http.StatusBadRequest)
 		return
 	}
 
-	repoInfo, ok := h.RepoProvider.GetRepo(repoID)
-	if !ok {
-		http.Error(w, fmt.Sprintf("仓库 ID '%d' 未找到", repoID), http.StatusNotFound)
+	limit, err := h.fileResultLimit(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	settings := h.repoSettings(repoID)
+	ignore, includeIgnored, err := h.searchIgnore(r, settings)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	results, err := engine.SearchFiles(repoInfo, query)
+	// 模糊匹配基于仓库文件列表，与搜索引擎无关
+	if r.URL.Query().Get("fuzzy") == "true" {
+		h.searchFilesFuzzy(w, r, repoID, query, limit, ignore, includeIgnored)
+		return
+	}
+
+	mode, err := ParseFileMatchMode(r.URL.Query().Get("mode"))
 	if err != nil {
-		log.Printf("文件名搜索失败 (engine: %s, repo: %d): %v", engineName, repoID, err)
-		http.Error(w, fmt.Sprintf("File search failed: %v", err), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if mode == FileModeRegex {
+		if err := ValidateRegex(query); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	matcher, err := compileFileMatcher(query, mode)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// 缓存结果
-	h.Cache.Set(cacheKey, results, cache.DefaultExpiration)
+	// 为 SearchFiles 添加缓存 (缓存引擎返回的路径，匹配位置在输出时计算)
+	var results *FileSearchResult
+	cacheKey := fmt.Sprintf("search:files:%s:%s:%d:%d:g%d:%t:%s", mode, engineName, limit, repoID, h.RepoProvider.IndexGeneration(repoID), includeIgnored, query)
+	if data, found := h.Cache.Get(cacheKey); found {
+		logging.FromContext(r.Context()).Debug("缓存命中 (search-files)", "key", cacheKey)
+		results, _ = data.(*FileSearchResult)
+	} else {
+		results, err = engine.SearchFiles(r.Context(), repoInfo, query, FileSearchOptions{Mode: mode, Limit: limit, Ignore: ignore})
+		if err != nil {
+			if writeBusy(w, err) {
+				return
+			}
+			logging.FromContext(r.Context()).Error("文件名搜索失败", "engine", engineName, "repo", repoID, "err", err)
+			http.Error(w, fmt.Sprintf("File search failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if results != nil {
+			results.Files = filterIgnoredFiles(settings.FilterHidden(results.Files), ignore)
+		}
 
+		// 缓存结果
+		h.Cache.Set(cacheKey, results, cache.DefaultExpiration)
+	}
+
+	// 默认返回每个路径的匹配位置；plain=true 保持旧的纯路径数组
+	var resp any = results
+	if results != nil && r.URL.Query().Get("plain") != "true" {
+		resp = withMatchRanges(results, matcher, mode)
+	}
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(results); err != nil {
-		log.Printf("序列化文件结果失败: %v", err)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).Error("序列化文件结果失败", "err", err)
+	}
+}
+
+// fileResultLimit 解析可选的 limit 参数，不能超过 MaxFileResults
+func (h *Handlers) fileResultLimit(r *http.Request) (int, error) {
+	maxResults := h.MaxFileResults
+	if maxResults <= 0 {
+		maxResults = DefaultMaxFileResults
+	}
+	s := r.URL.Query().Get("limit")
+	if s == "" {
+		return maxResults, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("无效的 limit: '%s'", s)
+	}
+	return min(n, maxResults), nil
+}
+
+// searchFilesFuzzy 在仓库的完整文件列表上执行模糊匹配，按得分排序返回路径及匹配的字符位置；
+// plain=true 时与普通模式一样只返回路径
+func (h *Handlers) searchFilesFuzzy(w http.ResponseWriter, r *http.Request, repoID uint32, query string, limit int, ignore []string, includeIgnored bool) {
+	limit = min(limit, fuzzyMaxResults)
+	var results *FileMatchResult
+	cacheKey := fmt.Sprintf("search:files:fuzzy:%d:%d:g%d:%t:%s", limit, repoID, h.RepoProvider.IndexGeneration(repoID), includeIgnored, query)
+	if data, found := h.Cache.Get(cacheKey); found {
+		logging.FromContext(r.Context()).Debug("缓存命中 (search-files-fuzzy)", "key", cacheKey)
+		results = data.(*FileMatchResult)
+	} else {
+		files, err := h.CoreService.ListAllFiles(r.Context(), repoID)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("获取文件列表失败", "repo", repoID, "err", err)
+			http.Error(w, fmt.Sprintf("File search failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		// 多取一个用于判断是否截断；模糊结果按相关度排序，不做字典序排序
+		matches := FuzzyFind(query, filterIgnoredFiles(files, ignore), limit+1)
+		results = &FileMatchResult{Files: make([]FileMatch, 0, len(matches))}
+		for _, m := range matches {
+			if len(results.Files) == limit {
+				results.Truncated = true
+				break
+			}
+			results.Files = append(results.Files, FileMatch{Path: m.Path, MatchRanges: m.MatchRanges})
+		}
+		h.Cache.Set(cacheKey, results, cache.DefaultExpiration)
+	}
+
+	var resp any = results
+	if r.URL.Query().Get("plain") == "true" {
+		plain := &FileSearchResult{Files: make([]string, 0, len(results.Files)), Truncated: results.Truncated}
+		for _, f := range results.Files {
+			plain.Files = append(plain.Files, f.Path)
+		}
+		resp = plain
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).Error("序列化文件结果失败", "err", err)
+	}
+}
+
+// writeBusy 搜索进程名额已满 (ErrSearchBusy) 时返回 503 并提示客户端稍后重试
+func writeBusy(w http.ResponseWriter, err error) bool {
+	if !errors.Is(err, ErrSearchBusy) {
+		return false
+	}
+	w.Header().Set("Retry-After", "1")
+	http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	return true
+}
+
+// repoSettings 返回仓库的 .code-browser.json 设置，未配置 CoreService 时为空设置
+func (h *Handlers) repoSettings(repoID uint32) core.RepoSettings {
+	if h.CoreService == nil {
+		return core.RepoSettings{}
+	}
+	return h.CoreService.GetRepoSettings(repoID)
+}
+
+// filterResults 去掉 excluded 为 true 的路径 (仓库设置中的隐藏路径、textOnly 时的二进制文件) 下的搜索结果
+func filterResults(results []SearchResult, excluded func(path string) bool) []SearchResult {
+	if results == nil {
+		return nil
+	}
+	visible := make([]SearchResult, 0, len(results))
+	for _, r := range results {
+		if !excluded(r.Path) {
+			visible = append(visible, r)
+		}
+	}
+	return visible
+}
+
+// filterCounts 去掉 excluded 路径的计数并重新计算总数
+func filterCounts(counts *CountResult, excluded func(path string) bool) *CountResult {
+	filtered := &CountResult{Files: make([]FileCount, 0, len(counts.Files)), Truncated: counts.Truncated}
+	for _, f := range counts.Files {
+		if !excluded(f.Path) {
+			filtered.Files = append(filtered.Files, f)
+			filtered.Total += f.Count
+		}
+	}
+	return filtered
+}
+
+// searchableRepo 查找可搜索的仓库；已归档的仓库默认不参与搜索 (除非 includeArchived=true)，已停用的仓库不能搜索
+// 查找失败时直接写入 404 (停用时为 403) 响应并返回 false
+func (h *Handlers) searchableRepo(w http.ResponseWriter, r *http.Request, repoID uint32) (repo.Repository, bool) {
+	repoInfo, ok := h.RepoProvider.GetRepo(repoID)
+	if !ok {
+		http.Error(w, fmt.Sprintf("仓库 ID '%d' 未找到", repoID), http.StatusNotFound)
+		return repo.Repository{}, false
+	}
+	if repoInfo.Archived && r.URL.Query().Get("includeArchived") != "true" {
+		http.Error(w, fmt.Sprintf("仓库 ID '%d' 已归档", repoID), http.StatusNotFound)
+		return repo.Repository{}, false
+	}
+	if err := repoInfo.CheckEnabled(); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return repo.Repository{}, false
+	}
+	return repoInfo, true
+}
+
+// resolveEngine 返回请求的 engine 参数对应的引擎，未指定时使用 DefaultEngine；
+// 未注册的引擎返回列出可用引擎的错误 (两个搜索接口都返回 400)
+func (h *Handlers) resolveEngine(r *http.Request) (string, Engine, error) {
+	name := r.URL.Query().Get("engine")
+	if name == "" {
+		name = h.DefaultEngine
+		if name == "" {
+			name = EngineZoekt
+		}
+	}
+	engine, ok := h.Engines[name]
+	if !ok {
+		return "", nil, fmt.Errorf("Invalid search engine: %s. Available: %v", name, getMapKeys(h.Engines))
 	}
+	return name, engine, nil
 }
 
-// getMapKeys 辅助函数，获取 map 的键
+// getMapKeys 辅助函数，获取 map 的键 (排序后返回，保证错误信息稳定)
 func getMapKeys(m map[string]Engine) []string {
 	keys := make([]string, 0, len(m))
 	for k := range m {
 		keys = append(keys, k)
 	}
+	sort.Strings(keys)
 	return keys
 }
 
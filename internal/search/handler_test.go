@@ -0,0 +1,823 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"code-browser/internal/core"
+	"code-browser/internal/repo"
+	"code-browser/internal/repo/repotest"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/patrickmn/go-cache"
+)
+
+// stubEngine 返回固定结果并记录调用次数
+type stubEngine struct {
+	calls   atomic.Int32 // SearchAll 在多个 goroutine 中调用引擎
+	results []SearchResult
+	counts  *CountResult
+	capped  bool // SearchContent 报告引擎截断了结果
+}
+
+func (e *stubEngine) Name() string { return "stub" }
+
+func (e *stubEngine) SearchContent(ctx context.Context, r repo.Repository, query string, opts SearchOptions) ([]SearchResult, error) {
+	e.calls.Add(1)
+	if e.capped && opts.Truncated != nil {
+		*opts.Truncated = true
+	}
+	if e.results != nil {
+		return append([]SearchResult(nil), e.results...), nil
+	}
+	return []SearchResult{}, nil
+}
+
+func (e *stubEngine) CountContent(ctx context.Context, r repo.Repository, query string, opts SearchOptions) (*CountResult, error) {
+	e.calls.Add(1)
+	if e.counts != nil {
+		c := *e.counts
+		return &c, nil
+	}
+	return &CountResult{}, nil
+}
+
+func (e *stubEngine) SearchFiles(ctx context.Context, r repo.Repository, query string, opts FileSearchOptions) (*FileSearchResult, error) {
+	e.calls.Add(1)
+	return NewFileSearchResult(nil, opts.Limit, false), nil
+}
+
+func (e *stubEngine) SyntaxHelp() []SyntaxItem { return nil }
+
+func TestSearchContent_RepoLookup(t *testing.T) {
+	provider := repotest.New(
+		repo.Repository{RepoID: 1, Name: "active"},
+		repo.Repository{RepoID: 2, Name: "old", Archived: true},
+		repo.Repository{RepoID: 4, Name: "frozen", Disabled: true},
+	)
+	engine := &stubEngine{}
+	h := &Handlers{
+		Engines:      map[string]Engine{"zoekt": engine},
+		RepoProvider: provider,
+		Cache:        cache.New(time.Minute, time.Minute),
+	}
+
+	for _, tc := range []struct {
+		id, query string
+		want      int
+	}{
+		{"1", "", http.StatusOK},
+		{"2", "", http.StatusNotFound},
+		{"2", "&includeArchived=true", http.StatusOK},
+		{"3", "", http.StatusNotFound},
+		{"4", "", http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/repositories/"+tc.id+"/search?engine=zoekt&q=foo"+tc.query, nil)
+		req.SetPathValue("id", tc.id)
+		rec := httptest.NewRecorder()
+		h.SearchContent(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("repo %s%s: status = %d, want %d (%s)", tc.id, tc.query, rec.Code, tc.want, rec.Body.String())
+		}
+	}
+	if engine.calls.Load() != 2 {
+		t.Errorf("engine called %d times, want 2", engine.calls.Load())
+	}
+}
+
+func TestEngineSyntax(t *testing.T) {
+	h := &Handlers{Engines: map[string]Engine{
+		EngineZoekt:   &ZoektEngine{},
+		EngineRipgrep: &RipgrepEngine{},
+		"stub":        &stubEngine{},
+	}}
+	tests := []struct {
+		engine   string
+		status   int
+		contains string
+	}{
+		{EngineZoekt, http.StatusOK, `"example":"sym:NewService"`},
+		{EngineRipgrep, http.StatusOK, `"engine":"ripgrep"`},
+		{"stub", http.StatusOK, `"items":[]`},
+		{"nope", http.StatusNotFound, "Unknown search engine"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/engines/"+tt.engine+"/syntax", nil)
+		req.SetPathValue("name", tt.engine)
+		rec := httptest.NewRecorder()
+		h.EngineSyntax(rec, req)
+		if rec.Code != tt.status || !strings.Contains(rec.Body.String(), tt.contains) {
+			t.Errorf("%s: status = %d, body = %q", tt.engine, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestSearchContent_IncludeIndex(t *testing.T) {
+	engine := &stubEngine{results: []SearchResult{
+		{Path: "a.go", LineNum: 3, LineText: "foo foo", Fragments: []SearchFragment{{Offset: 0, Length: 3}, {Offset: 4, Length: 3}}},
+		{Path: "b.go", LineNum: 1, LineText: "foo", Fragments: []SearchFragment{{Offset: 0, Length: 3}}},
+	}}
+	h := &Handlers{
+		Engines:      map[string]Engine{"zoekt": engine},
+		RepoProvider: repotest.New(repo.Repository{RepoID: 1, Name: "active"}),
+		Cache:        cache.New(time.Minute, time.Minute),
+	}
+	search := func(extra string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/repositories/1/search?engine=zoekt&q=foo"+extra, nil)
+		req.SetPathValue("id", "1")
+		rec := httptest.NewRecorder()
+		h.SearchContent(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d (%s)", extra, rec.Code, rec.Body.String())
+		}
+		return rec
+	}
+
+	var plain []SearchResult
+	if err := json.Unmarshal(search("").Body.Bytes(), &plain); err != nil || len(plain) != 2 {
+		t.Fatalf("without includeIndex the response should stay a plain array: %v, %s", err, search("").Body.String())
+	}
+
+	var indexed IndexedSearchResponse
+	if err := json.Unmarshal(search("&includeIndex=true").Body.Bytes(), &indexed); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := []MatchRef{{"a.go", 3, 0}, {"a.go", 3, 4}, {"b.go", 1, 0}}
+	if len(indexed.Results) != 2 || !slices.Equal(indexed.Index, want) {
+		t.Fatalf("indexed response = %+v, want index %+v", indexed, want)
+	}
+}
+
+func TestHandlers_EngineSelection(t *testing.T) {
+	zoekt, rg := &stubEngine{}, &stubEngine{}
+	h := &Handlers{
+		Engines:       map[string]Engine{EngineZoekt: zoekt, EngineRipgrep: rg},
+		RepoProvider:  repotest.New(repo.Repository{RepoID: 1, Name: "active"}),
+		Cache:         cache.New(time.Minute, time.Minute),
+		DefaultEngine: EngineRipgrep,
+	}
+	endpoints := map[string]http.HandlerFunc{"search": h.SearchContent, "search-files": h.SearchFiles}
+	for name, handle := range endpoints {
+		for _, tc := range []struct {
+			engine string
+			status int
+			called *stubEngine
+		}{
+			{"", http.StatusOK, rg}, // 未指定时使用 DefaultEngine
+			{EngineZoekt, http.StatusOK, zoekt},
+			{"bogus", http.StatusBadRequest, nil},
+		} {
+			zoekt.calls.Store(0)
+			rg.calls.Store(0)
+			url := "/api/repositories/1/" + name + "?q=foo"
+			if tc.engine != "" {
+				url = "/api/repositories/1/" + name + "?q=foo&engine=" + tc.engine
+			}
+			req := httptest.NewRequest(http.MethodGet, url, nil)
+			req.SetPathValue("id", "1")
+			rec := httptest.NewRecorder()
+			handle(rec, req)
+			if rec.Code != tc.status {
+				t.Errorf("%s engine=%q: status = %d, want %d (%s)", name, tc.engine, rec.Code, tc.status, rec.Body.String())
+				continue
+			}
+			if tc.called == nil {
+				if want := "Invalid search engine: bogus. Available: [ripgrep zoekt]"; !strings.Contains(rec.Body.String(), want) {
+					t.Errorf("%s: body = %q, want %q", name, rec.Body.String(), want)
+				}
+				if zoekt.calls.Load()+rg.calls.Load() != 0 {
+					t.Errorf("%s: no engine should run for an invalid engine", name)
+				}
+			} else if tc.called.calls.Load() != 1 || zoekt.calls.Load()+rg.calls.Load() != 1 {
+				t.Errorf("%s engine=%q: wrong engine called (zoekt %d, ripgrep %d)", name, tc.engine, zoekt.calls.Load(), rg.calls.Load())
+			}
+		}
+	}
+}
+
+func TestSearchContent_TextOnly(t *testing.T) {
+	engine := &stubEngine{results: []SearchResult{
+		{Path: "main.go", LineNum: 1, LineText: "foo"},
+		{Path: "assets/logo.PNG", LineNum: 1, LineText: "foo"},
+		{Path: "dist/app.wasm", LineNum: 1, LineText: "foo"},
+	}}
+	h := &Handlers{
+		Engines:      map[string]Engine{EngineZoekt: engine},
+		RepoProvider: repotest.New(repo.Repository{RepoID: 1, Name: "active"}),
+		Cache:        cache.New(time.Minute, time.Minute),
+	}
+	for _, tc := range []struct {
+		query string
+		want  int
+	}{
+		{"", 1}, // 默认只保留文本文件
+		{"&textOnly=true", 1},
+		{"&textOnly=false", 3},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/repositories/1/search?q=foo"+tc.query, nil)
+		req.SetPathValue("id", "1")
+		rec := httptest.NewRecorder()
+		h.SearchContent(rec, req)
+		var results []SearchResult
+		if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil {
+			t.Fatalf("%q: decode: %v (%s)", tc.query, err, rec.Body.String())
+		}
+		if len(results) != tc.want {
+			t.Errorf("%q: got %d results, want %d: %+v", tc.query, len(results), tc.want, results)
+		}
+	}
+
+	if args := ripgrepSearchArgs("foo", SearchOptions{Text: true}); !slices.Contains(args, "--text") {
+		t.Errorf("textOnly=false should pass --text to rg: %q", args)
+	}
+	if args := ripgrepSearchArgs("foo", SearchOptions{}); slices.Contains(args, "--text") {
+		t.Errorf("rg should skip binary files by default: %q", args)
+	}
+}
+
+func TestSearchContent_CacheFollowsIndexGeneration(t *testing.T) {
+	provider := repotest.New(repo.Repository{RepoID: 1, Name: "active"}, repo.Repository{RepoID: 2, Name: "other"})
+	engine := &stubEngine{}
+	h := &Handlers{
+		Engines:      map[string]Engine{EngineZoekt: engine},
+		RepoProvider: provider,
+		Cache:        cache.New(time.Minute, time.Minute),
+	}
+	search := func(id string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/repositories/"+id+"/search?q=foo", nil)
+		req.SetPathValue("id", id)
+		rec := httptest.NewRecorder()
+		h.SearchContent(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("repo %s: status = %d (%s)", id, rec.Code, rec.Body.String())
+		}
+	}
+
+	search("1")
+	search("2")
+	search("1")
+	if engine.calls.Load() != 2 {
+		t.Fatalf("expected second search to hit the cache, engine called %d times", engine.calls.Load())
+	}
+
+	// 重新索引仓库 1: 它的旧缓存不再命中，仓库 2 不受影响
+	provider.NotifyChanged(1)
+	search("1")
+	search("2")
+	if engine.calls.Load() != 3 {
+		t.Fatalf("expected only repo 1 to be searched again, engine called %d times", engine.calls.Load())
+	}
+	search("1")
+	if engine.calls.Load() != 3 {
+		t.Fatalf("expected new generation to be cached, engine called %d times", engine.calls.Load())
+	}
+}
+
+func TestSearchContent_ResponseSizeCap(t *testing.T) {
+	// 每行约 1 KiB，3 条结果就超过 2 KiB 的上限，远早于引擎的匹配数上限
+	line := strings.Repeat("x", 1024)
+	var many []SearchResult
+	for i := 1; i <= 50; i++ {
+		many = append(many, SearchResult{Path: "big.txt", LineNum: i, LineText: line, Fragments: []SearchFragment{{Offset: 0, Length: 1}}})
+	}
+	engine := &stubEngine{results: many}
+	h := &Handlers{
+		Engines:          map[string]Engine{EngineZoekt: engine},
+		RepoProvider:     repotest.New(repo.Repository{RepoID: 1, Name: "active"}),
+		Cache:            cache.New(time.Minute, time.Minute),
+		SnippetContext:   -1,
+		MaxResponseBytes: 2048,
+	}
+	search := func(query string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/repositories/1/search?q=x"+query, nil)
+		req.SetPathValue("id", "1")
+		rec := httptest.NewRecorder()
+		h.SearchContent(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%q: status = %d (%s)", query, rec.Code, rec.Body.String())
+		}
+		return rec
+	}
+
+	for i := 0; i < 2; i++ { // 第二次来自缓存，同样带有响应头
+		rec := search("")
+		var results []SearchResult
+		if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if len(results) != 1 || results[0].LineNum != 1 {
+			t.Fatalf("expected only the first result to fit, got %d", len(results))
+		}
+		if rec.Header().Get(TruncatedHeader) != "true" {
+			t.Fatalf("missing %s header", TruncatedHeader)
+		}
+	}
+
+	var indexed IndexedSearchResponse
+	if err := json.Unmarshal(search("&includeIndex=true").Body.Bytes(), &indexed); err != nil {
+		t.Fatalf("decode indexed: %v", err)
+	}
+	if !indexed.Truncated || len(indexed.Index) != len(indexed.Results) {
+		t.Fatalf("unexpected indexed response: truncated=%v results=%d index=%d", indexed.Truncated, len(indexed.Results), len(indexed.Index))
+	}
+
+	// 不限制时返回全部结果
+	h.MaxResponseBytes = -1
+	if got, truncated := LimitResponseSize(many, h.maxResponseBytes()); len(got) != len(many) || truncated {
+		t.Fatalf("unlimited: got %d results, truncated=%v", len(got), truncated)
+	}
+	if got, truncated := LimitResponseSize(many, 1); len(got) != 1 || !truncated {
+		t.Fatalf("at least one result should be kept, got %d", len(got))
+	}
+}
+
+func TestSearchContent_CountTruncated(t *testing.T) {
+	engine := &stubEngine{counts: &CountResult{Files: []FileCount{{Path: "a.go", Count: 7}}, Total: 7, Truncated: true}}
+	h := &Handlers{
+		Engines:      map[string]Engine{EngineZoekt: engine},
+		RepoProvider: repotest.New(repo.Repository{RepoID: 1, Name: "active"}),
+		Cache:        cache.New(time.Minute, time.Minute),
+	}
+	for i := 0; i < 2; i++ { // 第二次来自缓存，同样带有响应头
+		req := httptest.NewRequest(http.MethodGet, "/api/repositories/1/search?q=x&countOnly=true", nil)
+		req.SetPathValue("id", "1")
+		rec := httptest.NewRecorder()
+		h.SearchContent(rec, req)
+		var counts CountResult
+		if err := json.Unmarshal(rec.Body.Bytes(), &counts); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if !counts.Truncated || counts.Total != 7 {
+			t.Fatalf("unexpected counts: %+v", counts)
+		}
+		if rec.Header().Get(TruncatedHeader) != "true" {
+			t.Fatalf("missing %s header", TruncatedHeader)
+		}
+	}
+}
+
+func TestSearchContent_EngineTruncated(t *testing.T) {
+	engine := &stubEngine{capped: true, results: []SearchResult{{Path: "a.go", LineNum: 1, LineText: "x"}}}
+	h := &Handlers{
+		Engines:      map[string]Engine{EngineScip: engine},
+		RepoProvider: repotest.New(repo.Repository{RepoID: 1, Name: "active"}),
+		Cache:        cache.New(time.Minute, time.Minute),
+	}
+	for i := 0; i < 2; i++ { // 第二次来自缓存，同样带有响应头
+		req := httptest.NewRequest(http.MethodGet, "/api/repositories/1/search?engine=scip&q=x", nil)
+		req.SetPathValue("id", "1")
+		rec := httptest.NewRecorder()
+		h.SearchContent(rec, req)
+		if rec.Code != http.StatusOK || rec.Header().Get(TruncatedHeader) != "true" {
+			t.Fatalf("status = %d, %s = %q", rec.Code, TruncatedHeader, rec.Header().Get(TruncatedHeader))
+		}
+	}
+}
+
+// failingEngine 所有搜索都返回错误
+type failingEngine struct{ stubEngine }
+
+func (e *failingEngine) SearchContent(ctx context.Context, r repo.Repository, query string, opts SearchOptions) ([]SearchResult, error) {
+	return nil, errors.New("backend down")
+}
+
+func TestSearchAll(t *testing.T) {
+	content := &stubEngine{results: []SearchResult{
+		{Path: "a.go", LineNum: 1, LineText: "foo"},
+		{Path: "b.go", LineNum: 2, LineText: "foo"},
+		{Path: "c.go", LineNum: 3, LineText: "foo"},
+	}}
+	symbols := &stubEngine{results: []SearchResult{{Path: "a.go", LineNum: 1, LineText: "func foo()"}}}
+	h := &Handlers{
+		Engines:      map[string]Engine{EngineZoekt: content, EngineScip: symbols},
+		RepoProvider: repotest.New(repo.Repository{RepoID: 1, Name: "active"}),
+		Cache:        cache.New(time.Minute, time.Minute),
+	}
+	search := func(query string) (int, SearchAllResponse) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/repositories/1/search-all?q=foo"+query, nil)
+		req.SetPathValue("id", "1")
+		rec := httptest.NewRecorder()
+		h.SearchAll(rec, req)
+		var resp SearchAllResponse
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+		}
+		return rec.Code, resp
+	}
+
+	code, resp := search("&limit=2")
+	if code != http.StatusOK || len(resp.Content) != 2 || !resp.Truncated["content"] || len(resp.Symbols) != 1 || resp.Truncated["symbols"] || resp.Errors != nil {
+		t.Fatalf("unexpected response %d: %+v", code, resp)
+	}
+	if resp.Files == nil {
+		t.Fatal("files should be an empty list, not null")
+	}
+
+	// 符号后端失败或不可用时，其他列表不受影响
+	h.Engines[EngineScip] = &failingEngine{}
+	if _, resp := search(""); len(resp.Content) != 3 || len(resp.Symbols) != 0 || resp.Errors["symbols"] != "backend down" || resp.Errors["content"] != "" {
+		t.Fatalf("failing symbols: %+v", resp)
+	}
+	delete(h.Engines, EngineScip)
+	if _, resp := search(""); len(resp.Content) != 3 || resp.Errors["symbols"] == "" {
+		t.Fatalf("missing scip engine: %+v", resp)
+	}
+
+	if code, _ := search("&limit=0"); code != http.StatusBadRequest {
+		t.Fatalf("limit=0: status = %d", code)
+	}
+}
+
+// recordingStubEngine 记录收到的查询和选项
+type recordingStubEngine struct {
+	stubEngine
+	query    string
+	opts     SearchOptions
+	fileOpts FileSearchOptions
+	files    []string
+}
+
+func (e *recordingStubEngine) SearchContent(ctx context.Context, r repo.Repository, query string, opts SearchOptions) ([]SearchResult, error) {
+	e.query, e.opts = query, opts
+	return e.stubEngine.SearchContent(ctx, r, query, opts)
+}
+
+func (e *recordingStubEngine) SearchFiles(ctx context.Context, r repo.Repository, query string, opts FileSearchOptions) (*FileSearchResult, error) {
+	e.fileOpts = opts
+	return NewFileSearchResult(append([]string(nil), e.files...), opts.Limit, false), nil
+}
+
+func TestSearch_IgnoreList(t *testing.T) {
+	results := []SearchResult{
+		{Path: "main.go", LineNum: 1, LineText: "foo"},
+		{Path: "vendor/lib/lib.go", LineNum: 1, LineText: "foo"},
+		{Path: "web/node_modules/x/index.js", LineNum: 1, LineText: "foo"},
+		{Path: "web/app.min.js", LineNum: 1, LineText: "foo"},
+		{Path: "vendored.go", LineNum: 1, LineText: "foo"},
+	}
+	files := []string{"main.go", "vendor/lib/lib.go", "web/app.min.js", "vendored.go"}
+	zoekt := &recordingStubEngine{stubEngine: stubEngine{results: results}, files: files}
+	rg := &recordingStubEngine{stubEngine: stubEngine{results: results}, files: files}
+	h := &Handlers{
+		Engines:      map[string]Engine{EngineZoekt: zoekt, EngineRipgrep: rg},
+		RepoProvider: repotest.New(repo.Repository{RepoID: 1, Name: "active"}),
+		Cache:        cache.New(time.Minute, time.Minute),
+		SearchIgnore: DefaultSearchIgnore,
+	}
+	do := func(handler http.HandlerFunc, target string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.SetPathValue("id", "1")
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+	paths := func(rec *httptest.ResponseRecorder) []string {
+		t.Helper()
+		var got []SearchResult
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decode: %v (%s)", err, rec.Body.String())
+		}
+		var ps []string
+		for _, r := range got {
+			ps = append(ps, r.Path)
+		}
+		return ps
+	}
+
+	// 被排除的路径不出现在结果中，排除条件也交给了引擎
+	for _, engine := range []string{EngineZoekt, EngineRipgrep} {
+		got := paths(do(h.SearchContent, "/search?q=foo&engine="+engine))
+		if !slices.Equal(got, []string{"main.go", "vendored.go"}) {
+			t.Errorf("%s: paths = %q", engine, got)
+		}
+	}
+	if !strings.Contains(zoekt.query, ` -f:"(?:^|/)vendor(?:/|$)"`) || !strings.Contains(zoekt.query, "node_modules") {
+		t.Errorf("zoekt query = %q", zoekt.query)
+	}
+	if !slices.Equal(rg.opts.Ignore, DefaultSearchIgnore) {
+		t.Errorf("rg ignore = %q", rg.opts.Ignore)
+	}
+	if args := ripgrepSearchArgs("foo", rg.opts); !slices.Contains(args, "!vendor") || !slices.Contains(args, "!*.min.js") {
+		t.Errorf("rg args = %q", args)
+	}
+
+	// includeIgnored=true 返回所有结果
+	if got := paths(do(h.SearchContent, "/search?q=foo&engine=zoekt&includeIgnored=true")); len(got) != len(results) {
+		t.Errorf("includeIgnored: paths = %q", got)
+	}
+	if strings.Contains(zoekt.query, "-f:") {
+		t.Errorf("includeIgnored: zoekt query = %q", zoekt.query)
+	}
+	if rec := do(h.SearchContent, "/search?q=foo&includeIgnored=maybe"); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid includeIgnored: status = %d", rec.Code)
+	}
+
+	// 文件名搜索
+	var fileResult FileSearchResult
+	if err := json.Unmarshal(do(h.SearchFiles, "/search-files?q=go&engine=ripgrep&plain=true").Body.Bytes(), &fileResult); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(fileResult.Files, []string{"main.go", "vendored.go"}) || !slices.Equal(rg.fileOpts.Ignore, DefaultSearchIgnore) {
+		t.Errorf("search-files = %q, ignore = %q", fileResult.Files, rg.fileOpts.Ignore)
+	}
+
+	// 仓库设置的 searchIgnore 替换服务端列表，[] 表示不排除
+	r := httptest.NewRequest(http.MethodGet, "/search?q=foo", nil)
+	if ignore, _, _ := h.searchIgnore(r, core.RepoSettings{SearchIgnore: []string{"gen"}}); !slices.Equal(ignore, []string{"gen"}) {
+		t.Errorf("repo override = %q", ignore)
+	}
+	if ignore, _, _ := h.searchIgnore(r, core.RepoSettings{SearchIgnore: []string{}}); len(ignore) != 0 {
+		t.Errorf("empty repo override = %q", ignore)
+	}
+}
+
+func TestSearchFiles_MatchRanges(t *testing.T) {
+	files := []string{"Makefile", "cmd/server/main.go", "internal/search/handler_test.go"}
+	h := &Handlers{
+		Engines: map[string]Engine{
+			EngineZoekt:   &recordingStubEngine{files: files},
+			EngineRipgrep: &recordingStubEngine{files: files},
+		},
+		RepoProvider: repotest.New(repo.Repository{RepoID: 1, Name: "active"}),
+		Cache:        cache.New(time.Minute, time.Minute),
+	}
+	search := func(target string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.SetPathValue("id", "1")
+		rec := httptest.NewRecorder()
+		h.SearchFiles(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body %q", target, rec.Code, rec.Body.String())
+		}
+		return rec
+	}
+	// matched 返回每个路径中被匹配的文本
+	matched := func(target string) map[string][]string {
+		t.Helper()
+		var got FileMatchResult
+		if err := json.Unmarshal(search(target).Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		out := make(map[string][]string)
+		for _, f := range got.Files {
+			texts := []string{}
+			for _, r := range f.MatchRanges {
+				texts = append(texts, f.Path[r.Offset:r.Offset+r.Length])
+			}
+			out[f.Path] = texts
+		}
+		return out
+	}
+
+	for _, engine := range []string{EngineZoekt, EngineRipgrep} {
+		tests := []struct {
+			query string
+			want  map[string][]string
+		}{
+			// 大小写不敏感，同一路径中的多次出现都有范围
+			{"q=MA&mode=substring", map[string][]string{"cmd/server/main.go": {"ma"}, "Makefile": {"Ma"}, "internal/search/handler_test.go": {}}},
+			{"q=er&mode=substring", map[string][]string{"cmd/server/main.go": {"er", "er"}, "internal/search/handler_test.go": {"er", "er"}, "Makefile": {}}},
+			// glob 范围覆盖文件名片段，不包含前面的 /
+			{"q=*_test.go&mode=glob", map[string][]string{"internal/search/handler_test.go": {"handler_test.go"}, "cmd/server/main.go": {}, "Makefile": {}}},
+			{"q=cmd/*/main.go&mode=glob", map[string][]string{"cmd/server/main.go": {"cmd/server/main.go"}, "internal/search/handler_test.go": {}, "Makefile": {}}},
+			{"q=/[a-z]%2B%5C.go$&mode=regex", map[string][]string{"cmd/server/main.go": {"/main.go"}, "internal/search/handler_test.go": {}, "Makefile": {}}},
+		}
+		for _, tt := range tests {
+			got := matched("/search-files?engine=" + engine + "&" + tt.query)
+			for path, want := range tt.want {
+				if !slices.Equal(got[path], want) {
+					t.Errorf("%s %s: %s matched %q, want %q", engine, tt.query, path, got[path], want)
+				}
+			}
+		}
+	}
+
+	// plain=true 返回纯路径数组
+	var plain FileSearchResult
+	if err := json.Unmarshal(search("/search-files?q=main&plain=true").Body.Bytes(), &plain); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(plain.Files, files) {
+		t.Errorf("plain files = %q", plain.Files)
+	}
+}
+
+func TestSearchContent_ChangedSince(t *testing.T) {
+	src := t.TempDir()
+	gitRepo, err := git.PlainInit(src, false)
+	if err != nil {
+		t.Fatalf("PlainInit: %v", err)
+	}
+	wt, err := gitRepo.Worktree()
+	if err != nil {
+		t.Fatalf("Worktree: %v", err)
+	}
+	commit := func(files map[string]string) {
+		t.Helper()
+		for name, content := range files {
+			if err := os.MkdirAll(filepath.Dir(filepath.Join(src, name)), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := wt.Add(name); err != nil {
+				t.Fatalf("Add %s: %v", name, err)
+			}
+		}
+		sig := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+		if _, err := wt.Commit("change", &git.CommitOptions{Author: sig}); err != nil {
+			t.Fatalf("Commit: %v", err)
+		}
+	}
+	commit(map[string]string{"main.go": "foo", "util.go": "foo", "docs/a.md": "foo"})
+	commit(map[string]string{"util.go": "foo bar", "docs/a.md": "foo bar"})
+
+	results := []SearchResult{
+		{Path: "main.go", LineNum: 1, LineText: "foo"},
+		{Path: "util.go", LineNum: 1, LineText: "foo bar"},
+		{Path: "docs/a.md", LineNum: 1, LineText: "foo bar"},
+	}
+	zoekt := &recordingStubEngine{stubEngine: stubEngine{results: results}}
+	rg := &recordingStubEngine{stubEngine: stubEngine{results: results}}
+	h := &Handlers{
+		Engines:      map[string]Engine{EngineZoekt: zoekt, EngineRipgrep: rg},
+		RepoProvider: repotest.New(repo.Repository{RepoID: 1, Name: "active", SourcePath: src}),
+		Cache:        cache.New(time.Minute, time.Minute),
+	}
+	do := func(target string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.SetPathValue("id", "1")
+		rec := httptest.NewRecorder()
+		h.SearchContent(rec, req)
+		return rec
+	}
+	paths := func(rec *httptest.ResponseRecorder) []string {
+		t.Helper()
+		var got []SearchResult
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decode: %v (%s)", err, rec.Body.String())
+		}
+		ps := []string{}
+		for _, r := range got {
+			ps = append(ps, r.Path)
+		}
+		return ps
+	}
+
+	for _, engine := range []string{EngineZoekt, EngineRipgrep} {
+		got := paths(do("/search?q=foo&changedSince=HEAD~1&engine=" + engine))
+		if slices.Sort(got); !slices.Equal(got, []string{"docs/a.md", "util.go"}) {
+			t.Errorf("%s: paths = %q", engine, got)
+		}
+	}
+	if !strings.Contains(zoekt.query, `f:"^(?:docs/a\\.md|util\\.go)$"`) {
+		t.Errorf("zoekt query = %q", zoekt.query)
+	}
+	if !slices.Equal(rg.opts.Paths, []string{"docs/a.md", "util.go"}) {
+		t.Errorf("rg paths = %q", rg.opts.Paths)
+	}
+	if args := ripgrepSearchArgs("foo", rg.opts); !slices.Equal(args[len(args)-3:], []string{"--", "docs/a.md", "util.go"}) {
+		t.Errorf("rg args = %q", args)
+	}
+
+	// rg 不对显式给出的文件应用 -g，path: 条件在传入前筛选
+	if rec := do("/search?q=foo+path:*.go&changedSince=HEAD~1&engine=ripgrep"); rec.Code != http.StatusOK {
+		t.Errorf("path filter: status = %d", rec.Code)
+	}
+	if !slices.Equal(rg.opts.Paths, []string{"util.go"}) {
+		t.Errorf("path filter: rg paths = %q", rg.opts.Paths)
+	}
+
+	// 没有变化的文件时不调用引擎，直接返回空结果
+	calls := zoekt.calls.Load()
+	if rec := do("/search?q=foo&changedSince=HEAD"); rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("empty change set: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := do("/search?q=foo&changedSince=HEAD&countOnly=true"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"total":0`) {
+		t.Errorf("empty change set (countOnly): %d %s", rec.Code, rec.Body.String())
+	}
+	if zoekt.calls.Load() != calls {
+		t.Errorf("engine called for an empty change set")
+	}
+
+	if rec := do("/search?q=foo&changedSince=no-such-branch"); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid revision: status = %d", rec.Code)
+	}
+
+	// HEAD 移动后 HEAD~1 指向新的提交，不能命中之前的缓存
+	commit(map[string]string{"main.go": "foo baz"})
+	if got := paths(do("/search?q=foo&changedSince=HEAD~1&engine=zoekt")); !slices.Equal(got, []string{"main.go"}) {
+		t.Errorf("after HEAD moved: paths = %q", got)
+	}
+
+	// 变化文件过多时不交给引擎，只在结果中过滤
+	many := make([]string, MaxChangedFiles+1)
+	for i := range many {
+		many[i] = fmt.Sprintf("f%d.go", i)
+	}
+	for _, engine := range []string{EngineZoekt, EngineRipgrep} {
+		q, opts, ok := applyChangedFiles(engine, "foo", SearchOptions{}, many)
+		if !ok || q != "foo" || opts.Paths != nil {
+			t.Errorf("%s: over MaxChangedFiles: query %q, paths %d, ok %v", engine, q, len(opts.Paths), ok)
+		}
+	}
+}
+
+func TestSearchContent_Debug(t *testing.T) {
+	var requests []zoektSearchRequest
+	zoekt := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req zoektSearchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode zoekt request: %v", err)
+		}
+		requests = append(requests, req)
+		w.Write([]byte(`{"Result": {"FileCount": 1, "MatchCount": 1, "Duration": 1500, "Files": [
+			{"FileName": "cmd/main.go", "Repository": "active", "Score": 1,
+			 "LineMatches": [{"Line": "Zm9v", "LineNumber": 3, "LineFragments": [{"LineOffset": 0, "MatchLength": 3}]}]}]}}`))
+	}))
+	defer zoekt.Close()
+
+	h := &Handlers{
+		Engines:      map[string]Engine{EngineZoekt: &ZoektEngine{ApiUrl: zoekt.URL}},
+		RepoProvider: repotest.New(repo.Repository{RepoID: 1, Name: "active"}),
+		Cache:        cache.New(time.Minute, time.Minute),
+		AdminToken:   "secret",
+	}
+	search := func(target, token string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.SetPathValue("id", "1")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.SearchContent(rec, req)
+		return rec
+	}
+
+	// 不带 debug 时响应保持原样，并写入缓存
+	rec := search("/search?q=foo+path:cmd/*", "")
+	var plain []SearchResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &plain); err != nil || len(plain) != 1 {
+		t.Fatalf("plain response: %v (%s)", err, rec.Body.String())
+	}
+
+	for _, token := range []string{"", "wrong"} {
+		if rec := search("/search?q=foo+path:cmd/*&debug=true", token); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("token %q: status = %d, want 401", token, rec.Code)
+		}
+	}
+
+	// debug 不读缓存，返回引擎实际收到的请求和 Zoekt 的统计信息
+	rec = search("/search?q=foo+path:cmd/*&debug=true", "secret")
+	if rec.Code != http.StatusOK || len(requests) != 2 {
+		t.Fatalf("status = %d, zoekt requests = %d", rec.Code, len(requests))
+	}
+	var resp struct {
+		Results []SearchResult `json:"results"`
+		Debug   struct {
+			Engine       string                     `json:"engine"`
+			Query        string                     `json:"query"`
+			NativeQuery  string                     `json:"nativeQuery"`
+			ZoektRequest zoektSearchRequest         `json:"zoektRequest"`
+			ZoektStats   map[string]json.RawMessage `json:"zoektStats"`
+		} `json:"debug"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v (%s)", err, rec.Body.String())
+	}
+	d := resp.Debug
+	if len(resp.Results) != 1 || d.Engine != EngineZoekt || d.Query != "foo path:cmd/*" {
+		t.Fatalf("response = %+v", resp)
+	}
+	if d.ZoektRequest.Q != requests[1].Q || d.NativeQuery != requests[1].Q || !strings.Contains(d.NativeQuery, "f:") {
+		t.Fatalf("native query %q, zoekt request %+v, sent %q", d.NativeQuery, d.ZoektRequest, requests[1].Q)
+	}
+	if string(d.ZoektStats["MatchCount"]) != "1" || string(d.ZoektStats["Duration"]) != "1500" {
+		t.Fatalf("zoekt stats = %v", d.ZoektStats)
+	}
+	if _, ok := d.ZoektStats["Files"]; ok {
+		t.Fatal("zoekt stats should not include the file matches")
+	}
+}
@@ -0,0 +1,106 @@
+package search
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"code-browser/internal/codetree"
+	"code-browser/internal/core"
+)
+
+// DefaultSearchIgnore -search-ignore 的默认值: 依赖目录、压缩后的前端资源和常见的生成代码
+var DefaultSearchIgnore = []string{"vendor", "node_modules", "*.min.js", "*.min.css", "*.pb.go", "*_generated.go"}
+
+// searchIgnore 返回本次搜索排除的路径模式 (规则同 .code-browser.json 的 hiddenPaths):
+// includeIgnored=true 时为空；仓库设置了 searchIgnore 时使用仓库的列表，否则使用服务端的 SearchIgnore
+func (h *Handlers) searchIgnore(r *http.Request, settings core.RepoSettings) ([]string, bool, error) {
+	if v := r.URL.Query().Get("includeIgnored"); v != "" {
+		include, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, false, fmt.Errorf("无效的 includeIgnored 参数: '%s'", v)
+		}
+		if include {
+			return nil, true, nil
+		}
+	}
+	if settings.SearchIgnore != nil {
+		return settings.SearchIgnore, false, nil
+	}
+	return h.SearchIgnore, false, nil
+}
+
+// applySearchIgnore 让引擎在搜索时直接排除 ignore 中的路径，避免被排除的文件占用结果上限:
+// Zoekt 在查询后追加 -f: 条件，ripgrep 传 -g !<glob>；其他引擎只能在结果中过滤 (见 excludedBy)
+func applySearchIgnore(engineName, nativeQuery string, opts SearchOptions, ignore []string) (string, SearchOptions) {
+	if len(ignore) == 0 {
+		return nativeQuery, opts
+	}
+	switch engineName {
+	case EngineZoekt:
+		nativeQuery += zoektIgnoreAtoms(ignore)
+	case EngineRipgrep:
+		opts.Ignore = ignore
+	}
+	return nativeQuery, opts
+}
+
+// zoektIgnoreAtoms 把排除模式转换为 Zoekt 的 -f:"<regex>" 条件 (以空格开头)
+func zoektIgnoreAtoms(ignore []string) string {
+	var sb strings.Builder
+	for _, pattern := range ignore {
+		if pattern = strings.Trim(pattern, "/"); pattern == "" {
+			continue
+		}
+		fmt.Fprintf(&sb, " -f:%q", ignoreRegex(pattern))
+	}
+	return sb.String()
+}
+
+// ignoreRegex 把排除模式转换为路径正则。与 globToRegex 不同，模式匹配目录时其下所有文件都匹配
+func ignoreRegex(pattern string) string {
+	return strings.TrimSuffix(globToRegex(pattern), "$") + "(?:/|$)"
+}
+
+// ripgrepIgnoreArgs 生成 rg 的 -g !<glob> 参数 (rg 的 glob 与 .gitignore 规则一致，匹配目录时跳过整个目录)
+func ripgrepIgnoreArgs(ignore []string) []string {
+	var args []string
+	for _, pattern := range ignore {
+		if pattern = strings.Trim(pattern, "/"); pattern != "" {
+			args = append(args, "-g", "!"+pattern)
+		}
+	}
+	return args
+}
+
+// excludedBy 组合多个排除条件，任意一个为 true 即排除
+func excludedBy(conds ...func(string) bool) func(string) bool {
+	return func(p string) bool {
+		for _, c := range conds {
+			if c(p) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ignoredBy 返回按 ignore 模式判断路径是否被排除的函数
+func ignoredBy(ignore []string) func(string) bool {
+	return func(p string) bool { return codetree.MatchAnyPattern(ignore, p) }
+}
+
+// filterIgnoredFiles 去掉被 ignore 排除的路径
+func filterIgnoredFiles(files []string, ignore []string) []string {
+	if len(ignore) == 0 {
+		return files
+	}
+	visible := make([]string, 0, len(files))
+	for _, f := range files {
+		if !codetree.MatchAnyPattern(ignore, f) {
+			visible = append(visible, f)
+		}
+	}
+	return visible
+}
@@ -0,0 +1,32 @@
+package search
+
+// MatchRef 匹配导航索引中的一项: 一个匹配片段的位置 (Offset 与 SearchResult.Fragments 一致，相对返回的 lineText)
+type MatchRef struct {
+	Path   string `json:"path"`
+	Line   int    `json:"line"`
+	Offset int    `json:"offset"`
+}
+
+// IndexedSearchResponse includeIndex=true 时内容搜索的响应: 结果及按结果顺序展开的匹配索引，
+// 客户端据此在匹配之间前后跳转，无需自行展开片段
+type IndexedSearchResponse struct {
+	Results []SearchResult `json:"results"`
+	Index   []MatchRef     `json:"index"`
+	// Truncated 结果因响应大小或引擎的结果数上限被截断 (同 TruncatedHeader)
+	Truncated bool `json:"truncated"`
+}
+
+// BuildMatchIndex 按结果顺序为每个匹配片段生成一项；没有片段的结果 (如跨行的 SCIP 范围) 生成 Offset 为 0 的一项
+func BuildMatchIndex(results []SearchResult) []MatchRef {
+	index := make([]MatchRef, 0, len(results))
+	for _, r := range results {
+		if len(r.Fragments) == 0 {
+			index = append(index, MatchRef{Path: r.Path, Line: r.LineNum})
+			continue
+		}
+		for _, f := range r.Fragments {
+			index = append(index, MatchRef{Path: r.Path, Line: r.LineNum, Offset: f.Offset})
+		}
+	}
+	return index
+}
@@ -0,0 +1,65 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultMaxRipgrepProcesses 同时运行的 rg 进程数上限的默认值
+const DefaultMaxRipgrepProcesses = 8
+
+// ripgrepQueueTimeout 等待空闲 rg 名额的最长时间，超时返回 ErrSearchBusy
+var ripgrepQueueTimeout = 5 * time.Second
+
+// ErrSearchBusy 同时运行的搜索进程已达上限且等待超时，调用方应返回 503
+var ErrSearchBusy = errors.New("搜索繁忙，请稍后重试")
+
+// ripgrepSlots 全局的 rg 进程信号量: 每个运行中的 rg 占用 channel 中的一个位置
+// 与 HTTP 层的限流无关，直接限制子进程数量，防止突发请求耗尽主机资源
+var ripgrepSlots = struct {
+	mu sync.RWMutex
+	ch chan struct{} // nil 表示不限制
+}{ch: make(chan struct{}, DefaultMaxRipgrepProcesses)}
+
+// SetMaxRipgrepProcesses 设置同时运行的 rg 进程数上限，n <= 0 表示不限制
+// 已在运行的进程仍占用旧信号量的名额，结束时归还给旧信号量
+func SetMaxRipgrepProcesses(n int) {
+	ripgrepSlots.mu.Lock()
+	defer ripgrepSlots.mu.Unlock()
+	if n <= 0 {
+		ripgrepSlots.ch = nil
+		return
+	}
+	ripgrepSlots.ch = make(chan struct{}, n)
+}
+
+// acquireRipgrep 在启动 rg 之前获取一个名额，返回的 release 必须在进程结束后调用
+// 名额已满时阻塞等待，直到有空闲名额、ctx 被取消或等待超过 ripgrepQueueTimeout
+func acquireRipgrep(ctx context.Context) (release func(), err error) {
+	ripgrepSlots.mu.RLock()
+	ch := ripgrepSlots.ch
+	ripgrepSlots.mu.RUnlock()
+	if ch == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case ch <- struct{}{}:
+		return func() { <-ch }, nil
+	default:
+	}
+
+	timer := time.NewTimer(ripgrepQueueTimeout)
+	defer timer.Stop()
+	select {
+	case ch <- struct{}{}:
+		return func() { <-ch }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("等待 rg 进程名额时请求已取消: %w", ctx.Err())
+	case <-timer.C:
+		return nil, fmt.Errorf("%w: 已有 %d 个 rg 进程在运行", ErrSearchBusy, cap(ch))
+	}
+}
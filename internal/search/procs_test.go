@@ -0,0 +1,54 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAcquireRipgrep(t *testing.T) {
+	SetMaxRipgrepProcesses(1)
+	oldTimeout := ripgrepQueueTimeout
+	ripgrepQueueTimeout = 20 * time.Millisecond
+	t.Cleanup(func() {
+		SetMaxRipgrepProcesses(DefaultMaxRipgrepProcesses)
+		ripgrepQueueTimeout = oldTimeout
+	})
+
+	release, err := acquireRipgrep(context.Background())
+	if err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+
+	// 名额已满: 等待超时返回 ErrSearchBusy
+	if _, err := acquireRipgrep(context.Background()); !errors.Is(err, ErrSearchBusy) {
+		t.Fatalf("acquire when saturated = %v, want ErrSearchBusy", err)
+	}
+	// 请求取消时立即返回上下文错误
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := acquireRipgrep(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("acquire with canceled ctx = %v, want context.Canceled", err)
+	}
+
+	// 等待中的请求在名额释放后获得名额
+	done := make(chan error, 1)
+	go func() {
+		r, err := acquireRipgrep(context.Background())
+		if err == nil {
+			r()
+		}
+		done <- err
+	}()
+	time.Sleep(5 * time.Millisecond)
+	release()
+	if err := <-done; err != nil {
+		t.Fatalf("acquire after release: %v", err)
+	}
+
+	SetMaxRipgrepProcesses(0)
+	if _, err := acquireRipgrep(context.Background()); err != nil {
+		t.Fatalf("unlimited acquire: %v", err)
+	}
+}
@@ -0,0 +1,316 @@
+package search
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"code-browser/internal/codetree"
+)
+
+// 统一查询语法: 前端只需使用以下过滤条件，由 TranslateQuery 转换为各引擎的原生查询
+//
+//	path:<glob>      按路径过滤 (规则同文件名搜索的 glob 模式)，多个 path: 之间为 OR
+//	lang:<language>  按语言过滤，多个 lang: 之间为 OR
+//	case:yes|no|auto 大小写敏感性
+//	word:<term>      整词匹配
+//
+// 其余部分 (普通词) 原样保留，因此各引擎的原生语法 (如 Zoekt 的 f:、sym:) 仍然可用。
+const (
+	queryPath = "path:"
+	queryLang = "lang:"
+	queryCase = "case:"
+	queryWord = "word:"
+)
+
+// ParsedQuery 解析后的统一查询
+type ParsedQuery struct {
+	Raw   string   // 原始查询
+	Text  string   // 去掉过滤条件后剩余的查询，保留原有的空白和引号
+	Paths []string // path: 的 glob
+	Langs []string // lang: 的语言 (小写)
+	Case  string   // case: 的值，空表示引擎默认
+	Words []string // word: 的整词
+}
+
+// ParseQuery 解析统一查询语法；过滤条件的值为空或 case: 的值无效时返回错误
+// 值可以用双引号包含空格 (path:"docs/my notes/*")；含括号的 token 不视为过滤条件，以免破坏 Zoekt 的分组语法
+func ParseQuery(q string) (ParsedQuery, error) {
+	parsed := ParsedQuery{Raw: q}
+	var rest strings.Builder
+	last := 0
+	for _, tok := range splitQueryTokens(q) {
+		prefix, value, ok := queryFilter(q[tok[0]:tok[1]])
+		if !ok {
+			continue
+		}
+		if value == "" {
+			return ParsedQuery{}, fmt.Errorf("查询条件 '%s' 缺少值", prefix)
+		}
+		switch prefix {
+		case queryPath:
+			parsed.Paths = append(parsed.Paths, value)
+		case queryLang:
+			parsed.Langs = append(parsed.Langs, strings.ToLower(value))
+		case queryCase:
+			value = strings.ToLower(value)
+			if value != "yes" && value != "no" && value != "auto" {
+				return ParsedQuery{}, fmt.Errorf("无效的 case: '%s' (可选: yes, no, auto)", value)
+			}
+			parsed.Case = value
+		case queryWord:
+			parsed.Words = append(parsed.Words, value)
+		}
+		rest.WriteString(q[last:tok[0]])
+		last = tok[1]
+		// 连同其后的一个空白一起去掉，剩余查询中其他空白保持不变 (正则中的连续空白可能有意义)
+		if last < len(q) && isQuerySpace(q[last]) {
+			last++
+		}
+	}
+	rest.WriteString(q[last:])
+	parsed.Text = strings.TrimSpace(rest.String())
+	return parsed, nil
+}
+
+// splitQueryTokens 按空白切分查询，双引号内的空白不切分；返回每个 token 的 [start, end) 位置
+func splitQueryTokens(q string) [][2]int {
+	var tokens [][2]int
+	start, inQuote := -1, false
+	for i := 0; i < len(q); i++ {
+		c := q[i]
+		switch {
+		case c == '\\' && inQuote && i+1 < len(q):
+			i++
+		case c == '"':
+			if start < 0 {
+				start = i
+			}
+			inQuote = !inQuote
+		case isQuerySpace(c) && !inQuote:
+			if start >= 0 {
+				tokens = append(tokens, [2]int{start, i})
+				start = -1
+			}
+		default:
+			if start < 0 {
+				start = i
+			}
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, [2]int{start, len(q)})
+	}
+	return tokens
+}
+
+func isQuerySpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+// queryFilter 判断 token 是否为统一语法的过滤条件，返回前缀和 (去掉引号的) 值
+func queryFilter(tok string) (prefix, value string, ok bool) {
+	for _, p := range []string{queryPath, queryLang, queryCase, queryWord} {
+		if len(tok) < len(p) || !strings.EqualFold(tok[:len(p)], p) {
+			continue
+		}
+		value = tok[len(p):]
+		if strings.HasPrefix(value, `"`) {
+			if len(value) < 2 || !strings.HasSuffix(value, `"`) {
+				return "", "", false
+			}
+			value = strings.ReplaceAll(strings.ReplaceAll(value[1:len(value)-1], `\"`, `"`), `\\`, `\`)
+		} else if strings.ContainsAny(value, "()") {
+			return "", "", false
+		}
+		return p, value, true
+	}
+	return "", "", false
+}
+
+// langAliases 常见的语言简写，统一为 Zoekt (linguist) 的语言名
+var langAliases = map[string]string{
+	"golang": "go",
+	"py":     "python",
+	"js":     "javascript",
+	"ts":     "typescript",
+	"rs":     "rust",
+	"rb":     "ruby",
+	"cpp":    "c++",
+	"cs":     "c#",
+	"csharp": "c#",
+	"sh":     "shell",
+	"bash":   "shell",
+	"md":     "markdown",
+	"yml":    "yaml",
+	"kt":     "kotlin",
+}
+
+// codetreeLanguages Zoekt 语言名与 codetree 语言名不同的部分
+var codetreeLanguages = map[string]string{"c++": "cpp", "c#": "csharp", "shell": "bash"}
+
+// langExtensions 语言对应的文件扩展名 (ripgrep 按扩展名过滤)，取自 codetree 的扩展名表；
+// 表中没有的语言把语言名本身当作扩展名
+func langExtensions(lang string) []string {
+	name := lang
+	if n, ok := codetreeLanguages[lang]; ok {
+		name = n
+	}
+	exts := codetree.Extensions(name)
+	if lang == "c++" {
+		// .h 在表中归为 C，C++ 项目同样使用
+		exts = append(exts, "h")
+	}
+	if len(exts) == 0 {
+		return []string{lang}
+	}
+	return exts
+}
+
+// canonicalLang 返回语言的规范名称
+func canonicalLang(lang string) string {
+	if canonical, ok := langAliases[lang]; ok {
+		return canonical
+	}
+	return lang
+}
+
+// TranslateQuery 把解析后的统一查询转换为引擎的原生查询和搜索选项
+//   - Zoekt: path: → f:"<regex>"，lang: → lang:，case: 原样保留，word: → /\bterm\b/
+//   - ripgrep: path: → -g，lang: → 按扩展名的 -g (或结果过滤，见 SearchOptions.Extensions)，
+//     case: → -i/-s/-S，word: → \bterm\b；rg 只有一个正则，剩余查询与整词按出现顺序以空格连接
+//
+// 其他引擎 (如 SCIP) 不支持过滤条件，原样返回原始查询。
+func TranslateQuery(parsed ParsedQuery, engineName string) (string, SearchOptions) {
+	switch engineName {
+	case EngineZoekt:
+		return zoektQuery(parsed), SearchOptions{}
+	case EngineRipgrep:
+		return ripgrepQuery(parsed)
+	}
+	return strings.TrimSpace(parsed.Raw), SearchOptions{}
+}
+
+func zoektQuery(parsed ParsedQuery) string {
+	var parts []string
+	if parsed.Case != "" {
+		parts = append(parts, queryCase+parsed.Case)
+	}
+	paths := make([]string, len(parsed.Paths))
+	for i, p := range parsed.Paths {
+		paths[i] = fmt.Sprintf("f:%q", globToRegex(p))
+	}
+	parts = appendOr(parts, paths)
+	langs := make([]string, len(parsed.Langs))
+	for i, l := range parsed.Langs {
+		langs[i] = queryLang + canonicalLang(l)
+	}
+	parts = appendOr(parts, langs)
+	for _, w := range parsed.Words {
+		parts = append(parts, "/"+strings.ReplaceAll(wordRegex(w), "/", `\/`)+"/")
+	}
+	if parsed.Text != "" {
+		parts = append(parts, parsed.Text)
+	}
+	return strings.Join(parts, " ")
+}
+
+// appendOr 把多个条件组合为 (a or b)，单个条件不加括号
+func appendOr(parts, terms []string) []string {
+	switch len(terms) {
+	case 0:
+		return parts
+	case 1:
+		return append(parts, terms[0])
+	}
+	return append(parts, "("+strings.Join(terms, " or ")+")")
+}
+
+func ripgrepQuery(parsed ParsedQuery) (string, SearchOptions) {
+	opts := SearchOptions{Case: parsed.Case, Globs: parsed.Paths}
+	for _, l := range parsed.Langs {
+		opts.Extensions = append(opts.Extensions, langExtensions(canonicalLang(l))...)
+	}
+	var parts []string
+	if parsed.Text != "" {
+		parts = append(parts, parsed.Text)
+	}
+	for _, w := range parsed.Words {
+		parts = append(parts, wordRegex(w))
+	}
+	return strings.Join(parts, " "), opts
+}
+
+// wordRegex 整词匹配 term 的正则
+func wordRegex(term string) string {
+	return `\b` + regexp.QuoteMeta(term) + `\b`
+}
+
+// ripgrepFilterArgs 根据搜索选项生成 rg 的大小写、跨行和路径过滤参数
+// 未指定 case 时保持忽略大小写 (-i)。rg 中 -g 的优先级高于文件类型，无法与扩展名过滤取交集，
+// 因此同时有 path 和 lang 时只传 path 的 -g，扩展名由 matchesExtensions 在结果中过滤
+func ripgrepFilterArgs(opts SearchOptions) []string {
+	var args []string
+	switch opts.Case {
+	case "yes":
+		args = append(args, "-s")
+	case "auto":
+		args = append(args, "-S")
+	default:
+		args = append(args, "-i")
+	}
+	if opts.Multiline {
+		args = append(args, "-U", "--multiline-dotall")
+	}
+	if opts.Text {
+		args = append(args, "--text")
+	}
+	globs := opts.Globs
+	if len(globs) == 0 {
+		for _, ext := range opts.Extensions {
+			globs = append(globs, "*."+ext)
+		}
+	}
+	for _, g := range globs {
+		args = append(args, "-g", g)
+	}
+	return append(args, ripgrepIgnoreArgs(opts.Ignore)...)
+}
+
+// matchesExtensions 判断路径是否满足 opts 中的扩展名过滤 (仅在 -g 无法表达时使用)
+func matchesExtensions(p string, opts SearchOptions) bool {
+	if len(opts.Globs) == 0 || len(opts.Extensions) == 0 {
+		return true
+	}
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(p), "."))
+	for _, e := range opts.Extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// ripgrepSearchArgs 生成 RipgrepEngine.SearchContent 的完整 rg 参数 (查询用 -e 传递，避免以 - 开头时被当作参数)
+func ripgrepSearchArgs(query string, opts SearchOptions) []string {
+	args := []string{"--json", "-m", strconv.Itoa(RipgrepMaxMatchesPerFile)}
+	args = append(args, ripgrepFilterArgs(opts)...)
+	return append(append(args, "-e", query), ripgrepPathArgs(opts)...)
+}
+
+// filterCountExtensions 对计数结果应用 matchesExtensions
+func filterCountExtensions(counts *CountResult, opts SearchOptions) *CountResult {
+	if len(opts.Globs) == 0 || len(opts.Extensions) == 0 {
+		return counts
+	}
+	filtered := &CountResult{Files: []FileCount{}, Truncated: counts.Truncated}
+	for _, f := range counts.Files {
+		if matchesExtensions(f.Path, opts) {
+			filtered.Files = append(filtered.Files, f)
+			filtered.Total += f.Count
+		}
+	}
+	return filtered
+}
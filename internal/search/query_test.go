@@ -0,0 +1,118 @@
+package search
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+
+	"code-browser/internal/repo"
+)
+
+func TestTranslateQuery(t *testing.T) {
+	m := strconv.Itoa(RipgrepMaxMatchesPerFile)
+	tests := []struct {
+		query string
+		zoekt string
+		rg    []string
+	}{
+		{
+			query: "handleRequest",
+			zoekt: "handleRequest",
+			rg:    []string{"--json", "-m", m, "-i", "-e", "handleRequest", "."},
+		},
+		{
+			query: "path:cmd/**/*.go lang:go case:yes NewServer",
+			zoekt: `case:yes f:"^cmd/(?:.*/)?[^/]*\\.go$" lang:go NewServer`,
+			rg:    []string{"--json", "-m", m, "-s", "-g", "cmd/**/*.go", "-e", "NewServer", "."},
+		},
+		{
+			query: "lang:py lang:ts word:init",
+			zoekt: `(lang:python or lang:typescript) /\binit\b/`,
+			rg:    []string{"--json", "-m", m, "-i", "-g", "*.py", "-g", "*.pyi", "-g", "*.cts", "-g", "*.mts", "-g", "*.ts", "-g", "*.tsx", "-e", `\binit\b`, "."},
+		},
+		{
+			// 扩展名取自 codetree 的表；.h 同时用于 C 和 C++
+			query: "lang:cpp Widget",
+			zoekt: "lang:c++ Widget",
+			rg:    []string{"--json", "-m", m, "-i", "-g", "*.cc", "-g", "*.cpp", "-g", "*.cxx", "-g", "*.hh", "-g", "*.hpp", "-g", "*.hxx", "-g", "*.h", "-e", "Widget", "."},
+		},
+		{
+			query: `path:*_test.go path:"docs/my notes/*" case:auto TODO`,
+			zoekt: `case:auto (f:"(?:^|/)[^/]*_test\\.go$" or f:"^docs/my notes/[^/]*$") TODO`,
+			rg:    []string{"--json", "-m", m, "-S", "-g", "*_test.go", "-g", "docs/my notes/*", "-e", "TODO", "."},
+		},
+		{
+			// 原生语法和正则中的空白保持不变；含括号的 token 不是过滤条件
+			query: "func  main (lang:go or lang:rust)",
+			zoekt: "func  main (lang:go or lang:rust)",
+			rg:    []string{"--json", "-m", m, "-i", "-e", "func  main (lang:go or lang:rust)", "."},
+		},
+		{
+			query: "a.b word:x/y lang:go",
+			zoekt: `lang:go /\bx\/y\b/ a.b`,
+			rg:    []string{"--json", "-m", m, "-i", "-g", "*.go", "-e", `a.b \bx/y\b`, "."},
+		},
+	}
+	for _, tt := range tests {
+		parsed, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Fatalf("%q: ParseQuery: %v", tt.query, err)
+		}
+		if got, _ := TranslateQuery(parsed, EngineZoekt); got != tt.zoekt {
+			t.Errorf("%q: zoekt query = %q, want %q", tt.query, got, tt.zoekt)
+		}
+		q, opts := TranslateQuery(parsed, EngineRipgrep)
+		if got := ripgrepSearchArgs(q, opts); !slices.Equal(got, tt.rg) {
+			t.Errorf("%q: rg args = %q, want %q", tt.query, got, tt.rg)
+		}
+		if got, _ := TranslateQuery(parsed, EngineScip); got != tt.query {
+			t.Errorf("%q: scip query = %q, want the raw query", tt.query, got)
+		}
+	}
+
+	for _, bad := range []string{"path: foo", "case:maybe foo", `lang:"" foo`} {
+		if _, err := ParseQuery(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+// path: 与 lang: 同时使用时，rg 只能用 -g 表达 path，扩展名在结果中过滤
+func TestRipgrepEngine_PathAndLang(t *testing.T) {
+	if _, err := exec.LookPath("rg"); err != nil {
+		t.Skip("rg not installed")
+	}
+	dir := t.TempDir()
+	for _, f := range []string{"cmd/a.go", "cmd/b.txt", "lib/c.go"} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, f)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, f), []byte("needle\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	parsed, err := ParseQuery("path:cmd/** lang:go needle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, opts := TranslateQuery(parsed, EngineRipgrep)
+	rg := &RipgrepEngine{}
+	results, err := rg.SearchContent(context.Background(), repo.Repository{SourcePath: dir}, q, opts)
+	if err != nil {
+		t.Fatalf("SearchContent: %v", err)
+	}
+	if len(results) != 1 || filepath.ToSlash(results[0].Path) != "cmd/a.go" {
+		t.Fatalf("expected only cmd/a.go, got %+v", results)
+	}
+	counts, err := rg.CountContent(context.Background(), repo.Repository{SourcePath: dir}, q, opts)
+	if err != nil {
+		t.Fatalf("CountContent: %v", err)
+	}
+	if counts.Total != 1 || len(counts.Files) != 1 || counts.Files[0].Path != "cmd/a.go" {
+		t.Fatalf("expected one count for cmd/a.go, got %+v", counts)
+	}
+}
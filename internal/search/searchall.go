@@ -0,0 +1,189 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"code-browser/internal/core"
+	"code-browser/internal/logging"
+	"code-browser/internal/repo"
+)
+
+const (
+	// DefaultSearchAllLimit search-all 每个子列表默认返回的条数
+	DefaultSearchAllLimit = 20
+	// MaxSearchAllLimit search-all 每个子列表的条数上限
+	MaxSearchAllLimit = 100
+)
+
+// SearchAllResponse 组合搜索的结果: 内容、文件名和符号 (SCIP) 三个子列表，各自独立降级。
+// 某个子搜索失败或后端不可用时对应列表为空，原因写入 Errors (键为 content/files/symbols)
+type SearchAllResponse struct {
+	Content   []SearchResult    `json:"content"`
+	Files     []string          `json:"files"`
+	Symbols   []SearchResult    `json:"symbols"`
+	Truncated map[string]bool   `json:"truncated"`
+	Errors    map[string]string `json:"errors,omitempty"`
+}
+
+// SearchAll 处理 GET /api/repositories/{id}/search-all?q=
+// 内容搜索和文件名搜索使用 engine 参数 (或默认引擎)，符号搜索使用 SCIP 引擎，三者并发执行。
+// 内容搜索支持统一查询语法；文件名和符号搜索只使用去掉过滤条件后的查询文本。
+// 子搜索不会因其他子搜索失败而取消；查询本身无效 (过长、统一语法错误) 时整体返回 400
+func (h *Handlers) SearchAll(w http.ResponseWriter, r *http.Request) {
+	repoID, err := parseRepoIDHelper(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		http.Error(w, "Query parameter 'q' is required", http.StatusBadRequest)
+		return
+	}
+	if err := ValidateQuery(query, h.MaxQueryLength); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	parsed, err := ParseQuery(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	engineName, engine, err := h.resolveEngine(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit := DefaultSearchAllLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			http.Error(w, fmt.Sprintf("无效的 limit: '%s'", v), http.StatusBadRequest)
+			return
+		}
+		limit = min(limit, MaxSearchAllLimit)
+	}
+
+	repoInfo, ok := h.searchableRepo(w, r, repoID)
+	if !ok {
+		return
+	}
+	settings := h.repoSettings(repoID)
+	ignore, _, err := h.searchIgnore(r, settings)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := SearchAllResponse{
+		Content:   []SearchResult{},
+		Files:     []string{},
+		Symbols:   []SearchResult{},
+		Truncated: map[string]bool{"content": false, "files": false, "symbols": false},
+	}
+	var mu sync.Mutex
+	fail := func(part string, err error) {
+		logging.FromContext(r.Context()).Warn("组合搜索的子搜索失败", "part", part, "repo", repoID, "err", err)
+		mu.Lock()
+		defer mu.Unlock()
+		if resp.Errors == nil {
+			resp.Errors = make(map[string]string)
+		}
+		resp.Errors[part] = err.Error()
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		results, truncated, err := h.searchAllContent(r.Context(), repoInfo, engineName, engine, parsed, settings, ignore, limit)
+		if err != nil {
+			fail("content", err)
+			return
+		}
+		mu.Lock()
+		resp.Content, resp.Truncated["content"] = results, truncated
+		mu.Unlock()
+	}()
+	go func() {
+		defer wg.Done()
+		files, err := engine.SearchFiles(r.Context(), repoInfo, parsed.Text, FileSearchOptions{Mode: FileModeSubstring, Limit: limit, Ignore: ignore})
+		if err != nil {
+			fail("files", err)
+			return
+		}
+		if files == nil {
+			return
+		}
+		visible := filterIgnoredFiles(settings.FilterHidden(files.Files), ignore)
+		mu.Lock()
+		resp.Files, resp.Truncated["files"] = visible, files.Truncated
+		mu.Unlock()
+	}()
+	go func() {
+		defer wg.Done()
+		scip, ok := h.Engines[EngineScip]
+		if !ok {
+			fail("symbols", fmt.Errorf("%s 引擎未启用", EngineScip))
+			return
+		}
+		engineTruncated := false
+		symbols, err := scip.SearchContent(r.Context(), repoInfo, parsed.Text, SearchOptions{Truncated: &engineTruncated})
+		if err != nil {
+			fail("symbols", err)
+			return
+		}
+		symbols, truncated := capResults(filterResults(symbols, excludedBy(settings.IsHidden, ignoredBy(ignore))), limit)
+		mu.Lock()
+		resp.Symbols, resp.Truncated["symbols"] = symbols, truncated || engineTruncated
+		mu.Unlock()
+	}()
+	wg.Wait()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).Error("序列化组合搜索结果失败", "err", err)
+	}
+}
+
+// searchAllContent 执行 search-all 的内容搜索，过滤、排序和截断规则与 SearchContent 的默认选项相同
+func (h *Handlers) searchAllContent(ctx context.Context, repoInfo repo.Repository, engineName string, engine Engine, parsed ParsedQuery, settings core.RepoSettings, ignore []string, limit int) ([]SearchResult, bool, error) {
+	nativeQuery, opts := TranslateQuery(parsed, engineName)
+	if nativeQuery == "" {
+		return nil, false, fmt.Errorf("查询中没有搜索词")
+	}
+	if engineName == EngineRipgrep {
+		if err := ValidateRegex(nativeQuery); err != nil {
+			return nil, false, err
+		}
+	}
+	sortOrder, _ := ParseSortOrder("", engineName)
+	nativeQuery, opts = applySearchIgnore(engineName, nativeQuery, opts, ignore)
+	engineTruncated := false
+	opts.Truncated = &engineTruncated
+
+	results, err := engine.SearchContent(ctx, repoInfo, nativeQuery, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	results = filterResults(results, excludedBy(settings.IsHidden, ignoredBy(ignore), core.IsBinaryPath))
+	SortResults(results, sortOrder)
+	results, truncated := capResults(results, limit)
+	TrimLongLines(results, h.snippetContext())
+	return results, truncated || engineTruncated, nil
+}
+
+// capResults 最多保留 limit 条结果，nil 视为空列表
+func capResults(results []SearchResult, limit int) ([]SearchResult, bool) {
+	if results == nil {
+		return []SearchResult{}, false
+	}
+	if len(results) > limit {
+		return results[:limit], true
+	}
+	return results, false
+}
@@ -0,0 +1,35 @@
+package search
+
+// DefaultMaxResponseBytes 内容搜索响应的默认大小上限 (按 JSON 编码后的字节数估算)
+const DefaultMaxResponseBytes = 8 << 20
+
+// TruncatedHeader 内容搜索结果因响应大小上限被截断时设置的响应头 (值为 "true")
+const TruncatedHeader = "X-Search-Truncated"
+
+// resultOverhead 单条结果中字段名、数字和标点的大致字节数
+const resultOverhead = 96
+
+// fragmentOverhead 单个匹配片段编码后的大致字节数
+const fragmentOverhead = 32
+
+// estimateResultSize 估算一条结果 JSON 编码后的字节数；不计转义，对源码文本通常偏差不大
+func estimateResultSize(r SearchResult) int {
+	return resultOverhead + len(r.Path) + len(r.LineText) + len(r.MatchText) + fragmentOverhead*len(r.Fragments)
+}
+
+// LimitResponseSize 按顺序累计结果的估算大小，超过 maxBytes 时丢弃当前及之后的结果并返回 truncated=true。
+// 至少保留一条结果，避免单条超长结果导致响应为空；maxBytes <= 0 时不限制。
+// 在排序和 TrimLongLines 之后调用，使保留的是排在前面的结果，且按截断后的行估算。
+func LimitResponseSize(results []SearchResult, maxBytes int) ([]SearchResult, bool) {
+	if maxBytes <= 0 {
+		return results, false
+	}
+	total := 0
+	for i, r := range results {
+		total += estimateResultSize(r)
+		if total > maxBytes && i > 0 {
+			return results[:i], true
+		}
+	}
+	return results, false
+}
@@ -0,0 +1,60 @@
+package search
+
+import "unicode/utf8"
+
+// DefaultSnippetContext 截断超长行时，首个匹配片段两侧各保留的字节数
+const DefaultSnippetContext = 200
+
+// TrimLongLines 把超长的 LineText 截断为首个匹配片段两侧各 context 字节的窗口，
+// 并把片段偏移调整为相对窗口的位置；窗口外的片段被丢弃，跨越窗口边界的片段被裁剪。
+// 截断过的结果 TruncatedLine 为 true。窗口边界对齐到 UTF-8 字符边界。
+// 两个引擎的偏移都按字节计算，context <= 0 时不做截断。
+func TrimLongLines(results []SearchResult, context int) {
+	if context <= 0 {
+		return
+	}
+	for i := range results {
+		trimLine(&results[i], context)
+	}
+}
+
+// trimLine 截断单条结果，行长度不超过窗口时保持不变
+func trimLine(r *SearchResult, context int) {
+	line := r.LineText
+	anchorStart, anchorEnd := 0, 0
+	if len(r.Fragments) > 0 {
+		first := r.Fragments[0]
+		for _, f := range r.Fragments[1:] {
+			if f.Offset < first.Offset {
+				first = f
+			}
+		}
+		anchorStart, anchorEnd = first.Offset, first.Offset+first.Length
+	}
+
+	// 片段偏移可能越出行 (如引擎给出的偏移与 LineText 不一致)，窗口限制在 [0, len(line)] 内
+	start := min(max(0, anchorStart-context), len(line))
+	end := max(min(len(line), anchorEnd+context), start)
+	if start == 0 && end == len(line) {
+		return
+	}
+	// 窗口不能从 UTF-8 多字节字符的中间开始或结束
+	for start > 0 && start < len(line) && !utf8.RuneStart(line[start]) {
+		start--
+	}
+	for end < len(line) && !utf8.RuneStart(line[end]) {
+		end++
+	}
+
+	fragments := make([]SearchFragment, 0, len(r.Fragments))
+	for _, f := range r.Fragments {
+		fs, fe := max(f.Offset, start), min(f.Offset+f.Length, end)
+		if fs > fe || (fs == fe && f.Length > 0) {
+			continue
+		}
+		fragments = append(fragments, SearchFragment{Offset: fs - start, Length: fe - fs})
+	}
+	r.LineText = line[start:end]
+	r.Fragments = fragments
+	r.TruncatedLine = true
+}
@@ -0,0 +1,99 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestTrimLongLines_LongLineMatchInMiddle(t *testing.T) {
+	line := strings.Repeat("a", 5000) + "needle" + strings.Repeat("b", 4994)
+	results := []SearchResult{{
+		Path:      "app.min.js",
+		LineNum:   1,
+		LineText:  line,
+		Fragments: []SearchFragment{{Offset: 5000, Length: 6}, {Offset: 9990, Length: 5}},
+	}}
+
+	TrimLongLines(results, 200)
+
+	r := results[0]
+	if !r.TruncatedLine {
+		t.Fatalf("expected TruncatedLine")
+	}
+	if len(r.LineText) != 406 {
+		t.Fatalf("len(LineText) = %d, want 406", len(r.LineText))
+	}
+	// 窗口外的第二个片段被丢弃
+	if len(r.Fragments) != 1 || r.Fragments[0].Offset != 200 || r.Fragments[0].Length != 6 {
+		t.Fatalf("Fragments = %+v", r.Fragments)
+	}
+	f := r.Fragments[0]
+	if got := r.LineText[f.Offset : f.Offset+f.Length]; got != "needle" {
+		t.Fatalf("fragment text = %q", got)
+	}
+}
+
+func TestTrimLongLines_ShortAndMultibyte(t *testing.T) {
+	short := SearchResult{LineText: "func main() {}", Fragments: []SearchFragment{{Offset: 5, Length: 4}}}
+	// 窗口起点落在 3 字节的 "中" 内部时应向前对齐到字符边界
+	long := SearchResult{LineText: strings.Repeat("中", 100) + "x", Fragments: []SearchFragment{{Offset: 300, Length: 1}}}
+	results := []SearchResult{short, long}
+
+	TrimLongLines(results, 10)
+
+	if results[0].TruncatedLine || results[0].LineText != short.LineText {
+		t.Fatalf("short line should be unchanged: %+v", results[0])
+	}
+	r := results[1]
+	if !r.TruncatedLine || r.LineText != strings.Repeat("中", 4)+"x" {
+		t.Fatalf("LineText = %q", r.LineText)
+	}
+	if f := r.Fragments[0]; r.LineText[f.Offset:f.Offset+f.Length] != "x" {
+		t.Fatalf("Fragments = %+v", r.Fragments)
+	}
+}
+
+func TestTrimLongLines_RipgrepIndentedLines(t *testing.T) {
+	// rg 的偏移基于未去掉前导空白的原始行，parseRgMatch 需要换算到去掉空白后的 LineText
+	rgMatch := func(text string, start, end int) rgMatchData {
+		line := fmt.Sprintf(`{"type":"match","data":{"path":{"text":"a.go"},"lines":{"text":%q},"line_number":1,"submatches":[{"match":{"text":""},"start":%d,"end":%d}]}}`, text, start, end)
+		var msg rgMessage
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		return msg.Data
+	}
+	long := rgMatch("\t\t"+strings.Repeat("x", 1000)+"needle"+strings.Repeat("y", 1000)+"\n", 1002, 1008)
+	short := rgMatch(strings.Repeat(" ", 400)+"foo()\n", 400, 403)
+
+	results := []SearchResult{parseRgMatch(long), parseRgMatch(short)}
+	TrimLongLines(results, DefaultSnippetContext)
+
+	for i, want := range []string{"needle", "foo"} {
+		r := results[i]
+		if len(r.Fragments) != 1 {
+			t.Fatalf("result %d: Fragments = %+v", i, r.Fragments)
+		}
+		f := r.Fragments[0]
+		if got := r.LineText[f.Offset : f.Offset+f.Length]; got != want {
+			t.Fatalf("result %d: fragment text = %q, want %q", i, got, want)
+		}
+	}
+	if !results[0].TruncatedLine || len(results[0].LineText) != 406 {
+		t.Fatalf("long line: TruncatedLine=%v len=%d", results[0].TruncatedLine, len(results[0].LineText))
+	}
+	if results[1].TruncatedLine || results[1].LineText != "foo()" {
+		t.Fatalf("short line should be unchanged: %+v", results[1])
+	}
+}
+
+func TestTrimLongLines_OffsetPastLineEnd(t *testing.T) {
+	// 偏移越出 LineText 时不应 panic
+	results := []SearchResult{{LineText: "foo()", Fragments: []SearchFragment{{Offset: 400, Length: 3}}}}
+	TrimLongLines(results, DefaultSnippetContext)
+	if len(results[0].LineText) > len("foo()") {
+		t.Fatalf("LineText = %q", results[0].LineText)
+	}
+}
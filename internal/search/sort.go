@@ -0,0 +1,51 @@
+package search
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SortOrder 内容搜索结果的排序方式
+type SortOrder string
+
+const (
+	SortRelevance SortOrder = "relevance" // 按 Score 降序；没有分数的引擎保持引擎返回的顺序 (Zoekt、SCIP 本身按相关度返回)
+	SortPath      SortOrder = "path"      // 按路径、行号升序
+	SortLine      SortOrder = "line"      // 按行号、路径升序
+)
+
+// ParseSortOrder 解析 sort 参数；为空时 ripgrep 默认按路径 (rg 没有排名且输出顺序不稳定)，其他引擎默认按相关度
+func ParseSortOrder(s, engineName string) (SortOrder, error) {
+	switch SortOrder(s) {
+	case "":
+		if engineName == EngineRipgrep {
+			return SortPath, nil
+		}
+		return SortRelevance, nil
+	case SortRelevance, SortPath, SortLine:
+		return SortOrder(s), nil
+	}
+	return "", fmt.Errorf("无效的排序方式: '%s' (可选: relevance, path, line)", s)
+}
+
+// SortResults 按 order 对搜索结果做稳定排序，分数相同的结果保持引擎返回的相对顺序
+func SortResults(results []SearchResult, order SortOrder) {
+	switch order {
+	case SortRelevance:
+		sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	case SortPath:
+		sort.SliceStable(results, func(i, j int) bool {
+			if results[i].Path != results[j].Path {
+				return results[i].Path < results[j].Path
+			}
+			return results[i].LineNum < results[j].LineNum
+		})
+	case SortLine:
+		sort.SliceStable(results, func(i, j int) bool {
+			if results[i].LineNum != results[j].LineNum {
+				return results[i].LineNum < results[j].LineNum
+			}
+			return results[i].Path < results[j].Path
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package search
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSortResults(t *testing.T) {
+	// Zoekt 的返回顺序: 文件按分数降序，文件内按行号
+	engineOrder := []SearchResult{
+		{Path: "b.go", LineNum: 3, Score: 9},
+		{Path: "b.go", LineNum: 7, Score: 9},
+		{Path: "a.go", LineNum: 1, Score: 5},
+		{Path: "c.go", LineNum: 2, Score: 5},
+	}
+	tests := []struct {
+		order SortOrder
+		want  []string
+	}{
+		{SortRelevance, []string{"b.go:3", "b.go:7", "a.go:1", "c.go:2"}},
+		{SortPath, []string{"a.go:1", "b.go:3", "b.go:7", "c.go:2"}},
+		{SortLine, []string{"a.go:1", "c.go:2", "b.go:3", "b.go:7"}},
+	}
+	for _, tt := range tests {
+		results := append([]SearchResult(nil), engineOrder...)
+		SortResults(results, tt.order)
+		for i, r := range results {
+			if got := fmt.Sprintf("%s:%d", r.Path, r.LineNum); got != tt.want[i] {
+				t.Errorf("%s: result %d = %s, want %s", tt.order, i, got, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestParseSortOrder(t *testing.T) {
+	if o, _ := ParseSortOrder("", EngineZoekt); o != SortRelevance {
+		t.Errorf("zoekt default = %s", o)
+	}
+	if o, _ := ParseSortOrder("", EngineRipgrep); o != SortPath {
+		t.Errorf("ripgrep default = %s", o)
+	}
+	if o, _ := ParseSortOrder("line", EngineRipgrep); o != SortLine {
+		t.Errorf("explicit sort = %s", o)
+	}
+	if _, err := ParseSortOrder("score", EngineZoekt); err == nil {
+		t.Error("expected error for unknown sort order")
+	}
+}
@@ -0,0 +1,72 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"code-browser/internal/logging"
+)
+
+// SyntaxItem 描述引擎支持的一种查询语法 (操作符、修饰符等)，供前端渲染内联帮助
+type SyntaxItem struct {
+	Syntax      string `json:"syntax"`            // 语法形式，如 "f:<regex>"
+	Description string `json:"description"`       // 说明
+	Example     string `json:"example,omitempty"` // 可直接填入搜索框的示例查询
+}
+
+// SyntaxHelp 实现 Engine 接口；查询原样发送给 Zoekt，支持其完整的查询语言
+func (z *ZoektEngine) SyntaxHelp() []SyntaxItem {
+	return []SyntaxItem{
+		{Syntax: "word", Description: "子字符串匹配；多个词之间为 AND 关系", Example: "http handler"},
+		{Syntax: "\"phrase\"", Description: "精确短语 (包含空格)", Example: "\"func main\""},
+		{Syntax: "/regex/", Description: "正则表达式 (RE2 语法)", Example: "/func \\w+Handler/"},
+		{Syntax: "a or b", Description: "匹配任意一个条件", Example: "TODO or FIXME"},
+		{Syntax: "-term", Description: "排除匹配的结果，可与其他操作符组合", Example: "Config -f:_test\\.go$"},
+		{Syntax: "( )", Description: "分组", Example: "(TODO or FIXME) lang:go"},
+		{Syntax: "f:<regex>", Description: "按文件路径过滤 (别名 file:)", Example: "f:\\.go$ ctx"},
+		{Syntax: "path:<glob>", Description: "按路径 glob 过滤，转换为 f: 正则；多个 path: 之间为 OR", Example: "path:cmd/** NewServer"},
+		{Syntax: "word:<term>", Description: "整词匹配，转换为 /\\bterm\\b/", Example: "word:ctx"},
+		{Syntax: "lang:<language>", Description: "按语言过滤", Example: "lang:python import"},
+		{Syntax: "sym:<name>", Description: "只匹配符号定义 (函数、类型等)", Example: "sym:NewService"},
+		{Syntax: "case:yes|no|auto", Description: "大小写敏感性；默认 auto (查询包含大写字母时区分大小写)", Example: "case:yes Error"},
+		{Syntax: "content:<term>", Description: "只匹配文件内容，不匹配文件名", Example: "content:main"},
+	}
+}
+
+// SyntaxHelp 实现 Engine 接口；查询作为 rg 正则执行 (Rust regex 语法，忽略大小写)
+func (rg *RipgrepEngine) SyntaxHelp() []SyntaxItem {
+	return []SyntaxItem{
+		{Syntax: "regex", Description: "去掉下列过滤条件后，剩余查询是一个正则表达式 (Rust regex 语法)，默认忽略大小写", Example: "func \\w+Handler"},
+		{Syntax: "path:<glob>", Description: "按路径过滤 (* 不跨目录，** 跨目录)；多个 path: 之间为 OR", Example: "path:cmd/** NewServer"},
+		{Syntax: "lang:<language>", Description: "按语言 (文件扩展名) 过滤", Example: "lang:go ctx"},
+		{Syntax: "case:yes|no|auto", Description: "大小写敏感性；默认 no", Example: "case:yes Error"},
+		{Syntax: "word:<term>", Description: "整词匹配", Example: "word:ctx"},
+		{Syntax: "\\.", Description: "正则元字符 . ( ) [ ] { } * + ? | ^ $ \\ 需要用反斜杠转义才能按字面匹配", Example: "fmt\\.Errorf\\("},
+		{Syntax: "a|b", Description: "匹配任意一个分支", Example: "TODO|FIXME"},
+		{Syntax: "\\b", Description: "单词边界", Example: "\\bctx\\b"},
+		{Syntax: "[^...]", Description: "字符类及其取反", Example: "err [!=]= nil"},
+		{Syntax: "multiline=true", Description: "请求参数 (不是查询语法)：允许匹配跨行，. 也匹配换行符", Example: "func main\\(\\) \\{\\s+fmt"},
+	}
+}
+
+// EngineSyntax 处理 GET /api/engines/{name}/syntax，返回指定引擎支持的查询语法
+func (h *Handlers) EngineSyntax(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	engine, ok := h.Engines[name]
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown search engine: %s. Available: %v", name, getMapKeys(h.Engines)), http.StatusNotFound)
+		return
+	}
+	resp := struct {
+		Engine string       `json:"engine"`
+		Items  []SyntaxItem `json:"items"`
+	}{Engine: engine.Name(), Items: engine.SyntaxHelp()}
+	if resp.Items == nil {
+		resp.Items = []SyntaxItem{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).Error("序列化查询语法失败", "err", err)
+	}
+}
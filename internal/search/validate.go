@@ -0,0 +1,73 @@
+package search
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"unicode/utf8"
+)
+
+// DefaultMaxQueryLength 未配置时允许的最大查询长度 (按字符计)
+const DefaultMaxQueryLength = 512
+
+// maxRegexRepeat 正则中单个 {n,m} 重复次数的上限，过大的重复会显著放大编译后的程序
+const maxRegexRepeat = 100
+
+// ValidateQuery 检查查询长度是否超出上限，maxLen <= 0 时使用 DefaultMaxQueryLength
+func ValidateQuery(query string, maxLen int) error {
+	if maxLen <= 0 {
+		maxLen = DefaultMaxQueryLength
+	}
+	if n := utf8.RuneCountInString(query); n > maxLen {
+		return fmt.Errorf("查询过长: %d 个字符 (上限 %d)", n, maxLen)
+	}
+	return nil
+}
+
+// ValidateRegex 拒绝可能导致 ReDoS 或编译膨胀的正则表达式。
+// ripgrep 会把查询当作正则执行，因此在启动子进程之前先做一次语法检查:
+//   - 嵌套的无界量词, 例如 (a+)+、(a*)*、(\w+\s?)*
+//   - 过大的重复次数, 例如 a{1000}
+func ValidateRegex(pattern string) error {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return fmt.Errorf("无效的正则表达式: %w", err)
+	}
+	return checkRegexNode(re, false)
+}
+
+// checkRegexNode 递归检查语法树，inRepeat 表示当前节点位于某个量词之内
+func checkRegexNode(re *syntax.Regexp, inRepeat bool) error {
+	switch re.Op {
+	case syntax.OpStar, syntax.OpPlus, syntax.OpRepeat:
+		if re.Op == syntax.OpRepeat && (re.Max > maxRegexRepeat || re.Min > maxRegexRepeat) {
+			return fmt.Errorf("正则重复次数过大 (上限 %d): %s", maxRegexRepeat, re.String())
+		}
+		if inRepeat && isUnbounded(re) {
+			return fmt.Errorf("正则包含嵌套量词，可能导致回溯爆炸: %s", re.String())
+		}
+		for _, sub := range re.Sub {
+			// 量词内部再出现无界量词即视为危险
+			if err := checkRegexNode(sub, inRepeat || isUnbounded(re)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, sub := range re.Sub {
+		if err := checkRegexNode(sub, inRepeat); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isUnbounded 判断量词是否没有上限 (*, +, {n,})
+func isUnbounded(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpStar, syntax.OpPlus:
+		return true
+	case syntax.OpRepeat:
+		return re.Max == -1
+	}
+	return false
+}
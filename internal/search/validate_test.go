@@ -0,0 +1,56 @@
+package search
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestValidateQuery_RejectsOverlongQuery(t *testing.T) {
+	if err := ValidateQuery(strings.Repeat("a", 10), 10); err != nil {
+		t.Fatalf("query at the limit should pass: %v", err)
+	}
+	if err := ValidateQuery(strings.Repeat("a", 11), 10); err == nil {
+		t.Fatalf("expected error for overlong query")
+	}
+	// 按字符而非字节计数
+	if err := ValidateQuery(strings.Repeat("搜", 10), 10); err != nil {
+		t.Fatalf("multi-byte query within limit should pass: %v", err)
+	}
+	if err := ValidateQuery(strings.Repeat("a", DefaultMaxQueryLength+1), 0); err == nil {
+		t.Fatalf("expected default limit to apply when maxLen is 0")
+	}
+}
+
+func TestValidateRegex_RejectsReDoSPatterns(t *testing.T) {
+	for _, p := range []string{`(a+)+$`, `(a*)*b`, `(\w+\s?)*$`, `(x+x+)+y`, `a{500}`, `(ab`} {
+		if err := ValidateRegex(p); err == nil {
+			t.Errorf("expected %q to be rejected", p)
+		}
+	}
+	for _, p := range []string{`func\s+main`, `\bProvider\b`, `foo.*bar`, `(ab)+`, `(a?b)+`, `x{2,5}`, `(a{1,3}b)+`} {
+		if err := ValidateRegex(p); err != nil {
+			t.Errorf("expected %q to be accepted: %v", p, err)
+		}
+	}
+}
+
+func TestSearchContent_RejectsInvalidQueriesBeforeSearching(t *testing.T) {
+	h := &Handlers{MaxQueryLength: 16}
+
+	cases := []struct{ query, engine string }{
+		{strings.Repeat("x", 17), "zoekt"},
+		{`(a+)+$`, "ripgrep"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/api/repositories/1/search?engine="+c.engine+"&q="+url.QueryEscape(c.query), nil)
+		req.SetPathValue("id", "1")
+		rec := httptest.NewRecorder()
+		h.SearchContent(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("query %q (%s): expected 400, got %d", c.query, c.engine, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+// Package web 内嵌前端静态文件，使服务端二进制可以脱离源码目录单独部署
+package web
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// 新增静态资源 (如 css/js 目录) 时需要同步更新这里的模式
+//
+//go:embed *.html
+var files embed.FS
+
+// FS 返回内嵌的前端静态文件
+func FS() fs.FS {
+	return files
+}